@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"mfus_WalletTransactionManager/services"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// One-off data migrations. Run with: go run ./cmd/migrate -name <migration>
+
+func main() {
+	uri := flag.String("mongo", "mongodb://localhost:27017", "MongoDB connection URI")
+	name := flag.String("name", "", "Migration to run: money, transactions")
+	flag.Parse()
+
+	// Connect to MongoDB
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(*uri))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+
+	switch *name {
+	case "money":
+		modified, err := services.MigrateMoneyFields(client)
+		if err != nil {
+			log.Fatalf("Money migration failed after %d documents: %v", modified, err)
+		}
+		log.Printf("Money migration rewrote %d documents", modified)
+	case "transactions":
+		if err := services.EnsureTransactionIndexes(client); err != nil {
+			log.Fatalf("Failed to create transaction indexes: %v", err)
+		}
+		moved, err := services.MigrateEmbeddedTransactions(client)
+		if err != nil {
+			log.Fatalf("Transaction migration failed after %d entries: %v", moved, err)
+		}
+		log.Printf("Transaction migration moved %d entries into the ledger", moved)
+	default:
+		log.Fatalf("Unknown migration %q", *name)
+	}
+}
@@ -16,19 +16,15 @@ import (
 
 func CreateAccountHandler(client *mongo.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse request body
-		var request models.Account
-		err := json.NewDecoder(r.Body).Decode(&request)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request body"})
+		// Parse and validate request body
+		var request models.CreateAccountRequest
+		if !decodeRequest(w, r, &request) {
 			return
 		}
 		//Check if duplicate request is received
 		account, _ := services.GetAccountByEmail(client, request.Email)
 		if account != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Account already exist"})
+			writeError(w, r, http.StatusBadRequest, "Account already exist")
 			return
 		}
 
@@ -37,18 +33,16 @@ func CreateAccountHandler(client *mongo.Client) http.HandlerFunc {
 			Email:          request.Email,
 			Type:           request.Type,
 			Balance:        request.Balance,
-			HoldBalance:    0,
-			Transactions:   []models.Transaction{},
+			HoldBalance:    models.NewMoney(0, request.Balance.CurrencyCode()),
 			CreatedAt:      time.Now(),
 			DateModified:   time.Time{},
 			VirtualWallets: []string{},
 		}
 
 		// Insert new account document into database
-		result, err := client.Database("walletManager").Collection("accounts").InsertOne(context.Background(), newAccount)
+		accountID, err := services.CreateAccount(client, newAccount)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to create account"})
+			writeError(w, r, http.StatusInternalServerError, "Failed to create account")
 			return
 		}
 
@@ -56,7 +50,7 @@ func CreateAccountHandler(client *mongo.Client) http.HandlerFunc {
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(models.SuccessResponse{
 			Message: "Account created successfully",
-			Data:    result.InsertedID,
+			Data:    accountID,
 		})
 	}
 }
@@ -67,16 +61,14 @@ func GetAccountHandler(client *mongo.Client) http.HandlerFunc {
 		vars := mux.Vars(r)
 		accountID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid account ID"})
+			writeError(w, r, http.StatusBadRequest, "Invalid account ID")
 			return
 		}
 		// Find account document in database
 		var account models.Account
 		err = client.Database("walletManager").Collection("accounts").FindOne(context.Background(), bson.M{"_id": accountID}).Decode(&account)
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Account not found"})
+			writeError(w, r, http.StatusNotFound, "Account not found")
 			return
 		}
 
@@ -89,83 +81,305 @@ func GetAccountHandler(client *mongo.Client) http.HandlerFunc {
 	}
 }
 
-// Handler for creating a new virtual wallet transaction
-func CreateTransactionHandler(client *mongo.Client) http.HandlerFunc {
+// Handler for listing the virtual wallets of an account together with their balances
+func GetAccountWalletsHandler(client *mongo.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse virtual wallet ID from URL path parameter
+		// Parse account ID from URL parameter
 		vars := mux.Vars(r)
-		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
+		accountID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid account ID")
+			return
+		}
+
+		// Make sure the account exists
+		_, err = services.FindAccount(client, accountID)
+		if err != nil {
+			if err == services.ErrAccountNotFound {
+				writeError(w, r, http.StatusNotFound, "Account not found")
+			} else {
+				writeError(w, r, http.StatusInternalServerError, "Failed to retrieve account")
+			}
+			return
+		}
+
+		// Wallets carry the owning account ID as their customer ID
+		virtualWallets, err := FindAllVirtualWallets(client, accountID.Hex())
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve virtual wallets")
+			return
+		}
+		if virtualWallets == nil {
+			virtualWallets = []models.VirtualWallet{}
+		}
+
+		// Return success response with the account wallets
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Account wallets retrieved successfully",
+			Data:    virtualWallets,
+		})
+	}
+}
+
+// Handler for retrieving the effective transaction limits of an account and its per-account overrides
+func GetAccountLimitsHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse account ID from URL parameter
+		vars := mux.Vars(r)
+		accountID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid account ID")
+			return
+		}
+
+		account, err := services.FindAccount(client, accountID)
+		if err != nil {
+			if err == services.ErrAccountNotFound {
+				writeError(w, r, http.StatusNotFound, "Account not found")
+			} else {
+				writeError(w, r, http.StatusInternalServerError, "Failed to retrieve account")
+			}
+			return
+		}
+		limits, err := services.EffectiveLimits(client, account)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve account limits")
+			return
+		}
+
+		// Return success response with the account limits
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Account limits retrieved successfully",
+			Data: map[string]interface{}{
+				"effective": limits,
+				"overrides": account.LimitOverrides,
+			},
+		})
+	}
+}
+
+// Handler for replacing the per-account limit overrides
+func UpdateAccountLimitsHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse account ID from URL parameter
+		vars := mux.Vars(r)
+		accountID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid account ID")
+			return
+		}
+		// Decode and validate request body
+		var overrides models.TransactionLimits
+		if !decodeRequest(w, r, &overrides) {
+			return
+		}
+
+		err = services.SetLimitOverrides(client, accountID, overrides)
+		if err != nil {
+			if err == services.ErrAccountNotFound {
+				writeError(w, r, http.StatusNotFound, "Account not found")
+			} else {
+				writeError(w, r, http.StatusInternalServerError, "Failed to update account limits")
+			}
+			return
+		}
+
+		// Return success response
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Account limits updated successfully",
+			Data:    overrides,
+		})
+	}
+}
+
+// Helper function to answer a transaction rejected by the account limits with the violated limit and its headroom.
+// It reports whether err was a limit violation.
+func writeLimitError(w http.ResponseWriter, r *http.Request, err error) bool {
+	limitErr, ok := err.(*services.LimitExceededError)
+	if !ok {
+		return false
+	}
+	translator := requestTranslator(r)
+	message := translate(translator, "limit_exceeded", limitErr.Violation.Limit)
+	writeErrorResponse(w, translator, http.StatusUnprocessableEntity, "limit_exceeded", message, limitErr.Violation)
+	return true
+}
+
+// Handler for posting a deposit or withdrawal to an account
+func CreateAccountTransactionHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse account ID from URL parameter
+		vars := mux.Vars(r)
+		accountID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid virtual wallet ID"})
+			writeError(w, r, http.StatusBadRequest, "Invalid account ID")
 			return
 		}
-		// Decode request body
+		// Decode and validate request body; the type is credit or debit and the amount positive
 		var reqBody models.CreateTransactionRequest
-		err = json.NewDecoder(r.Body).Decode(&reqBody)
+		if !decodeRequest(w, r, &reqBody) {
+			return
+		}
+
+		// The account's transaction limits are checked in the same MongoDB transaction as the posting
+		transaction, err := services.CreateAccountTransaction(client, accountID, reqBody.Type, reqBody.Amount)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request body"})
+			if writeLimitError(w, r, err) {
+				return
+			}
+			switch err {
+			case services.ErrAccountNotFound:
+				writeError(w, r, http.StatusNotFound, "Account not found")
+			case services.ErrInsufficientFunds:
+				// The current balance cannot cover the debit, possibly because a concurrent debit took it
+				writeError(w, r, http.StatusConflict, "Insufficient balance")
+			case models.ErrCurrencyMismatch:
+				writeError(w, r, http.StatusBadRequest, err.Error())
+			case services.ErrConcurrentUpdate:
+				writeError(w, r, http.StatusConflict, err.Error())
+			case services.ErrHouseWalletNotConfigured:
+				writeError(w, r, http.StatusServiceUnavailable, err.Error())
+			default:
+				writeError(w, r, http.StatusInternalServerError, "Failed to update account")
+			}
 			return
 		}
 
-		// Validate transaction type
-		if reqBody.Type != "debit" && reqBody.Type != "credit" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid transaction type. Must be 'debit' or 'credit'"})
+		// Return success response with the ledger entry
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Transaction created successfully",
+			Data:    transaction,
+		})
+	}
+}
+
+// Handler for listing a page of account transactions. Accepts the same parameters as the wallet listing.
+func GetAccountTransactionsHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse account ID from URL parameter
+		vars := mux.Vars(r)
+		accountID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid account ID")
 			return
 		}
 
-		// Validate transaction amount
-		if reqBody.Amount <= 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Transaction amount must be positive"})
+		// Parse paging, sorting and filter parameters
+		query, err := parseTransactionQuery(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		// Find virtual wallet document in database
-		virtualWallet, err := services.FindVirtualWallet(client, virtualWalletID, "transactions")
+		// Make sure the account exists
+		_, err = services.FindAccount(client, accountID)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				w.WriteHeader(http.StatusNotFound)
-				json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Virtual wallet not found"})
+			if err == services.ErrAccountNotFound {
+				writeError(w, r, http.StatusNotFound, "Account not found")
 			} else {
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to retrieve virtual wallet"})
+				writeError(w, r, http.StatusInternalServerError, "Failed to retrieve account")
 			}
 			return
 		}
 
-		// Update virtual wallet balance and add transaction
-		if reqBody.Type == "debit" {
-			if reqBody.Amount > virtualWallet.Balance {
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Insufficient balance"})
+		page, err := services.ListAccountTransactions(client, accountID, query)
+		if err != nil {
+			if err == services.ErrInvalidCursor {
+				writeError(w, r, http.StatusBadRequest, err.Error())
 				return
 			}
-			virtualWallet.Balance -= reqBody.Amount
-		} else {
-			virtualWallet.Balance += reqBody.Amount
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve account transactions")
+			return
 		}
-		virtualWallet.Transactions = append(virtualWallet.Transactions, models.Transaction{
-			Type:      models.TransactionType(reqBody.Type),
-			Amount:    reqBody.Amount,
-			CreatedAt: time.Now(),
+
+		// Return success response with account transactions
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message:    "Account transactions retrieved successfully",
+			Data:       page.Transactions,
+			NextCursor: page.NextCursor,
 		})
-		virtualWallet.DateModified = time.Now()
+	}
+}
 
-		_, err = client.Database("walletManager").Collection("virtual_wallets").UpdateOne(
-			context.Background(),
-			bson.M{"_id": virtualWalletID},
-			bson.M{"$set": bson.M{
-				"balance":       virtualWallet.Balance,
-				"transactions":  virtualWallet.Transactions,
-				"date_modified": virtualWallet.DateModified,
-			}},
-		)
+// Handler for releasing a named hold on an account back into its balance
+func ReleaseAccountHoldHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse account ID from URL parameter
+		vars := mux.Vars(r)
+		accountID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to update virtual wallet"})
+			writeError(w, r, http.StatusBadRequest, "Invalid account ID")
+			return
+		}
+		// Parse and validate request body
+		var request models.ReleaseAccountHoldRequest
+		if !decodeRequest(w, r, &request) {
+			return
+		}
+		holdID, err := primitive.ObjectIDFromHex(request.HoldID)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid hold ID")
+			return
+		}
+
+		hold, err := services.ReleaseAccountHold(client, accountID, holdID)
+		if err != nil {
+			writeHoldError(w, r, err)
+			return
+		}
+
+		// Return success response with the released hold
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Hold balance released successfully",
+			Data:    hold,
+		})
+	}
+}
+
+// Handler for creating a new virtual wallet transaction
+func CreateTransactionHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse virtual wallet ID from URL path parameter
+		vars := mux.Vars(r)
+		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
+			return
+		}
+		// Decode and validate request body; the type is credit or debit and the amount positive
+		var reqBody models.CreateTransactionRequest
+		if !decodeRequest(w, r, &reqBody) {
+			return
+		}
+
+		// Apply the balance change and history entry in a single conditional update. The limits of the
+		// account owning the wallet are checked in the same MongoDB transaction.
+		err = services.CreateVirtualWalletTransaction(client, virtualWalletID, "", reqBody.Type, reqBody.Amount)
+		if err != nil {
+			if writeLimitError(w, r, err) {
+				return
+			}
+			switch err {
+			case mongo.ErrNoDocuments:
+				writeError(w, r, http.StatusNotFound, "Virtual wallet not found")
+			case services.ErrInsufficientFunds:
+				// The current balance cannot cover the debit, possibly because a concurrent debit took it
+				writeError(w, r, http.StatusConflict, "Insufficient balance")
+			case services.ErrConcurrentUpdate:
+				writeError(w, r, http.StatusConflict, err.Error())
+			case services.ErrHouseWalletNotConfigured:
+				writeError(w, r, http.StatusServiceUnavailable, err.Error())
+			case services.ErrCashWithdrawalNotAllowed, services.ErrHoldsNotAllowed, services.ErrSystemTransfersOnly, models.ErrCurrencyMismatch:
+				writeError(w, r, http.StatusBadRequest, err.Error())
+			default:
+				writeError(w, r, http.StatusInternalServerError, "Failed to update virtual wallet")
+			}
 			return
 		}
 
@@ -180,13 +394,24 @@ func CreateTransactionHandler(client *mongo.Client) http.HandlerFunc {
 // Handler function to get the total balance for a customer across all virtual wallets
 func GetCustomerTotalBalanceHandler(client *mongo.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse customer ID from query parameter
-		customerID := r.URL.Query().Get("customer_id")
-		// Get total balance for customer across all virtual wallets
-		totalBalance, err := GetCustomerTotalBalance(client, customerID)
+		// Parse customer ID from the URL; AccountOwnershipMiddleware made sure it is the caller
+		customerID := mux.Vars(r)["id"]
+
+		// Get total balance for customer across all virtual wallets, now or as of a past time
+		var totalBalance models.Money
+		var err error
+		if r.URL.Query().Get("as_of") != "" {
+			asOf, parseErr := parseAsOf(r)
+			if parseErr != nil {
+				writeError(w, r, http.StatusBadRequest, "Invalid as_of, expected an RFC 3339 time")
+				return
+			}
+			totalBalance, err = services.CustomerBalanceAsOf(client, customerID, asOf)
+		} else {
+			totalBalance, err = GetCustomerTotalBalance(client, customerID)
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to retrieve customer balance"})
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve customer balance")
 			return
 		}
 
@@ -200,14 +425,17 @@ func GetCustomerTotalBalanceHandler(client *mongo.Client) http.HandlerFunc {
 }
 
 // Helper function to get total balance for a customer across all virtual wallets
-func GetCustomerTotalBalance(client *mongo.Client, customerID string) (float64, error) {
+func GetCustomerTotalBalance(client *mongo.Client, customerID string) (models.Money, error) {
 	virtualWallets, err := FindAllVirtualWallets(client, customerID)
 	if err != nil {
-		return 0, err
+		return models.Money{}, err
 	}
-	var totalBalance float64
+	totalBalance := models.NewMoney(0, models.DefaultCurrency)
 	for _, virtualWallet := range virtualWallets {
-		totalBalance += virtualWallet.Balance
+		totalBalance, err = totalBalance.Add(virtualWallet.Balance)
+		if err != nil {
+			return models.Money{}, err
+		}
 	}
 
 	return totalBalance, nil
@@ -231,6 +459,7 @@ func FindAllVirtualWallets(client *mongo.Client, customerID string) ([]models.Vi
 		if err != nil {
 			return []models.VirtualWallet{}, err
 		}
+		virtualWallet.Policy = services.WalletPolicyFor(&virtualWallet)
 		virtualWallets = append(virtualWallets, virtualWallet)
 	}
 
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Helper function to create an account with one wallet holding the given balance
+func createTestWallet(t *testing.T, client *mongo.Client, balance string) (primitive.ObjectID, primitive.ObjectID) {
+	t.Helper()
+	now := time.Now()
+	accountID, err := services.CreateAccount(client, models.Account{
+		Email:        "parallel@example.com",
+		Type:         models.Retail,
+		Balance:      models.NewMoney(0, models.DefaultCurrency),
+		HoldBalance:  models.NewMoney(0, models.DefaultCurrency),
+		CreatedAt:    now,
+		DateModified: now,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	walletID, err := services.CreateVirtualWallet(client, models.VirtualWallet{
+		CustomerID:   accountID.Hex(),
+		WalletType:   models.CashWallet,
+		Balance:      models.MustParseMoney(balance),
+		DateCreated:  now,
+		DateModified: now,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	return accountID, walletID
+}
+
+func TestParallelDebitsNeverOverdraw(t *testing.T) {
+	client := testMongoClient(t)
+	_, walletID := createTestWallet(t, client, "100.00")
+
+	router := mux.NewRouter()
+	router.Handle("/virtual_wallets/{id}/transactions", CreateTransactionHandler(client)).Methods("POST")
+
+	// 100.00 covers exactly six debits of 15.00
+	const requests = 12
+	const expectedDebits = 6
+	start := make(chan struct{})
+	codes := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			request := httptest.NewRequest("POST", "/virtual_wallets/"+walletID.Hex()+"/transactions",
+				strings.NewReader(`{"type": "debit", "amount": "15.00"}`))
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			codes <- recorder.Code
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(codes)
+
+	succeeded, conflicts := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			succeeded++
+		case http.StatusConflict:
+			conflicts++
+		default:
+			t.Errorf("Unexpected status %d", code)
+		}
+	}
+	if succeeded != expectedDebits || conflicts != requests-expectedDebits {
+		t.Errorf("%d debits succeeded and %d got 409, want %d and %d", succeeded, conflicts, expectedDebits, requests-expectedDebits)
+	}
+
+	virtualWallet, err := services.FindVirtualWallet(client, walletID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := models.MustParseMoney("10.00"); virtualWallet.Balance != want {
+		t.Errorf("Balance is %v, want %v", virtualWallet.Balance, want)
+	}
+	ledgerEntries, err := client.Database("walletManager").Collection("transactions").CountDocuments(context.Background(),
+		bson.M{"wallet_id": walletID, "type": models.Debit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ledgerEntries != int64(succeeded) {
+		t.Errorf("Ledger holds %d debits, want %d", ledgerEntries, succeeded)
+	}
+}
+
+func TestCreateTransactionValidation(t *testing.T) {
+	router := mux.NewRouter()
+	router.Handle("/virtual_wallets/{id}/transactions", CreateTransactionHandler(nil)).Methods("POST")
+
+	tests := []struct {
+		name   string
+		wallet string
+		body   string
+	}{
+		{name: "invalid wallet ID", wallet: "not-an-id", body: `{"type": "debit", "amount": "1.00"}`},
+		{name: "invalid type", wallet: primitive.NewObjectID().Hex(), body: `{"type": "steal", "amount": "1.00"}`},
+		{name: "zero amount", wallet: primitive.NewObjectID().Hex(), body: `{"type": "debit", "amount": "0"}`},
+		{name: "malformed body", wallet: primitive.NewObjectID().Hex(), body: `{"type":`},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("POST", "/virtual_wallets/"+test.wallet+"/transactions", strings.NewReader(test.body))
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", test.name, recorder.Code)
+		}
+	}
+}
+
+func TestCreateTransactionCurrencyMismatch(t *testing.T) {
+	client := testMongoClient(t)
+	_, walletID := createTestWallet(t, client, "100.00")
+
+	router := mux.NewRouter()
+	router.Handle("/virtual_wallets/{id}/transactions", CreateTransactionHandler(client)).Methods("POST")
+	for _, transactionType := range []string{"credit", "debit"} {
+		body := `{"type": "` + transactionType + `", "amount": "1.00 USD"}`
+		request := httptest.NewRequest("POST", "/virtual_wallets/"+walletID.Hex()+"/transactions", strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("USD %s on an INR wallet: status = %d, want 400", transactionType, recorder.Code)
+			continue
+		}
+		var response models.ErrorResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Code != "currency_mismatch" {
+			t.Errorf("USD %s on an INR wallet: code = %q, want currency_mismatch", transactionType, response.Code)
+		}
+	}
+}
+
+// The total balance is always the caller's own; the legacy customer_id query parameter no longer overrides the path
+func TestCustomerTotalBalanceIgnoresQueryOverride(t *testing.T) {
+	client := testMongoClient(t)
+	ownerID, _ := createTestWallet(t, client, "10.00")
+	otherID, _ := createTestWallet(t, client, "99.00")
+
+	router := mux.NewRouter()
+	router.Handle("/customers/{id}/total_balance", AccountOwnershipMiddleware(GetCustomerTotalBalanceHandler(client))).Methods("GET")
+	request := httptest.NewRequest("GET", "/customers/"+ownerID.Hex()+"/total_balance?customer_id="+otherID.Hex(), nil)
+	request.Header.Set(AccountIDHeader, ownerID.Hex())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	var response struct {
+		Data models.Money `json:"data"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Data != models.MustParseMoney("10.00") {
+		t.Errorf("total balance = %v, want the caller's 10.00", response.Data)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Handler for retrieving a virtual wallet's Balance and HoldBalance, optionally as of an RFC 3339 as_of time
+func GetVirtualWalletBalanceHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse virtual wallet ID from URL path parameter
+		vars := mux.Vars(r)
+		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
+			return
+		}
+		asOf, err := parseAsOf(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid as_of, expected an RFC 3339 time")
+			return
+		}
+
+		balance, err := services.WalletBalanceAsOf(client, virtualWalletID, asOf)
+		if err != nil {
+			writeBalanceError(w, r, err, "Virtual wallet not found")
+			return
+		}
+
+		// Return success response with the balances
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Virtual wallet balance retrieved successfully",
+			Data:    balance,
+		})
+	}
+}
+
+// Handler for retrieving an account's Balance and HoldBalance, optionally as of an RFC 3339 as_of time
+func GetAccountBalanceHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse account ID from URL parameter
+		vars := mux.Vars(r)
+		accountID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid account ID")
+			return
+		}
+		asOf, err := parseAsOf(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid as_of, expected an RFC 3339 time")
+			return
+		}
+
+		balance, err := services.AccountBalanceAsOf(client, accountID, asOf)
+		if err != nil {
+			writeBalanceError(w, r, err, "Account not found")
+			return
+		}
+
+		// Return success response with the balances
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Account balance retrieved successfully",
+			Data:    balance,
+		})
+	}
+}
+
+// Helper function to read the optional as_of query parameter; without it the current time is used
+func parseAsOf(r *http.Request) (time.Time, error) {
+	value := r.URL.Query().Get("as_of")
+	if value == "" {
+		return time.Now(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// Helper function to map point-in-time balance errors to HTTP responses
+func writeBalanceError(w http.ResponseWriter, r *http.Request, err error, notFound string) {
+	switch err {
+	case mongo.ErrNoDocuments:
+		writeError(w, r, http.StatusNotFound, notFound)
+	case services.ErrBalanceBeforeCreation:
+		writeError(w, r, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, r, http.StatusInternalServerError, "Failed to compute balance")
+	}
+}
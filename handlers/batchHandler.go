@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Largest batch upload accepted, in bytes; enforced by MaxBodySizeMiddleware
+const MaxBatchUploadSize = 64 << 20
+
+// Handler for uploading a CSV or NDJSON batch of wallet transactions. The file is either the raw request body
+// or the "file" field of a multipart form. The format comes from the format parameter, the Content-Type or the
+// file extension; the mode parameter selects per_row (default) or all_or_nothing.
+func CreateBatchHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var file io.Reader = r.Body
+		format := r.URL.Query().Get("format")
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType == "multipart/form-data" {
+			part, header, err := r.FormFile("file")
+			if isBodyTooLarge(err) {
+				writeError(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+				return
+			}
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "Missing file field")
+				return
+			}
+			defer part.Close()
+			file = part
+			if format == "" {
+				format = r.FormValue("format")
+			}
+			if format == "" {
+				format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+			}
+		}
+		if format == "" {
+			switch contentType {
+			case "text/csv":
+				format = "csv"
+			case "application/x-ndjson", "application/ndjson":
+				format = "ndjson"
+			}
+		}
+
+		mode := models.BatchMode(r.URL.Query().Get("mode"))
+		if mode == "" {
+			mode = models.BatchPerRow
+		}
+
+		batch, err := services.CreateBatch(client, format, mode, file)
+		if err != nil {
+			switch err {
+			case services.ErrInvalidBatchFormat, services.ErrInvalidBatchMode, services.ErrBatchEmpty, services.ErrBatchTooLarge:
+				writeError(w, r, http.StatusBadRequest, err.Error())
+			default:
+				if isBodyTooLarge(err) {
+					writeError(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+					return
+				}
+				if _, ok := err.(*csv.ParseError); ok {
+					writeError(w, r, http.StatusBadRequest, "Invalid CSV file: "+err.Error())
+					return
+				}
+				writeError(w, r, http.StatusInternalServerError, "Failed to import batch")
+			}
+			return
+		}
+
+		// Apply the valid rows in the background; clients poll GET /batches/{id}
+		if batch.Status == models.BatchProcessing {
+			go services.ProcessBatch(client, batch.ID)
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Batch accepted",
+			Data:    batch,
+		})
+	}
+}
+
+// Handler for polling the progress of a batch
+func GetBatchHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse batch ID from URL parameter
+		vars := mux.Vars(r)
+		batchID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid batch ID")
+			return
+		}
+
+		batch, err := services.FindBatch(client, batchID)
+		if err != nil {
+			if err == services.ErrBatchNotFound {
+				writeError(w, r, http.StatusNotFound, err.Error())
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve batch")
+			return
+		}
+
+		// Return success response with the batch progress
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Batch found",
+			Data:    batch,
+		})
+	}
+}
+
+// Handler for downloading the rejected rows of a batch as CSV
+func GetBatchErrorsHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse batch ID from URL parameter
+		vars := mux.Vars(r)
+		batchID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid batch ID")
+			return
+		}
+
+		writer := csv.NewWriter(w)
+		started := false
+		start := func() {
+			started = true
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "batch-"+batchID.Hex()+"-errors.csv"))
+			w.WriteHeader(http.StatusOK)
+			writer.Write([]string{"line", "wallet_id", "type", "amount", "reference", "error"})
+		}
+		err = services.WriteBatchErrorReport(client, batchID, func(row models.BatchRow) error {
+			if !started {
+				start()
+			}
+			return writer.Write([]string{strconv.FormatInt(row.Line, 10), row.WalletID, row.Type, row.Amount, row.Reference, row.Error})
+		})
+		if err == nil && !started {
+			// No rejected rows: the report only has its header
+			start()
+		}
+		writer.Flush()
+		if err == nil {
+			return
+		}
+		if started {
+			log.Printf("Error report for batch %s ended early: %v", batchID.Hex(), err)
+			return
+		}
+		if err == services.ErrBatchNotFound {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "Failed to build error report")
+	}
+}
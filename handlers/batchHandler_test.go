@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBatchUploadTooLarge(t *testing.T) {
+	const limit = 16
+	handler := MaxBodySizeMiddleware(limit, IdempotencyMiddleware(nil, CreateBatchHandler(nil)))
+	rows := "wallet_id,type,amount\n" + strings.Repeat("0123456789abcdef01234567,credit,1.00\n", 10)
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, _ := writer.CreateFormFile("file", "rows.csv")
+	part.Write([]byte(rows))
+	writer.Close()
+
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		idempotencyKey string
+	}{
+		{name: "multipart form", contentType: writer.FormDataContentType(), body: form.String()},
+		// The idempotency middleware reads the whole body before the handler
+		{name: "with an idempotency key", contentType: "text/csv", body: rows, idempotencyKey: "batch-1"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("POST", "/batches", strings.NewReader(test.body))
+		request.Header.Set("Content-Type", test.contentType)
+		if test.idempotencyKey != "" {
+			request.Header.Set("Idempotency-Key", test.idempotencyKey)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, http.StatusRequestEntityTooLarge)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Handler for previewing the fee of a transaction without posting it.
+// Expects type and amount query parameters and either wallet_id or account_id.
+func FeeQuoteHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		// Validate transaction amount
+		amount, err := models.ParseMoney(query.Get("amount"), models.DefaultCurrency)
+		if err != nil || !amount.IsPositive() {
+			writeError(w, r, http.StatusBadRequest, "Transaction amount must be positive")
+			return
+		}
+		transactionType := models.TransactionType(query.Get("type"))
+
+		var quote models.FeeQuote
+		switch {
+		case query.Get("wallet_id") != "":
+			virtualWalletID, err := primitive.ObjectIDFromHex(query.Get("wallet_id"))
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
+				return
+			}
+			quote, err = services.QuoteWalletFee(client, virtualWalletID, transactionType, amount)
+			if err == mongo.ErrNoDocuments {
+				writeError(w, r, http.StatusNotFound, "Virtual wallet not found")
+				return
+			}
+		case query.Get("account_id") != "":
+			accountID, err := primitive.ObjectIDFromHex(query.Get("account_id"))
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "Invalid account ID")
+				return
+			}
+			quote, err = services.QuoteAccountFee(client, accountID, transactionType, amount)
+			if err == services.ErrAccountNotFound {
+				writeError(w, r, http.StatusNotFound, "Account not found")
+				return
+			}
+		default:
+			writeError(w, r, http.StatusBadRequest, "Either wallet_id or account_id is required")
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to compute fee")
+			return
+		}
+
+		// Return success response with the fee breakdown
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Fee quote computed successfully",
+			Data:    quote,
+		})
+	}
+}
+
+// Handler for adding a fee schedule
+func CreateFeeScheduleHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Decode and validate request body
+		var request models.CreateFeeScheduleRequest
+		if !decodeRequest(w, r, &request) {
+			return
+		}
+
+		created, err := services.CreateFeeSchedule(client, models.FeeSchedule{
+			Name:            request.Name,
+			TransactionType: request.TransactionType,
+			AccountType:     request.AccountType,
+			WalletType:      request.WalletType,
+			Currency:        request.Currency,
+			Method:          request.Method,
+			Flat:            request.Flat,
+			BasisPoints:     request.BasisPoints,
+			Tiers:           request.Tiers,
+			Min:             request.Min,
+			Max:             request.Max,
+			Active:          request.Active,
+		})
+		if err != nil {
+			if err == services.ErrInvalidFeeSchedule || err == services.ErrOpenEndedTierRequired || err == models.ErrCurrencyMismatch {
+				writeError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, "Failed to create fee schedule")
+			return
+		}
+
+		// Return success response with the stored schedule
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Fee schedule created successfully",
+			Data:    created,
+		})
+	}
+}
+
+// Handler for listing every fee schedule
+func GetFeeSchedulesHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schedules, err := services.ListFeeSchedules(client)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve fee schedules")
+			return
+		}
+
+		// Return success response with the fee schedules
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Fee schedules retrieved successfully",
+			Data:    schedules,
+		})
+	}
+}
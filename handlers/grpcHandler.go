@@ -0,0 +1,750 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"mfus_WalletTransactionManager/walletpb"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AccountIDMetadata is the gRPC metadata key carrying the caller's account ID, like AccountIDHeader over REST
+const AccountIDMetadata = "x-account-id"
+
+// ServiceTokenMetadata is the gRPC metadata key carrying the token every internal service must present
+const ServiceTokenMetadata = "x-service-token"
+
+// AdminTokenMetadata is the gRPC metadata key carrying the operator token of admin calls, like AdminTokenHeader over REST
+const AdminTokenMetadata = "x-admin-token"
+
+// Token that gRPC callers must present. While it is empty every gRPC request is refused.
+var grpcServiceToken string
+
+// SetGRPCServiceToken sets the token that gRPC callers must present in the x-service-token metadata
+func SetGRPCServiceToken(token string) {
+	grpcServiceToken = token
+}
+
+// Number of ledger entries read per page while streaming a transaction history
+const grpcHistoryPageSize = services.MaxTransactionPageSize
+
+// WalletManagerServer implements the gRPC API on top of the same services layer as the REST handlers
+type WalletManagerServer struct {
+	walletpb.UnimplementedWalletManagerServer
+	client *mongo.Client
+}
+
+// NewGRPCServer returns a gRPC server serving the wallet manager API, with server reflection for tools like grpcurl.
+// Every call must present the service token; options such as TLS credentials are passed on to the server.
+func NewGRPCServer(client *mongo.Client, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.UnaryInterceptor(serviceTokenUnaryInterceptor), grpc.StreamInterceptor(serviceTokenStreamInterceptor))
+	server := grpc.NewServer(opts...)
+	walletpb.RegisterWalletManagerServer(server, &WalletManagerServer{client: client})
+	reflection.Register(server)
+	return server
+}
+
+// CreateAccount opens an account; the email must not be in use yet
+func (s *WalletManagerServer) CreateAccount(ctx context.Context, request *walletpb.CreateAccountRequest) (*walletpb.Account, error) {
+	balance, err := moneyFromProto(request.Balance)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid balance: "+err.Error())
+	}
+	//Check if duplicate request is received
+	if account, _ := services.GetAccountByEmail(s.client, request.Email); account != nil {
+		return nil, status.Error(codes.AlreadyExists, "Account already exist")
+	}
+
+	account := models.Account{
+		Email:          request.Email,
+		Type:           models.AccountType(request.Type),
+		Balance:        balance,
+		HoldBalance:    models.NewMoney(0, balance.CurrencyCode()),
+		CreatedAt:      time.Now(),
+		VirtualWallets: []string{},
+	}
+	account.ID, err = services.CreateAccount(s.client, account)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to create account")
+	}
+	return accountToProto(account), nil
+}
+
+// GetAccount returns the caller's own account
+func (s *WalletManagerServer) GetAccount(ctx context.Context, request *walletpb.GetAccountRequest) (*walletpb.Account, error) {
+	accountID, err := parseGRPCObjectID(request.AccountId, "Invalid account ID")
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeAccount(ctx, request.AccountId); err != nil {
+		return nil, err
+	}
+	account, err := services.FindAccount(s.client, accountID)
+	if err != nil {
+		return nil, grpcError(err, "Account not found", "Failed to retrieve account")
+	}
+	return accountToProto(*account), nil
+}
+
+// ListAccountWallets lists the virtual wallets of the caller's own account
+func (s *WalletManagerServer) ListAccountWallets(ctx context.Context, request *walletpb.ListAccountWalletsRequest) (*walletpb.ListAccountWalletsResponse, error) {
+	accountID, err := parseGRPCObjectID(request.AccountId, "Invalid account ID")
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeAccount(ctx, request.AccountId); err != nil {
+		return nil, err
+	}
+	if _, err := services.FindAccount(s.client, accountID); err != nil {
+		return nil, grpcError(err, "Account not found", "Failed to retrieve account")
+	}
+
+	// Wallets carry the owning account ID as their customer ID
+	virtualWallets, err := FindAllVirtualWallets(s.client, accountID.Hex())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to retrieve virtual wallets")
+	}
+	response := &walletpb.ListAccountWalletsResponse{Wallets: []*walletpb.Wallet{}}
+	for _, virtualWallet := range virtualWallets {
+		response.Wallets = append(response.Wallets, walletToProto(virtualWallet))
+	}
+	return response, nil
+}
+
+func (s *WalletManagerServer) GetAccountBalance(ctx context.Context, request *walletpb.GetBalanceRequest) (*walletpb.Balance, error) {
+	accountID, err := parseGRPCObjectID(request.Id, "Invalid account ID")
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeAccount(ctx, request.Id); err != nil {
+		return nil, err
+	}
+	balance, err := services.AccountBalanceAsOf(s.client, accountID, asOfFromProto(request.AsOf))
+	if err != nil {
+		return nil, grpcError(err, "Account not found", "Failed to compute balance")
+	}
+	return balanceToProto(*balance), nil
+}
+
+// CreateAccountTransaction posts a credit or debit to an account within its limits
+func (s *WalletManagerServer) CreateAccountTransaction(ctx context.Context, request *walletpb.CreateTransactionRequest) (*walletpb.Transaction, error) {
+	accountID, err := parseGRPCObjectID(request.Id, "Invalid account ID")
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeAccount(ctx, request.Id); err != nil {
+		return nil, err
+	}
+	transactionType, amount, err := transactionRequestFromProto(request)
+	if err != nil {
+		return nil, err
+	}
+	transaction, err := services.CreateAccountTransaction(s.client, accountID, transactionType, amount)
+	if err != nil {
+		return nil, grpcError(err, "Account not found", "Failed to update account")
+	}
+	return transactionToProto(*transaction), nil
+}
+
+func (s *WalletManagerServer) ListAccountTransactions(request *walletpb.ListTransactionsRequest, stream walletpb.WalletManager_ListAccountTransactionsServer) error {
+	accountID, err := parseGRPCObjectID(request.Id, "Invalid account ID")
+	if err != nil {
+		return err
+	}
+	if err := authorizeAccount(stream.Context(), request.Id); err != nil {
+		return err
+	}
+	if _, err := services.FindAccount(s.client, accountID); err != nil {
+		return grpcError(err, "Account not found", "Failed to retrieve account")
+	}
+	return streamTransactions(stream.Context(), request, stream.Send, func(query models.TransactionQuery) (*models.TransactionPage, error) {
+		return services.ListAccountTransactions(s.client, accountID, query)
+	})
+}
+
+// CreateWallet creates a virtual wallet for the caller's own account
+func (s *WalletManagerServer) CreateWallet(ctx context.Context, request *walletpb.CreateWalletRequest) (*walletpb.Wallet, error) {
+	if request.CustomerId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Customer ID is required")
+	}
+	balance, err := moneyFromProto(request.Balance)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid balance: "+err.Error())
+	}
+	creditLimit, err := moneyFromProto(request.CreditLimit)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid credit limit: "+err.Error())
+	}
+	walletType := models.WalletType(request.WalletType)
+	if walletType == "" {
+		walletType = models.CashWallet
+	}
+	if !walletType.IsValid() {
+		return nil, status.Error(codes.InvalidArgument, services.ErrInvalidWalletType.Error())
+	}
+	if !creditLimit.IsZero() && walletType != models.CreditWallet {
+		return nil, status.Error(codes.InvalidArgument, services.ErrCreditLimitNotAllowed.Error())
+	}
+	// Like over REST, accounts may only create empty wallets for themselves; operators may set the balance and credit limit
+	if authorizeAdmin(ctx) != nil {
+		if err := authorizeAccount(ctx, request.CustomerId); err != nil {
+			return nil, err
+		}
+		if !balance.IsZero() {
+			return nil, status.Error(codes.PermissionDenied, "Opening balance requires admin access")
+		}
+		if !creditLimit.IsZero() {
+			return nil, status.Error(codes.PermissionDenied, "Credit limit requires admin access")
+		}
+	}
+
+	virtualWallet := models.VirtualWallet{
+		CustomerID:   request.CustomerId,
+		WalletType:   walletType,
+		CreditLimit:  creditLimit,
+		Balance:      balance,
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+	}
+	virtualWallet.ID, err = services.CreateVirtualWallet(s.client, virtualWallet)
+	if err != nil {
+		return nil, grpcError(err, "Account not found", "Failed to create virtual wallet")
+	}
+	return walletToProto(virtualWallet), nil
+}
+
+func (s *WalletManagerServer) GetWallet(ctx context.Context, request *walletpb.GetWalletRequest) (*walletpb.Wallet, error) {
+	virtualWalletID, err := s.authorizeWallet(ctx, request.WalletId)
+	if err != nil {
+		return nil, err
+	}
+	return s.findWallet(virtualWalletID)
+}
+
+// SetWalletBalance sets the balance of a wallet on behalf of an admin, recording the difference in the ledger
+func (s *WalletManagerServer) SetWalletBalance(ctx context.Context, request *walletpb.SetWalletBalanceRequest) (*walletpb.Wallet, error) {
+	if err := authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	virtualWalletID, err := parseGRPCObjectID(request.WalletId, "Invalid virtual wallet ID")
+	if err != nil {
+		return nil, err
+	}
+	balance, err := moneyFromProto(request.Balance)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid balance: "+err.Error())
+	}
+	if err := services.SetVirtualWalletBalance(s.client, virtualWalletID, balance); err != nil {
+		return nil, grpcError(err, "Virtual wallet not found", "Failed to update virtual wallet")
+	}
+	return s.findWallet(virtualWalletID)
+}
+
+func (s *WalletManagerServer) DeleteWallet(ctx context.Context, request *walletpb.DeleteWalletRequest) (*walletpb.DeleteWalletResponse, error) {
+	virtualWalletID, err := s.authorizeWallet(ctx, request.WalletId)
+	if err != nil {
+		return nil, err
+	}
+	if err := services.DeleteVirtualWallet(s.client, virtualWalletID); err != nil {
+		return nil, grpcError(err, "Virtual wallet not found", "Failed to delete virtual wallet")
+	}
+	return &walletpb.DeleteWalletResponse{}, nil
+}
+
+func (s *WalletManagerServer) GetWalletBalance(ctx context.Context, request *walletpb.GetBalanceRequest) (*walletpb.Balance, error) {
+	virtualWalletID, err := s.authorizeWallet(ctx, request.Id)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := services.WalletBalanceAsOf(s.client, virtualWalletID, asOfFromProto(request.AsOf))
+	if err != nil {
+		return nil, grpcError(err, "Virtual wallet not found", "Failed to compute balance")
+	}
+	return balanceToProto(*balance), nil
+}
+
+// CreateWalletTransaction posts a credit or debit to a wallet and returns the wallet with its new balance
+func (s *WalletManagerServer) CreateWalletTransaction(ctx context.Context, request *walletpb.CreateTransactionRequest) (*walletpb.Wallet, error) {
+	virtualWalletID, err := s.authorizeWallet(ctx, request.Id)
+	if err != nil {
+		return nil, err
+	}
+	transactionType, amount, err := transactionRequestFromProto(request)
+	if err != nil {
+		return nil, err
+	}
+	err = services.CreateVirtualWalletTransaction(s.client, virtualWalletID, "", transactionType, amount)
+	if err != nil {
+		return nil, grpcError(err, "Virtual wallet not found", "Failed to update virtual wallet")
+	}
+	return s.findWallet(virtualWalletID)
+}
+
+// ReleaseWalletHold moves an amount from a wallet's hold balance back into its balance
+func (s *WalletManagerServer) ReleaseWalletHold(ctx context.Context, request *walletpb.ReleaseWalletHoldRequest) (*walletpb.Wallet, error) {
+	virtualWalletID, err := s.authorizeWallet(ctx, request.WalletId)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := moneyFromProto(request.Amount)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid amount: "+err.Error())
+	}
+	if !amount.IsPositive() {
+		return nil, status.Error(codes.InvalidArgument, "Release amount must be positive")
+	}
+
+	err = services.CreateVirtualWalletTransaction(s.client, virtualWalletID, "", models.Release, amount)
+	if err != nil {
+		return nil, grpcError(err, "Virtual wallet not found", "Failed to release hold balance")
+	}
+	return s.findWallet(virtualWalletID)
+}
+
+func (s *WalletManagerServer) ListWalletTransactions(request *walletpb.ListTransactionsRequest, stream walletpb.WalletManager_ListWalletTransactionsServer) error {
+	virtualWalletID, err := s.authorizeWallet(stream.Context(), request.Id)
+	if err != nil {
+		return err
+	}
+	return streamTransactions(stream.Context(), request, stream.Send, func(query models.TransactionQuery) (*models.TransactionPage, error) {
+		return services.ListWalletTransactions(s.client, virtualWalletID, query)
+	})
+}
+
+// CreateHold places an authorization hold on account funds within the account's limits
+func (s *WalletManagerServer) CreateHold(ctx context.Context, request *walletpb.CreateHoldRequest) (*walletpb.Hold, error) {
+	accountID, err := parseGRPCObjectID(request.AccountId, "Invalid account ID")
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeAccount(ctx, request.AccountId); err != nil {
+		return nil, err
+	}
+	amount, err := moneyFromProto(request.Amount)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid amount: "+err.Error())
+	}
+	if !amount.IsPositive() {
+		return nil, status.Error(codes.InvalidArgument, "Hold amount must be positive")
+	}
+	var expiresAt time.Time
+	if request.ExpiresAt != nil {
+		expiresAt = request.ExpiresAt.AsTime()
+	}
+	hold, err := services.CreateHold(s.client, accountID, amount, request.Reference, expiresAt)
+	if err != nil {
+		return nil, grpcError(err, "Account not found", "Failed to process hold")
+	}
+	return holdToProto(*hold), nil
+}
+
+func (s *WalletManagerServer) GetHold(ctx context.Context, request *walletpb.GetHoldRequest) (*walletpb.Hold, error) {
+	hold, err := s.authorizeHold(ctx, request.HoldId)
+	if err != nil {
+		return nil, err
+	}
+	return holdToProto(*hold), nil
+}
+
+// CaptureHold captures a hold in full, or in part when an amount is given
+func (s *WalletManagerServer) CaptureHold(ctx context.Context, request *walletpb.CaptureHoldRequest) (*walletpb.Hold, error) {
+	authorized, err := s.authorizeHold(ctx, request.HoldId)
+	if err != nil {
+		return nil, err
+	}
+	var amount *models.Money
+	if request.Amount != nil {
+		captured, err := moneyFromProto(request.Amount)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Invalid amount: "+err.Error())
+		}
+		if !captured.IsPositive() {
+			return nil, status.Error(codes.InvalidArgument, "Capture amount must be positive")
+		}
+		amount = &captured
+	}
+
+	hold, err := services.CaptureHold(s.client, authorized.ID, amount)
+	if err != nil {
+		return nil, grpcError(err, "Hold not found", "Failed to process hold")
+	}
+	return holdToProto(*hold), nil
+}
+
+func (s *WalletManagerServer) VoidHold(ctx context.Context, request *walletpb.VoidHoldRequest) (*walletpb.Hold, error) {
+	authorized, err := s.authorizeHold(ctx, request.HoldId)
+	if err != nil {
+		return nil, err
+	}
+	hold, err := services.VoidHold(s.client, authorized.ID)
+	if err != nil {
+		return nil, grpcError(err, "Hold not found", "Failed to process hold")
+	}
+	return holdToProto(*hold), nil
+}
+
+// ReleaseAccountHold releases a named hold of an account back into its balance
+func (s *WalletManagerServer) ReleaseAccountHold(ctx context.Context, request *walletpb.ReleaseAccountHoldRequest) (*walletpb.Hold, error) {
+	accountID, err := parseGRPCObjectID(request.AccountId, "Invalid account ID")
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeAccount(ctx, request.AccountId); err != nil {
+		return nil, err
+	}
+	holdID, err := parseGRPCObjectID(request.HoldId, "Invalid hold ID")
+	if err != nil {
+		return nil, err
+	}
+	hold, err := services.ReleaseAccountHold(s.client, accountID, holdID)
+	if err != nil {
+		return nil, grpcError(err, "Hold not found", "Failed to process hold")
+	}
+	return holdToProto(*hold), nil
+}
+
+// GetCustomerBalance adds up the balances of the caller's virtual wallets, now or as of a past time
+func (s *WalletManagerServer) GetCustomerBalance(ctx context.Context, request *walletpb.GetCustomerBalanceRequest) (*walletpb.CustomerBalance, error) {
+	if request.CustomerId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Customer ID is required")
+	}
+	if err := authorizeAccount(ctx, request.CustomerId); err != nil {
+		return nil, err
+	}
+	var total models.Money
+	var err error
+	if request.AsOf != nil {
+		total, err = services.CustomerBalanceAsOf(s.client, request.CustomerId, request.AsOf.AsTime())
+	} else {
+		total, err = GetCustomerTotalBalance(s.client, request.CustomerId)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to retrieve customer balance")
+	}
+	return &walletpb.CustomerBalance{CustomerId: request.CustomerId, TotalBalance: moneyToProto(total)}, nil
+}
+
+// Helper function to check that the caller owns the wallet, like WalletOwnershipMiddleware does over REST
+func (s *WalletManagerServer) authorizeWallet(ctx context.Context, id string) (primitive.ObjectID, error) {
+	callerAccountID := metadataAccountID(ctx)
+	if callerAccountID == "" {
+		return primitive.NilObjectID, status.Error(codes.Unauthenticated, "Missing "+AccountIDMetadata+" metadata")
+	}
+	virtualWalletID, err := parseGRPCObjectID(id, "Invalid virtual wallet ID")
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if err := services.VerifyWalletOwner(s.client, virtualWalletID, callerAccountID); err != nil {
+		return primitive.NilObjectID, grpcError(err, "Virtual wallet not found", "Failed to retrieve virtual wallet")
+	}
+	return virtualWalletID, nil
+}
+
+// Helper function to check that the caller's account holds the hold, like HoldOwnershipMiddleware does over REST
+func (s *WalletManagerServer) authorizeHold(ctx context.Context, id string) (*models.AuthorizationHold, error) {
+	callerAccountID := metadataAccountID(ctx)
+	if callerAccountID == "" {
+		return nil, status.Error(codes.Unauthenticated, "Missing "+AccountIDMetadata+" metadata")
+	}
+	holdID, err := parseGRPCObjectID(id, "Invalid hold ID")
+	if err != nil {
+		return nil, err
+	}
+	hold, err := services.FindHold(s.client, holdID)
+	if err != nil {
+		return nil, grpcError(err, "Hold not found", "Failed to process hold")
+	}
+	if hold.AccountID.Hex() != callerAccountID {
+		return nil, status.Error(codes.PermissionDenied, "Access to this hold is not allowed")
+	}
+	return hold, nil
+}
+
+// Helper function to check that the caller is the account, like AccountOwnershipMiddleware does over REST
+func authorizeAccount(ctx context.Context, accountID string) error {
+	callerAccountID := metadataAccountID(ctx)
+	if callerAccountID == "" {
+		return status.Error(codes.Unauthenticated, "Missing "+AccountIDMetadata+" metadata")
+	}
+	if callerAccountID != accountID {
+		return status.Error(codes.PermissionDenied, "Access to this account is not allowed")
+	}
+	return nil
+}
+
+// Interceptor to refuse unary calls that do not present the service token
+func serviceTokenUnaryInterceptor(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := authorizeService(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, request)
+}
+
+// Interceptor to refuse streaming calls that do not present the service token
+func serviceTokenStreamInterceptor(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authorizeService(stream.Context()); err != nil {
+		return err
+	}
+	return handler(server, stream)
+}
+
+// Helper function to check the service token, like AdminMiddleware checks the admin token over REST
+func authorizeService(ctx context.Context) error {
+	values := metadata.ValueFromIncomingContext(ctx, ServiceTokenMetadata)
+	if len(values) == 0 || values[0] == "" {
+		return status.Error(codes.Unauthenticated, "Missing "+ServiceTokenMetadata+" metadata")
+	}
+	if grpcServiceToken == "" || subtle.ConstantTimeCompare([]byte(values[0]), []byte(grpcServiceToken)) != 1 {
+		return status.Error(codes.PermissionDenied, "Invalid service token")
+	}
+	return nil
+}
+
+// Helper function to check the admin token, like AdminMiddleware does over REST
+func authorizeAdmin(ctx context.Context) error {
+	values := metadata.ValueFromIncomingContext(ctx, AdminTokenMetadata)
+	if len(values) == 0 || values[0] == "" {
+		return status.Error(codes.Unauthenticated, "Missing "+AdminTokenMetadata+" metadata")
+	}
+	if adminToken == "" || subtle.ConstantTimeCompare([]byte(values[0]), []byte(adminToken)) != 1 {
+		return status.Error(codes.PermissionDenied, "Admin access required")
+	}
+	return nil
+}
+
+func metadataAccountID(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, AccountIDMetadata)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (s *WalletManagerServer) findWallet(virtualWalletID primitive.ObjectID) (*walletpb.Wallet, error) {
+	virtualWallet, err := services.FindVirtualWallet(s.client, virtualWalletID, "")
+	if err != nil {
+		return nil, grpcError(err, "Virtual wallet not found", "Failed to retrieve virtual wallet")
+	}
+	return walletToProto(*virtualWallet), nil
+}
+
+// Helper function to send every page of a transaction history to a stream
+func streamTransactions(ctx context.Context, request *walletpb.ListTransactionsRequest, send func(*walletpb.Transaction) error, list func(query models.TransactionQuery) (*models.TransactionPage, error)) error {
+	query := models.TransactionQuery{Limit: grpcHistoryPageSize, Descending: request.Descending}
+	for _, transactionType := range request.Types {
+		query.Types = append(query.Types, models.TransactionType(strings.TrimSpace(transactionType)))
+	}
+	if request.MinAmount != nil {
+		amount, err := moneyFromProto(request.MinAmount)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, "Invalid min_amount: "+err.Error())
+		}
+		query.MinAmount = &amount
+	}
+	if request.MaxAmount != nil {
+		amount, err := moneyFromProto(request.MaxAmount)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, "Invalid max_amount: "+err.Error())
+		}
+		query.MaxAmount = &amount
+	}
+	if request.StartTime != nil {
+		query.StartDate = request.StartTime.AsTime()
+	}
+	if request.EndTime != nil {
+		query.EndDate = request.EndTime.AsTime()
+	}
+
+	for {
+		page, err := list(query)
+		if err != nil {
+			return grpcError(err, "Transactions not found", "Failed to retrieve transactions")
+		}
+		for _, transaction := range page.Transactions {
+			if err := send(transactionToProto(transaction)); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// Helper function to validate the type and amount of a credit or debit
+func transactionRequestFromProto(request *walletpb.CreateTransactionRequest) (models.TransactionType, models.Money, error) {
+	if request.Type != "debit" && request.Type != "credit" {
+		return "", models.Money{}, status.Error(codes.InvalidArgument, "Invalid transaction type. Must be 'debit' or 'credit'")
+	}
+	amount, err := moneyFromProto(request.Amount)
+	if err != nil {
+		return "", models.Money{}, status.Error(codes.InvalidArgument, "Invalid amount: "+err.Error())
+	}
+	if !amount.IsPositive() {
+		return "", models.Money{}, status.Error(codes.InvalidArgument, "Transaction amount must be positive")
+	}
+	return models.TransactionType(request.Type), amount, nil
+}
+
+func parseGRPCObjectID(id string, invalid string) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, status.Error(codes.InvalidArgument, invalid)
+	}
+	return objectID, nil
+}
+
+// Helper function to map service errors to gRPC status codes. notFound is the message for a missing
+// document and failure the message for unexpected errors, which are not passed on to the caller.
+func grpcError(err error, notFound string, failure string) error {
+	if limitErr, ok := err.(*services.LimitExceededError); ok {
+		return status.Error(codes.ResourceExhausted, limitErr.Error())
+	}
+	switch err {
+	case mongo.ErrNoDocuments:
+		return status.Error(codes.NotFound, notFound)
+	case services.ErrAccountNotFound, services.ErrHoldNotFound, services.ErrTransactionNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case services.ErrWalletNotOwned:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case services.ErrInsufficientFunds, services.ErrHoldNotActive, services.ErrCashWithdrawalNotAllowed,
+		services.ErrHoldsNotAllowed, services.ErrSystemTransfersOnly:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case services.ErrCaptureExceedsHold, services.ErrHoldExpiryInPast, services.ErrInvalidCursor, models.ErrCurrencyMismatch,
+		services.ErrBalanceBeforeCreation, services.ErrInvalidWalletType, services.ErrCreditLimitNotAllowed:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case services.ErrConcurrentUpdate:
+		return status.Error(codes.Aborted, err.Error())
+	case services.ErrHouseWalletNotConfigured:
+		return status.Error(codes.Unavailable, err.Error())
+	case context.Canceled, context.DeadlineExceeded:
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, failure)
+}
+
+// Helper function to read a protobuf amount. A missing amount is zero; amounts cannot be negative.
+func moneyFromProto(amount *walletpb.Money) (models.Money, error) {
+	if amount == nil {
+		return models.NewMoney(0, models.DefaultCurrency), nil
+	}
+	if amount.Units < 0 {
+		return models.Money{}, models.ErrNegativeAmount
+	}
+	currency := strings.ToUpper(strings.TrimSpace(amount.Currency))
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	return models.NewMoney(amount.Units, currency), nil
+}
+
+func moneyToProto(amount models.Money) *walletpb.Money {
+	return &walletpb.Money{Units: amount.Units, Currency: amount.CurrencyCode()}
+}
+
+// Helper function to read an optional as_of time, which defaults to now
+func asOfFromProto(asOf *timestamppb.Timestamp) time.Time {
+	if asOf == nil {
+		return time.Now()
+	}
+	return asOf.AsTime()
+}
+
+// Helper function to convert a time, leaving unset times empty
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// Helper function to convert an ID, leaving unset IDs empty
+func objectIDToProto(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
+
+func accountToProto(account models.Account) *walletpb.Account {
+	return &walletpb.Account{
+		Id:             objectIDToProto(account.ID),
+		Email:          account.Email,
+		Type:           string(account.Type),
+		Balance:        moneyToProto(account.Balance),
+		HoldBalance:    moneyToProto(account.HoldBalance),
+		VirtualWallets: account.VirtualWallets,
+		CreatedAt:      timeToProto(account.CreatedAt),
+		DateModified:   timeToProto(account.DateModified),
+	}
+}
+
+func walletToProto(virtualWallet models.VirtualWallet) *walletpb.Wallet {
+	return &walletpb.Wallet{
+		Id:           objectIDToProto(virtualWallet.ID),
+		CustomerId:   virtualWallet.CustomerID,
+		WalletType:   string(virtualWallet.WalletType),
+		CreditLimit:  moneyToProto(virtualWallet.CreditLimit),
+		Balance:      moneyToProto(virtualWallet.Balance),
+		HoldBalance:  moneyToProto(virtualWallet.HoldBalance),
+		DateCreated:  timeToProto(virtualWallet.DateCreated),
+		DateModified: timeToProto(virtualWallet.DateModified),
+	}
+}
+
+func transactionToProto(transaction models.Transaction) *walletpb.Transaction {
+	return &walletpb.Transaction{
+		Id:                  objectIDToProto(transaction.ID),
+		WalletId:            objectIDToProto(transaction.WalletID),
+		AccountId:           objectIDToProto(transaction.AccountID),
+		Type:                string(transaction.Type),
+		Amount:              moneyToProto(transaction.Amount),
+		Reference:           transaction.Reference,
+		TransferId:          objectIDToProto(transaction.TransferID),
+		HoldId:              objectIDToProto(transaction.HoldID),
+		LinkedTransactionId: objectIDToProto(transaction.LinkedID),
+		Status:              string(transaction.Status),
+		RefundedAmount:      moneyToProto(transaction.RefundedAmount),
+		ReasonCode:          string(transaction.ReasonCode),
+		CreatedAt:           timeToProto(transaction.CreatedAt),
+	}
+}
+
+func holdToProto(hold models.AuthorizationHold) *walletpb.Hold {
+	return &walletpb.Hold{
+		Id:             objectIDToProto(hold.ID),
+		AccountId:      objectIDToProto(hold.AccountID),
+		Amount:         moneyToProto(hold.Amount),
+		CapturedAmount: moneyToProto(hold.CapturedAmount),
+		Reference:      hold.Reference,
+		Status:         string(hold.Status),
+		ExpiresAt:      timeToProto(hold.ExpiresAt),
+		CreatedAt:      timeToProto(hold.CreatedAt),
+		DateModified:   timeToProto(hold.DateModified),
+	}
+}
+
+func balanceToProto(balance models.BalanceSnapshot) *walletpb.Balance {
+	return &walletpb.Balance{
+		WalletId:    objectIDToProto(balance.WalletID),
+		AccountId:   objectIDToProto(balance.AccountID),
+		Balance:     moneyToProto(balance.Balance),
+		HoldBalance: moneyToProto(balance.HoldBalance),
+		AsOf:        timeToProto(balance.TakenAt),
+	}
+}
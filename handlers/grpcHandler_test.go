@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"mfus_WalletTransactionManager/walletpb"
+	"net"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// transactionStream collects the transactions sent by a streaming RPC
+type transactionStream struct {
+	grpc.ServerStream
+	ctx          context.Context
+	transactions []*walletpb.Transaction
+}
+
+func (s *transactionStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *transactionStream) Send(transaction *walletpb.Transaction) error {
+	s.transactions = append(s.transactions, transaction)
+	return nil
+}
+
+// Helper function to build the incoming context of a call made by an account
+func callerContext(accountID string) context.Context {
+	if accountID == "" {
+		return context.Background()
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(AccountIDMetadata, accountID))
+}
+
+func TestGRPCServiceToken(t *testing.T) {
+	SetGRPCServiceToken("s3cret")
+	defer SetGRPCServiceToken("")
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(nil)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	connection, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+	client := walletpb.NewWalletManagerClient(connection)
+
+	tests := []struct {
+		name  string
+		token string
+		want  codes.Code
+	}{
+		{name: "missing token", want: codes.Unauthenticated},
+		{name: "wrong token", token: "guess", want: codes.PermissionDenied},
+		// The call gets past the interceptor and fails on its own arguments
+		{name: "service", token: "s3cret", want: codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx = metadata.AppendToOutgoingContext(ctx, AccountIDMetadata, primitive.NewObjectID().Hex())
+		if test.token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, ServiceTokenMetadata, test.token)
+		}
+		_, err := client.GetAccount(ctx, &walletpb.GetAccountRequest{AccountId: "not-an-id"})
+		if code := status.Code(err); code != test.want {
+			t.Errorf("%s: GetAccount code = %v, want %v", test.name, code, test.want)
+		}
+
+		stream, err := client.ListWalletTransactions(ctx, &walletpb.ListTransactionsRequest{Id: "not-an-id"})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		if code := status.Code(err); code != test.want {
+			t.Errorf("%s: ListWalletTransactions code = %v, want %v", test.name, code, test.want)
+		}
+		cancel()
+	}
+
+	// Without a configured token every call is refused
+	SetGRPCServiceToken("")
+	ctx := metadata.AppendToOutgoingContext(context.Background(), ServiceTokenMetadata, "s3cret")
+	if _, err := client.GetAccount(ctx, &walletpb.GetAccountRequest{AccountId: "not-an-id"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("call without a configured token returned %v, want PermissionDenied", err)
+	}
+}
+
+func TestGRPCAccountAuthorization(t *testing.T) {
+	server := &WalletManagerServer{}
+	accountID := primitive.NewObjectID().Hex()
+	holdID := primitive.NewObjectID().Hex()
+	amount := &walletpb.Money{Units: 100}
+
+	calls := map[string]func(ctx context.Context) error{
+		"GetAccount": func(ctx context.Context) error {
+			_, err := server.GetAccount(ctx, &walletpb.GetAccountRequest{AccountId: accountID})
+			return err
+		},
+		"GetAccountBalance": func(ctx context.Context) error {
+			_, err := server.GetAccountBalance(ctx, &walletpb.GetBalanceRequest{Id: accountID})
+			return err
+		},
+		"CreateAccountTransaction": func(ctx context.Context) error {
+			_, err := server.CreateAccountTransaction(ctx, &walletpb.CreateTransactionRequest{Id: accountID, Type: "credit", Amount: amount})
+			return err
+		},
+		"ListAccountTransactions": func(ctx context.Context) error {
+			return server.ListAccountTransactions(&walletpb.ListTransactionsRequest{Id: accountID}, &transactionStream{ctx: ctx})
+		},
+		"ListAccountWallets": func(ctx context.Context) error {
+			_, err := server.ListAccountWallets(ctx, &walletpb.ListAccountWalletsRequest{AccountId: accountID})
+			return err
+		},
+		"CreateWallet": func(ctx context.Context) error {
+			_, err := server.CreateWallet(ctx, &walletpb.CreateWalletRequest{CustomerId: accountID, Balance: amount})
+			return err
+		},
+		"CreateHold": func(ctx context.Context) error {
+			_, err := server.CreateHold(ctx, &walletpb.CreateHoldRequest{AccountId: accountID, Amount: amount})
+			return err
+		},
+		"ReleaseAccountHold": func(ctx context.Context) error {
+			_, err := server.ReleaseAccountHold(ctx, &walletpb.ReleaseAccountHoldRequest{AccountId: accountID, HoldId: holdID})
+			return err
+		},
+		"GetCustomerBalance": func(ctx context.Context) error {
+			_, err := server.GetCustomerBalance(ctx, &walletpb.GetCustomerBalanceRequest{CustomerId: accountID})
+			return err
+		},
+	}
+	for name, call := range calls {
+		if code := status.Code(call(callerContext(""))); code != codes.Unauthenticated {
+			t.Errorf("%s without metadata: code = %v, want Unauthenticated", name, code)
+		}
+		if code := status.Code(call(callerContext(primitive.NewObjectID().Hex()))); code != codes.PermissionDenied {
+			t.Errorf("%s by another account: code = %v, want PermissionDenied", name, code)
+		}
+	}
+}
+
+func TestGRPCSetWalletBalanceRequiresAdmin(t *testing.T) {
+	SetAdminToken("s3cret")
+	defer SetAdminToken("")
+	server := &WalletManagerServer{}
+	request := &walletpb.SetWalletBalanceRequest{WalletId: primitive.NewObjectID().Hex(), Balance: &walletpb.Money{Units: 100000}}
+
+	// The wallet's owner is not enough
+	owner := callerContext(primitive.NewObjectID().Hex())
+	if _, err := server.SetWalletBalance(owner, request); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("SetWalletBalance without the admin token returned %v, want Unauthenticated", err)
+	}
+	wrong := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AdminTokenMetadata, "guess"))
+	if _, err := server.SetWalletBalance(wrong, request); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("SetWalletBalance with a wrong admin token returned %v, want PermissionDenied", err)
+	}
+}
+
+func TestGRPCCreateWalletAdminFields(t *testing.T) {
+	server := &WalletManagerServer{}
+	accountID := primitive.NewObjectID().Hex()
+	request := &walletpb.CreateWalletRequest{CustomerId: accountID, Balance: &walletpb.Money{Units: 10000}}
+	if _, err := server.CreateWallet(callerContext(accountID), request); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("CreateWallet with an opening balance returned %v, want PermissionDenied", err)
+	}
+	request = &walletpb.CreateWalletRequest{CustomerId: accountID, WalletType: string(models.CreditWallet), CreditLimit: &walletpb.Money{Units: 500000}}
+	if _, err := server.CreateWallet(callerContext(accountID), request); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("CreateWallet with a credit limit returned %v, want PermissionDenied", err)
+	}
+}
+
+func TestGRPCHoldAuthorization(t *testing.T) {
+	server := &WalletManagerServer{}
+	holdID := primitive.NewObjectID().Hex()
+	calls := map[string]func(ctx context.Context) error{
+		"GetHold": func(ctx context.Context) error {
+			_, err := server.GetHold(ctx, &walletpb.GetHoldRequest{HoldId: holdID})
+			return err
+		},
+		"CaptureHold": func(ctx context.Context) error {
+			_, err := server.CaptureHold(ctx, &walletpb.CaptureHoldRequest{HoldId: holdID})
+			return err
+		},
+		"VoidHold": func(ctx context.Context) error {
+			_, err := server.VoidHold(ctx, &walletpb.VoidHoldRequest{HoldId: holdID})
+			return err
+		},
+	}
+	for name, call := range calls {
+		if code := status.Code(call(callerContext(""))); code != codes.Unauthenticated {
+			t.Errorf("%s without metadata: code = %v, want Unauthenticated", name, code)
+		}
+	}
+}
+
+func TestGRPCHoldOwnership(t *testing.T) {
+	client := testMongoClient(t)
+	server := &WalletManagerServer{client: client}
+	accountID, _ := createTestWallet(t, client, "0.00")
+	if _, err := services.CreateAccountTransaction(client, accountID, models.Credit, models.MustParseMoney("100.00")); err != nil {
+		t.Fatal(err)
+	}
+	hold, err := services.CreateHold(client, accountID, models.MustParseMoney("40.00"), "order-1", time.Time{})
+	if err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+
+	other := callerContext(primitive.NewObjectID().Hex())
+	if _, err := server.GetHold(other, &walletpb.GetHoldRequest{HoldId: hold.ID.Hex()}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("GetHold by another account returned %v, want PermissionDenied", err)
+	}
+	if _, err := server.VoidHold(other, &walletpb.VoidHoldRequest{HoldId: hold.ID.Hex()}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("VoidHold by another account returned %v, want PermissionDenied", err)
+	}
+
+	owner := callerContext(accountID.Hex())
+	captured, err := server.CaptureHold(owner, &walletpb.CaptureHoldRequest{HoldId: hold.ID.Hex(), Amount: &walletpb.Money{Units: 1500}})
+	if err != nil {
+		t.Fatalf("CaptureHold by the owner: %v", err)
+	}
+	if captured.CapturedAmount.Units != 1500 {
+		t.Errorf("captured = %v, want 15.00", captured.CapturedAmount)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Handler for retrieving an authorization hold by ID
+func GetHoldHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse hold ID from URL parameter
+		vars := mux.Vars(r)
+		holdID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid hold ID")
+			return
+		}
+
+		hold, err := services.FindHold(client, holdID)
+		if err != nil {
+			writeHoldError(w, r, err)
+			return
+		}
+
+		// Return success response with hold information
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Hold found",
+			Data:    hold,
+		})
+	}
+}
+
+// Handler for capturing an authorization hold in full or in part
+func CaptureHoldHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse hold ID from URL parameter
+		vars := mux.Vars(r)
+		holdID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid hold ID")
+			return
+		}
+
+		// Parse request body; an empty body captures the full hold
+		var request models.CaptureHoldRequest
+		if r.ContentLength != 0 && !decodeRequest(w, r, &request) {
+			return
+		}
+
+		hold, err := services.CaptureHold(client, holdID, request.Amount)
+		if err != nil {
+			writeHoldError(w, r, err)
+			return
+		}
+
+		// Return success response with the captured hold
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Hold captured successfully",
+			Data:    hold,
+		})
+	}
+}
+
+// Handler for voiding an authorization hold and releasing its funds
+func VoidHoldHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse hold ID from URL parameter
+		vars := mux.Vars(r)
+		holdID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid hold ID")
+			return
+		}
+
+		hold, err := services.VoidHold(client, holdID)
+		if err != nil {
+			writeHoldError(w, r, err)
+			return
+		}
+
+		// Return success response with the voided hold
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Hold voided successfully",
+			Data:    hold,
+		})
+	}
+}
+
+// Helper function to map hold service errors to HTTP responses
+func writeHoldError(w http.ResponseWriter, r *http.Request, err error) {
+	if writeLimitError(w, r, err) {
+		return
+	}
+	switch err {
+	case services.ErrHoldNotFound, services.ErrAccountNotFound:
+		writeError(w, r, http.StatusNotFound, err.Error())
+	case services.ErrHoldNotActive, services.ErrConcurrentUpdate:
+		writeError(w, r, http.StatusConflict, err.Error())
+	case services.ErrInsufficientFunds, services.ErrCaptureExceedsHold, services.ErrHoldExpiryInPast, models.ErrCurrencyMismatch:
+		writeError(w, r, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, r, http.StatusInternalServerError, "Failed to process hold")
+	}
+}
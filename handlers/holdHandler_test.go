@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHoldOwnershipMiddlewareRejectsBadRequests(t *testing.T) {
+	router := mux.NewRouter()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	router.Handle("/holds/{id}", HoldOwnershipMiddleware(nil, ok))
+
+	tests := []struct {
+		name   string
+		holdID string
+		caller string
+		want   int
+	}{
+		{name: "missing caller", holdID: primitive.NewObjectID().Hex(), want: http.StatusUnauthorized},
+		{name: "invalid hold ID", holdID: "not-an-id", caller: primitive.NewObjectID().Hex(), want: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("GET", "/holds/"+test.holdID, nil)
+		if test.caller != "" {
+			request.Header.Set(AccountIDHeader, test.caller)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.want)
+		}
+	}
+}
+
+func TestHoldOwnershipMiddlewareChecksAccount(t *testing.T) {
+	client := testMongoClient(t)
+	ownerID, _ := createTestWallet(t, client, "0.00")
+	if _, err := services.CreateAccountTransaction(client, ownerID, models.Credit, models.MustParseMoney("50.00")); err != nil {
+		t.Fatalf("Failed to fund account: %v", err)
+	}
+	hold, err := services.CreateHold(client, ownerID, models.MustParseMoney("20.00"), "order-1", time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to create hold: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.Handle("/holds/{id}", HoldOwnershipMiddleware(client, GetHoldHandler(client))).Methods("GET")
+
+	tests := []struct {
+		name   string
+		holdID string
+		caller string
+		want   int
+	}{
+		{name: "owner", holdID: hold.ID.Hex(), caller: ownerID.Hex(), want: http.StatusOK},
+		{name: "other account", holdID: hold.ID.Hex(), caller: primitive.NewObjectID().Hex(), want: http.StatusForbidden},
+		{name: "unknown hold", holdID: primitive.NewObjectID().Hex(), caller: ownerID.Hex(), want: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("GET", "/holds/"+test.holdID, nil)
+		request.Header.Set(AccountIDHeader, test.caller)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"fmt"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Handler for downloading the ISO 20022 camt.054 debit/credit notification of a posting
+func GetTransactionNotificationHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse transaction ID from URL parameter
+		vars := mux.Vars(r)
+		transactionID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid transaction ID")
+			return
+		}
+
+		document, err := services.TransactionNotification(client, transactionID)
+		if err != nil {
+			switch err {
+			case services.ErrTransactionNotFound:
+				writeError(w, r, http.StatusNotFound, err.Error())
+			case services.ErrNoBalanceEffect:
+				writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+			default:
+				writeError(w, r, http.StatusInternalServerError, "Failed to build notification")
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "camt054-"+transactionID.Hex()+".xml"))
+		w.WriteHeader(http.StatusOK)
+		writeCamt(w, document)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTransactionOwnershipMiddlewareRejectsBadRequests(t *testing.T) {
+	router := mux.NewRouter()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	router.Handle("/transactions/{id}/camt054", TransactionOwnershipMiddleware(nil, ok))
+
+	tests := []struct {
+		name          string
+		transactionID string
+		caller        string
+		want          int
+	}{
+		{name: "missing caller", transactionID: primitive.NewObjectID().Hex(), want: http.StatusUnauthorized},
+		{name: "invalid transaction ID", transactionID: "not-an-id", caller: primitive.NewObjectID().Hex(), want: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("GET", "/transactions/"+test.transactionID+"/camt054", nil)
+		if test.caller != "" {
+			request.Header.Set(AccountIDHeader, test.caller)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.want)
+		}
+	}
+}
+
+func TestTransactionNotificationOwnership(t *testing.T) {
+	client := testMongoClient(t)
+	accountID, _ := createTestWallet(t, client, "0.00")
+	credit, err := services.CreateAccountTransaction(client, accountID, models.Credit, models.MustParseMoney("20.00"))
+	if err != nil {
+		t.Fatalf("Failed to credit account: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.Handle("/transactions/{id}/camt054", TransactionOwnershipMiddleware(client, GetTransactionNotificationHandler(client))).Methods("GET")
+
+	tests := []struct {
+		name          string
+		transactionID string
+		caller        string
+		want          int
+	}{
+		{name: "owner", transactionID: credit.ID.Hex(), caller: accountID.Hex(), want: http.StatusOK},
+		{name: "other account", transactionID: credit.ID.Hex(), caller: primitive.NewObjectID().Hex(), want: http.StatusForbidden},
+		{name: "unknown transaction", transactionID: primitive.NewObjectID().Hex(), caller: accountID.Hex(), want: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("GET", "/transactions/"+test.transactionID+"/camt054", nil)
+		request.Header.Set(AccountIDHeader, test.caller)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.want)
+		}
+	}
+}
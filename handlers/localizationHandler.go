@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/hi"
+	ut "github.com/go-playground/universal-translator"
+)
+
+// localizedMessage is one entry of a message catalog. Code is the stable, machine-readable name of the
+// message; the English text is also what the handlers pass in, so it doubles as the lookup key.
+type localizedMessage struct {
+	Code    string
+	English string
+	Hindi   string
+	Spanish string
+}
+
+// Error messages sent in ErrorResponse.Message, keyed in the response by their code
+var errorMessages = []localizedMessage{
+	// Malformed requests
+	{"invalid_request_body", "Invalid request body", "अनुरोध का मुख्य भाग अमान्य है", "Cuerpo de la solicitud no válido"},
+	{"validation_failed", "Request validation failed", "अनुरोध का सत्यापन विफल रहा", "La validación de la solicitud falló"},
+	{"invalid_content_type", "Invalid Content-Type. Expected application/json", "अमान्य Content-Type। application/json अपेक्षित है", "Content-Type no válido. Se esperaba application/json"},
+	{"read_body_failed", "Failed to read request body", "अनुरोध का मुख्य भाग पढ़ने में विफल", "Error al leer el cuerpo de la solicitud"},
+	{"request_body_too_large", "Request body is too large", "अनुरोध का मुख्य भाग बहुत बड़ा है", "El cuerpo de la solicitud es demasiado grande"},
+	{"invalid_account_id", "Invalid account ID", "अमान्य खाता ID", "ID de cuenta no válido"},
+	{"invalid_virtual_wallet_id", "Invalid virtual wallet ID", "अमान्य वर्चुअल वॉलेट ID", "ID de monedero virtual no válido"},
+	{"invalid_source_wallet_id", "Invalid source wallet ID", "अमान्य स्रोत वॉलेट ID", "ID de monedero de origen no válido"},
+	{"invalid_destination_wallet_id", "Invalid destination wallet ID", "अमान्य गंतव्य वॉलेट ID", "ID de monedero de destino no válido"},
+	{"invalid_customer_id", "Invalid customer ID", "अमान्य ग्राहक ID", "ID de cliente no válido"},
+	{"invalid_hold_id", "Invalid hold ID", "अमान्य होल्ड ID", "ID de retención no válido"},
+	{"invalid_transaction_id", "Invalid transaction ID", "अमान्य लेनदेन ID", "ID de transacción no válido"},
+	{"invalid_batch_id", "Invalid batch ID", "अमान्य बैच ID", "ID de lote no válido"},
+	{"invalid_webhook_id", "Invalid webhook ID", "अमान्य वेबहुक ID", "ID de webhook no válido"},
+	{"invalid_delivery_id", "Invalid delivery ID", "अमान्य डिलीवरी ID", "ID de entrega no válido"},
+	{"invalid_limit", "Invalid limit. Must be a positive integer", "limit का मान अमान्य है। यह एक धनात्मक पूर्णांक होना चाहिए", "Valor de limit no válido. Debe ser un entero positivo"},
+	{"invalid_order", "Invalid order. Must be 'asc' or 'desc'", "order का मान अमान्य है। यह 'asc' या 'desc' होना चाहिए", "Valor de order no válido. Debe ser 'asc' o 'desc'"},
+	{"invalid_min_amount", "Invalid min_amount", "min_amount अमान्य है", "min_amount no válido"},
+	{"invalid_max_amount", "Invalid max_amount", "max_amount अमान्य है", "max_amount no válido"},
+	{"invalid_start_date", "Invalid start date", "अमान्य प्रारंभ तिथि", "Fecha de inicio no válida"},
+	{"invalid_end_date", "Invalid end date", "अमान्य अंतिम तिथि", "Fecha de fin no válida"},
+	{"invalid_from_date", "Invalid from date", "from तिथि अमान्य है", "Fecha from no válida"},
+	{"invalid_to_date", "Invalid to date", "to तिथि अमान्य है", "Fecha to no válida"},
+	{"invalid_date_range", "from must be before to", "from, to से पहले होना चाहिए", "from debe ser anterior a to"},
+	{"invalid_as_of", "Invalid as_of, expected an RFC 3339 time", "as_of अमान्य है, RFC 3339 समय अपेक्षित है", "as_of no válido, se esperaba una hora RFC 3339"},
+	{"invalid_status", "Invalid status. Must be 'pending', 'delivered' or 'dead'", "status अमान्य है। यह 'pending', 'delivered' या 'dead' होना चाहिए", "Valor de status no válido. Debe ser 'pending', 'delivered' o 'dead'"},
+	{"invalid_statement_format", "Invalid format. Must be 'csv', 'ofx', 'camt053' or 'json'", "अमान्य फ़ॉर्मेट। यह 'csv', 'ofx', 'camt053' या 'json' होना चाहिए", "Formato no válido. Debe ser 'csv', 'ofx', 'camt053' o 'json'"},
+	{"invalid_cursor", services.ErrInvalidCursor.Error(), "अमान्य cursor", "Cursor no válido"},
+	{"invalid_csv_file", "Invalid CSV file", "अमान्य CSV फ़ाइल", "Archivo CSV no válido"},
+	{"missing_file_field", "Missing file field", "file फ़ील्ड नहीं है", "Falta el campo file"},
+	{"wallet_or_account_required", "Either wallet_id or account_id is required", "wallet_id या account_id में से एक आवश्यक है", "Se requiere wallet_id o account_id"},
+	{"amount_not_positive", "Transaction amount must be positive", "लेनदेन राशि धनात्मक होनी चाहिए", "El importe de la transacción debe ser positivo"},
+	{"invalid_last_event_id", services.ErrInvalidLastEventID.Error(), "अमान्य Last-Event-ID", "Last-Event-ID no válido"},
+	{"invalid_batch_format", services.ErrInvalidBatchFormat.Error(), "अमान्य बैच फ़ॉर्मेट। यह 'csv' या 'ndjson' होना चाहिए", "Formato de lote no válido. Debe ser 'csv' o 'ndjson'"},
+	{"invalid_batch_mode", services.ErrInvalidBatchMode.Error(), "अमान्य बैच मोड। यह 'per_row' या 'all_or_nothing' होना चाहिए", "Modo de lote no válido. Debe ser 'per_row' o 'all_or_nothing'"},
+	{"batch_empty", services.ErrBatchEmpty.Error(), "बैच फ़ाइल में कोई पंक्ति नहीं है", "El archivo de lote no contiene filas"},
+	{"batch_too_large", services.ErrBatchTooLarge.Error(), "बैच फ़ाइल में बहुत अधिक पंक्तियाँ हैं", "El archivo de lote contiene demasiadas filas"},
+	{"invalid_reason_code", services.ErrInvalidReasonCode.Error(), "अमान्य कारण कोड", "Código de motivo no válido"},
+	{"invalid_wallet_type", services.ErrInvalidWalletType.Error(), "अमान्य वॉलेट प्रकार", "Tipo de monedero no válido"},
+	{"invalid_webhook_url", services.ErrInvalidWebhookURL.Error(), "वेबहुक URL एक पूर्ण http या https URL होना चाहिए", "La URL del webhook debe ser una URL http o https absoluta"},
+	{"webhook_host_not_allowed", services.ErrWebhookHostNotAllowed.Error(), "वेबहुक URL केवल सार्वजनिक IP पतों पर रिज़ॉल्व होना चाहिए", "La URL del webhook solo debe resolverse en direcciones IP públicas"},
+	{"invalid_event_type", services.ErrInvalidEventType.Error(), "अमान्य इवेंट प्रकार", "Tipo de evento no válido"},
+	{"invalid_fee_schedule", services.ErrInvalidFeeSchedule.Error(), "अमान्य शुल्क अनुसूची", "Tarifa de comisiones no válida"},
+	{"open_ended_tier_required", services.ErrOpenEndedTierRequired.Error(), "स्तरीय शुल्क अनुसूची में ठीक एक स्तर up_to के बिना होना चाहिए", "Una tarifa por tramos necesita exactamente un tramo sin up_to"},
+
+	// Invalid amounts, usually the cause after a "prefix: " message
+	{"invalid_amount", models.ErrInvalidAmount.Error(), "अमान्य राशि", "importe no válido"},
+	{"negative_amount", models.ErrNegativeAmount.Error(), "राशि ऋणात्मक नहीं हो सकती", "el importe no puede ser negativo"},
+	{"amount_precision", models.ErrAmountPrecision.Error(), "राशि में मुद्रा की अनुमति से अधिक दशमलव स्थान हैं", "el importe tiene más decimales de los que permite la moneda"},
+	{"amount_overflow", models.ErrAmountOverflow.Error(), "राशि सीमा से बाहर है", "el importe está fuera de rango"},
+	{"currency_mismatch", models.ErrCurrencyMismatch.Error(), "मुद्रा मेल नहीं खाती", "las monedas no coinciden"},
+
+	// Authentication and authorization
+	{"missing_account_id_header", "Missing " + AccountIDHeader + " header", AccountIDHeader + " हेडर नहीं है", "Falta la cabecera " + AccountIDHeader},
+	{"account_access_denied", "Access to this account is not allowed", "इस खाते तक पहुँच की अनुमति नहीं है", "No se permite el acceso a esta cuenta"},
+	{"missing_admin_token_header", "Missing " + AdminTokenHeader + " header", AdminTokenHeader + " हेडर नहीं है", "Falta la cabecera " + AdminTokenHeader},
+	{"admin_access_required", "Admin access required", "एडमिन पहुँच आवश्यक है", "Se requiere acceso de administrador"},
+	{"opening_balance_admin_only", "Opening balance requires admin access", "प्रारंभिक शेष राशि के लिए एडमिन पहुँच आवश्यक है", "El saldo inicial requiere acceso de administrador"},
+	{"credit_limit_admin_only", "Credit limit requires admin access", "क्रेडिट सीमा के लिए एडमिन पहुँच आवश्यक है", "El límite de crédito requiere acceso de administrador"},
+	{"hold_access_denied", "Access to this hold is not allowed", "इस होल्ड तक पहुँच की अनुमति नहीं है", "No se permite el acceso a esta retención"},
+	{"transaction_access_denied", "Access to this transaction is not allowed", "इस लेनदेन तक पहुँच की अनुमति नहीं है", "No se permite el acceso a esta transacción"},
+	{"refund_not_recipient", services.ErrRefundNotRecipient.Error(), "केवल वही खाता इस लेनदेन का रिफ़ंड कर सकता है जिसे धनराशि मिली थी", "Solo la cuenta que recibió los fondos puede reembolsar esta transacción"},
+	{"wallet_not_owned", services.ErrWalletNotOwned.Error(), "वर्चुअल वॉलेट इस खाते का नहीं है", "El monedero virtual no pertenece a esta cuenta"},
+
+	// Missing resources
+	{"account_not_found", "Account not found", "खाता नहीं मिला", "Cuenta no encontrada"},
+	{"virtual_wallet_not_found", "Virtual wallet not found", "वर्चुअल वॉलेट नहीं मिला", "Monedero virtual no encontrado"},
+	{"source_wallet_not_found", services.ErrSourceWalletNotFound.Error(), "स्रोत वॉलेट नहीं मिला", "Monedero de origen no encontrado"},
+	{"destination_wallet_not_found", services.ErrDestinationWalletNotFound.Error(), "गंतव्य वॉलेट नहीं मिला", "Monedero de destino no encontrado"},
+	{"transaction_not_found", services.ErrTransactionNotFound.Error(), "लेनदेन नहीं मिला", "Transacción no encontrada"},
+	{"transaction_owner_not_found", "Owner of the transaction not found", "लेनदेन का स्वामी नहीं मिला", "No se encontró el propietario de la transacción"},
+	{"hold_not_found", services.ErrHoldNotFound.Error(), "होल्ड नहीं मिला", "Retención no encontrada"},
+	{"batch_not_found", services.ErrBatchNotFound.Error(), "बैच नहीं मिला", "Lote no encontrado"},
+	{"webhook_not_found", services.ErrWebhookNotFound.Error(), "वेबहुक सदस्यता नहीं मिली", "Suscripción de webhook no encontrada"},
+	{"webhook_delivery_not_found", services.ErrWebhookDeliveryNotFound.Error(), "वेबहुक डिलीवरी नहीं मिली", "Entrega de webhook no encontrada"},
+
+	// Rejected operations
+	{"account_exists", "Account already exist", "खाता पहले से मौजूद है", "La cuenta ya existe"},
+	{"insufficient_balance", "Insufficient balance", "अपर्याप्त शेष राशि", "Saldo insuficiente"},
+	{"insufficient_funds", services.ErrInsufficientFunds.Error(), "अपर्याप्त धनराशि", "Fondos insuficientes"},
+	{"concurrent_update", services.ErrConcurrentUpdate.Error(), "वॉलेट को एक समवर्ती अनुरोध ने बदल दिया है, कृपया पुनः प्रयास करें", "Una solicitud simultánea modificó el monedero, inténtelo de nuevo"},
+	{"limit_exceeded", "Transaction exceeds the {0} limit", "लेनदेन {0} सीमा से अधिक है", "La transacción supera el límite {0}"},
+	{"wallet_owner_immutable", "Virtual wallet owner cannot be changed", "वर्चुअल वॉलेट का स्वामी बदला नहीं जा सकता", "No se puede cambiar el propietario del monedero virtual"},
+	{"credit_limit_not_allowed", services.ErrCreditLimitNotAllowed.Error(), "केवल CreditWallet की क्रेडिट सीमा हो सकती है", "Solo un CreditWallet puede tener un límite de crédito"},
+	{"cash_withdrawal_not_allowed", services.ErrCashWithdrawalNotAllowed.Error(), "इस प्रकार के वॉलेट से नकद निकासी नहीं की जा सकती", "Este tipo de monedero no permite retiros en efectivo"},
+	{"holds_not_allowed", services.ErrHoldsNotAllowed.Error(), "इस प्रकार का वॉलेट होल्ड का समर्थन नहीं करता", "Este tipo de monedero no admite retenciones"},
+	{"system_transfers_only", services.ErrSystemTransfersOnly.Error(), "इस प्रकार का वॉलेट केवल सिस्टम ट्रांसफ़र स्वीकार करता है", "Este tipo de monedero solo acepta transferencias del sistema"},
+	{"same_wallet", services.ErrSameWallet.Error(), "स्रोत और गंतव्य वॉलेट अलग-अलग होने चाहिए", "Los monederos de origen y destino deben ser distintos"},
+	{"hold_not_active", services.ErrHoldNotActive.Error(), "होल्ड अब सक्रिय नहीं है", "La retención ya no está activa"},
+	{"hold_expiry_in_past", services.ErrHoldExpiryInPast.Error(), "होल्ड की समाप्ति भविष्य में होनी चाहिए", "El vencimiento de la retención debe estar en el futuro"},
+	{"capture_exceeds_hold", services.ErrCaptureExceedsHold.Error(), "कैप्चर राशि रोकी गई राशि से अधिक है", "El importe a capturar supera el importe retenido"},
+	{"transaction_not_reversible", services.ErrTransactionNotReversible.Error(), "केवल deposit, withdraw, credit और debit लेनदेन ही उलटे या रिफ़ंड किए जा सकते हैं", "Solo las transacciones deposit, withdraw, credit y debit se pueden revertir o reembolsar"},
+	{"already_reversed", services.ErrAlreadyReversed.Error(), "लेनदेन पहले ही उलटा या पूरी तरह रिफ़ंड किया जा चुका है", "La transacción ya fue revertida o reembolsada por completo"},
+	{"reversal_after_refund", services.ErrReversalAfterRefund.Error(), "लेनदेन आंशिक रूप से रिफ़ंड हो चुका है और अब उलटा नहीं किया जा सकता", "La transacción fue reembolsada parcialmente y ya no se puede revertir"},
+	{"refund_exceeds_original", services.ErrRefundExceedsOriginal.Error(), "रिफ़ंड राशि शेष रिफ़ंड योग्य राशि से अधिक है", "El importe del reembolso supera el importe reembolsable restante"},
+	{"no_balance_effect", services.ErrNoBalanceEffect.Error(), "लेनदेन से शेष राशि नहीं बदलती", "La transacción no modifica el saldo"},
+	{"balance_before_creation", services.ErrBalanceBeforeCreation.Error(), "अनुरोधित समय वॉलेट या खाता बनने से पहले का है", "La hora solicitada es anterior a la creación del monedero o la cuenta"},
+	{"stream_history_lost", services.ErrStreamHistoryLost.Error(), "Last-Event-ID फिर से शुरू करने के लिए बहुत पुराना है, इसके बिना फिर से कनेक्ट करें", "Last-Event-ID es demasiado antiguo para reanudar, vuelva a conectarse sin él"},
+	{"webhook_delivery_pending", services.ErrWebhookDeliveryNotFinished.Error(), "वेबहुक डिलीवरी अभी लंबित है", "La entrega del webhook aún está pendiente"},
+	{"idempotency_key_mismatch", "Idempotency key was already used with a different request", "यह Idempotency-Key पहले ही किसी अन्य अनुरोध के साथ उपयोग की जा चुकी है", "La clave de idempotencia ya se usó con una solicitud diferente"},
+	{"idempotency_key_in_progress", "A request with this idempotency key is still in progress", "इस Idempotency-Key वाला अनुरोध अभी प्रगति पर है", "Una solicitud con esta clave de idempotencia aún está en curso"},
+	{"streaming_not_supported", "Streaming is not supported", "स्ट्रीमिंग समर्थित नहीं है", "No se admite la transmisión"},
+
+	// Server failures
+	{"internal_error", "Internal Server Error", "आंतरिक सर्वर त्रुटि", "Error interno del servidor"},
+	{"house_wallet_not_configured", services.ErrHouseWalletNotConfigured.Error(), "हाउस राजस्व वॉलेट कॉन्फ़िगर नहीं है", "El monedero de ingresos de la casa no está configurado"},
+	{"retrieve_account_failed", "Failed to retrieve account", "खाता प्राप्त करने में विफल", "Error al obtener la cuenta"},
+	{"retrieve_account_limits_failed", "Failed to retrieve account limits", "खाता सीमाएँ प्राप्त करने में विफल", "Error al obtener los límites de la cuenta"},
+	{"retrieve_account_transactions_failed", "Failed to retrieve account transactions", "खाता लेनदेन प्राप्त करने में विफल", "Error al obtener las transacciones de la cuenta"},
+	{"retrieve_virtual_wallet_failed", "Failed to retrieve virtual wallet", "वर्चुअल वॉलेट प्राप्त करने में विफल", "Error al obtener el monedero virtual"},
+	{"retrieve_virtual_wallets_failed", "Failed to retrieve virtual wallets", "वर्चुअल वॉलेट प्राप्त करने में विफल", "Error al obtener los monederos virtuales"},
+	{"decode_virtual_wallets_failed", "Failed to decode virtual wallets", "वर्चुअल वॉलेट डिकोड करने में विफल", "Error al decodificar los monederos virtuales"},
+	{"retrieve_virtual_wallet_transactions_failed", "Failed to retrieve virtual wallet transactions", "वर्चुअल वॉलेट लेनदेन प्राप्त करने में विफल", "Error al obtener las transacciones del monedero virtual"},
+	{"retrieve_source_wallet_failed", "Failed to retrieve source wallet", "स्रोत वॉलेट प्राप्त करने में विफल", "Error al obtener el monedero de origen"},
+	{"retrieve_customer_balance_failed", "Failed to retrieve customer balance", "ग्राहक की शेष राशि प्राप्त करने में विफल", "Error al obtener el saldo del cliente"},
+	{"retrieve_batch_failed", "Failed to retrieve batch", "बैच प्राप्त करने में विफल", "Error al obtener el lote"},
+	{"retrieve_fee_schedules_failed", "Failed to retrieve fee schedules", "शुल्क अनुसूचियाँ प्राप्त करने में विफल", "Error al obtener las tarifas de comisiones"},
+	{"retrieve_reconciliation_reports_failed", "Failed to retrieve reconciliation reports", "मिलान रिपोर्ट प्राप्त करने में विफल", "Error al obtener los informes de conciliación"},
+	{"retrieve_webhooks_failed", "Failed to retrieve webhook subscriptions", "वेबहुक सदस्यताएँ प्राप्त करने में विफल", "Error al obtener las suscripciones de webhook"},
+	{"retrieve_webhook_deliveries_failed", "Failed to retrieve webhook deliveries", "वेबहुक डिलीवरी प्राप्त करने में विफल", "Error al obtener las entregas de webhook"},
+	{"retrieve_dead_letters_failed", "Failed to retrieve dead letters", "डेड लेटर प्राप्त करने में विफल", "Error al obtener las entregas fallidas"},
+	{"create_account_failed", "Failed to create account", "खाता बनाने में विफल", "Error al crear la cuenta"},
+	{"create_virtual_wallet_failed", "Failed to create virtual wallet", "वर्चुअल वॉलेट बनाने में विफल", "Error al crear el monedero virtual"},
+	{"create_fee_schedule_failed", "Failed to create fee schedule", "शुल्क अनुसूची बनाने में विफल", "Error al crear la tarifa de comisiones"},
+	{"create_webhook_failed", "Failed to create webhook subscription", "वेबहुक सदस्यता बनाने में विफल", "Error al crear la suscripción de webhook"},
+	{"update_account_failed", "Failed to update account", "खाता अपडेट करने में विफल", "Error al actualizar la cuenta"},
+	{"update_account_limits_failed", "Failed to update account limits", "खाता सीमाएँ अपडेट करने में विफल", "Error al actualizar los límites de la cuenta"},
+	{"update_virtual_wallet_failed", "Failed to update virtual wallet", "वर्चुअल वॉलेट अपडेट करने में विफल", "Error al actualizar el monedero virtual"},
+	{"delete_virtual_wallet_failed", "Failed to delete virtual wallet", "वर्चुअल वॉलेट हटाने में विफल", "Error al eliminar el monedero virtual"},
+	{"delete_webhook_failed", "Failed to delete webhook subscription", "वेबहुक सदस्यता हटाने में विफल", "Error al eliminar la suscripción de webhook"},
+	{"check_idempotency_key_failed", "Failed to check idempotency key", "Idempotency-Key जाँचने में विफल", "Error al comprobar la clave de idempotencia"},
+	{"compute_balance_failed", "Failed to compute balance", "शेष राशि की गणना करने में विफल", "Error al calcular el saldo"},
+	{"compute_fee_failed", "Failed to compute fee", "शुल्क की गणना करने में विफल", "Error al calcular la comisión"},
+	{"process_hold_failed", "Failed to process hold", "होल्ड संसाधित करने में विफल", "Error al procesar la retención"},
+	{"complete_transfer_failed", "Failed to complete transfer", "ट्रांसफ़र पूरा करने में विफल", "Error al completar la transferencia"},
+	{"post_compensating_transaction_failed", "Failed to post compensating transaction", "प्रतिपूरक लेनदेन दर्ज करने में विफल", "Error al registrar la transacción compensatoria"},
+	{"import_batch_failed", "Failed to import batch", "बैच आयात करने में विफल", "Error al importar el lote"},
+	{"build_error_report_failed", "Failed to build error report", "त्रुटि रिपोर्ट बनाने में विफल", "Error al generar el informe de errores"},
+	{"build_statement_failed", "Failed to build statement", "विवरण बनाने में विफल", "Error al generar el extracto"},
+	{"build_notification_failed", "Failed to build notification", "सूचना बनाने में विफल", "Error al generar la notificación"},
+	{"run_reconciliation_failed", "Failed to run reconciliation", "मिलान चलाने में विफल", "Error al ejecutar la conciliación"},
+	{"redeliver_webhook_failed", "Failed to redeliver webhook", "वेबहुक दोबारा भेजने में विफल", "Error al reenviar el webhook"},
+	{"open_event_stream_failed", "Failed to open event stream", "इवेंट स्ट्रीम खोलने में विफल", "Error al abrir el flujo de eventos"},
+}
+
+// Messages of the field errors in ErrorResponse.Details, keyed by the rule that failed
+var fieldMessages = []localizedMessage{
+	{"required", "is required", "आवश्यक है", "es obligatorio"},
+	{"not_empty", "must not be empty", "खाली नहीं होना चाहिए", "no debe estar vacío"},
+	{"json", "is not valid JSON", "मान्य JSON नहीं है", "no es un JSON válido"},
+	{"content_type", "must be application/json", "application/json होना चाहिए", "debe ser application/json"},
+	{"email", "must be a valid email address", "एक मान्य ईमेल पता होना चाहिए", "debe ser una dirección de correo electrónico válida"},
+	{"http_url", "must be an http or https URL", "एक http या https URL होना चाहिए", "debe ser una URL http o https"},
+	{"mongodb", "must be a 24 character hex ID", "24 अक्षरों का hex ID होना चाहिए", "debe ser un ID hexadecimal de 24 caracteres"},
+	{"positive", "must be positive", "धनात्मक होना चाहिए", "debe ser positivo"},
+	{"greater_than", "must be greater than {0}", "{0} से अधिक होना चाहिए", "debe ser mayor que {0}"},
+	{"non_negative", "cannot be negative", "ऋणात्मक नहीं हो सकता", "no puede ser negativo"},
+	{"at_least", "must be at least {0}", "कम से कम {0} होना चाहिए", "debe ser al menos {0}"},
+	{"one_of", "must be one of {0}", "इनमें से एक होना चाहिए: {0}", "debe ser uno de {0}"},
+	{"min_length", "must be at least {0} characters long", "कम से कम {0} अक्षरों का होना चाहिए", "debe tener al menos {0} caracteres"},
+	{"pattern", "must match the pattern {0}", "पैटर्न {0} से मेल खाना चाहिए", "debe coincidir con el patrón {0}"},
+	{"type", "must be of type {0}", "{0} प्रकार का होना चाहिए", "debe ser de tipo {0}"},
+	{"format", "must be a valid {0}", "एक मान्य {0} होना चाहिए", "debe ser un {0} válido"},
+	{"account_type", "must be a valid account type", "एक मान्य खाता प्रकार होना चाहिए", "debe ser un tipo de cuenta válido"},
+	{"wallet_type", "must be a valid wallet type", "एक मान्य वॉलेट प्रकार होना चाहिए", "debe ser un tipo de monedero válido"},
+	{"event_type", "must be a valid event type", "एक मान्य इवेंट प्रकार होना चाहिए", "debe ser un tipo de evento válido"},
+	{"iso4217", "must be an ISO 4217 currency code", "एक ISO 4217 मुद्रा कोड होना चाहिए", "debe ser un código de moneda ISO 4217"},
+	{"reason_code", "must be a valid reason code", "एक मान्य कारण कोड होना चाहिए", "debe ser un código de motivo válido"},
+	{"amount", `must be a decimal amount with an optional currency, e.g. "12.34 INR"`, `वैकल्पिक मुद्रा के साथ एक दशमलव राशि होनी चाहिए, जैसे "12.34 INR"`, `debe ser un importe decimal con una moneda opcional, p. ej. "12.34 INR"`},
+	{"non_negative_amount", `must be a decimal amount of at least zero with an optional currency, e.g. "12.34 INR"`, `वैकल्पिक मुद्रा के साथ शून्य या अधिक की दशमलव राशि होनी चाहिए, जैसे "12.34 INR"`, `debe ser un importe decimal de al menos cero con una moneda opcional, p. ej. "12.34 INR"`},
+	{"date_or_time", "must be an RFC 3339 timestamp or a whole day (2006-01-02)", "RFC 3339 टाइमस्टैम्प या पूरा दिन (2006-01-02) होना चाहिए", "debe ser una marca de tiempo RFC 3339 o un día completo (2006-01-02)"},
+	{"failed_rule", "failed the {0} rule", "{0} नियम पूरा नहीं करता", "no cumple la regla {0}"},
+}
+
+var (
+	// Translators for English, Hindi and Spanish; English is the fallback
+	universalTranslator = newUniversalTranslator()
+
+	// Codes of the error messages, keyed by their English text
+	errorCodes = newErrorCodes()
+)
+
+// Helper function to register the message catalogs with a translator per language
+func newUniversalTranslator() *ut.UniversalTranslator {
+	english := en.New()
+	universal := ut.New(english, english, hi.New(), es.New())
+	for _, catalog := range [][]localizedMessage{errorMessages, fieldMessages} {
+		for _, message := range catalog {
+			texts := map[string]string{"en": message.English, "hi": message.Hindi, "es": message.Spanish}
+			for locale, text := range texts {
+				translator, _ := universal.GetTranslator(locale)
+				if err := translator.Add(message.Code, text, false); err != nil {
+					panic(err)
+				}
+			}
+		}
+	}
+	return universal
+}
+
+// Helper function to index the error codes by their English text
+func newErrorCodes() map[string]string {
+	codes := make(map[string]string, len(errorMessages))
+	for _, message := range errorMessages {
+		codes[message.English] = message.Code
+	}
+	return codes
+}
+
+// Helper function to pick the translator for the languages of the Accept-Language header, in order of
+// preference. Regional tags like es-MX fall back to their language; unsupported languages to English.
+func requestTranslator(r *http.Request) ut.Translator {
+	type language struct {
+		tag     string
+		quality float64
+	}
+	var languages []language
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if tag == "" || tag == "*" || quality <= 0 {
+			continue
+		}
+		languages = append(languages, language{tag: strings.ReplaceAll(strings.ToLower(tag), "-", "_"), quality: quality})
+	}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+
+	locales := make([]string, 0, 2*len(languages))
+	for _, language := range languages {
+		locales = append(locales, language.tag)
+		if base, _, ok := strings.Cut(language.tag, "_"); ok {
+			locales = append(locales, base)
+		}
+	}
+	translator, _ := universalTranslator.FindTranslator(locales...)
+	return translator
+}
+
+// Helper function to translate a catalog entry, falling back to its code
+func translate(translator ut.Translator, code string, params ...string) string {
+	text, err := translator.T(code, params...)
+	if err != nil {
+		return code
+	}
+	return text
+}
+
+// Helper function to find the code of an error message and translate it. A "prefix: cause" message is
+// translated part by part, e.g. "Invalid min_amount: amount cannot be negative". Returns an empty code
+// for messages missing from the catalog, which are left untranslated.
+func translateError(translator ut.Translator, message string) (string, string) {
+	if code, ok := errorCodes[message]; ok {
+		return code, translate(translator, code)
+	}
+	if prefix, cause, ok := strings.Cut(message, ": "); ok {
+		if code, ok := errorCodes[prefix]; ok {
+			if causeCode, ok := errorCodes[cause]; ok {
+				cause = translate(translator, causeCode)
+			}
+			return code, translate(translator, code) + ": " + cause
+		}
+	}
+	return "", message
+}
+
+// Helper function to write an error response in the language of the request's Accept-Language header
+func writeError(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
+	writeErrorDetails(w, r, statusCode, message, nil)
+}
+
+// Helper function to write an error response with details, e.g. the invalid fields of a request
+func writeErrorDetails(w http.ResponseWriter, r *http.Request, statusCode int, message string, details interface{}) {
+	translator := requestTranslator(r)
+	code, text := translateError(translator, message)
+	writeErrorResponse(w, translator, statusCode, code, text, details)
+}
+
+// Helper function to write a translated error response. Errors without a code of their own get one
+// derived from the status, e.g. bad_request.
+func writeErrorResponse(w http.ResponseWriter, translator ut.Translator, statusCode int, code string, message string, details interface{}) {
+	if code == "" {
+		code = strings.ToLower(strings.ReplaceAll(http.StatusText(statusCode), " ", "_"))
+	}
+	w.Header().Set("Content-Language", translator.Locale())
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.ErrorResponse{Code: code, Message: message, Details: details})
+}
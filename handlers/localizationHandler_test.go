@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"mfus_WalletTransactionManager/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMessageCatalogs(t *testing.T) {
+	for name, catalog := range map[string][]localizedMessage{"error": errorMessages, "field": fieldMessages} {
+		codes := map[string]bool{}
+		texts := map[string]bool{}
+		for _, message := range catalog {
+			if codes[message.Code] || texts[message.English] {
+				t.Errorf("%s message %q is listed twice", name, message.Code)
+			}
+			codes[message.Code] = true
+			texts[message.English] = true
+
+			if message.English == "" || message.Hindi == "" || message.Spanish == "" {
+				t.Errorf("%s message %q is missing a translation", name, message.Code)
+			}
+			// Every translation takes the same parameters as the English text
+			placeholders := strings.Count(message.English, "{")
+			if strings.Count(message.Hindi, "{") != placeholders || strings.Count(message.Spanish, "{") != placeholders {
+				t.Errorf("%s message %q has translations with other parameters", name, message.Code)
+			}
+		}
+	}
+}
+
+// Every message the handlers write must be in the catalog, or it would go out untranslated and without a code
+func TestHandlerErrorsAreCataloged(t *testing.T) {
+	files := token.NewFileSet()
+	packages, err := parser.ParseDir(files, ".", func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range packages {
+		for _, file := range pkg.Files {
+			ast.Inspect(file, func(node ast.Node) bool {
+				call, ok := node.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				function, ok := call.Fun.(*ast.Ident)
+				if !ok || (function.Name != "writeError" && function.Name != "writeErrorDetails") || len(call.Args) < 4 {
+					return true
+				}
+				literal, ok := call.Args[3].(*ast.BasicLit)
+				if !ok || literal.Kind != token.STRING {
+					return true
+				}
+				message, _ := strconv.Unquote(literal.Value)
+				if code, _ := translateError(universalTranslator.GetFallback(), message); code == "" {
+					t.Errorf("%s: %q is missing from the error catalog", files.Position(literal.Pos()), message)
+				}
+				return true
+			})
+		}
+	}
+}
+
+func TestRequestTranslator(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{acceptLanguage: "", want: "en"},
+		{acceptLanguage: "es", want: "es"},
+		{acceptLanguage: "es-MX", want: "es"},
+		{acceptLanguage: "fr, hi;q=0.8", want: "hi"},
+		{acceptLanguage: "hi;q=0.2, es;q=0.9", want: "es"},
+		{acceptLanguage: "es;q=0, hi;q=invalid", want: "en"},
+		{acceptLanguage: "de-DE, *", want: "en"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("GET", "/", nil)
+		request.Header.Set("Accept-Language", test.acceptLanguage)
+		if locale := requestTranslator(request).Locale(); locale != test.want {
+			t.Errorf("Accept-Language %q: locale = %s, want %s", test.acceptLanguage, locale, test.want)
+		}
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	spanish, _ := universalTranslator.GetTranslator("es")
+
+	code, text := translateError(spanish, "Invalid request body")
+	if code != "invalid_request_body" || text != "Cuerpo de la solicitud no válido" {
+		t.Errorf("catalog message = %s %q, want the Spanish invalid_request_body", code, text)
+	}
+
+	// A "prefix: cause" message keeps the code of its prefix; a cause outside the catalog stays as it is
+	code, text = translateError(spanish, "Invalid request body: unexpected EOF")
+	if code != "invalid_request_body" || text != "Cuerpo de la solicitud no válido: unexpected EOF" {
+		t.Errorf("prefixed message = %s %q, want the Spanish prefix and the cause", code, text)
+	}
+
+	code, text = translateError(spanish, "Something nobody cataloged")
+	if code != "" || text != "Something nobody cataloged" {
+		t.Errorf("unknown message = %s %q, want it untranslated without a code", code, text)
+	}
+}
+
+func TestWriteErrorLanguage(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		message        string
+		code           string
+		want           string
+	}{
+		{acceptLanguage: "es-ES", message: "Invalid request body", code: "invalid_request_body", want: "Cuerpo de la solicitud no válido"},
+		{acceptLanguage: "hi", message: "Invalid request body", code: "invalid_request_body", want: "अनुरोध का मुख्य भाग अमान्य है"},
+		// Messages outside the catalog get a code derived from the status
+		{acceptLanguage: "es", message: "Something nobody cataloged", code: "bad_request", want: "Something nobody cataloged"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("POST", "/accounts", nil)
+		request.Header.Set("Accept-Language", test.acceptLanguage)
+		recorder := httptest.NewRecorder()
+		writeError(recorder, request, http.StatusBadRequest, test.message)
+
+		var response models.ErrorResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Code != test.code || response.Message != test.want {
+			t.Errorf("%s %q: response = %s %q, want %s %q", test.acceptLanguage, test.message, response.Code, response.Message, test.code, test.want)
+		}
+		if language := recorder.Header().Get("Content-Language"); language != strings.SplitN(test.acceptLanguage, "-", 2)[0] {
+			t.Errorf("%s: Content-Language = %q", test.acceptLanguage, language)
+		}
+	}
+
+	// Field errors are translated too
+	request := httptest.NewRequest("POST", "/accounts", strings.NewReader(`{"email":"a@example.com","type":"Savings"}`))
+	request.Header.Set("Accept-Language", "es")
+	recorder := httptest.NewRecorder()
+	CreateAccountHandler(nil).ServeHTTP(recorder, request)
+	var response struct {
+		Message string              `json:"message"`
+		Details []models.FieldError `json:"details"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Details) != 1 || response.Details[0].Message != "debe ser un tipo de cuenta válido" {
+		t.Errorf("field errors = %+v, want the Spanish account_type message", response.Details)
+	}
+}
@@ -2,36 +2,56 @@ package handlers
 
 import (
 	"bytes"
-	"encoding/json"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"io/ioutil"
 	"log"
-	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
 	"net/http"
 	"time"
 
-	"github.com/go-playground/validator"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// Define middleware to validate requests using the given validator instance
-func ValidationMiddleware(validate *validator.Validate, next http.Handler) http.Handler {
+// Middleware to validate the query parameters, headers and JSON body of a request against the OpenAPI spec.
+// Requests the spec does not describe are passed on for the router to reject. Invalid requests are
+// answered with 400 and one entry per invalid field in the error details.
+func ValidationMiddleware(spec *OpenAPISpec, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var requestBody interface{}
-		err := json.NewDecoder(r.Body).Decode(&requestBody)
+		route, pathParams, err := spec.router.FindRoute(r)
 		if err != nil {
-			http.Error(w, "Failed to decode JSON", http.StatusBadRequest)
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		err = validate.Struct(requestBody)
+		// Only JSON bodies are described by a schema; uploads are streamed to the handler unread
+		options := &openapi3filter.Options{
+			MultiError:          true,
+			SkipSettingDefaults: true,
+			AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+		}
+		if body := route.Operation.RequestBody; body == nil || body.Value.Content.Get("application/json") == nil {
+			options.ExcludeRequestBody = true
+		} else if r.Header.Get("Content-Type") == "" {
+			// Clients have always been allowed to leave out the Content-Type of a JSON body
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		})
 		if err != nil {
-			log.Printf("Validation error: %v\n", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeErrorDetails(w, r, http.StatusBadRequest, "Request validation failed", fieldErrors(requestTranslator(r), err))
 			return
 		}
-
-		// Reset the request body reader
-		r.Body = ioutil.NopCloser(bytes.NewBuffer([]byte{}))
-
 		next.ServeHTTP(w, r)
 	})
 }
@@ -42,8 +62,7 @@ func ValidateRequest(next http.Handler) http.Handler {
 		if r.Method == http.MethodPost || r.Method == http.MethodPut {
 			contentType := r.Header.Get("Content-Type")
 			if contentType != "application/json" {
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid Content-Type. Expected application/json"})
+				writeError(w, r, http.StatusBadRequest, "Invalid Content-Type. Expected application/json")
 				return
 			}
 		}
@@ -65,10 +84,269 @@ func RecoverFromPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Internal Server Error"})
+				writeError(w, r, http.StatusInternalServerError, "Internal Server Error")
 			}
 		}()
 		next.ServeHTTP(w, r)
 	})
 }
+
+// responseRecorder captures the status code and body written by a handler while passing them through
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Middleware to make money-moving POST requests safe to retry using the Idempotency-Key header.
+// The first request with a key is executed and its response stored; a replay with the same body
+// receives the stored response, and a replay with a different body is rejected with 422.
+// Keys are scoped to the caller, so one caller can never see or block another caller's response.
+func IdempotencyMiddleware(client *mongo.Client, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Hash the method, path and body so a key cannot be reused for a different request
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			if isBodyTooLarge(err) {
+				writeError(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+				return
+			}
+			writeError(w, r, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		scope := idempotencyScope(r)
+		record, err := services.ReserveIdempotencyKey(client, scope, key, requestHash)
+		if err == services.ErrIdempotencyKeyInUse {
+			switch {
+			case record.RequestHash != requestHash:
+				writeError(w, r, http.StatusUnprocessableEntity, "Idempotency key was already used with a different request")
+			case !record.Completed:
+				writeError(w, r, http.StatusConflict, "A request with this idempotency key is still in progress")
+			default:
+				if record.ContentType != "" {
+					w.Header().Set("Content-Type", record.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.StatusCode)
+				w.Write(record.ResponseBody)
+			}
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to check idempotency key")
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		// Server errors are not cached so the client can retry the same key
+		if rec.statusCode == 0 || rec.statusCode >= http.StatusInternalServerError {
+			if err := services.ReleaseIdempotencyKey(client, scope, key); err != nil {
+				log.Printf("Failed to release idempotency key %s: %v", key, err)
+			}
+			return
+		}
+		if err := services.CompleteIdempotencyKey(client, scope, key, rec.statusCode, rec.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+			log.Printf("Failed to store response for idempotency key %s: %v", key, err)
+		}
+	})
+}
+
+// Helper function to name the caller an idempotency key belongs to: the operator, or the account in the X-Account-ID header
+func idempotencyScope(r *http.Request) string {
+	if isAdminRequest(r) {
+		return "admin"
+	}
+	return "account:" + r.Header.Get(AccountIDHeader)
+}
+
+// Middleware to cap the size of a request body. It must wrap every middleware that reads the body.
+func MaxBodySizeMiddleware(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Helper function to check whether reading a body failed because it exceeded MaxBodySizeMiddleware's limit
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// Header carrying the ID of the account making the request
+const AccountIDHeader = "X-Account-ID"
+
+// Middleware to make sure the calling account owns the virtual wallet in the {id} path parameter
+func WalletOwnershipMiddleware(client *mongo.Client, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callerAccountID := r.Header.Get(AccountIDHeader)
+		if callerAccountID == "" {
+			writeError(w, r, http.StatusUnauthorized, "Missing "+AccountIDHeader+" header")
+			return
+		}
+		virtualWalletID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
+			return
+		}
+
+		err = services.VerifyWalletOwner(client, virtualWalletID, callerAccountID)
+		switch err {
+		case nil:
+			next.ServeHTTP(w, r)
+		case mongo.ErrNoDocuments:
+			writeError(w, r, http.StatusNotFound, "Virtual wallet not found")
+		case services.ErrWalletNotOwned:
+			writeError(w, r, http.StatusForbidden, err.Error())
+		default:
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve virtual wallet")
+		}
+	})
+}
+
+// Middleware to make sure the calling account is the account in the {id} path parameter
+func AccountOwnershipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callerAccountID := r.Header.Get(AccountIDHeader)
+		if callerAccountID == "" {
+			writeError(w, r, http.StatusUnauthorized, "Missing "+AccountIDHeader+" header")
+			return
+		}
+		if callerAccountID != mux.Vars(r)["id"] {
+			writeError(w, r, http.StatusForbidden, "Access to this account is not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware to require the X-Account-ID header, or the admin token, on routes whose account is only known from
+// the request body. The handler must still check that the caller is that account.
+func CallerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(AccountIDHeader) == "" && !isAdminRequest(r) {
+			writeError(w, r, http.StatusUnauthorized, "Missing "+AccountIDHeader+" header")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware to make sure the calling account owns the authorization hold in the {id} path parameter
+func HoldOwnershipMiddleware(client *mongo.Client, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callerAccountID := r.Header.Get(AccountIDHeader)
+		if callerAccountID == "" {
+			writeError(w, r, http.StatusUnauthorized, "Missing "+AccountIDHeader+" header")
+			return
+		}
+		holdID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid hold ID")
+			return
+		}
+
+		hold, err := services.FindHold(client, holdID)
+		if err != nil {
+			writeHoldError(w, r, err)
+			return
+		}
+		if hold.AccountID.Hex() != callerAccountID {
+			writeError(w, r, http.StatusForbidden, "Access to this hold is not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware to restrict a transaction route to the account the transaction was posted to or whose wallet it moved
+func TransactionOwnershipMiddleware(client *mongo.Client, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callerAccountID := r.Header.Get(AccountIDHeader)
+		if callerAccountID == "" {
+			writeError(w, r, http.StatusUnauthorized, "Missing "+AccountIDHeader+" header")
+			return
+		}
+		transactionID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid transaction ID")
+			return
+		}
+
+		transaction, err := services.FindTransaction(client, transactionID)
+		if err != nil {
+			writeReversalError(w, r, err)
+			return
+		}
+		owner, err := services.TransactionOwner(client, transaction)
+		if err != nil {
+			writeReversalError(w, r, err)
+			return
+		}
+		if owner != callerAccountID {
+			writeError(w, r, http.StatusForbidden, "Access to this transaction is not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Header carrying the operator token of admin requests
+const AdminTokenHeader = "X-Admin-Token"
+
+// Token that admin requests must present. While it is empty every admin request is refused.
+var adminToken string
+
+// SetAdminToken sets the token that admin requests must present in the X-Admin-Token header
+func SetAdminToken(token string) {
+	adminToken = token
+}
+
+// Middleware to restrict a route to operators presenting the admin token
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(AdminTokenHeader)
+		if token == "" {
+			writeError(w, r, http.StatusUnauthorized, "Missing "+AdminTokenHeader+" header")
+			return
+		}
+		if !isAdminRequest(r) {
+			writeError(w, r, http.StatusForbidden, "Admin access required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Helper function to check whether a request presents the admin token
+func isAdminRequest(r *http.Request) bool {
+	token := r.Header.Get(AdminTokenHeader)
+	return token != "" && adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	handler := AdminMiddleware(ok)
+	defer SetAdminToken("")
+
+	tests := []struct {
+		name       string
+		configured string
+		presented  string
+		want       int
+	}{
+		{name: "missing token", configured: "s3cret", want: http.StatusUnauthorized},
+		{name: "wrong token", configured: "s3cret", presented: "guess", want: http.StatusForbidden},
+		{name: "no token configured", configured: "", presented: "anything", want: http.StatusForbidden},
+		{name: "admin", configured: "s3cret", presented: "s3cret", want: http.StatusOK},
+	}
+	for _, test := range tests {
+		SetAdminToken(test.configured)
+		request := httptest.NewRequest("PUT", "/accounts/x/limits", nil)
+		if test.presented != "" {
+			request.Header.Set(AdminTokenHeader, test.presented)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.want)
+		}
+	}
+}
+
+// Helper function to send a POST with an idempotency key through a handler
+func sendIdempotent(handler http.Handler, key string, caller string, admin string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("POST", "/transfers", strings.NewReader(`{"amount":"1.00"}`))
+	request.Header.Set("Idempotency-Key", key)
+	if caller != "" {
+		request.Header.Set(AccountIDHeader, caller)
+	}
+	if admin != "" {
+		request.Header.Set(AdminTokenHeader, admin)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotencyKeysAreScopedToCaller(t *testing.T) {
+	client := testMongoClient(t)
+	SetAdminToken("s3cret")
+	defer SetAdminToken("")
+
+	calls := 0
+	handler := IdempotencyMiddleware(client, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"call":%d}`, calls)
+	}))
+
+	first := sendIdempotent(handler, "key-1", "account-a", "")
+	replay := sendIdempotent(handler, "key-1", "account-a", "")
+	if replay.Header().Get("Idempotent-Replayed") != "true" || replay.Body.String() != first.Body.String() {
+		t.Errorf("replay by the same caller = %q, want the stored %q", replay.Body.String(), first.Body.String())
+	}
+	for _, test := range []struct{ caller, admin string }{{caller: "account-b"}, {admin: "s3cret"}} {
+		recorder := sendIdempotent(handler, "key-1", test.caller, test.admin)
+		if recorder.Header().Get("Idempotent-Replayed") != "" || recorder.Body.String() == first.Body.String() {
+			t.Errorf("caller %q admin %q received another caller's response %q", test.caller, test.admin, recorder.Body.String())
+		}
+	}
+	if calls != 3 {
+		t.Errorf("handler ran %d times, want once per caller", calls)
+	}
+}
+
+func TestIdempotencyKeyReleasedOnServerError(t *testing.T) {
+	client := testMongoClient(t)
+
+	status := http.StatusInternalServerError
+	calls := 0
+	handler := IdempotencyMiddleware(client, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+
+	if recorder := sendIdempotent(handler, "key-1", "account-a", ""); recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("first attempt: status = %d, want %d", recorder.Code, http.StatusInternalServerError)
+	}
+	// The retry runs the handler again instead of replaying the error
+	status = http.StatusCreated
+	if recorder := sendIdempotent(handler, "key-1", "account-a", ""); recorder.Code != http.StatusCreated || recorder.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("retry after a server error: status = %d, replayed = %q, want a fresh %d", recorder.Code, recorder.Header().Get("Idempotent-Replayed"), http.StatusCreated)
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mfus_WalletTransactionManager/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/gorilla/mux"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// The OpenAPI 3 description of every REST route
+//
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+// OpenAPISpec is the loaded OpenAPI document together with the router matching requests to its operations
+type OpenAPISpec struct {
+	doc    *openapi3.T
+	router routers.Router
+	json   []byte
+}
+
+// LoadOpenAPISpec parses and validates the embedded OpenAPI document
+func LoadOpenAPISpec() (*OpenAPISpec, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(openAPIDocument)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &OpenAPISpec{doc: doc, router: router, json: data}, nil
+}
+
+// CheckRoutes makes sure every route and method registered on r is described by the spec.
+// Routes without methods, like the Swagger UI file server, are skipped.
+func (spec *OpenAPISpec) CheckRoutes(r *mux.Router) error {
+	var missing []string
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		pathItem := spec.doc.Paths.Find(path)
+		for _, method := range methods {
+			if pathItem == nil || pathItem.GetOperation(method) == nil {
+				missing = append(missing, method+" "+path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("routes missing from the OpenAPI spec: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Handler for the OpenAPI document as JSON
+func GetOpenAPISpecHandler(spec *OpenAPISpec) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(spec.json)
+	}
+}
+
+// Swagger UI configuration pointing at the served spec instead of the bundled petstore example
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    presets: [
+      SwaggerUIBundle.presets.apis,
+      SwaggerUIStandalonePreset
+    ],
+    plugins: [
+      SwaggerUIBundle.plugins.DownloadUrl
+    ],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// Handler serving the embedded Swagger UI under prefix, e.g. "/docs/"
+func SwaggerUIHandler(prefix string) http.Handler {
+	files := http.StripPrefix(prefix, http.FileServer(http.FS(swaggerFiles.FS)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == prefix+"swagger-initializer.js" {
+			w.Header().Set("Content-Type", "application/javascript")
+			w.Write([]byte(swaggerInitializer))
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// Helper function to flatten the errors of openapi3filter.ValidateRequest into field errors
+// with their messages in the language of translator
+func fieldErrors(translator ut.Translator, err error) []models.FieldError {
+	if multiError, ok := err.(openapi3.MultiError); ok {
+		var fields []models.FieldError
+		for _, err := range multiError {
+			fields = append(fields, fieldErrors(translator, err)...)
+		}
+		return fields
+	}
+
+	requestError, ok := err.(*openapi3filter.RequestError)
+	if !ok {
+		return []models.FieldError{{Field: "request", Rule: "invalid", Message: err.Error()}}
+	}
+
+	// Parameters are reported by name, body fields by their dotted path
+	field := "body"
+	if requestError.Parameter != nil {
+		field = requestError.Parameter.Name
+	}
+	switch {
+	case errors.Is(requestError.Err, openapi3filter.ErrInvalidRequired):
+		return []models.FieldError{{Field: field, Rule: "required", Message: translate(translator, "required")}}
+	case errors.Is(requestError.Err, openapi3filter.ErrInvalidEmptyValue):
+		return []models.FieldError{{Field: field, Rule: "required", Message: translate(translator, "not_empty")}}
+	case requestError.Err == nil && requestError.RequestBody != nil:
+		return []models.FieldError{{Field: "Content-Type", Rule: "content_type", Message: translate(translator, "content_type")}}
+	}
+
+	var fields []models.FieldError
+	schemaErrors, ok := requestError.Err.(openapi3.MultiError)
+	if !ok {
+		schemaErrors = openapi3.MultiError{requestError.Err}
+	}
+	for _, err := range schemaErrors {
+		schemaError, ok := err.(*openapi3.SchemaError)
+		if !ok {
+			var parseError *openapi3filter.ParseError
+			switch {
+			case errors.As(err, &parseError) && requestError.Parameter == nil:
+				fields = append(fields, models.FieldError{Field: field, Rule: "json", Message: translate(translator, "json")})
+			case errors.As(err, &parseError):
+				fields = append(fields, models.FieldError{Field: field, Rule: "type", Message: parseError.Error()})
+			default:
+				fields = append(fields, models.FieldError{Field: field, Rule: "invalid", Message: err.Error()})
+			}
+			continue
+		}
+		name := field
+		if pointer := schemaError.JSONPointer(); len(pointer) > 0 {
+			name = strings.Join(pointer, ".")
+			if requestError.Parameter != nil {
+				name = field + "." + name
+			}
+		}
+		fields = append(fields, models.FieldError{
+			Field:   name,
+			Rule:    schemaError.SchemaField,
+			Message: schemaErrorMessage(translator, schemaError),
+		})
+	}
+	return fields
+}
+
+// Helper function to describe a failed schema rule in the language of translator. Rules without a
+// translation keep the English reason given by the validator.
+func schemaErrorMessage(translator ut.Translator, schemaError *openapi3.SchemaError) string {
+	schema := schemaError.Schema
+	switch schemaError.SchemaField {
+	case "required":
+		return translate(translator, "required")
+	case "enum":
+		values := make([]string, 0, len(schema.Enum))
+		for _, value := range schema.Enum {
+			values = append(values, fmt.Sprint(value))
+		}
+		return translate(translator, "one_of", strings.Join(values, ", "))
+	case "minimum":
+		if schema.Min != nil {
+			if *schema.Min == 0 {
+				return translate(translator, "non_negative")
+			}
+			return translate(translator, "at_least", strconv.FormatFloat(*schema.Min, 'f', -1, 64))
+		}
+	case "minLength":
+		if schema.MinLength == 1 {
+			return translate(translator, "not_empty")
+		}
+		return translate(translator, "min_length", strconv.FormatUint(schema.MinLength, 10))
+	case "pattern":
+		return translate(translator, "pattern", schema.Pattern)
+	case "type":
+		return translate(translator, "type", schema.Type)
+	case "format":
+		return translate(translator, "format", schema.Format)
+	case "anyOf", "oneOf":
+		// "doesn't match any schema" says little; the schema's x-error-message names what was expected
+		if key, ok := schema.Extensions["x-error-message"].(string); ok {
+			return translate(translator, key)
+		}
+	}
+	return schemaError.Reason
+}
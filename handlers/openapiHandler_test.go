@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// Helper function to load the embedded spec
+func loadTestSpec(t *testing.T) *OpenAPISpec {
+	t.Helper()
+	spec, err := LoadOpenAPISpec()
+	if err != nil {
+		t.Fatalf("LoadOpenAPISpec: %v", err)
+	}
+	return spec
+}
+
+func TestGetOpenAPISpecHandler(t *testing.T) {
+	spec := loadTestSpec(t)
+	recorder := httptest.NewRecorder()
+	GetOpenAPISpecHandler(spec).ServeHTTP(recorder, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	var document struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&document); err != nil {
+		t.Fatalf("spec is not JSON: %v", err)
+	}
+	if !strings.HasPrefix(document.OpenAPI, "3.") || document.Paths["/accounts"] == nil {
+		t.Errorf("spec = openapi %q with %d paths, want an OpenAPI 3 document describing /accounts", document.OpenAPI, len(document.Paths))
+	}
+}
+
+func TestCheckRoutes(t *testing.T) {
+	spec := loadTestSpec(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/accounts", ok).Methods("POST")
+	r.HandleFunc("/accounts/{id}", ok).Methods("GET")
+	// Routes without methods, like the Swagger UI, are not API routes
+	r.PathPrefix("/docs/").Handler(ok)
+	if err := spec.CheckRoutes(r); err != nil {
+		t.Errorf("documented routes: %v", err)
+	}
+
+	r.HandleFunc("/accounts/{id}", ok).Methods("PATCH")
+	r.HandleFunc("/undocumented", ok).Methods("GET")
+	err := spec.CheckRoutes(r)
+	if err == nil || !strings.Contains(err.Error(), "GET /undocumented, PATCH /accounts/{id}") {
+		t.Errorf("undocumented routes returned %v, want both routes reported", err)
+	}
+}
+
+func TestSwaggerUIHandler(t *testing.T) {
+	handler := SwaggerUIHandler("/docs/")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/docs/swagger-initializer.js", nil))
+	if !strings.Contains(recorder.Body.String(), `url: "/openapi.json"`) {
+		t.Errorf("initializer does not point at the served spec:\n%s", recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/docs/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("UI status = %d, want 200", recorder.Code)
+	}
+}
+
+func TestValidationMiddleware(t *testing.T) {
+	var reached *http.Request
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = r
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := ValidationMiddleware(loadTestSpec(t), next)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		field  string
+		rule   string
+	}{
+		{name: "valid", method: "POST", path: "/accounts", body: `{"email":"a@example.com","type":"Retail","balance":"10.00"}`},
+		{name: "missing email", method: "POST", path: "/accounts", body: `{"type":"Retail"}`, field: "email", rule: "required"},
+		{name: "unknown account type", method: "POST", path: "/accounts", body: `{"email":"a@example.com","type":"Savings"}`, field: "type", rule: "enum"},
+		{name: "negative balance", method: "POST", path: "/accounts", body: `{"email":"a@example.com","balance":"-1.00"}`, field: "balance", rule: "anyOf"},
+		{name: "malformed JSON", method: "POST", path: "/accounts", body: `{"email":`, field: "body", rule: "json"},
+		{name: "page size", method: "GET", path: "/accounts/0123456789abcdef01234567/transactions?limit=0", field: "limit", rule: "minimum"},
+		{name: "unknown route", method: "GET", path: "/undocumented"},
+	}
+	for _, test := range tests {
+		reached = nil
+		request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		if test.field == "" {
+			if reached == nil {
+				t.Errorf("%s: request did not reach the handler, status %d: %s", test.name, recorder.Code, recorder.Body.String())
+			}
+			continue
+		}
+		if reached != nil || recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", test.name, recorder.Code)
+			continue
+		}
+		var response struct {
+			Code    string              `json:"code"`
+			Details []models.FieldError `json:"details"`
+		}
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, detail := range response.Details {
+			found = found || (detail.Field == test.field && detail.Rule == test.rule && detail.Message != "")
+		}
+		if response.Code == "" || !found {
+			t.Errorf("%s: error = %+v, want a %s error on %s", test.name, response, test.rule, test.field)
+		}
+	}
+}
+
+func TestValidationMiddlewareDefaultsContentType(t *testing.T) {
+	var contentType string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { contentType = r.Header.Get("Content-Type") })
+	handler := ValidationMiddleware(loadTestSpec(t), next)
+
+	// Clients have always been allowed to leave out the Content-Type of a JSON body
+	request := httptest.NewRequest("POST", "/accounts", strings.NewReader(`{"email":"a@example.com"}`))
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+
+	// A body the spec does not describe as JSON is not a JSON body
+	request = httptest.NewRequest("POST", "/accounts", strings.NewReader("email=a@example.com"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("form body status = %d, want 400", recorder.Code)
+	}
+}
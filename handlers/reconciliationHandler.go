@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Handler for listing the most recent reconciliation reports. Accepts an optional limit (default 20).
+func GetReconciliationReportsHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := int64(20)
+		if value := r.URL.Query().Get("limit"); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed <= 0 {
+				writeError(w, r, http.StatusBadRequest, "Invalid limit. Must be a positive integer")
+				return
+			}
+			limit = parsed
+		}
+
+		reports, err := services.ListReconciliationReports(client, limit)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve reconciliation reports")
+			return
+		}
+
+		// Return success response with the reports
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Reconciliation reports retrieved successfully",
+			Data:    reports,
+		})
+	}
+}
+
+// Handler for running a reconciliation now, optionally posting correcting adjustment entries
+func RunReconciliationHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse request body; an empty body runs in report-only mode
+		var request models.ReconciliationRequest
+		if r.ContentLength != 0 && !decodeRequest(w, r, &request) {
+			return
+		}
+		if request.Correct && request.ReasonCode == "" {
+			request.ReasonCode = models.ReasonReconciliationDrift
+		}
+
+		report, err := services.Reconcile(client, request.Correct, request.ReasonCode)
+		if err != nil {
+			if err == services.ErrInvalidReasonCode {
+				writeError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, "Failed to run reconciliation")
+			return
+		}
+
+		// Return success response with the new report
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Reconciliation completed",
+			Data:    report,
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Handler for reversing a ledger transaction in full
+func ReverseTransactionHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse transaction ID from URL parameter
+		vars := mux.Vars(r)
+		transactionID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid transaction ID")
+			return
+		}
+
+		// Parse request body; the body is optional
+		var request models.ReverseTransactionRequest
+		if r.ContentLength != 0 && !decodeRequest(w, r, &request) {
+			return
+		}
+
+		reversal, err := services.ReverseTransaction(client, transactionID, request.Reference)
+		if err != nil {
+			writeReversalError(w, r, err)
+			return
+		}
+
+		// Return success response with the compensating transaction
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Transaction reversed successfully",
+			Data:    reversal,
+		})
+	}
+}
+
+// Handler for refunding part or all of a ledger transaction
+func RefundTransactionHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse transaction ID from URL parameter
+		vars := mux.Vars(r)
+		transactionID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid transaction ID")
+			return
+		}
+
+		// Only an operator or the account that received the funds may refund them
+		if !isAdminRequest(r) {
+			callerAccountID := r.Header.Get(AccountIDHeader)
+			if callerAccountID == "" {
+				writeError(w, r, http.StatusUnauthorized, "Missing "+AccountIDHeader+" header")
+				return
+			}
+			if err := services.VerifyRefundRecipient(client, transactionID, callerAccountID); err != nil {
+				writeReversalError(w, r, err)
+				return
+			}
+		}
+
+		// Decode and validate request body
+		var request models.RefundTransactionRequest
+		if !decodeRequest(w, r, &request) {
+			return
+		}
+
+		refund, err := services.RefundTransaction(client, transactionID, request.Amount, request.Reference)
+		if err != nil {
+			writeReversalError(w, r, err)
+			return
+		}
+
+		// Return success response with the compensating transaction
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Transaction refunded successfully",
+			Data:    refund,
+		})
+	}
+}
+
+// Helper function to map reversal and refund errors to HTTP responses
+func writeReversalError(w http.ResponseWriter, r *http.Request, err error) {
+	if writeLimitError(w, r, err) {
+		return
+	}
+	switch err {
+	case services.ErrTransactionNotFound:
+		writeError(w, r, http.StatusNotFound, err.Error())
+	case mongo.ErrNoDocuments:
+		writeError(w, r, http.StatusNotFound, "Owner of the transaction not found")
+	case services.ErrRefundNotRecipient:
+		writeError(w, r, http.StatusForbidden, err.Error())
+	case services.ErrAlreadyReversed, services.ErrReversalAfterRefund:
+		writeError(w, r, http.StatusConflict, err.Error())
+	case services.ErrTransactionNotReversible, services.ErrRefundExceedsOriginal, services.ErrInsufficientFunds, models.ErrCurrencyMismatch,
+		services.ErrCashWithdrawalNotAllowed, services.ErrSystemTransfersOnly:
+		writeError(w, r, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, r, http.StatusInternalServerError, "Failed to post compensating transaction")
+	}
+}
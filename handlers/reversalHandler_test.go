@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRefundRequiresCaller(t *testing.T) {
+	router := mux.NewRouter()
+	router.Handle("/transactions/{id}/refund", RefundTransactionHandler(nil))
+
+	request := httptest.NewRequest("POST", "/transactions/"+primitive.NewObjectID().Hex()+"/refund", strings.NewReader(`{"amount":"1.00"}`))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("refund without a caller: status = %d, want %d", recorder.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRefundOnlyByRecipient(t *testing.T) {
+	client := testMongoClient(t)
+	accountID, _ := createTestWallet(t, client, "0.00")
+	credit, err := services.CreateAccountTransaction(client, accountID, models.Credit, models.MustParseMoney("20.00"))
+	if err != nil {
+		t.Fatalf("Failed to credit account: %v", err)
+	}
+	SetAdminToken("s3cret")
+	defer SetAdminToken("")
+
+	router := mux.NewRouter()
+	router.Handle("/transactions/{id}/refund", RefundTransactionHandler(client)).Methods("POST")
+	router.Handle("/transactions/{id}/reverse", AdminMiddleware(ReverseTransactionHandler(client))).Methods("POST")
+
+	tests := []struct {
+		name   string
+		path   string
+		caller string
+		admin  string
+		want   int
+	}{
+		{name: "refund by another account", path: "/refund", caller: primitive.NewObjectID().Hex(), want: http.StatusForbidden},
+		{name: "refund by the recipient", path: "/refund", caller: accountID.Hex(), want: http.StatusCreated},
+		{name: "refund by an operator", path: "/refund", admin: "s3cret", want: http.StatusCreated},
+		{name: "reversal by the recipient", path: "/reverse", caller: accountID.Hex(), want: http.StatusUnauthorized},
+		{name: "reversal by an operator", path: "/reverse", admin: "s3cret", want: http.StatusConflict},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("POST", "/transactions/"+credit.ID.Hex()+test.path, strings.NewReader(`{"amount":"1.00"}`))
+		request.Header.Set(AccountIDHeader, test.caller)
+		if test.admin != "" {
+			request.Header.Set(AdminTokenHeader, test.admin)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.want)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Number of statement lines written between flushes of the response
+const statementFlushEvery = 100
+
+// Handler for downloading a virtual wallet statement. Accepts from, to and format (csv, ofx, camt053 or json).
+func GetVirtualWalletStatementHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse virtual wallet ID from URL path parameter
+		vars := mux.Vars(r)
+		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
+			return
+		}
+
+		writeStatement(w, r, virtualWalletID, "Virtual wallet not found", func(from, to time.Time, sink services.StatementSink) error {
+			return services.WriteWalletStatement(client, virtualWalletID, from, to, sink)
+		})
+	}
+}
+
+// Handler for downloading an account statement. Accepts from, to and format (csv, ofx, camt053 or json).
+func GetAccountStatementHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse account ID from URL parameter
+		vars := mux.Vars(r)
+		accountID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid account ID")
+			return
+		}
+
+		writeStatement(w, r, accountID, "Account not found", func(from, to time.Time, sink services.StatementSink) error {
+			return services.WriteAccountStatement(client, accountID, from, to, sink)
+		})
+	}
+}
+
+// Helper function to parse the statement parameters, pick the output format and stream the statement.
+// Errors found before the first byte is written get a JSON error response; later ones end the download early.
+func writeStatement(w http.ResponseWriter, r *http.Request, ownerID primitive.ObjectID, notFound string, build func(from, to time.Time, sink services.StatementSink) error) {
+	query := r.URL.Query()
+	var from time.Time
+	to := time.Now()
+	var err error
+	if value := query.Get("from"); value != "" {
+		from, err = parseQueryTime(value, false)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid from date")
+			return
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		to, err = parseQueryTime(value, true)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid to date")
+			return
+		}
+	}
+	if !from.Before(to) {
+		writeError(w, r, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	stream := &statementStream{w: w}
+	var sink services.StatementSink
+	format := query.Get("format")
+	switch format {
+	case "", "json":
+		format = "json"
+		sink = &jsonStatementSink{statementStream: stream}
+	case "csv":
+		sink = &csvStatementSink{statementStream: stream, writer: csv.NewWriter(w)}
+	case "ofx":
+		sink = &ofxStatementSink{statementStream: stream}
+	case "camt053":
+		sink = &camt053StatementSink{statementStream: stream}
+	default:
+		writeError(w, r, http.StatusBadRequest, "Invalid format. Must be 'csv', 'ofx', 'camt053' or 'json'")
+		return
+	}
+	stream.contentType = map[string]string{
+		"json":    "application/json",
+		"csv":     "text/csv",
+		"ofx":     "application/x-ofx",
+		"camt053": "application/xml",
+	}[format]
+	extension := format
+	if format == "camt053" {
+		extension = "xml"
+	}
+	stream.filename = fmt.Sprintf("statement-%s.%s", ownerID.Hex(), extension)
+
+	err = build(from, to, sink)
+	if err == nil {
+		return
+	}
+	if stream.started {
+		log.Printf("Statement for %s ended early: %v", ownerID.Hex(), err)
+		return
+	}
+	if err == mongo.ErrNoDocuments {
+		writeError(w, r, http.StatusNotFound, notFound)
+		return
+	}
+	writeError(w, r, http.StatusInternalServerError, "Failed to build statement")
+}
+
+// statementStream writes the response headers when the statement starts and flushes every few lines
+type statementStream struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+	lines       int
+}
+
+func (s *statementStream) start() {
+	s.started = true
+	s.w.Header().Set("Content-Type", s.contentType)
+	s.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", s.filename))
+	s.w.WriteHeader(http.StatusOK)
+}
+
+func (s *statementStream) lineWritten() {
+	s.lines++
+	if s.lines%statementFlushEvery == 0 {
+		s.flush()
+	}
+}
+
+func (s *statementStream) flush() {
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// jsonStatementSink writes {"statement": header, "lines": [...], "summary": summary}
+type jsonStatementSink struct {
+	*statementStream
+}
+
+func (s *jsonStatementSink) Begin(header models.StatementHeader) error {
+	s.start()
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, `{"statement":%s,"lines":[`, data)
+	return err
+}
+
+func (s *jsonStatementSink) Line(line models.StatementLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	if s.lines > 0 {
+		if _, err := io.WriteString(s.w, ","); err != nil {
+			return err
+		}
+	}
+	if _, err := s.w.Write(data); err != nil {
+		return err
+	}
+	s.lineWritten()
+	return nil
+}
+
+func (s *jsonStatementSink) End(summary models.StatementSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, `],"summary":%s}`, data)
+	s.flush()
+	return err
+}
+
+// csvStatementSink writes one row per entry between an opening balance row and closing balance and total rows
+type csvStatementSink struct {
+	*statementStream
+	writer *csv.Writer
+}
+
+func (s *csvStatementSink) Begin(header models.StatementHeader) error {
+	s.start()
+	s.writer.Write([]string{"date", "transaction_id", "type", "reference", "amount", "balance"})
+	return s.writer.Write([]string{header.From.UTC().Format(time.RFC3339), "", "opening_balance", "", "", header.OpeningBalance.Decimal()})
+}
+
+func (s *csvStatementSink) Line(line models.StatementLine) error {
+	err := s.writer.Write([]string{
+		line.Date.UTC().Format(time.RFC3339),
+		line.TransactionID.Hex(),
+		string(line.Type),
+		line.Reference,
+		line.Amount.Decimal(),
+		line.Balance.Decimal(),
+	})
+	if err != nil {
+		return err
+	}
+	s.lineWritten()
+	if s.lines%statementFlushEvery == 0 {
+		s.writer.Flush()
+	}
+	return s.writer.Error()
+}
+
+func (s *csvStatementSink) End(summary models.StatementSummary) error {
+	s.writer.Write([]string{"", "", "closing_balance", "", "", summary.ClosingBalance.Decimal()})
+	for _, total := range summary.Totals {
+		s.writer.Write([]string{"", "", "total_" + string(total.Type), strconv.FormatInt(total.Count, 10), total.Amount.Decimal(), ""})
+	}
+	s.writer.Flush()
+	s.flush()
+	return s.writer.Error()
+}
+
+// ofxStatementSink writes an OFX 2.2 bank statement. The opening balance and the totals per
+// TransactionType are listed as BAL entries of the BALLIST aggregate.
+type ofxStatementSink struct {
+	*statementStream
+	header models.StatementHeader
+}
+
+// OFX dates are written as YYYYMMDDHHMMSS in UTC
+func ofxDate(t time.Time) string {
+	return t.UTC().Format("20060102150405") + "[0:GMT]"
+}
+
+func ofxEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func (s *ofxStatementSink) Begin(header models.StatementHeader) error {
+	s.start()
+	s.header = header
+	accountID := header.AccountID.Hex()
+	if !header.WalletID.IsZero() {
+		accountID = header.WalletID.Hex()
+	}
+	_, err := fmt.Fprintf(s.w, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>0</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<STMTRS><CURDEF>%s</CURDEF>
+<BANKACCTFROM><BANKID>walletManager</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>
+<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>
+`, ofxDate(time.Now()), header.OpeningBalance.CurrencyCode(), accountID, ofxDate(header.From), ofxDate(header.To))
+	return err
+}
+
+// Helper function mapping ledger types to OFX transaction types
+func ofxTransactionType(line models.StatementLine) string {
+	switch line.Type {
+	case models.Fee:
+		return "FEE"
+	case models.Hold, models.Release, models.Capture:
+		return "OTHER"
+	case models.TransferIn, models.TransferOut:
+		return "XFER"
+	}
+	if line.Amount.IsNegative() {
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+func (s *ofxStatementSink) Line(line models.StatementLine) error {
+	memo := string(line.Type)
+	if line.Reference != "" {
+		memo += ": " + line.Reference
+	}
+	_, err := fmt.Fprintf(s.w, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s</FITID><MEMO>%s</MEMO></STMTTRN>\n",
+		ofxTransactionType(line), ofxDate(line.Date), line.Amount.Decimal(), line.TransactionID.Hex(), ofxEscape(memo))
+	if err != nil {
+		return err
+	}
+	s.lineWritten()
+	return nil
+}
+
+func (s *ofxStatementSink) End(summary models.StatementSummary) error {
+	_, err := fmt.Fprintf(s.w, "</BANKTRANLIST>\n<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n<BALLIST>\n",
+		summary.ClosingBalance.Decimal(), ofxDate(s.header.To))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "<BAL><NAME>Opening balance</NAME><DESC>Balance on %s</DESC><BALTYPE>DOLLAR</BALTYPE><VALUE>%s</VALUE><DTASOF>%s</DTASOF></BAL>\n",
+		s.header.From.UTC().Format(time.RFC3339), s.header.OpeningBalance.Decimal(), ofxDate(s.header.From))
+	if err != nil {
+		return err
+	}
+	for _, total := range summary.Totals {
+		_, err = fmt.Fprintf(s.w, "<BAL><NAME>Total %s</NAME><DESC>%d transactions</DESC><BALTYPE>DOLLAR</BALTYPE><VALUE>%s</VALUE></BAL>\n",
+			ofxEscape(string(total.Type)), total.Count, total.Amount.Decimal())
+		if err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(s.w, "</BALLIST>\n</STMTRS></STMTTRNRS></BANKMSGSRSV1>\n</OFX>\n")
+	s.flush()
+	return err
+}
+
+// camt053StatementSink writes an ISO 20022 camt.053 statement. The message is only written once
+// the statement is complete, so errors while reading the ledger still get a JSON error response.
+type camt053StatementSink struct {
+	*statementStream
+	services.Camt053Builder
+}
+
+func (s *camt053StatementSink) End(summary models.StatementSummary) error {
+	if err := s.Camt053Builder.End(summary); err != nil {
+		return err
+	}
+	s.start()
+	return writeCamt(s.w, s.Document())
+}
+
+// Helper function to write an ISO 20022 document with its XML declaration
+func writeCamt(w io.Writer, document interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
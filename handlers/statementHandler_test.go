@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestStatementParameters(t *testing.T) {
+	router := mux.NewRouter()
+	router.Handle("/virtual_wallets/{id}/statement", GetVirtualWalletStatementHandler(nil))
+	walletID := primitive.NewObjectID().Hex()
+
+	for _, query := range []string{
+		"format=pdf",
+		"from=yesterday",
+		"to=2024-13-01",
+		"from=2024-02-01&to=2024-01-01",
+	} {
+		request := httptest.NewRequest("GET", "/virtual_wallets/"+walletID+"/statement?"+query, nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, recorder.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// Helper function to feed a small statement through a sink
+func writeSampleStatement(t *testing.T, begin func(models.StatementHeader) error, line func(models.StatementLine) error, end func(models.StatementSummary) error) {
+	t.Helper()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := begin(models.StatementHeader{WalletID: primitive.NewObjectID(), From: from, To: from.AddDate(0, 1, 0), OpeningBalance: models.MustParseMoney("100.00")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := line(models.StatementLine{
+		TransactionID: primitive.NewObjectID(),
+		Date:          from.Add(time.Hour),
+		Type:          models.Debit,
+		Reference:     "coffee, large",
+		Amount:        models.NewMoney(-250, models.DefaultCurrency),
+		Balance:       models.MustParseMoney("97.50"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := end(models.StatementSummary{
+		ClosingBalance: models.MustParseMoney("97.50"),
+		Totals:         []models.StatementTotal{{Type: models.Debit, Count: 1, Amount: models.MustParseMoney("2.50")}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCSVStatementSink(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	sink := &csvStatementSink{statementStream: &statementStream{w: recorder, contentType: "text/csv"}, writer: csv.NewWriter(recorder)}
+	writeSampleStatement(t, sink.Begin, sink.Line, sink.End)
+
+	rows, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("statement is not valid CSV: %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("statement has %d rows, want header, opening, line, closing and total", len(rows))
+	}
+	if rows[1][2] != "opening_balance" || rows[1][5] != "100.00" {
+		t.Errorf("opening row = %v", rows[1])
+	}
+	if rows[2][3] != "coffee, large" || rows[2][4] != "-2.50" || rows[2][5] != "97.50" {
+		t.Errorf("line row = %v", rows[2])
+	}
+	if rows[3][2] != "closing_balance" || rows[3][5] != "97.50" || rows[4][2] != "total_debit" || rows[4][3] != "1" {
+		t.Errorf("closing rows = %v", rows[3:])
+	}
+	if recorder.Header().Get("Content-Type") != "text/csv" {
+		t.Errorf("Content-Type = %q, want text/csv", recorder.Header().Get("Content-Type"))
+	}
+}
+
+func TestJSONStatementSink(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	sink := &jsonStatementSink{statementStream: &statementStream{w: recorder, contentType: "application/json"}}
+	writeSampleStatement(t, sink.Begin, sink.Line, sink.End)
+
+	var statement struct {
+		Statement models.StatementHeader  `json:"statement"`
+		Lines     []map[string]string     `json:"lines"`
+		Summary   models.StatementSummary `json:"summary"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &statement); err != nil {
+		t.Fatalf("statement is not valid JSON: %v\n%s", err, recorder.Body.String())
+	}
+	// Signed line amounts are written as they are, even though requests never accept them
+	if len(statement.Lines) != 1 || statement.Lines[0]["amount"] != "-2.50 INR" || statement.Summary.ClosingBalance != models.MustParseMoney("97.50") {
+		t.Errorf("statement = %+v, want one line of -2.50 INR closing at 97.50", statement)
+	}
+}
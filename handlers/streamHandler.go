@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Handler for the live Server-Sent Events stream of a virtual wallet's ledger entries and balance changes.
+// A reconnecting client resumes after the event in its Last-Event-ID header.
+func GetVirtualWalletEventsHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse virtual wallet ID from URL path parameter
+		vars := mux.Vars(r)
+		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
+			return
+		}
+
+		writeEventStream(w, r, virtualWalletID, "Virtual wallet not found", func(ctx context.Context, lastEventID string, sink services.EventStreamSink) error {
+			return services.StreamWalletEvents(ctx, client, virtualWalletID, lastEventID, sink)
+		})
+	}
+}
+
+// Handler for the live Server-Sent Events stream of a customer's account and all of its virtual wallets.
+// A reconnecting client resumes after the event in its Last-Event-ID header.
+func GetCustomerEventsHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse customer ID from URL parameter; it is the ID of the customer's account
+		vars := mux.Vars(r)
+		accountID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid customer ID")
+			return
+		}
+
+		writeEventStream(w, r, accountID, "Customer not found", func(ctx context.Context, lastEventID string, sink services.EventStreamSink) error {
+			return services.StreamCustomerEvents(ctx, client, accountID, lastEventID, sink)
+		})
+	}
+}
+
+// Helper function to run an event stream until the client disconnects. Errors found before the stream
+// starts get a JSON error response; later ones end the stream and the client reconnects.
+func writeEventStream(w http.ResponseWriter, r *http.Request, ownerID primitive.ObjectID, notFound string, run func(ctx context.Context, lastEventID string, sink services.EventStreamSink) error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	sink := &sseStreamSink{w: w, flusher: flusher}
+	err := run(r.Context(), r.Header.Get("Last-Event-ID"), sink)
+	if err == nil {
+		return
+	}
+	if sink.started {
+		log.Printf("Event stream for %s ended: %v", ownerID.Hex(), err)
+		return
+	}
+	switch err {
+	case services.ErrInvalidLastEventID:
+		writeError(w, r, http.StatusBadRequest, err.Error())
+	case services.ErrStreamHistoryLost:
+		writeError(w, r, http.StatusGone, err.Error())
+	case mongo.ErrNoDocuments:
+		writeError(w, r, http.StatusNotFound, notFound)
+	default:
+		writeError(w, r, http.StatusInternalServerError, "Failed to open event stream")
+	}
+}
+
+// sseStreamSink writes events in the text/event-stream format and flushes each one
+type sseStreamSink struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool
+}
+
+func (s *sseStreamSink) Begin() error {
+	s.started = true
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.Header().Set("Connection", "keep-alive")
+	// Keep reverse proxies from buffering the stream
+	s.w.Header().Set("X-Accel-Buffering", "no")
+	s.w.WriteHeader(http.StatusOK)
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseStreamSink) Event(event models.StreamEvent) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if event.ID != "" {
+		if _, err := fmt.Fprintf(s.w, "id: %s\n", event.ID); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseStreamSink) Heartbeat() error {
+	if _, err := fmt.Fprint(s.w, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
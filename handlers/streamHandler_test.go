@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestEventStreamInvalidIDs(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/virtualWallets/{id}/events", GetVirtualWalletEventsHandler(nil)).Methods("GET")
+	router.HandleFunc("/customers/{id}/events", GetCustomerEventsHandler(nil)).Methods("GET")
+
+	for _, path := range []string{"/virtualWallets/not-an-id/events", "/customers/not-an-id/events"} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", path, recorder.Code)
+		}
+	}
+}
+
+func TestWriteEventStreamErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: services.ErrInvalidLastEventID, want: http.StatusBadRequest},
+		{err: services.ErrStreamHistoryLost, want: http.StatusGone},
+		{err: mongo.ErrNoDocuments, want: http.StatusNotFound},
+		{err: errors.New("connection refused"), want: http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("GET", "/customers/x/events", nil)
+		request.Header.Set("Last-Event-ID", "8263A1")
+		recorder := httptest.NewRecorder()
+		writeEventStream(recorder, request, primitive.NewObjectID(), "Customer not found", func(ctx context.Context, lastEventID string, sink services.EventStreamSink) error {
+			if lastEventID != "8263A1" {
+				t.Errorf("stream started after %q, want the Last-Event-ID header", lastEventID)
+			}
+			return test.err
+		})
+		if recorder.Code != test.want {
+			t.Errorf("%v: status = %d, want %d", test.err, recorder.Code, test.want)
+		}
+	}
+
+	// Once events were sent the status is already written and the client simply reconnects
+	recorder := httptest.NewRecorder()
+	writeEventStream(recorder, httptest.NewRequest("GET", "/customers/x/events", nil), primitive.NewObjectID(), "Customer not found", func(ctx context.Context, lastEventID string, sink services.EventStreamSink) error {
+		sink.Begin()
+		return errors.New("connection reset")
+	})
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status after the stream began = %d, want 200", recorder.Code)
+	}
+}
+
+func TestSSEStreamSink(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	sink := &sseStreamSink{w: recorder, flusher: recorder}
+	if err := sink.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	balance := map[string]string{"balance": "10.00 INR"}
+	if err := sink.Event(models.StreamEvent{ID: "8263A1", Type: models.EventBalanceUpdated, Data: balance}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Heartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	// An event without a position has no id line
+	if err := sink.Event(models.StreamEvent{Type: models.EventWalletDeleted, Data: map[string]string{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", contentType)
+	}
+	if !recorder.Flushed {
+		t.Error("stream was not flushed")
+	}
+	want := "id: 8263A1\nevent: balance.updated\ndata: {\"balance\":\"10.00 INR\"}\n\n" +
+		": heartbeat\n\n" +
+		"event: wallet.deleted\ndata: {}\n\n"
+	if body := recorder.Body.String(); body != want {
+		t.Errorf("stream =\n%s\nwant\n%s", body, want)
+	}
+}
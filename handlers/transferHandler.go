@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Handler for moving funds atomically from one virtual wallet to another
+func TransferHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Decode and validate request body
+		var reqBody models.TransferRequest
+		if !decodeRequest(w, r, &reqBody) {
+			return
+		}
+
+		// Validate wallet IDs
+		sourceWalletID, err := primitive.ObjectIDFromHex(reqBody.SourceWalletID)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid source wallet ID")
+			return
+		}
+		destinationWalletID, err := primitive.ObjectIDFromHex(reqBody.DestinationWalletID)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid destination wallet ID")
+			return
+		}
+
+		// Only the owner of the source wallet may move money out of it
+		callerAccountID := r.Header.Get(AccountIDHeader)
+		if callerAccountID == "" {
+			writeError(w, r, http.StatusUnauthorized, "Missing "+AccountIDHeader+" header")
+			return
+		}
+		err = services.VerifyWalletOwner(client, sourceWalletID, callerAccountID)
+		if err != nil {
+			switch err {
+			case mongo.ErrNoDocuments:
+				writeError(w, r, http.StatusNotFound, services.ErrSourceWalletNotFound.Error())
+			case services.ErrWalletNotOwned:
+				writeError(w, r, http.StatusForbidden, err.Error())
+			default:
+				writeError(w, r, http.StatusInternalServerError, "Failed to retrieve source wallet")
+			}
+			return
+		}
+
+		transfer, err := services.Transfer(client, sourceWalletID, destinationWalletID, reqBody.Amount, reqBody.Reference)
+		if err != nil {
+			if writeLimitError(w, r, err) {
+				return
+			}
+			switch err {
+			case services.ErrSourceWalletNotFound, services.ErrDestinationWalletNotFound:
+				writeError(w, r, http.StatusNotFound, err.Error())
+			case services.ErrInsufficientFunds:
+				// The source balance cannot cover the transfer, possibly because a concurrent debit took it
+				writeError(w, r, http.StatusConflict, err.Error())
+			case services.ErrSameWallet, services.ErrSystemTransfersOnly, models.ErrCurrencyMismatch:
+				writeError(w, r, http.StatusBadRequest, err.Error())
+			default:
+				writeError(w, r, http.StatusInternalServerError, "Failed to complete transfer")
+			}
+			return
+		}
+
+		// Return success response with the linked transfer details
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Transfer completed successfully",
+			Data:    transfer,
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTransferWithoutFundsConflicts(t *testing.T) {
+	client := testMongoClient(t)
+	accountID, sourceID := createTestWallet(t, client, "10.00")
+	now := time.Now()
+	destinationID, err := services.CreateVirtualWallet(client, models.VirtualWallet{
+		CustomerID:   accountID.Hex(),
+		WalletType:   models.CashWallet,
+		Balance:      models.MustParseMoney("0.00"),
+		DateCreated:  now,
+		DateModified: now,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.Handle("/transfers", TransferHandler(client)).Methods("POST")
+
+	request := httptest.NewRequest("POST", "/transfers", strings.NewReader(
+		`{"source_wallet_id": "`+sourceID.Hex()+`", "destination_wallet_id": "`+destinationID.Hex()+`", "amount": "25.00"}`))
+	request.Header.Set(AccountIDHeader, accountID.Hex())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("transfer without funds: status = %d, want %d", recorder.Code, http.StatusConflict)
+	}
+}
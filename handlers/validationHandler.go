@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"net/http"
+	"reflect"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validator for the validate tags of the request models
+var requestValidator = newRequestValidator()
+
+// Helper function to set up the request validator. Money is checked by its minor units, so gt=0 means
+// a positive amount, and fields are reported by their JSON names.
+func newRequestValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(models.Money).Units
+	}, models.Money{})
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// Enum membership of the model types
+	validate.RegisterValidation("account_type", func(fl validator.FieldLevel) bool {
+		return models.AccountType(fl.Field().String()).IsValid()
+	})
+	validate.RegisterValidation("wallet_type", func(fl validator.FieldLevel) bool {
+		return models.WalletType(fl.Field().String()).IsValid()
+	})
+	validate.RegisterValidation("event_type", func(fl validator.FieldLevel) bool {
+		return models.EventType(fl.Field().String()).IsValid()
+	})
+	validate.RegisterValidation("reason_code", func(fl validator.FieldLevel) bool {
+		return models.ReasonCode(fl.Field().String()).IsValid()
+	})
+	return validate
+}
+
+// Helper function to decode a JSON request body into request and check its validate tags.
+// Writes the 400 response and returns false when the body cannot be decoded or is invalid.
+func decodeRequest(w http.ResponseWriter, r *http.Request, request interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	if fields := validateRequest(requestTranslator(r), request); len(fields) > 0 {
+		writeErrorDetails(w, r, http.StatusBadRequest, "Request validation failed", fields)
+		return false
+	}
+	return true
+}
+
+// Helper function to check the validate tags of request, returning one field error per failed rule
+// with its message in the language of translator
+func validateRequest(translator ut.Translator, request interface{}) []models.FieldError {
+	err := requestValidator.Struct(request)
+	if err == nil {
+		return nil
+	}
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return []models.FieldError{{Field: "body", Rule: "invalid", Message: err.Error()}}
+	}
+
+	fields := make([]models.FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		fields = append(fields, models.FieldError{
+			Field:   fieldPath(fieldError),
+			Rule:    fieldError.Tag(),
+			Message: validationMessage(translator, fieldError),
+		})
+	}
+	return fields
+}
+
+// Helper function to turn the namespace of a field error, e.g. CreateFeeScheduleRequest.tiers[0].flat,
+// into the dotted path used by the OpenAPI validation, e.g. tiers.0.flat
+func fieldPath(fieldError validator.FieldError) string {
+	path := fieldError.Namespace()
+	if i := strings.Index(path, "."); i >= 0 {
+		path = path[i+1:]
+	}
+	return strings.NewReplacer("[", ".", "]", "").Replace(path)
+}
+
+// Helper function to describe a failed rule in the language of translator
+func validationMessage(translator ut.Translator, fieldError validator.FieldError) string {
+	switch tag := fieldError.Tag(); tag {
+	case "required", "required_if":
+		return translate(translator, "required")
+	case "email", "http_url", "mongodb", "account_type", "wallet_type", "event_type", "reason_code", "iso4217":
+		return translate(translator, tag)
+	case "gt":
+		if fieldError.Param() == "0" {
+			return translate(translator, "positive")
+		}
+		return translate(translator, "greater_than", fieldError.Param())
+	case "gte":
+		if fieldError.Param() == "0" {
+			return translate(translator, "non_negative")
+		}
+		return translate(translator, "at_least", fieldError.Param())
+	case "oneof":
+		return translate(translator, "one_of", strings.Join(strings.Fields(fieldError.Param()), ", "))
+	default:
+		return translate(translator, "failed_rule", tag)
+	}
+}
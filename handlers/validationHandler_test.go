@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Helper function to index field errors by field and rule
+func fieldRules(fields []models.FieldError) map[string]string {
+	rules := map[string]string{}
+	for _, field := range fields {
+		rules[field.Field] = field.Rule
+	}
+	return rules
+}
+
+func TestValidateRequest(t *testing.T) {
+	translator := requestTranslator(httptest.NewRequest("GET", "/", nil))
+	negative := models.NewMoney(-100, models.DefaultCurrency)
+	upTo := models.MustParseMoney("100.00")
+
+	tests := []struct {
+		name    string
+		request interface{}
+		want    map[string]string
+	}{
+		{
+			name:    "valid account",
+			request: &models.CreateAccountRequest{Email: "a@example.com", Type: models.Retail, Balance: models.MustParseMoney("10.00")},
+			want:    map[string]string{},
+		},
+		{
+			name:    "invalid account",
+			request: &models.CreateAccountRequest{Email: "not-an-email", Type: "Savings", Balance: negative},
+			want:    map[string]string{"email": "email", "type": "account_type", "balance": "gte"},
+		},
+		{
+			name:    "zero hold",
+			request: &models.HoldRequest{Amount: models.MustParseMoney("0")},
+			want:    map[string]string{"amount": "gt"},
+		},
+		{
+			name:    "transfer between unknown wallets",
+			request: &models.TransferRequest{SourceWalletID: "not-an-id", Amount: models.MustParseMoney("1.00")},
+			want:    map[string]string{"source_wallet_id": "mongodb", "destination_wallet_id": "required"},
+		},
+		{
+			name:    "transaction type",
+			request: &models.CreateTransactionRequest{Type: models.Withdraw, Amount: models.MustParseMoney("1.00")},
+			want:    map[string]string{"type": "oneof"},
+		},
+		{
+			name:    "webhook",
+			request: &models.CreateWebhookRequest{URL: "example.com/hook", EventTypes: []models.EventType{models.EventWalletCreated, "wallet.renamed"}},
+			want:    map[string]string{"url": "http_url", "event_types.1": "event_type"},
+		},
+		{
+			name:    "tiered fee without tiers",
+			request: &models.CreateFeeScheduleRequest{TransactionType: models.Debit, Method: models.TieredFee},
+			want:    map[string]string{"tiers": "required_if"},
+		},
+		{
+			// Nested fields are reported by their dotted path, like the OpenAPI validation does
+			name: "fee tier",
+			request: &models.CreateFeeScheduleRequest{TransactionType: models.Debit, Method: models.TieredFee, Tiers: []models.FeeTier{
+				{UpTo: &upTo},
+				{Flat: negative},
+			}},
+			want: map[string]string{"tiers.1.flat": "gte"},
+		},
+	}
+	for _, test := range tests {
+		fields := validateRequest(translator, test.request)
+		rules := fieldRules(fields)
+		if len(rules) != len(test.want) {
+			t.Errorf("%s: field errors = %+v, want %v", test.name, fields, test.want)
+			continue
+		}
+		for field, rule := range test.want {
+			if rules[field] != rule {
+				t.Errorf("%s: %s failed %q, want %q", test.name, field, rules[field], rule)
+			}
+		}
+		for _, field := range fields {
+			if field.Message == "" || field.Message == field.Rule {
+				t.Errorf("%s: %s has no message for %s", test.name, field.Field, field.Rule)
+			}
+		}
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		code   string
+		fields map[string]string
+	}{
+		{name: "malformed JSON", body: `{"email":`, code: "invalid_request_body"},
+		{name: "negative amount", body: `{"email":"a@example.com","balance":"-1.00"}`, code: "invalid_request_body"},
+		{name: "invalid fields", body: `{"email":"","type":"Savings"}`, code: "validation_failed", fields: map[string]string{"email": "required", "type": "account_type"}},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("POST", "/accounts", strings.NewReader(test.body))
+		recorder := httptest.NewRecorder()
+		// The handler returns before touching the database
+		CreateAccountHandler(nil).ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", test.name, recorder.Code)
+			continue
+		}
+
+		var response struct {
+			Code    string              `json:"code"`
+			Details []models.FieldError `json:"details"`
+		}
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Code != test.code {
+			t.Errorf("%s: code = %q, want %q", test.name, response.Code, test.code)
+		}
+		rules := fieldRules(response.Details)
+		if len(rules) != len(test.fields) {
+			t.Errorf("%s: details = %+v, want %v", test.name, response.Details, test.fields)
+		}
+		for field, rule := range test.fields {
+			if rules[field] != rule {
+				t.Errorf("%s: %s failed %q, want %q", test.name, field, rules[field], rule)
+			}
+		}
+	}
+}
@@ -3,9 +3,12 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"mfus_WalletTransactionManager/models"
 	"mfus_WalletTransactionManager/services"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -14,41 +17,38 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// Handler for placing an authorization hold on account funds
 func HoldBalanceHandler(client *mongo.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse account ID from URL parameter
 		vars := mux.Vars(r)
 		accountID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid account ID"})
+			writeError(w, r, http.StatusBadRequest, "Invalid account ID")
 			return
 		}
-		// Parse request body
+		// Parse and validate request body
 		var request models.HoldRequest
-		err = json.NewDecoder(r.Body).Decode(&request)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request body"})
+		if !decodeRequest(w, r, &request) {
 			return
 		}
 
-		// Update account document with hold balance
-		update := bson.M{
-			"$inc": bson.M{"hold_balance": request.Amount},
+		// Place a named authorization hold on the account funds, within the account's transaction limits
+		var expiresAt time.Time
+		if request.ExpiresAt != nil {
+			expiresAt = *request.ExpiresAt
 		}
-		_, err = client.Database("walletManager").Collection("accounts").UpdateOne(context.Background(), bson.M{"_id": accountID}, update)
+		hold, err := services.CreateHold(client, accountID, request.Amount, request.Reference, expiresAt)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to hold balance"})
+			writeHoldError(w, r, err)
 			return
 		}
 
-		// Return success response
-		w.WriteHeader(http.StatusOK)
+		// Return success response with the new hold
+		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(models.SuccessResponse{
 			Message: "Balance held successfully",
-			Data:    nil,
+			Data:    hold,
 		})
 	}
 }
@@ -60,27 +60,26 @@ func ReleaseHoldBalanceHandler(client *mongo.Client) http.HandlerFunc {
 		vars := mux.Vars(r)
 		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid virtual wallet ID"})
+			writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
 			return
 		}
 		// Parse customer ID from query parameter
 		customerID := r.URL.Query().Get("customer_id")
 
-		// Parse request body
+		// Parse and validate request body
 		var request models.ReleaseHoldBalanceRequest
-		err = json.NewDecoder(r.Body).Decode(&request)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request body"})
+		if !decodeRequest(w, r, &request) {
 			return
 		}
 
 		// Create new virtual wallet transaction to release funds from hold balance
-		err = services.CreateVirtualWalletTransaction(client, virtualWalletID, customerID, "release", request.Amount)
+		err = services.CreateVirtualWalletTransaction(client, virtualWalletID, customerID, models.Release, request.Amount)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: err.Error()})
+			if err == services.ErrConcurrentUpdate {
+				writeError(w, r, http.StatusConflict, err.Error())
+			} else {
+				writeError(w, r, http.StatusBadRequest, err.Error())
+			}
 			return
 		}
 
@@ -93,14 +92,22 @@ func ReleaseHoldBalanceHandler(client *mongo.Client) http.HandlerFunc {
 	}
 }
 
+// Handler for listing a page of virtual wallet transactions.
+// Supports limit, cursor, order=asc|desc and filters on type, min_amount, max_amount, start_date and end_date.
 func GetVirtualWalletTransactionsHandler(client *mongo.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse virtual wallet ID from URL parameter
 		vars := mux.Vars(r)
 		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid virtual wallet ID"})
+			writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
+			return
+		}
+
+		// Parse paging, sorting and filter parameters
+		query, err := parseTransactionQuery(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
@@ -117,58 +124,169 @@ func GetVirtualWalletTransactionsHandler(client *mongo.Client) http.HandlerFunc
 		var virtualWallet models.VirtualWallet
 		err = client.Database("walletManager").Collection("virtual_wallets").FindOne(context.Background(), filter).Decode(&virtualWallet)
 		if err != nil {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Virtual wallet not found"})
+			writeError(w, r, http.StatusNotFound, "Virtual wallet not found")
+			return
+		}
+
+		// Load one page of the wallet history from the transactions ledger
+		page, err := services.ListWalletTransactions(client, virtualWallet.ID, query)
+		if err != nil {
+			if err == services.ErrInvalidCursor {
+				writeError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve virtual wallet transactions")
 			return
 		}
 
 		// Return success response with virtual wallet transactions
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(models.SuccessResponse{
-			Message: "Virtual wallet transactions retrieved successfully",
-			Data:    virtualWallet.Transactions,
+			Message:    "Virtual wallet transactions retrieved successfully",
+			Data:       page.Transactions,
+			NextCursor: page.NextCursor,
 		})
 	}
 }
 
+// Helper function to parse the paging, sorting and filter query parameters of a transaction listing
+func parseTransactionQuery(r *http.Request) (models.TransactionQuery, error) {
+	params := r.URL.Query()
+	query := models.TransactionQuery{Cursor: params.Get("cursor")}
+
+	if limit := params.Get("limit"); limit != "" {
+		value, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || value <= 0 {
+			return query, errors.New("Invalid limit. Must be a positive integer")
+		}
+		query.Limit = value
+	}
+
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, errors.New("Invalid order. Must be 'asc' or 'desc'")
+	}
+
+	if types := params.Get("type"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			query.Types = append(query.Types, models.TransactionType(strings.TrimSpace(t)))
+		}
+	}
+
+	if minAmount := params.Get("min_amount"); minAmount != "" {
+		amount, err := models.ParseMoney(minAmount, "")
+		if err != nil {
+			return query, errors.New("Invalid min_amount: " + err.Error())
+		}
+		query.MinAmount = &amount
+	}
+	if maxAmount := params.Get("max_amount"); maxAmount != "" {
+		amount, err := models.ParseMoney(maxAmount, "")
+		if err != nil {
+			return query, errors.New("Invalid max_amount: " + err.Error())
+		}
+		query.MaxAmount = &amount
+	}
+
+	// Dates are RFC3339 timestamps or whole days (2006-01-02); a whole end day is inclusive
+	var err error
+	if startDate := params.Get("start_date"); startDate != "" {
+		query.StartDate, err = parseQueryTime(startDate, false)
+		if err != nil {
+			return query, errors.New("Invalid start date")
+		}
+	}
+	if endDate := params.Get("end_date"); endDate != "" {
+		query.EndDate, err = parseQueryTime(endDate, true)
+		if err != nil {
+			return query, errors.New("Invalid end date")
+		}
+	}
+
+	return query, nil
+}
+
+// Helper function to parse an RFC3339 timestamp or a 2006-01-02 date.
+// For an end of range a bare date is moved to the start of the following day.
+func parseQueryTime(value string, endOfRange bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
 // Handler for creating a new virtual wallet
 func CreateVirtualWalletHandler(client *mongo.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Decode request body
+		// Decode and validate request body
 		var reqBody models.CreateVirtualWalletRequest
-		err := json.NewDecoder(r.Body).Decode(&reqBody)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request body"})
+		if !decodeRequest(w, r, &reqBody) {
 			return
 		}
-		// Validate customer ID
-		if reqBody.CustomerID == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Customer ID is required"})
+
+		// Wallets default to CashWallet; only a CreditWallet has a credit limit
+		if reqBody.WalletType == "" {
+			reqBody.WalletType = models.CashWallet
+		}
+		if !reqBody.CreditLimit.IsZero() && reqBody.WalletType != models.CreditWallet {
+			writeError(w, r, http.StatusBadRequest, services.ErrCreditLimitNotAllowed.Error())
 			return
 		}
 
-		// Validate balance
-		if reqBody.Balance < 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Balance cannot be negative"})
-			return
+		// The caller may only create empty wallets for its own account; operators may set the opening balance and credit limit
+		if !isAdminRequest(r) {
+			callerAccountID := r.Header.Get(AccountIDHeader)
+			if callerAccountID == "" {
+				writeError(w, r, http.StatusUnauthorized, "Missing "+AccountIDHeader+" header")
+				return
+			}
+			if callerAccountID != reqBody.CustomerID {
+				writeError(w, r, http.StatusForbidden, "Access to this account is not allowed")
+				return
+			}
+			if !reqBody.Balance.IsZero() {
+				writeError(w, r, http.StatusForbidden, "Opening balance requires admin access")
+				return
+			}
+			// Credit wallets get the configured credit limit instead
+			if !reqBody.CreditLimit.IsZero() {
+				writeError(w, r, http.StatusForbidden, "Credit limit requires admin access")
+				return
+			}
 		}
 
 		// Create new virtual wallet document
 		virtualWallet := models.VirtualWallet{
 			CustomerID:   reqBody.CustomerID,
+			WalletType:   reqBody.WalletType,
+			CreditLimit:  reqBody.CreditLimit,
 			Balance:      reqBody.Balance,
 			DateCreated:  time.Now(),
 			DateModified: time.Now(),
 		}
 
-		// Insert virtual wallet document into database
-		result, err := client.Database("walletManager").Collection("virtual_wallets").InsertOne(context.Background(), virtualWallet)
+		// Insert virtual wallet document and link it to the owning account
+		virtualWalletID, err := services.CreateVirtualWallet(client, virtualWallet)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to create virtual wallet"})
+			if err == services.ErrAccountNotFound {
+				writeError(w, r, http.StatusNotFound, "Account not found")
+				return
+			}
+			if err == models.ErrCurrencyMismatch {
+				writeError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, "Failed to create virtual wallet")
 			return
 		}
 
@@ -176,7 +294,7 @@ func CreateVirtualWalletHandler(client *mongo.Client) http.HandlerFunc {
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(models.SuccessResponse{
 			Message: "Virtual wallet created successfully",
-			Data:    result.InsertedID,
+			Data:    virtualWalletID,
 		})
 	}
 }
@@ -187,19 +305,21 @@ func GetAllVirtualWalletsHandler(client *mongo.Client) http.HandlerFunc {
 		// Retrieve all virtual wallet documents from database
 		cursor, err := client.Database("walletManager").Collection("virtual_wallets").Find(context.Background(), bson.M{})
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to retrieve virtual wallets"})
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve virtual wallets")
 			return
 		}
 		// Decode virtual wallet documents into slice
 		var virtualWallets []models.VirtualWallet
 		err = cursor.All(context.Background(), &virtualWallets)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to decode virtual wallets"})
+			writeError(w, r, http.StatusInternalServerError, "Failed to decode virtual wallets")
 			return
 		}
 
+		for i := range virtualWallets {
+			virtualWallets[i].Policy = services.WalletPolicyFor(&virtualWallets[i])
+		}
+
 		// Return success response with virtual wallet documents
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(models.SuccessResponse{
@@ -216,19 +336,16 @@ func GetVirtualWalletHandler(client *mongo.Client) http.HandlerFunc {
 		vars := mux.Vars(r)
 		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid virtual wallet ID"})
+			writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
 			return
 		}
 		// Retrieve virtual wallet document from database
 		virtualWallet, err := services.FindVirtualWallet(client, virtualWalletID, "")
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				w.WriteHeader(http.StatusNotFound)
-				json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Virtual wallet not found"})
+				writeError(w, r, http.StatusNotFound, "Virtual wallet not found")
 			} else {
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to retrieve virtual wallet"})
+				writeError(w, r, http.StatusInternalServerError, "Failed to retrieve virtual wallet")
 			}
 			return
 		}
@@ -242,37 +359,19 @@ func GetVirtualWalletHandler(client *mongo.Client) http.HandlerFunc {
 	}
 }
 
-// Handler for updating a virtual wallet by ID
+// Handler for setting the balance of a virtual wallet by ID. Admin only.
 func UpdateVirtualWalletHandler(client *mongo.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse virtual wallet ID from URL path parameter
 		vars := mux.Vars(r)
 		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid virtual wallet ID"})
+			writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
 			return
 		}
-		// Decode request body
-		var reqBody models.CreateVirtualWalletRequest
-		err = json.NewDecoder(r.Body).Decode(&reqBody)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid request body"})
-			return
-		}
-
-		// Validate customer ID
-		if reqBody.CustomerID == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Customer ID is required"})
-			return
-		}
-
-		// Validate balance
-		if reqBody.Balance < 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Balance cannot be negative"})
+		// Decode and validate request body
+		var reqBody models.UpdateVirtualWalletRequest
+		if !decodeRequest(w, r, &reqBody) {
 			return
 		}
 
@@ -280,28 +379,30 @@ func UpdateVirtualWalletHandler(client *mongo.Client) http.HandlerFunc {
 		virtualWallet, err := services.FindVirtualWallet(client, virtualWalletID, "")
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				w.WriteHeader(http.StatusNotFound)
-				json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Virtual wallet not found"})
+				writeError(w, r, http.StatusNotFound, "Virtual wallet not found")
 			} else {
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to retrieve virtual wallet"})
+				writeError(w, r, http.StatusInternalServerError, "Failed to retrieve virtual wallet")
 			}
 			return
 		}
 
-		// Update virtual wallet document
-		virtualWallet.CustomerID = reqBody.CustomerID
-		virtualWallet.Balance = reqBody.Balance
-		virtualWallet.DateModified = time.Now()
+		// Wallets stay linked to the account they were created for
+		if reqBody.CustomerID != virtualWallet.CustomerID {
+			writeError(w, r, http.StatusBadRequest, "Virtual wallet owner cannot be changed")
+			return
+		}
 
-		_, err = client.Database("walletManager").Collection("virtual_wallets").UpdateOne(
-			context.Background(),
-			bson.M{"_id": virtualWalletID},
-			bson.M{"$set": virtualWallet},
-		)
+		// Update virtual wallet balance through a ledger adjustment
+		err = services.SetVirtualWalletBalance(client, virtualWalletID, reqBody.Balance)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to update virtual wallet"})
+			switch err {
+			case models.ErrCurrencyMismatch:
+				writeError(w, r, http.StatusBadRequest, err.Error())
+			case services.ErrConcurrentUpdate:
+				writeError(w, r, http.StatusConflict, err.Error())
+			default:
+				writeError(w, r, http.StatusInternalServerError, "Failed to update virtual wallet")
+			}
 			return
 		}
 
@@ -320,20 +421,17 @@ func DeleteVirtualWalletHandler(client *mongo.Client) http.HandlerFunc {
 		vars := mux.Vars(r)
 		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Invalid virtual wallet ID"})
+			writeError(w, r, http.StatusBadRequest, "Invalid virtual wallet ID")
 			return
 		}
-		// Delete virtual wallet document from database
-		result, err := client.Database("walletManager").Collection("virtual_wallets").DeleteOne(context.Background(), bson.M{"_id": virtualWalletID})
+		// Delete virtual wallet document and unlink it from its account
+		err = services.DeleteVirtualWallet(client, virtualWalletID)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Failed to delete virtual wallet"})
-			return
-		}
-		if result.DeletedCount == 0 {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(models.ErrorResponse{Message: "Virtual wallet not found"})
+			if err == mongo.ErrNoDocuments {
+				writeError(w, r, http.StatusNotFound, "Virtual wallet not found")
+			} else {
+				writeError(w, r, http.StatusInternalServerError, "Failed to delete virtual wallet")
+			}
 			return
 		}
 
@@ -344,86 +442,3 @@ func DeleteVirtualWalletHandler(client *mongo.Client) http.HandlerFunc {
 		})
 	}
 }
-
-// To DO Need to test
-func GetVirtualWalletTransactionsByTrnTypeDtRangeHandler(client *mongo.Client) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse request parameters
-		vars := mux.Vars(r)
-		virtualWalletID, err := primitive.ObjectIDFromHex(vars["id"])
-		if err != nil {
-			http.Error(w, "Invalid virtual wallet ID", http.StatusBadRequest)
-			return
-		}
-		filter := r.URL.Query().Get("type")
-		startDateStr := r.URL.Query().Get("start_date")
-		endDateStr := r.URL.Query().Get("end_date")
-
-		// Find virtual wallet document by ID
-		virtualWallet, err := services.FindVirtualWallet(client, virtualWalletID, "")
-		if err != nil {
-			http.Error(w, "Virtual wallet not found", http.StatusNotFound)
-			return
-		}
-
-		// Filter transactions by type
-		var filteredTransactions []models.Transaction
-		if filter != "" {
-			for _, transaction := range virtualWallet.Transactions {
-				filteredTransactions = append(filteredTransactions, transaction)
-			}
-		} else {
-			filteredTransactions = virtualWallet.Transactions
-		}
-
-		// Filter transactions by date range
-		var startDate, endDate time.Time
-		if startDateStr != "" {
-			startDate, err = time.Parse("2006-01-02", startDateStr)
-			if err != nil {
-				http.Error(w, "Invalid start date", http.StatusBadRequest)
-				return
-			}
-		} else {
-			startDate = time.Time{}
-		}
-		if endDateStr != "" {
-			endDate, err = time.Parse("2006-01-02", endDateStr)
-			if err != nil {
-				http.Error(w, "Invalid end date", http.StatusBadRequest)
-				return
-			}
-		} else {
-			endDate = time.Now()
-		}
-
-		// Filter transactions by date range
-		var dateFilteredTransactions []models.Transaction
-		for _, transaction := range filteredTransactions {
-			if models.TransactionType(transaction.Type) == transactionType(filter) &&
-				startDate.Before(transaction.CreatedAt) && endDate.After(transaction.CreatedAt) {
-				dateFilteredTransactions = append(dateFilteredTransactions, transaction)
-			}
-		}
-
-		// Marshal transactions to JSON and write response
-		jsonData, err := json.Marshal(dateFilteredTransactions)
-		if err != nil {
-			http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(jsonData)
-	}
-}
-
-func transactionType(str string) models.TransactionType {
-	switch str {
-	case "DebitTransaction":
-		return models.Deposit
-	case "CreditTransaction":
-		return models.Withdraw
-	default:
-		return models.Deposit
-	}
-}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseTransactionQuery(t *testing.T) {
+	request := httptest.NewRequest("GET", "/virtual_wallets/x/transactions?limit=25&order=desc&type=credit,%20debit&min_amount=1.50&max_amount=10&start_date=2024-01-01&end_date=2024-01-31", nil)
+	query, err := parseTransactionQuery(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Limit != 25 || !query.Descending {
+		t.Errorf("Limit %d, descending %v; want 25 and true", query.Limit, query.Descending)
+	}
+	if len(query.Types) != 2 || query.Types[0] != models.Credit || query.Types[1] != models.Debit {
+		t.Errorf("Types are %v", query.Types)
+	}
+	if *query.MinAmount != models.MustParseMoney("1.50") || *query.MaxAmount != models.MustParseMoney("10") {
+		t.Errorf("Amount range is %v to %v", query.MinAmount, query.MaxAmount)
+	}
+	if !query.StartDate.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Start date is %v", query.StartDate)
+	}
+	// A whole end day is inclusive
+	if !query.EndDate.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("End date is %v, want the start of the following day", query.EndDate)
+	}
+
+	for _, invalid := range []string{"limit=0", "limit=abc", "order=sideways", "min_amount=-1", "max_amount=1.001", "start_date=yesterday", "end_date=2024-13-01"} {
+		if _, err := parseTransactionQuery(httptest.NewRequest("GET", "/?"+invalid, nil)); err == nil {
+			t.Errorf("%s was accepted", invalid)
+		}
+	}
+}
+
+func TestWalletOwnershipMiddleware(t *testing.T) {
+	client := testMongoClient(t)
+	ownerID, walletID := createTestWallet(t, client, "10.00")
+
+	router := mux.NewRouter()
+	router.Handle("/virtual_wallets/{id}", WalletOwnershipMiddleware(client, GetVirtualWalletHandler(client))).Methods("GET")
+
+	tests := []struct {
+		name     string
+		walletID string
+		caller   string
+		want     int
+	}{
+		{name: "owner", walletID: walletID.Hex(), caller: ownerID.Hex(), want: http.StatusOK},
+		{name: "missing caller", walletID: walletID.Hex(), want: http.StatusUnauthorized},
+		{name: "invalid wallet ID", walletID: "not-an-id", caller: ownerID.Hex(), want: http.StatusBadRequest},
+		{name: "other account", walletID: walletID.Hex(), caller: primitive.NewObjectID().Hex(), want: http.StatusForbidden},
+		{name: "unknown wallet", walletID: primitive.NewObjectID().Hex(), caller: ownerID.Hex(), want: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("GET", "/virtual_wallets/"+test.walletID, nil)
+		if test.caller != "" {
+			request.Header.Set(AccountIDHeader, test.caller)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.want)
+		}
+	}
+}
+
+func TestGetAccountWalletsHandler(t *testing.T) {
+	client := testMongoClient(t)
+	ownerID, walletID := createTestWallet(t, client, "10.00")
+
+	router := mux.NewRouter()
+	router.Handle("/accounts/{id}/wallets", AccountOwnershipMiddleware(GetAccountWalletsHandler(client))).Methods("GET")
+
+	request := httptest.NewRequest("GET", "/accounts/"+ownerID.Hex()+"/wallets", nil)
+	request.Header.Set(AccountIDHeader, ownerID.Hex())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	var response struct {
+		Data []models.VirtualWallet `json:"data"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Data) != 1 || response.Data[0].ID != walletID || response.Data[0].Balance != models.MustParseMoney("10.00") {
+		t.Errorf("wallets = %+v, want the one wallet with 10.00", response.Data)
+	}
+
+	request = httptest.NewRequest("GET", "/accounts/"+ownerID.Hex()+"/wallets", nil)
+	request.Header.Set(AccountIDHeader, primitive.NewObjectID().Hex())
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("listing another account's wallets: status = %d, want 403", recorder.Code)
+	}
+}
+
+func TestCreateVirtualWalletHandlerChecksWalletType(t *testing.T) {
+	customerID := primitive.NewObjectID().Hex()
+	for _, body := range []string{
+		`{"customer_id": "` + customerID + `", "wallet_type": "PiggyBank", "balance": "0"}`,
+		`{"customer_id": "` + customerID + `", "wallet_type": "CashWallet", "credit_limit": "50.00", "balance": "0"}`,
+		`{"customer_id": "` + customerID + `", "credit_limit": "50.00", "balance": "0"}`,
+	} {
+		recorder := httptest.NewRecorder()
+		CreateVirtualWalletHandler(nil).ServeHTTP(recorder, httptest.NewRequest("POST", "/virtual_wallets", strings.NewReader(body)))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, recorder.Code)
+		}
+	}
+}
+
+func TestCreateVirtualWalletHandlerAuthorization(t *testing.T) {
+	customerID := primitive.NewObjectID().Hex()
+	tests := []struct {
+		name        string
+		caller      string
+		balance     string
+		creditLimit string
+		want        int
+	}{
+		{name: "missing caller", balance: "0", want: http.StatusUnauthorized},
+		{name: "other account", caller: primitive.NewObjectID().Hex(), balance: "0", want: http.StatusForbidden},
+		// Only operators may fund a wallet without a ledger entry
+		{name: "opening balance", caller: customerID, balance: "100.00", want: http.StatusForbidden},
+		{name: "credit limit", caller: customerID, balance: "0", creditLimit: "5000.00", want: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		body := `{"customer_id": "` + customerID + `", "wallet_type": "CreditWallet", "balance": "` + test.balance + `"`
+		if test.creditLimit != "" {
+			body += `, "credit_limit": "` + test.creditLimit + `"`
+		}
+		body += "}"
+		request := httptest.NewRequest("POST", "/virtual_wallets", strings.NewReader(body))
+		if test.caller != "" {
+			request.Header.Set(AccountIDHeader, test.caller)
+		}
+		recorder := httptest.NewRecorder()
+		// The handler returns before touching the database
+		CreateVirtualWalletHandler(nil).ServeHTTP(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.name, recorder.Code, test.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Handler for subscribing a webhook URL to the customer's events. The response is the only time the secret is returned.
+func CreateWebhookHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		customerID := mux.Vars(r)["id"]
+
+		// Parse and validate request body
+		var request models.CreateWebhookRequest
+		if !decodeRequest(w, r, &request) {
+			return
+		}
+
+		subscription, err := services.CreateWebhookSubscription(client, customerID, request)
+		if err != nil {
+			switch err {
+			case services.ErrInvalidWebhookURL, services.ErrWebhookHostNotAllowed, services.ErrInvalidEventType:
+				writeError(w, r, http.StatusBadRequest, err.Error())
+			default:
+				writeError(w, r, http.StatusInternalServerError, "Failed to create webhook subscription")
+			}
+			return
+		}
+
+		// Return success response with the new subscription
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Webhook subscription created successfully",
+			Data:    subscription,
+		})
+	}
+}
+
+// Handler for listing the customer's webhook subscriptions
+func GetWebhooksHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subscriptions, err := services.ListWebhookSubscriptions(client, mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve webhook subscriptions")
+			return
+		}
+
+		// Return success response with the subscriptions
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Webhook subscriptions retrieved successfully",
+			Data:    subscriptions,
+		})
+	}
+}
+
+// Handler for deleting a webhook subscription
+func DeleteWebhookHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse subscription ID from URL parameter
+		vars := mux.Vars(r)
+		subscriptionID, err := primitive.ObjectIDFromHex(vars["webhook_id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid webhook ID")
+			return
+		}
+
+		err = services.DeleteWebhookSubscription(client, vars["id"], subscriptionID)
+		if err != nil {
+			if err == services.ErrWebhookNotFound {
+				writeError(w, r, http.StatusNotFound, err.Error())
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, "Failed to delete webhook subscription")
+			return
+		}
+
+		// Return success response
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Webhook subscription deleted successfully",
+		})
+	}
+}
+
+// Handler for the delivery log of a webhook subscription. Accepts an optional status and limit (default 50).
+func GetWebhookDeliveriesHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse subscription ID from URL parameter
+		vars := mux.Vars(r)
+		subscriptionID, err := primitive.ObjectIDFromHex(vars["webhook_id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid webhook ID")
+			return
+		}
+		limit, ok := parseDeliveryLimit(w, r)
+		if !ok {
+			return
+		}
+		status := models.WebhookDeliveryStatus(r.URL.Query().Get("status"))
+		switch status {
+		case "", models.WebhookPending, models.WebhookDelivered, models.WebhookDead:
+		default:
+			writeError(w, r, http.StatusBadRequest, "Invalid status. Must be 'pending', 'delivered' or 'dead'")
+			return
+		}
+
+		deliveries, err := services.ListWebhookDeliveries(client, vars["id"], subscriptionID, status, limit)
+		if err != nil {
+			if err == services.ErrWebhookNotFound {
+				writeError(w, r, http.StatusNotFound, err.Error())
+				return
+			}
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve webhook deliveries")
+			return
+		}
+
+		// Return success response with the deliveries and their attempts
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Webhook deliveries retrieved successfully",
+			Data:    deliveries,
+		})
+	}
+}
+
+// Handler for listing the customer's dead-letter deliveries. Accepts an optional limit (default 50).
+func GetDeadWebhookDeliveriesHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := parseDeliveryLimit(w, r)
+		if !ok {
+			return
+		}
+
+		deliveries, err := services.ListDeadWebhookDeliveries(client, mux.Vars(r)["id"], limit)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "Failed to retrieve dead letters")
+			return
+		}
+
+		// Return success response with the dead letters
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Dead letters retrieved successfully",
+			Data:    deliveries,
+		})
+	}
+}
+
+// Handler for sending a delivered or dead webhook delivery again
+func RedeliverWebhookHandler(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse delivery ID from URL parameter
+		vars := mux.Vars(r)
+		deliveryID, err := primitive.ObjectIDFromHex(vars["delivery_id"])
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid delivery ID")
+			return
+		}
+
+		delivery, err := services.RedeliverWebhook(client, vars["id"], deliveryID)
+		if err != nil {
+			switch err {
+			case services.ErrWebhookDeliveryNotFound:
+				writeError(w, r, http.StatusNotFound, err.Error())
+			case services.ErrWebhookDeliveryNotFinished:
+				writeError(w, r, http.StatusConflict, err.Error())
+			default:
+				writeError(w, r, http.StatusInternalServerError, "Failed to redeliver webhook")
+			}
+			return
+		}
+
+		// Return success response with the queued delivery
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(models.SuccessResponse{
+			Message: "Webhook delivery queued",
+			Data:    delivery,
+		})
+	}
+}
+
+// Helper function to parse the limit parameter of the delivery lists
+func parseDeliveryLimit(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	limit := int64(50)
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			writeError(w, r, http.StatusBadRequest, "Invalid limit. Must be a positive integer")
+			return 0, false
+		}
+		limit = parsed
+	}
+	return limit, true
+}
@@ -10,12 +10,12 @@ type Account struct {
 	ID             primitive.ObjectID `bson:"_id,omitempty"`
 	Email          string             `bson:"email"`
 	Type           AccountType        `bson:"type,omitempty"`
-	Balance        float64            `bson:"balance,omitempty"`
-	HoldBalance    float64            `bson:"hold_balance,omitempty"`
-	Transactions   []Transaction      `bson:"transactions,omitempty"`
+	Balance        Money              `bson:"balance"`
+	HoldBalance    Money              `bson:"hold_balance"`
 	CreatedAt      time.Time          `bson:"created_at,omitempty"`
 	DateModified   time.Time          `bson:"date_modified"`
 	VirtualWallets []string           `bson:"virtual_wallets,omitempty"`
+	LimitOverrides *TransactionLimits `bson:"limit_overrides,omitempty"`
 }
 
 type AccountType string
@@ -28,16 +28,62 @@ const (
 	PrimeCorporate AccountType = "PrimeCorporate"
 )
 
+// IsValid reports whether the account type is one of the supported types
+func (t AccountType) IsValid() bool {
+	switch t {
+	case Retail, Corporate, ChannelPartner, Traders, PrimeCorporate:
+		return true
+	}
+	return false
+}
+
+// Transaction is an entry in the transactions ledger collection, owned by either a virtual wallet or an account.
+// RefundedAmount is the total of the reversal or refunds posted against it.
 type Transaction struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty"`
-	Type      TransactionType    `bson:"type,omitempty"`
-	Amount    float64            `bson:"amount,omitempty"`
-	CreatedAt time.Time          `bson:"created_at,omitempty"`
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	WalletID       primitive.ObjectID `bson:"wallet_id,omitempty"`
+	AccountID      primitive.ObjectID `bson:"account_id,omitempty"`
+	Type           TransactionType    `bson:"type,omitempty"`
+	Amount         Money              `bson:"amount,omitempty"`
+	Reference      string             `bson:"reference,omitempty"`
+	TransferID     primitive.ObjectID `bson:"transfer_id,omitempty"`
+	HoldID         primitive.ObjectID `bson:"hold_id,omitempty"`
+	LinkedID       primitive.ObjectID `bson:"linked_transaction_id,omitempty"`
+	Status         TransactionStatus  `bson:"status,omitempty"`
+	RefundedAmount Money              `bson:"refunded_amount,omitempty"`
+	ReasonCode     ReasonCode         `bson:"reason_code,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at,omitempty"`
 }
 
+// TransactionStatus is empty for a transaction that was never reversed or refunded
+type TransactionStatus string
+
+const (
+	TransactionReversed          TransactionStatus = "reversed"
+	TransactionPartiallyRefunded TransactionStatus = "partially_refunded"
+	TransactionRefunded          TransactionStatus = "refunded"
+)
+
 type TransactionType string
 
 const (
-	Deposit  TransactionType = "deposit"
-	Withdraw TransactionType = "withdraw"
+	Deposit     TransactionType = "deposit"
+	Withdraw    TransactionType = "withdraw"
+	Credit      TransactionType = "credit"
+	Debit       TransactionType = "debit"
+	Hold        TransactionType = "hold"
+	Release     TransactionType = "release"
+	Capture     TransactionType = "capture"
+	Fee         TransactionType = "fee"
+	FeeIncome   TransactionType = "fee_income"
+	TransferOut TransactionType = "transfer_out"
+	TransferIn  TransactionType = "transfer_in"
+	Reversal    TransactionType = "reversal"
+	Refund      TransactionType = "refund"
+
+	// Adjustments correct the ledger without moving money, unless their ReasonCode moves the balance
+	AdjustmentCredit     TransactionType = "adjustment_credit"
+	AdjustmentDebit      TransactionType = "adjustment_debit"
+	HoldAdjustmentCredit TransactionType = "hold_adjustment_credit"
+	HoldAdjustmentDebit  TransactionType = "hold_adjustment_debit"
 )
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BalanceSnapshot records the balances of a virtual wallet or an account at TakenAt.
+// Point-in-time balances start from the latest snapshot and replay the ledger from there.
+type BalanceSnapshot struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	WalletID    primitive.ObjectID `bson:"wallet_id,omitempty" json:"wallet_id,omitempty"`
+	AccountID   primitive.ObjectID `bson:"account_id,omitempty" json:"account_id,omitempty"`
+	Balance     Money              `bson:"balance" json:"balance"`
+	HoldBalance Money              `bson:"hold_balance" json:"hold_balance"`
+	TakenAt     time.Time          `bson:"taken_at" json:"as_of"`
+}
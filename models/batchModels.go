@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BatchStatus string
+
+const (
+	BatchProcessing BatchStatus = "processing"
+	BatchCompleted  BatchStatus = "completed"
+	BatchFailed     BatchStatus = "failed"
+)
+
+// BatchMode decides what happens to the valid rows of a batch when other rows are rejected
+type BatchMode string
+
+const (
+	// Every valid row is applied on its own and gets its own result
+	BatchPerRow BatchMode = "per_row"
+	// Either every row is applied in one MongoDB transaction or none is
+	BatchAllOrNothing BatchMode = "all_or_nothing"
+)
+
+// Batch is a bulk import of wallet transactions stored in the batches collection; its rows are in batch_rows
+type Batch struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Mode        BatchMode          `bson:"mode" json:"mode"`
+	Format      string             `bson:"format" json:"format"`
+	Status      BatchStatus        `bson:"status" json:"status"`
+	TotalRows   int64              `bson:"total_rows" json:"total_rows"`
+	Processed   int64              `bson:"processed" json:"processed"`
+	Succeeded   int64              `bson:"succeeded" json:"succeeded"`
+	Rejected    int64              `bson:"rejected" json:"rejected"`
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+type BatchRowStatus string
+
+const (
+	BatchRowPending  BatchRowStatus = "pending"
+	BatchRowApplied  BatchRowStatus = "applied"
+	BatchRowRejected BatchRowStatus = "rejected"
+	// Valid rows of an all-or-nothing batch that was not applied
+	BatchRowSkipped BatchRowStatus = "skipped"
+)
+
+// BatchRow is one line of an imported file. Raw values are kept so rejected rows can be reported as uploaded.
+type BatchRow struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	BatchID       primitive.ObjectID `bson:"batch_id" json:"-"`
+	Line          int64              `bson:"line" json:"line"`
+	WalletID      string             `bson:"wallet_id" json:"wallet_id"`
+	Type          string             `bson:"type" json:"type"`
+	Amount        string             `bson:"amount" json:"amount"`
+	Reference     string             `bson:"reference,omitempty" json:"reference,omitempty"`
+	Status        BatchRowStatus     `bson:"status" json:"status"`
+	Error         string             `bson:"error,omitempty" json:"error,omitempty"`
+	TransactionID primitive.ObjectID `bson:"transaction_id,omitempty" json:"transaction_id,omitempty"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FeeMethod string
+
+const (
+	FlatFee       FeeMethod = "flat"
+	PercentageFee FeeMethod = "percentage"
+	TieredFee     FeeMethod = "tiered"
+)
+
+// FeeTier applies to amounts up to UpTo (inclusive); the top tier leaves UpTo empty to cover everything above
+type FeeTier struct {
+	UpTo        *Money `bson:"up_to,omitempty" json:"up_to,omitempty" validate:"omitempty,gt=0"`
+	Flat        Money  `bson:"flat" json:"flat" validate:"gte=0"`
+	BasisPoints int64  `bson:"basis_points" json:"basis_points" validate:"gte=0"`
+}
+
+// FeeSchedule is a fee rule stored in the fee_schedules collection. Empty AccountType or WalletType
+// match every type; the most specific active schedule for a transaction wins. A schedule only applies
+// to transactions in its Currency, and all of its amounts are in that currency.
+// Percentages are expressed in basis points (1/100 of a percent).
+type FeeSchedule struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name            string             `bson:"name" json:"name"`
+	TransactionType TransactionType    `bson:"transaction_type" json:"transaction_type"`
+	AccountType     AccountType        `bson:"account_type,omitempty" json:"account_type,omitempty"`
+	WalletType      WalletType         `bson:"wallet_type,omitempty" json:"wallet_type,omitempty"`
+	Currency        string             `bson:"currency,omitempty" json:"currency"`
+	Method          FeeMethod          `bson:"method" json:"method"`
+	Flat            Money              `bson:"flat,omitempty" json:"flat"`
+	BasisPoints     int64              `bson:"basis_points,omitempty" json:"basis_points"`
+	Tiers           []FeeTier          `bson:"tiers,omitempty" json:"tiers,omitempty"`
+	Min             *Money             `bson:"min,omitempty" json:"min,omitempty"`
+	Max             *Money             `bson:"max,omitempty" json:"max,omitempty"`
+	Active          bool               `bson:"active" json:"active"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Request body for creating a fee schedule. Tiers are required by the tiered method.
+// Currency defaults to INR and every amount of the schedule must be in it.
+type CreateFeeScheduleRequest struct {
+	Name            string          `json:"name"`
+	TransactionType TransactionType `json:"transaction_type" validate:"required,oneof=deposit credit withdraw debit"`
+	AccountType     AccountType     `json:"account_type" validate:"omitempty,account_type"`
+	WalletType      WalletType      `json:"wallet_type" validate:"omitempty,wallet_type"`
+	Currency        string          `json:"currency" validate:"omitempty,iso4217"`
+	Method          FeeMethod       `json:"method" validate:"required,oneof=flat percentage tiered"`
+	Flat            Money           `json:"flat" validate:"gte=0"`
+	BasisPoints     int64           `json:"basis_points" validate:"gte=0"`
+	Tiers           []FeeTier       `json:"tiers" validate:"required_if=Method tiered,dive"`
+	Min             *Money          `json:"min" validate:"omitempty,gte=0"`
+	Max             *Money          `json:"max" validate:"omitempty,gte=0"`
+	Active          bool            `json:"active"`
+}
+
+// FeeQuote is the fee that a transaction of the given amount would be charged
+type FeeQuote struct {
+	Amount     Money               `json:"amount"`
+	Fee        Money               `json:"fee"`
+	Total      Money               `json:"total"`
+	ScheduleID *primitive.ObjectID `json:"schedule_id,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuthorizationHold is a card-style authorization that reserves account funds until it is captured, voided or expires
+type AuthorizationHold struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AccountID      primitive.ObjectID `bson:"account_id" json:"account_id"`
+	Amount         Money              `bson:"amount" json:"amount"`
+	CapturedAmount Money              `bson:"captured_amount" json:"captured_amount"`
+	Reference      string             `bson:"reference,omitempty" json:"reference,omitempty"`
+	Status         HoldStatus         `bson:"status" json:"status"`
+	ExpiresAt      time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	DateModified   time.Time          `bson:"date_modified" json:"date_modified"`
+}
+
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "active"
+	HoldCaptured HoldStatus = "captured"
+	HoldVoided   HoldStatus = "voided"
+	HoldExpired  HoldStatus = "expired"
+)
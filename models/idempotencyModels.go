@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyRecord stores the outcome of a money-moving request so a retried request can be answered from cache.
+// Keys are scoped to the caller that sent them, so two callers may use the same key independently.
+type IdempotencyRecord struct {
+	ID           string    `bson:"_id"`
+	Scope        string    `bson:"scope"`
+	Key          string    `bson:"key"`
+	RequestHash  string    `bson:"request_hash"`
+	Completed    bool      `bson:"completed"`
+	StatusCode   int       `bson:"status_code,omitempty"`
+	ContentType  string    `bson:"content_type,omitempty"`
+	ResponseBody []byte    `bson:"response_body,omitempty"`
+	CreatedAt    time.Time `bson:"created_at"`
+}
@@ -0,0 +1,124 @@
+package models
+
+import "encoding/xml"
+
+// ISO 20022 cash management messages. Only the elements the wallet manager fills are declared;
+// field order follows the sequence of the camt.053.001.08 and camt.054.001.08 schemas.
+const (
+	Camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.08"
+	Camt054Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.054.001.08"
+)
+
+// Camt053Document is a BankToCustomerStatement
+type Camt053Document struct {
+	XMLName   xml.Name           `xml:"Document"`
+	Namespace string             `xml:"xmlns,attr"`
+	Statement CamtBankToCustomer `xml:"BkToCstmrStmt"`
+}
+
+// Camt054Document is a BankToCustomerDebitCreditNotification
+type Camt054Document struct {
+	XMLName      xml.Name              `xml:"Document"`
+	Namespace    string                `xml:"xmlns,attr"`
+	Notification CamtDebitCreditNotice `xml:"BkToCstmrDbtCdtNtfctn"`
+}
+
+type CamtBankToCustomer struct {
+	GroupHeader CamtGroupHeader `xml:"GrpHdr"`
+	Statements  []CamtStatement `xml:"Stmt"`
+}
+
+type CamtDebitCreditNotice struct {
+	GroupHeader   CamtGroupHeader `xml:"GrpHdr"`
+	Notifications []CamtStatement `xml:"Ntfctn"`
+}
+
+type CamtGroupHeader struct {
+	MessageID string `xml:"MsgId"`
+	CreatedAt string `xml:"CreDtTm"`
+}
+
+// CamtStatement is an AccountStatement9 in camt.053 and an AccountNotification17 in camt.054.
+// Notifications carry no balances.
+type CamtStatement struct {
+	ID        string          `xml:"Id"`
+	CreatedAt string          `xml:"CreDtTm"`
+	FromTo    *CamtFromTo     `xml:"FrToDt,omitempty"`
+	Account   CamtAccount     `xml:"Acct"`
+	Balances  []CamtBalance   `xml:"Bal,omitempty"`
+	Summary   *CamtTxsSummary `xml:"TxsSummry,omitempty"`
+	Entries   []CamtEntry     `xml:"Ntry,omitempty"`
+}
+
+type CamtFromTo struct {
+	From string `xml:"FrDtTm"`
+	To   string `xml:"ToDtTm"`
+}
+
+type CamtAccount struct {
+	ID       CamtOtherID `xml:"Id>Othr"`
+	Currency string      `xml:"Ccy"`
+	Owner    *CamtOwner  `xml:"Ownr,omitempty"`
+}
+
+type CamtOwner struct {
+	ID CamtOtherID `xml:"Id>PrvtId>Othr"`
+}
+
+type CamtOtherID struct {
+	ID string `xml:"Id"`
+}
+
+// CamtAmount is an ActiveOrHistoricCurrencyAndAmount. The value is never negative; the direction is given by CdtDbtInd.
+type CamtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type CamtBalance struct {
+	Code        string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount      CamtAmount `xml:"Amt"`
+	CreditDebit string     `xml:"CdtDbtInd"`
+	DateTime    string     `xml:"Dt>DtTm"`
+}
+
+type CamtTxsSummary struct {
+	Total   CamtTotal     `xml:"TtlNtries"`
+	Credits CamtNumberSum `xml:"TtlCdtNtries"`
+	Debits  CamtNumberSum `xml:"TtlDbtNtries"`
+}
+
+type CamtTotal struct {
+	Count       string `xml:"NbOfNtries"`
+	Sum         string `xml:"Sum"`
+	NetAmount   string `xml:"TtlNetNtry>Amt"`
+	CreditDebit string `xml:"TtlNetNtry>CdtDbtInd"`
+}
+
+type CamtNumberSum struct {
+	Count string `xml:"NbOfNtries"`
+	Sum   string `xml:"Sum"`
+}
+
+// CamtEntry is a ReportEntry10
+type CamtEntry struct {
+	Reference   string          `xml:"NtryRef,omitempty"`
+	Amount      CamtAmount      `xml:"Amt"`
+	CreditDebit string          `xml:"CdtDbtInd"`
+	Reversal    bool            `xml:"RvslInd,omitempty"`
+	Status      string          `xml:"Sts>Cd"`
+	BookingDate string          `xml:"BookgDt>DtTm"`
+	ValueDate   string          `xml:"ValDt>DtTm"`
+	ServicerRef string          `xml:"AcctSvcrRef,omitempty"`
+	BankTxCode  CamtProprietary `xml:"BkTxCd>Prtry"`
+	Details     *CamtTxDetails  `xml:"NtryDtls>TxDtls,omitempty"`
+}
+
+type CamtProprietary struct {
+	Code   string `xml:"Cd"`
+	Issuer string `xml:"Issr,omitempty"`
+}
+
+type CamtTxDetails struct {
+	Unstructured string `xml:"RmtInf>Ustrd,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TransactionLimits are the velocity controls applied to an account and its wallets.
+// A nil field means the limit is not enforced.
+type TransactionLimits struct {
+	MaxPerTransaction *Money `bson:"max_per_transaction,omitempty" json:"max_per_transaction,omitempty" validate:"omitempty,gte=0"`
+	DailyWithdrawal   *Money `bson:"daily_withdrawal,omitempty" json:"daily_withdrawal,omitempty" validate:"omitempty,gte=0"`
+	MonthlyWithdrawal *Money `bson:"monthly_withdrawal,omitempty" json:"monthly_withdrawal,omitempty" validate:"omitempty,gte=0"`
+	MaxCountPerHour   *int64 `bson:"max_count_per_hour,omitempty" json:"max_count_per_hour,omitempty" validate:"omitempty,gte=0"`
+}
+
+// LimitProfile stores the configured limits of one AccountType in the limit_profiles collection
+type LimitProfile struct {
+	AccountType AccountType       `bson:"_id" json:"account_type"`
+	Limits      TransactionLimits `bson:"limits" json:"limits"`
+}
+
+// LimitViolation explains which limit rejected a transaction and how much headroom is left
+type LimitViolation struct {
+	Limit     string `json:"limit"`
+	Maximum   string `json:"maximum"`
+	Used      string `json:"used"`
+	Requested string `json:"requested"`
+	Headroom  string `json:"headroom"`
+}
+
+// LimitUsage is what an account has used of its limits in the current UTC hour, day and month.
+// It is stored in the limit_usage collection and updated in the same MongoDB transaction as every
+// customer-initiated ledger entry, so concurrent transactions cannot both use the same headroom.
+type LimitUsage struct {
+	AccountID      primitive.ObjectID `bson:"_id"`
+	HourStart      time.Time          `bson:"hour_start"`
+	HourCount      int64              `bson:"hour_count"`
+	DayStart       time.Time          `bson:"day_start"`
+	DayWithdrawn   Money              `bson:"day_withdrawn"`
+	MonthStart     time.Time          `bson:"month_start"`
+	MonthWithdrawn Money              `bson:"month_withdrawn"`
+}
@@ -0,0 +1,327 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// DefaultCurrency is used whenever an amount is supplied without a currency code
+const DefaultCurrency = "INR"
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrNegativeAmount   = errors.New("amount cannot be negative")
+	ErrAmountPrecision  = errors.New("amount has more decimal places than the currency allows")
+	ErrAmountOverflow   = errors.New("amount is out of range")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// Number of minor units digits for currencies that do not use two decimals
+var currencyExponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+}
+
+// CurrencyExponent returns the number of decimal places used by a currency
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// Money is an exact amount stored as integer minor units (paise, cents, ...) of a currency.
+// It is encoded as a decimal string in JSON and as a {units, currency} document in BSON,
+// so balances can be updated with $inc on their units without losing precision.
+type Money struct {
+	Units    int64
+	Currency string
+}
+
+// NewMoney creates a Money value from minor units
+func NewMoney(units int64, currency string) Money {
+	return Money{Units: units, Currency: currency}
+}
+
+// ParseMoney parses a non-negative decimal amount such as "125.50" or "125.50 INR".
+// If the string carries no currency code the given currency is used.
+func ParseMoney(s string, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if fields := strings.Fields(s); len(fields) == 2 {
+		s, currency = fields[0], strings.ToUpper(fields[1])
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	lower := strings.ToLower(strings.TrimLeft(s, "+-"))
+	if lower == "nan" || strings.HasPrefix(lower, "inf") {
+		return Money{}, ErrInvalidAmount
+	}
+	if strings.HasPrefix(s, "-") {
+		return Money{}, ErrNegativeAmount
+	}
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" || !isDigits(whole) || (hasFrac && (frac == "" || !isDigits(frac))) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	exp := CurrencyExponent(currency)
+	if len(frac) > exp {
+		// Trailing zeros beyond the currency precision are harmless
+		if strings.TrimRight(frac[exp:], "0") != "" {
+			return Money{}, ErrAmountPrecision
+		}
+		frac = frac[:exp]
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, ErrAmountOverflow
+	}
+
+	return Money{Units: units, Currency: currency}, nil
+}
+
+// MustParseMoney is like ParseMoney but panics on error. Intended for constants and defaults.
+func MustParseMoney(s string) Money {
+	m, err := ParseMoney(s, "")
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// MoneyFromFloat converts a legacy float64 amount into Money, rounding to the nearest minor unit
+func MoneyFromFloat(f float64, currency string) (Money, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Money{}, ErrInvalidAmount
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	scaled := math.Round(f * math.Pow10(CurrencyExponent(currency)))
+	if scaled > math.MaxInt64 || scaled < math.MinInt64 {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Units: int64(scaled), Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrencyCode returns the currency of the amount, falling back to DefaultCurrency
+func (m Money) CurrencyCode() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+// IsZero reports whether the amount is zero. It also lets BSON omitempty skip zero amounts.
+func (m Money) IsZero() bool {
+	return m.Units == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Units > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Units < 0
+}
+
+// Neg returns the amount with its sign flipped
+func (m Money) Neg() Money {
+	return Money{Units: -m.Units, Currency: m.Currency}
+}
+
+// Add returns m + o. Both amounts must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.CurrencyCode() != o.CurrencyCode() {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum := m.Units + o.Units
+	if (o.Units > 0 && sum < m.Units) || (o.Units < 0 && sum > m.Units) {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Units: sum, Currency: m.CurrencyCode()}, nil
+}
+
+// Sub returns m - o. Both amounts must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Units == math.MinInt64 {
+		return Money{}, ErrAmountOverflow
+	}
+	return m.Add(o.Neg())
+}
+
+// Cmp compares two amounts and returns -1, 0 or +1. Both amounts must be in the same currency.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.CurrencyCode() != o.CurrencyCode() {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.Units < o.Units:
+		return -1, nil
+	case m.Units > o.Units:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Decimal returns the amount as a plain decimal string without the currency, e.g. "125.50"
+func (m Money) Decimal() string {
+	exp := CurrencyExponent(m.CurrencyCode())
+	units := m.Units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(absUnits(units), 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+func absUnits(units int64) uint64 {
+	if units < 0 {
+		return uint64(-(units + 1)) + 1
+	}
+	return uint64(units)
+}
+
+// String returns the amount with its currency, e.g. "125.50 INR"
+func (m Money) String() string {
+	return fmt.Sprintf("%s %s", m.Decimal(), m.CurrencyCode())
+}
+
+// MarshalJSON encodes the amount as a string such as "125.50 INR"
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts "125.50", "125.50 INR" or a bare JSON number.
+// NaN, Inf and negative amounts are rejected. A JSON null leaves the amount unchanged.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+	parsed, err := ParseMoney(s, "")
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// MarshalBSONValue stores the amount as a {units, currency} document
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	document := bsoncore.NewDocumentBuilder().
+		AppendInt64("units", m.Units).
+		AppendString("currency", m.CurrencyCode()).
+		Build()
+	return bsontype.EmbeddedDocument, document, nil
+}
+
+// UnmarshalBSONValue reads a {units, currency} document. Legacy int64, double and Decimal128 amounts
+// without a currency are read as DefaultCurrency.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bsoncore.Value{Type: t, Data: data}
+	if t != bsontype.EmbeddedDocument {
+		units, err := bsonMinorUnits(value, DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = Money{Units: units, Currency: DefaultCurrency}
+		return nil
+	}
+
+	document := value.Document()
+	currency := DefaultCurrency
+	if code, ok := document.Lookup("currency").StringValueOK(); ok && code != "" {
+		currency = code
+	}
+	units, err := bsonMinorUnits(document.Lookup("units"), currency)
+	if err != nil {
+		return err
+	}
+	*m = Money{Units: units, Currency: currency}
+	return nil
+}
+
+// Helper function to read minor units from a BSON number. Doubles and Decimal128 values are decimal
+// amounts of the currency rather than minor units, as written before amounts were stored as integers.
+func bsonMinorUnits(value bsoncore.Value, currency string) (int64, error) {
+	switch value.Type {
+	case bsontype.Int64:
+		return value.Int64(), nil
+	case bsontype.Int32:
+		return int64(value.Int32()), nil
+	case bsontype.Double:
+		legacy, err := MoneyFromFloat(value.Double(), currency)
+		return legacy.Units, err
+	case bsontype.Decimal128:
+		return decimalMinorUnits(value.Decimal128(), currency)
+	case bsontype.Null, bsontype.Undefined, 0:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("cannot decode BSON %s into Money", value.Type)
+	}
+}
+
+// Helper function to convert a Decimal128 amount such as "10.50" or "1.5E+3" into minor units
+func decimalMinorUnits(d primitive.Decimal128, currency string) (int64, error) {
+	coefficient, exponent, err := d.BigInt()
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+	exponent += CurrencyExponent(currency)
+	units := new(big.Int).Set(coefficient)
+	if exponent >= 0 {
+		units.Mul(units, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil))
+	} else {
+		var remainder big.Int
+		units.QuoRem(units, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-exponent)), nil), &remainder)
+		if remainder.Sign() != 0 {
+			return 0, ErrAmountPrecision
+		}
+	}
+	if !units.IsInt64() {
+		return 0, ErrAmountOverflow
+	}
+	return units.Int64(), nil
+}
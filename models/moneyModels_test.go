@@ -0,0 +1,173 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMoneyBSONRoundTripKeepsCurrency(t *testing.T) {
+	for _, s := range []string{"10.500 KWD", "105.00 INR", "1500 JPY", "0.01 USD"} {
+		amount, err := ParseMoney(s, "")
+		if err != nil {
+			t.Fatalf("ParseMoney(%q): %v", s, err)
+		}
+		data, err := bson.Marshal(bson.M{"amount": amount})
+		if err != nil {
+			t.Fatalf("Marshal(%q): %v", s, err)
+		}
+		var decoded struct {
+			Amount Money `bson:"amount"`
+		}
+		if err := bson.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Unmarshal(%q): %v", s, err)
+		}
+		if decoded.Amount != amount {
+			t.Errorf("round trip of %q = %v, want %v", s, decoded.Amount, amount)
+		}
+	}
+}
+
+func TestMoneyBSONStoresUnitsAndCurrency(t *testing.T) {
+	data, err := bson.Marshal(bson.M{"amount": NewMoney(10500, "KWD")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := bson.Raw(data)
+	if units, ok := raw.Lookup("amount", "units").Int64OK(); !ok || units != 10500 {
+		t.Errorf("amount.units = %v, want int64 10500", raw.Lookup("amount", "units"))
+	}
+	if currency, ok := raw.Lookup("amount", "currency").StringValueOK(); !ok || currency != "KWD" {
+		t.Errorf("amount.currency = %v, want KWD", raw.Lookup("amount", "currency"))
+	}
+}
+
+func TestMoneyBSONLegacyValues(t *testing.T) {
+	exponent, err := primitive.ParseDecimal128("1.5E+3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := primitive.ParseDecimal128("12.34")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		value interface{}
+		want  Money
+	}{
+		{name: "int64 minor units", value: int64(1050), want: NewMoney(1050, DefaultCurrency)},
+		{name: "int32 minor units", value: int32(7), want: NewMoney(7, DefaultCurrency)},
+		{name: "double amount", value: 10.5, want: NewMoney(1050, DefaultCurrency)},
+		{name: "decimal amount", value: plain, want: NewMoney(1234, DefaultCurrency)},
+		{name: "decimal exponent form", value: exponent, want: NewMoney(150000, DefaultCurrency)},
+		{name: "null", value: nil, want: NewMoney(0, DefaultCurrency)},
+		{name: "document without currency", value: bson.M{"units": int64(99)}, want: NewMoney(99, DefaultCurrency)},
+	}
+	for _, test := range tests {
+		data, err := bson.Marshal(bson.M{"amount": test.value})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var decoded struct {
+			Amount Money `bson:"amount"`
+		}
+		if err := bson.Unmarshal(data, &decoded); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if decoded.Amount != test.want {
+			t.Errorf("%s: decoded %v, want %v", test.name, decoded.Amount, test.want)
+		}
+	}
+}
+
+func TestMoneyBSONDecimalPrecision(t *testing.T) {
+	tooPrecise, err := primitive.ParseDecimal128("1.005")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := bson.Marshal(bson.M{"amount": tooPrecise})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Amount Money `bson:"amount"`
+	}
+	if err := bson.Unmarshal(data, &decoded); err == nil {
+		t.Errorf("decoding 1.005 INR succeeded with %v, want a precision error", decoded.Amount)
+	}
+}
+
+func TestMoneyJSONNull(t *testing.T) {
+	var request struct {
+		Amount Money  `json:"amount"`
+		Limit  *Money `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(`{"amount": null, "limit": null}`), &request); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !request.Amount.IsZero() || request.Limit != nil {
+		t.Errorf("decoded %+v, want zero values", request)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var amount Money
+	if err := json.Unmarshal([]byte(`"10.500 KWD"`), &amount); err != nil {
+		t.Fatal(err)
+	}
+	if amount != NewMoney(10500, "KWD") {
+		t.Errorf("decoded %v, want 10.500 KWD", amount)
+	}
+	data, err := json.Marshal(amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"10.500 KWD"` {
+		t.Errorf("encoded %s, want \"10.500 KWD\"", data)
+	}
+	for _, invalid := range []string{`"-1"`, `"NaN"`, `"1.001"`, `"abc"`} {
+		if err := json.Unmarshal([]byte(invalid), &amount); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestMoneyCmpChecksCurrency(t *testing.T) {
+	if _, err := NewMoney(100, "USD").Cmp(NewMoney(100, "INR")); err != ErrCurrencyMismatch {
+		t.Errorf("Cmp across currencies returned %v, want ErrCurrencyMismatch", err)
+	}
+	tests := []struct {
+		a, b Money
+		want int
+	}{
+		{a: NewMoney(1, "INR"), b: NewMoney(2, "INR"), want: -1},
+		{a: NewMoney(2, "INR"), b: NewMoney(2, ""), want: 0},
+		{a: NewMoney(3, "USD"), b: NewMoney(2, "USD"), want: 1},
+	}
+	for _, test := range tests {
+		got, err := test.a.Cmp(test.b)
+		if err != nil || got != test.want {
+			t.Errorf("%v.Cmp(%v) = %d, %v; want %d", test.a, test.b, got, err, test.want)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	sum, err := NewMoney(150, "USD").Add(NewMoney(50, "USD"))
+	if err != nil || sum != NewMoney(200, "USD") {
+		t.Errorf("Add = %v, %v; want 2.00 USD", sum, err)
+	}
+	if _, err := NewMoney(150, "USD").Add(NewMoney(50, "INR")); err != ErrCurrencyMismatch {
+		t.Errorf("Add across currencies returned %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := NewMoney(1<<62, "USD").Add(NewMoney(1<<62, "USD")); err != ErrAmountOverflow {
+		t.Errorf("overflowing Add returned %v, want ErrAmountOverflow", err)
+	}
+	if got := NewMoney(-5, "INR").Decimal(); got != "-0.05" {
+		t.Errorf("Decimal = %q, want -0.05", got)
+	}
+}
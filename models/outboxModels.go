@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxPending   OutboxStatus = "pending"
+	OutboxPublished OutboxStatus = "published"
+	// Gave up after MaxOutboxAttempts; later events of the same key are published without it
+	OutboxDead OutboxStatus = "dead"
+)
+
+// OutboxEvent is a domain event stored in the outbox collection in the same MongoDB transaction as the change
+// it describes. Key is the ID of the wallet or account the event belongs to; events with the same key are
+// published in Sequence order. Payload is the Event JSON handed to publishers and webhooks.
+type OutboxEvent struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Key         string             `bson:"key" json:"key"`
+	Sequence    int64              `bson:"sequence" json:"sequence"`
+	Type        EventType          `bson:"type" json:"type"`
+	CustomerID  string             `bson:"customer_id,omitempty" json:"customer_id,omitempty"`
+	Payload     json.RawMessage    `bson:"payload" json:"payload"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	Status      OutboxStatus       `bson:"status,omitempty" json:"-"`
+	PublishedAt *time.Time         `bson:"published_at,omitempty" json:"-"`
+	Attempts    int                `bson:"attempts,omitempty" json:"-"`
+	LastError   string             `bson:"last_error,omitempty" json:"-"`
+}
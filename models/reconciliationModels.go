@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReasonCode explains why an adjustment entry was posted to the ledger. Reconciliation adjustments only
+// explain drift of the stored balances, while manual balance edits by an admin change them.
+type ReasonCode string
+
+const (
+	ReasonReconciliationDrift  ReasonCode = "reconciliation_drift"
+	ReasonManualBalanceEdit    ReasonCode = "manual_balance_edit"
+	ReasonLegacyOpeningBalance ReasonCode = "legacy_opening_balance"
+)
+
+// IsValid reports whether the reason code is one of the supported codes
+func (r ReasonCode) IsValid() bool {
+	switch r {
+	case ReasonReconciliationDrift, ReasonManualBalanceEdit, ReasonLegacyOpeningBalance:
+		return true
+	}
+	return false
+}
+
+// MovesBalance reports whether adjustments with the reason code changed the stored balances
+func (r ReasonCode) MovesBalance() bool {
+	return r == ReasonManualBalanceEdit
+}
+
+// BalanceMismatch is a wallet or account whose stored balances disagree with its transaction history.
+// Drift is stored minus expected.
+type BalanceMismatch struct {
+	WalletID            primitive.ObjectID   `bson:"wallet_id,omitempty" json:"wallet_id,omitempty"`
+	AccountID           primitive.ObjectID   `bson:"account_id,omitempty" json:"account_id,omitempty"`
+	StoredBalance       Money                `bson:"stored_balance" json:"stored_balance"`
+	ExpectedBalance     Money                `bson:"expected_balance" json:"expected_balance"`
+	BalanceDrift        Money                `bson:"balance_drift" json:"balance_drift"`
+	StoredHoldBalance   Money                `bson:"stored_hold_balance" json:"stored_hold_balance"`
+	ExpectedHoldBalance Money                `bson:"expected_hold_balance" json:"expected_hold_balance"`
+	HoldBalanceDrift    Money                `bson:"hold_balance_drift" json:"hold_balance_drift"`
+	AdjustmentIDs       []primitive.ObjectID `bson:"adjustment_ids,omitempty" json:"adjustment_ids,omitempty"`
+}
+
+// ReconciliationReport is one run of the reconciliation job, stored in the reconciliation_reports collection
+type ReconciliationReport struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	StartedAt   time.Time          `bson:"started_at" json:"started_at"`
+	CompletedAt time.Time          `bson:"completed_at" json:"completed_at"`
+	Checked     int                `bson:"checked" json:"checked"`
+	Corrected   bool               `bson:"corrected" json:"corrected"`
+	ReasonCode  ReasonCode         `bson:"reason_code,omitempty" json:"reason_code,omitempty"`
+	Mismatches  []BalanceMismatch  `bson:"mismatches" json:"mismatches"`
+}
+
+// Request body for running a reconciliation. With Correct set, adjustment entries are posted for every mismatch.
+type ReconciliationRequest struct {
+	Correct    bool       `json:"correct"`
+	ReasonCode ReasonCode `json:"reason_code" validate:"omitempty,reason_code"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StatementHeader opens a wallet or account statement covering entries after From up to and including To
+type StatementHeader struct {
+	WalletID       primitive.ObjectID `json:"wallet_id,omitempty"`
+	AccountID      primitive.ObjectID `json:"account_id,omitempty"`
+	CustomerID     string             `json:"customer_id,omitempty"`
+	From           time.Time          `json:"from"`
+	To             time.Time          `json:"to"`
+	OpeningBalance Money              `json:"opening_balance"`
+}
+
+// StatementLine is one ledger entry of a statement. Amount is the signed change to Balance
+// and Balance is the running balance after the entry.
+type StatementLine struct {
+	TransactionID primitive.ObjectID `json:"transaction_id"`
+	Date          time.Time          `json:"date"`
+	Type          TransactionType    `json:"type"`
+	Reference     string             `json:"reference,omitempty"`
+	Amount        Money              `json:"amount"`
+	Balance       Money              `json:"balance"`
+}
+
+// StatementTotal adds up the entries of one TransactionType
+type StatementTotal struct {
+	Type   TransactionType `json:"type"`
+	Count  int64           `json:"count"`
+	Amount Money           `json:"amount"`
+}
+
+// StatementSummary closes a statement
+type StatementSummary struct {
+	ClosingBalance Money            `json:"closing_balance"`
+	Totals         []StatementTotal `json:"totals"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// Only sent on the live event streams; Data is a BalanceSnapshot taken when the balance changed
+const EventBalanceUpdated EventType = "balance.updated"
+
+// StreamEvent is one message of a live wallet or customer event stream. ID is the position in the
+// stream; a client that reconnects with it as Last-Event-ID continues right after this event.
+// Data is a Transaction for ledger events and a BalanceSnapshot for balance and wallet events.
+type StreamEvent struct {
+	ID   string
+	Type EventType
+	Data interface{}
+}
@@ -1,16 +1,62 @@
 package models
 
+import "time"
+
+// Request body for opening an account. Type defaults to no account type.
 type CreateAccountRequest struct {
-	Type    AccountType `json:"type"`
-	Balance float64     `json:"balance"`
+	Email   string      `json:"email" validate:"required,email"`
+	Type    AccountType `json:"type" validate:"omitempty,account_type"`
+	Balance Money       `json:"balance" validate:"gte=0"`
 }
 
-// Request body for holding funds in a virtual wallet
+// Request body for placing an authorization hold on account funds.
+// ExpiresAt is optional; holds without it expire after the default hold lifetime.
 type HoldRequest struct {
-	Amount float64 `json:"amount"`
+	Amount    Money      `json:"amount" validate:"gt=0"`
+	Reference string     `json:"reference"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Request body for capturing a hold. Without an amount the full hold is captured.
+type CaptureHoldRequest struct {
+	Amount *Money `json:"amount" validate:"omitempty,gt=0"`
 }
 
-// Request body for releasing funds from a virtual wallet hold balance
+// Request body for releasing an amount from the hold balance of a virtual wallet
 type ReleaseHoldBalanceRequest struct {
-	Amount float64 `json:"amount"`
+	Amount Money `json:"amount" validate:"gt=0"`
+}
+
+// Request body for releasing a named hold on an account
+type ReleaseAccountHoldRequest struct {
+	HoldID string `json:"hold_id" validate:"required,mongodb"`
+}
+
+// Request body for reversing a transaction in full
+type ReverseTransactionRequest struct {
+	Reference string `json:"reference"`
+}
+
+// Request body for refunding part or all of a transaction
+type RefundTransactionRequest struct {
+	Amount    Money  `json:"amount" validate:"gt=0"`
+	Reference string `json:"reference"`
+}
+
+// TransactionQuery describes one page of a transaction history listing
+type TransactionQuery struct {
+	Types      []TransactionType
+	MinAmount  *Money
+	MaxAmount  *Money
+	StartDate  time.Time
+	EndDate    time.Time
+	Limit      int64
+	Cursor     string
+	Descending bool
+}
+
+// TransactionPage is one page of transaction history and the opaque cursor of the next page
+type TransactionPage struct {
+	Transactions []Transaction
+	NextCursor   string
 }
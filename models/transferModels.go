@@ -0,0 +1,24 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Request body for moving funds between two virtual wallets
+type TransferRequest struct {
+	SourceWalletID      string `json:"source_wallet_id" validate:"required,mongodb"`
+	DestinationWalletID string `json:"destination_wallet_id" validate:"required,mongodb"`
+	Amount              Money  `json:"amount" validate:"gt=0"`
+	Reference           string `json:"reference"`
+}
+
+// Transfer describes a completed wallet-to-wallet transfer and the linked pair of transactions it wrote
+type Transfer struct {
+	ID                  primitive.ObjectID `json:"transfer_id"`
+	SourceWalletID      primitive.ObjectID `json:"source_wallet_id"`
+	DestinationWalletID primitive.ObjectID `json:"destination_wallet_id"`
+	Amount              Money              `json:"amount"`
+	Reference           string             `json:"reference,omitempty"`
+	DebitTransactionID  primitive.ObjectID `json:"debit_transaction_id"`
+	CreditTransactionID primitive.ObjectID `json:"credit_transaction_id"`
+}
@@ -2,11 +2,22 @@ package models
 
 // SuccessResponse represents a success response body
 type SuccessResponse struct {
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
 
-// ErrorResponse represents an error response body
+// ErrorResponse represents an error response body. Code is stable across languages; Message is
+// translated according to the request's Accept-Language header.
 type ErrorResponse struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// FieldError describes one invalid field of a request. Rule names the check that failed, e.g. required or enum.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
 	Message string `json:"message"`
 }
@@ -11,11 +11,12 @@ type VirtualWallet struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty"`
 	CustomerID   string             `bson:"customer_id"`
 	WalletType   WalletType         `bson:"WalletType"`
-	Balance      float64            `bson:"balance"`
-	HoldBalance  float64            `bson:"hold_balance"`
-	Transactions []Transaction      `bson:"transactions,omitempty"`
+	CreditLimit  Money              `bson:"credit_limit,omitempty"`
+	Balance      Money              `bson:"balance"`
+	HoldBalance  Money              `bson:"hold_balance"`
 	DateCreated  time.Time          `bson:"date_created,omitempty"`
 	DateModified time.Time          `bson:"date_modified,omitempty"`
+	Policy       WalletPolicy       `bson:"-"`
 }
 
 type WalletType string
@@ -28,14 +29,41 @@ const (
 	TransitWallet WalletType = "TransitWallet"
 )
 
-// Request body for creating a new virtual wallet
+// IsValid reports whether the wallet type is one of the supported types
+func (t WalletType) IsValid() bool {
+	switch t {
+	case CashWallet, CreditWallet, RewardWallet, TradeWallet, TransitWallet:
+		return true
+	}
+	return false
+}
+
+// WalletPolicy describes what a wallet of a given type is allowed to do
+type WalletPolicy struct {
+	WalletType          WalletType `json:"wallet_type"`
+	CreditLimit         Money      `json:"credit_limit"`
+	AllowCashWithdrawal bool       `json:"allow_cash_withdrawal"`
+	AllowHolds          bool       `json:"allow_holds"`
+	SystemTransfersOnly bool       `json:"system_transfers_only"`
+}
+
+// WalletType defaults to CashWallet; CreditLimit only applies to a CreditWallet. Balance and CreditLimit are admin only.
+// WalletType defaults to CashWallet; CreditLimit only applies to a CreditWallet.
 type CreateVirtualWalletRequest struct {
-	CustomerID string  `json:"customer_id"`
-	Balance    float64 `json:"balance"`
+	CustomerID  string     `json:"customer_id" validate:"required,mongodb"`
+	WalletType  WalletType `json:"wallet_type" validate:"omitempty,wallet_type"`
+	CreditLimit Money      `json:"credit_limit" validate:"gte=0"`
+	Balance     Money      `json:"balance" validate:"gte=0"`
+}
+
+// Request body for setting the balance of a virtual wallet. CustomerID must be the wallet's owner.
+type UpdateVirtualWalletRequest struct {
+	CustomerID string `json:"customer_id" validate:"required"`
+	Balance    Money  `json:"balance" validate:"gte=0"`
 }
 
-// Request body for creating a new virtual wallet transaction
+// Request body for creating a new virtual wallet or account transaction
 type CreateTransactionRequest struct {
-	Type   string  `json:"type"`
-	Amount float64 `json:"amount"`
+	Type   TransactionType `json:"type" validate:"required,oneof=credit debit"`
+	Amount Money           `json:"amount" validate:"gt=0"`
 }
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type EventType string
+
+const (
+	EventTransactionCreated EventType = "transaction.created"
+	EventHoldCreated        EventType = "hold.created"
+	EventHoldReleased       EventType = "hold.released"
+	EventHoldCaptured       EventType = "hold.captured"
+	EventBalanceSet         EventType = "wallet.balance_set"
+	EventWalletCreated      EventType = "wallet.created"
+	EventWalletDeleted      EventType = "wallet.deleted"
+	EventAccountCreated     EventType = "account.created"
+)
+
+// IsValid reports whether the event type is one the wallet manager emits
+func (t EventType) IsValid() bool {
+	switch t {
+	case EventTransactionCreated, EventHoldCreated, EventHoldReleased, EventHoldCaptured, EventBalanceSet,
+		EventWalletCreated, EventWalletDeleted, EventAccountCreated:
+		return true
+	}
+	return false
+}
+
+// Event is the JSON body of a published event and of a webhook delivery. CustomerID is the account owning
+// the wallet or the account itself; Key and Sequence order the events of one wallet or account.
+type Event struct {
+	ID         primitive.ObjectID `json:"id"`
+	Type       EventType          `json:"type"`
+	CustomerID string             `json:"customer_id"`
+	Key        string             `json:"key"`
+	Sequence   int64              `json:"sequence"`
+	CreatedAt  time.Time          `json:"created_at"`
+	Data       interface{}        `json:"data"`
+}
+
+// WebhookSubscription sends the events of a customer to URL. An empty EventTypes subscribes to every event.
+// Secret signs the deliveries and is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CustomerID string             `bson:"customer_id" json:"customer_id"`
+	URL        string             `bson:"url" json:"url"`
+	Secret     string             `bson:"secret" json:"secret,omitempty"`
+	EventTypes []EventType        `bson:"event_types" json:"event_types"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Request body for creating a webhook subscription. A secret is generated when none is given.
+type CreateWebhookRequest struct {
+	URL        string      `json:"url" validate:"required,http_url"`
+	Secret     string      `json:"secret"`
+	EventTypes []EventType `json:"event_types" validate:"dive,event_type"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookPending   WebhookDeliveryStatus = "pending"
+	WebhookDelivered WebhookDeliveryStatus = "delivered"
+	// Gave up after the last retry; listed as a dead letter until redelivered
+	WebhookDead WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one event sent to one subscription. Payload is the exact body that is signed and posted;
+// Retries counts the failed attempts since the delivery was created or last redelivered.
+type WebhookDelivery struct {
+	ID             primitive.ObjectID    `bson:"_id,omitempty" json:"id"`
+	SubscriptionID primitive.ObjectID    `bson:"subscription_id" json:"subscription_id"`
+	CustomerID     string                `bson:"customer_id" json:"customer_id"`
+	EventID        primitive.ObjectID    `bson:"event_id" json:"event_id"`
+	EventType      EventType             `bson:"event_type" json:"event_type"`
+	Payload        json.RawMessage       `bson:"payload" json:"payload"`
+	Status         WebhookDeliveryStatus `bson:"status" json:"status"`
+	Retries        int                   `bson:"retries" json:"retries"`
+	NextAttemptAt  time.Time             `bson:"next_attempt_at" json:"next_attempt_at"`
+	Attempts       []WebhookAttempt      `bson:"attempts" json:"attempts"`
+	CreatedAt      time.Time             `bson:"created_at" json:"created_at"`
+	DateModified   time.Time             `bson:"date_modified" json:"date_modified"`
+}
+
+// WebhookAttempt is one POST of a delivery. StatusCode is zero when no response was received.
+type WebhookAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMs int64     `bson:"duration_ms" json:"duration_ms"`
+}
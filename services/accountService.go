@@ -2,10 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mfus_WalletTransactionManager/models"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -30,3 +33,152 @@ func GetAccountByEmail(client *mongo.Client, email string) (*models.Account, err
 
 	return &account, nil
 }
+
+// CreateAccount inserts a new account together with a snapshot of its opening balance
+func CreateAccount(client *mongo.Client, account models.Account) (primitive.ObjectID, error) {
+	if account.ID.IsZero() {
+		account.ID = primitive.NewObjectID()
+	}
+	err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+		_, err := client.Database("walletManager").Collection("accounts").InsertOne(ctx, account)
+		if err != nil {
+			return err
+		}
+		err = insertBalanceSnapshot(ctx, client, models.BalanceSnapshot{
+			AccountID:   account.ID,
+			Balance:     account.Balance,
+			HoldBalance: account.HoldBalance,
+			TakenAt:     account.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		return recordEvent(ctx, client, models.EventAccountCreated, account.ID, account.ID.Hex(), account)
+	})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return account.ID, nil
+}
+
+// FindAccount returns an account document by ID
+func FindAccount(client *mongo.Client, accountID primitive.ObjectID) (*models.Account, error) {
+	var account models.Account
+	err := client.Database("walletManager").Collection("accounts").FindOne(context.Background(), bson.M{"_id": accountID}).Decode(&account)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// CreateAccountTransaction posts a deposit or withdrawal to an account. Like wallet transactions the
+// balance change is a guarded atomic update written in the same MongoDB transaction as the ledger entry.
+// Withdrawals can only use the available Balance; funds reserved in HoldBalance are not touched.
+func CreateAccountTransaction(client *mongo.Client, accountID primitive.ObjectID, transactionType models.TransactionType, amount models.Money) (*models.Transaction, error) {
+	if !amount.IsPositive() {
+		return nil, errors.New("Transaction amount must be positive")
+	}
+
+	account, err := FindAccount(client, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if amount.CurrencyCode() != account.Balance.CurrencyCode() {
+		return nil, models.ErrCurrencyMismatch
+	}
+
+	fee := models.NewMoney(0, amount.CurrencyCode())
+	if chargesFee(transactionType) {
+		quote, err := quoteFee(context.Background(), client, transactionType, account.Type, "", amount)
+		if err != nil {
+			return nil, err
+		}
+		fee = quote.Fee
+	}
+
+	newTransaction := models.Transaction{
+		ID:        primitive.NewObjectID(),
+		AccountID: accountID,
+		Type:      transactionType,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+
+	filter := bson.M{"_id": accountID}
+	update := bson.M{"$set": bson.M{"date_modified": newTransaction.CreatedAt}}
+	switch transactionType {
+	case models.Deposit, models.Credit:
+		net, err := amount.Sub(fee)
+		if err != nil {
+			return nil, err
+		}
+		if net.IsNegative() {
+			// A fee larger than the deposit is taken from the existing balance
+			if cmp, err := account.Balance.Cmp(net.Neg()); err != nil || cmp < 0 {
+				return nil, insufficientFunds(err)
+			}
+			filter["balance.units"] = bson.M{"$gte": -net.Units}
+		}
+		update["$inc"] = bson.M{"balance.units": net.Units}
+
+	case models.Withdraw, models.Debit:
+		total, err := amount.Add(fee)
+		if err != nil {
+			return nil, err
+		}
+		if cmp, err := account.Balance.Cmp(total); err != nil || cmp < 0 {
+			return nil, insufficientFunds(err)
+		}
+		update["$inc"] = bson.M{"balance.units": -total.Units}
+		filter["balance.units"] = bson.M{"$gte": total.Units}
+
+	default:
+		return nil, errors.New("Invalid transaction type")
+	}
+
+	err = runInTransaction(client, func(ctx mongo.SessionContext) error {
+		if err := applyTransactionLimits(ctx, client, newTransaction); err != nil {
+			return err
+		}
+		result, err := client.Database("walletManager").Collection("accounts").UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrConcurrentUpdate
+		}
+		if err := insertTransaction(ctx, client, newTransaction); err != nil {
+			return err
+		}
+		if !fee.IsPositive() {
+			return nil
+		}
+		return bookFee(ctx, client, feeTransaction(newTransaction, fee))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &newTransaction, nil
+}
+
+// ListAccountTransactions returns one filtered, sorted page of an account's history
+func ListAccountTransactions(client *mongo.Client, accountID primitive.ObjectID, query models.TransactionQuery) (*models.TransactionPage, error) {
+	return listTransactions(client, "account_id", accountID, query)
+}
+
+// ReleaseAccountHold voids an active hold after checking that it belongs to the given account
+func ReleaseAccountHold(client *mongo.Client, accountID primitive.ObjectID, holdID primitive.ObjectID) (*models.AuthorizationHold, error) {
+	hold, err := FindHold(client, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.AccountID != accountID {
+		return nil, ErrHoldNotFound
+	}
+	return VoidHold(client, holdID)
+}
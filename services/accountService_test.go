@@ -0,0 +1,80 @@
+package services
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateAccountTransaction(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "100.00")
+
+	if _, err := CreateAccountTransaction(client, accountID, models.Withdraw, models.MustParseMoney("40.00")); err != nil {
+		t.Fatalf("Withdraw: %v", err)
+	}
+	if _, err := CreateAccountTransaction(client, accountID, models.Deposit, models.MustParseMoney("15.50")); err != nil {
+		t.Fatalf("Deposit: %v", err)
+	}
+	assertAccountBalances(t, client, accountID, "75.50", "0.00")
+
+	tests := []struct {
+		name            string
+		accountID       primitive.ObjectID
+		transactionType models.TransactionType
+		amount          models.Money
+		want            error
+	}{
+		{name: "overdraw", accountID: accountID, transactionType: models.Withdraw, amount: models.MustParseMoney("75.51"), want: ErrInsufficientFunds},
+		{name: "other currency", accountID: accountID, transactionType: models.Deposit, amount: models.NewMoney(100, "USD"), want: models.ErrCurrencyMismatch},
+		{name: "unknown account", accountID: primitive.NewObjectID(), transactionType: models.Deposit, amount: models.MustParseMoney("1.00"), want: ErrAccountNotFound},
+	}
+	for _, test := range tests {
+		if _, err := CreateAccountTransaction(client, test.accountID, test.transactionType, test.amount); err != test.want {
+			t.Errorf("%s: error = %v, want %v", test.name, err, test.want)
+		}
+	}
+	if _, err := CreateAccountTransaction(client, accountID, models.Hold, models.MustParseMoney("1.00")); err == nil {
+		t.Error("posting a hold as a plain transaction succeeded")
+	}
+	assertAccountBalances(t, client, accountID, "75.50", "0.00")
+}
+
+func TestAccountHistoryIncludesHolds(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "50.00")
+
+	hold, err := CreateHold(client, accountID, models.MustParseMoney("20.00"), "order-1", time.Time{})
+	if err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+	// Another account cannot release the hold
+	if _, err := ReleaseAccountHold(client, primitive.NewObjectID(), hold.ID); err != ErrHoldNotFound {
+		t.Errorf("release by another account returned %v, want ErrHoldNotFound", err)
+	}
+	if _, err := ReleaseAccountHold(client, accountID, hold.ID); err != nil {
+		t.Fatalf("ReleaseAccountHold: %v", err)
+	}
+	assertAccountBalances(t, client, accountID, "50.00", "0.00")
+
+	page, err := ListAccountTransactions(client, accountID, models.TransactionQuery{})
+	if err != nil {
+		t.Fatalf("ListAccountTransactions: %v", err)
+	}
+	var types []models.TransactionType
+	for _, transaction := range page.Transactions {
+		types = append(types, transaction.Type)
+	}
+	want := []models.TransactionType{models.Credit, models.Hold, models.Release}
+	if len(types) != len(want) {
+		t.Fatalf("history types = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("history types = %v, want %v", types, want)
+			break
+		}
+	}
+}
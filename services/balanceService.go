@@ -0,0 +1,389 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"mfus_WalletTransactionManager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrBalanceBeforeCreation = errors.New("Requested time is before the wallet or account was created")
+
+// Periodic snapshots only cover ledger entries older than this, so transactions still committing are not missed
+const snapshotSettleDelay = time.Minute
+
+func balanceSnapshotsCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("balance_snapshots")
+}
+
+// EnsureBalanceSnapshotIndexes creates the indexes used to find the latest snapshot before a point in time
+func EnsureBalanceSnapshotIndexes(client *mongo.Client) error {
+	_, err := balanceSnapshotsCollection(client).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "wallet_id", Value: 1}, {Key: "taken_at", Value: -1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "account_id", Value: 1}, {Key: "taken_at", Value: -1}}, Options: options.Index().SetSparse(true)},
+	})
+	return err
+}
+
+// Helper function to store a snapshot. Balances set outside the ledger, like opening balances,
+// must write one in the same MongoDB transaction, since replaying the ledger cannot reproduce them.
+func insertBalanceSnapshot(ctx context.Context, client *mongo.Client, snapshot models.BalanceSnapshot) error {
+	if snapshot.ID.IsZero() {
+		snapshot.ID = primitive.NewObjectID()
+	}
+	_, err := balanceSnapshotsCollection(client).InsertOne(ctx, snapshot)
+	return err
+}
+
+// WalletBalanceAsOf returns the Balance and HoldBalance of a virtual wallet at the given time
+func WalletBalanceAsOf(client *mongo.Client, virtualWalletID primitive.ObjectID, asOf time.Time) (*models.BalanceSnapshot, error) {
+	return readBalanceAsOf(client, "wallet_id", virtualWalletID, asOf)
+}
+
+// AccountBalanceAsOf returns the Balance and HoldBalance of an account at the given time
+func AccountBalanceAsOf(client *mongo.Client, accountID primitive.ObjectID, asOf time.Time) (*models.BalanceSnapshot, error) {
+	return readBalanceAsOf(client, "account_id", accountID, asOf)
+}
+
+// Helper function to compute a point-in-time balance inside a read-only MongoDB transaction,
+// so the snapshot, ledger and current balance are all read from one consistent view
+func readBalanceAsOf(client *mongo.Client, ownerField string, ownerID primitive.ObjectID, asOf time.Time) (*models.BalanceSnapshot, error) {
+	var snapshot *models.BalanceSnapshot
+	err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+		var err error
+		snapshot, err = balanceAsOf(ctx, client, ownerField, ownerID, asOf)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Helper function to compute a point-in-time balance. Starting from the latest snapshot at or before asOf the
+// ledger is replayed forwards, with adjustments only from the opening snapshot. Without such a snapshot the
+// ledger is unwound backwards from the current balance.
+func balanceAsOf(ctx context.Context, client *mongo.Client, ownerField string, ownerID primitive.ObjectID, asOf time.Time) (*models.BalanceSnapshot, error) {
+	result := &models.BalanceSnapshot{TakenAt: asOf}
+	if ownerField == "wallet_id" {
+		result.WalletID = ownerID
+	} else {
+		result.AccountID = ownerID
+	}
+
+	var base models.BalanceSnapshot
+	err := balanceSnapshotsCollection(client).FindOne(ctx,
+		bson.M{ownerField: ownerID, "taken_at": bson.M{"$lte": asOf}},
+		options.FindOne().SetSort(bson.D{{Key: "taken_at", Value: -1}}),
+	).Decode(&base)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	if err == nil {
+		// Later snapshots hold balances that already include any drift, which adjustments after them explain
+		// rather than move. Only the opening snapshot predates all drift.
+		earlier, err := balanceSnapshotsCollection(client).CountDocuments(ctx,
+			bson.M{ownerField: ownerID, "taken_at": bson.M{"$lt": base.TakenAt}},
+			options.Count().SetLimit(1),
+		)
+		if err != nil {
+			return nil, err
+		}
+		balance, hold, err := ledgerEffect(ctx, client, ownerField, ownerID, bson.M{"$gt": base.TakenAt, "$lte": asOf}, earlier == 0, base.Balance.CurrencyCode())
+		if err != nil {
+			return nil, err
+		}
+		if result.Balance, err = base.Balance.Add(balance); err != nil {
+			return nil, err
+		}
+		if result.HoldBalance, err = base.HoldBalance.Add(hold); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// No snapshot yet: start from the current document
+	var current struct {
+		Balance     models.Money `bson:"balance"`
+		HoldBalance models.Money `bson:"hold_balance"`
+		DateCreated time.Time    `bson:"date_created"`
+		CreatedAt   time.Time    `bson:"created_at"`
+	}
+	collection := client.Database("walletManager").Collection("accounts")
+	if ownerField == "wallet_id" {
+		collection = client.Database("walletManager").Collection("virtual_wallets")
+	}
+	err = collection.FindOne(ctx, bson.M{"_id": ownerID}).Decode(&current)
+	if err != nil {
+		return nil, err
+	}
+	created := current.CreatedAt
+	if ownerField == "wallet_id" {
+		created = current.DateCreated
+	}
+	if asOf.Before(created) {
+		return nil, ErrBalanceBeforeCreation
+	}
+
+	balance, hold, err := ledgerEffect(ctx, client, ownerField, ownerID, bson.M{"$gt": asOf}, false, current.Balance.CurrencyCode())
+	if err != nil {
+		return nil, err
+	}
+	if result.Balance, err = current.Balance.Sub(balance); err != nil {
+		return nil, err
+	}
+	if result.HoldBalance, err = current.HoldBalance.Sub(hold); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// How each transaction type moves Balance and HoldBalance. Reversals and refunds depend on the entry they compensate.
+var (
+	balanceInflows  = []models.TransactionType{models.Deposit, models.Credit, models.FeeIncome, models.TransferIn, models.Release, models.AdjustmentCredit}
+	balanceOutflows = []models.TransactionType{models.Withdraw, models.Debit, models.Fee, models.TransferOut, models.Hold, models.AdjustmentDebit}
+	holdIncreases   = []models.TransactionType{models.Hold, models.HoldAdjustmentCredit}
+	holdDecreases   = []models.TransactionType{models.Release, models.Capture, models.HoldAdjustmentDebit}
+)
+
+// Helper function returning how a single ledger entry changed Balance and HoldBalance.
+// originalType is the type of the entry a reversal or refund compensates.
+func transactionEffect(transaction models.Transaction, originalType models.TransactionType) (models.Money, models.Money) {
+	zero := models.NewMoney(0, transaction.Amount.CurrencyCode())
+	balance, hold := zero, zero
+	switch {
+	case containsType(balanceInflows, transaction.Type):
+		balance = transaction.Amount
+	case containsType(balanceOutflows, transaction.Type):
+		balance = transaction.Amount.Neg()
+	case transaction.Type == models.Reversal || transaction.Type == models.Refund:
+		balance = transaction.Amount
+		if originalType == models.Deposit || originalType == models.Credit {
+			balance = transaction.Amount.Neg()
+		}
+	}
+	switch {
+	case containsType(holdIncreases, transaction.Type):
+		hold = transaction.Amount
+	case containsType(holdDecreases, transaction.Type):
+		hold = transaction.Amount.Neg()
+	}
+	return balance, hold
+}
+
+func containsType(types []models.TransactionType, transactionType models.TransactionType) bool {
+	for _, t := range types {
+		if t == transactionType {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to add up how the ledger entries of one owner within a created_at range changed its
+// Balance and HoldBalance. Reversals and refunds move money in the opposite direction of the entry they compensate.
+// Adjustments explain drift of the stored balances and can be left out when starting from those balances,
+// except manual balance edits, which changed the stored balances like any other entry.
+// Every entry of an owner is in the owner's currency, which the results are returned in.
+func ledgerEffect(ctx context.Context, client *mongo.Client, ownerField string, ownerID primitive.ObjectID, createdAt bson.M, adjustments bool, currency string) (models.Money, models.Money, error) {
+	match := bson.M{ownerField: ownerID, "created_at": createdAt}
+	if !adjustments {
+		match["$or"] = bson.A{
+			bson.M{"type": bson.M{"$nin": bson.A{models.AdjustmentCredit, models.AdjustmentDebit, models.HoldAdjustmentCredit, models.HoldAdjustmentDebit}}},
+			bson.M{"reason_code": models.ReasonManualBalanceEdit},
+		}
+	}
+
+	balanceSign := bson.M{"$switch": bson.M{
+		"branches": bson.A{
+			bson.M{"case": bson.M{"$in": bson.A{"$type", balanceInflows}}, "then": 1},
+			bson.M{"case": bson.M{"$in": bson.A{"$type", balanceOutflows}}, "then": -1},
+			bson.M{
+				"case": bson.M{"$in": bson.A{"$type", bson.A{models.Reversal, models.Refund}}},
+				"then": bson.M{"$cond": bson.A{
+					bson.M{"$in": bson.A{bson.M{"$arrayElemAt": bson.A{"$original.type", 0}}, bson.A{models.Deposit, models.Credit}}},
+					-1,
+					1,
+				}},
+			},
+		},
+		"default": 0,
+	}}
+	holdSign := bson.M{"$switch": bson.M{
+		"branches": bson.A{
+			bson.M{"case": bson.M{"$in": bson.A{"$type", holdIncreases}}, "then": 1},
+			bson.M{"case": bson.M{"$in": bson.A{"$type", holdDecreases}}, "then": -1},
+		},
+		"default": 0,
+	}}
+
+	cursor, err := transactionsCollection(client).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "transactions",
+			"localField":   "linked_transaction_id",
+			"foreignField": "_id",
+			"as":           "original",
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          nil,
+			"balance":      bson.M{"$sum": bson.M{"$multiply": bson.A{"$amount.units", balanceSign}}},
+			"hold_balance": bson.M{"$sum": bson.M{"$multiply": bson.A{"$amount.units", holdSign}}},
+		}}},
+	})
+	if err != nil {
+		return models.Money{}, models.Money{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Balance     int64 `bson:"balance"`
+		HoldBalance int64 `bson:"hold_balance"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return models.Money{}, models.Money{}, err
+	}
+	if len(result) == 0 {
+		zero := models.NewMoney(0, currency)
+		return zero, zero, nil
+	}
+	return models.NewMoney(result[0].Balance, currency), models.NewMoney(result[0].HoldBalance, currency), nil
+}
+
+// TakeBalanceSnapshots stores a snapshot for every wallet and account that had ledger activity since its
+// previous snapshot. Snapshots are derived from the previous snapshot and the ledger, never from the stored
+// balance, and cover everything up to snapshotSettleDelay ago.
+func TakeBalanceSnapshots(client *mongo.Client) (int, error) {
+	cutoff := time.Now().Add(-snapshotSettleDelay)
+	taken := 0
+	for _, owner := range []struct {
+		field      string
+		collection string
+	}{
+		{field: "wallet_id", collection: "virtual_wallets"},
+		{field: "account_id", collection: "accounts"},
+	} {
+		cursor, err := client.Database("walletManager").Collection(owner.collection).Find(
+			context.Background(),
+			bson.M{},
+			options.Find().SetProjection(bson.M{"_id": 1}),
+		)
+		if err != nil {
+			return taken, err
+		}
+		var owners []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		err = cursor.All(context.Background(), &owners)
+		if err != nil {
+			return taken, err
+		}
+
+		for _, o := range owners {
+			stored := false
+			err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+				stored = false
+				// Skip owners without new ledger entries since their latest snapshot
+				var latest models.BalanceSnapshot
+				err := balanceSnapshotsCollection(client).FindOne(ctx,
+					bson.M{owner.field: o.ID},
+					options.FindOne().SetSort(bson.D{{Key: "taken_at", Value: -1}}),
+				).Decode(&latest)
+				if err == mongo.ErrNoDocuments {
+					// Legacy owners without an opening snapshot are left to the reconciliation job
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if !latest.TakenAt.Before(cutoff) {
+					return nil
+				}
+				count, err := transactionsCollection(client).CountDocuments(ctx, bson.M{
+					owner.field:  o.ID,
+					"created_at": bson.M{"$gt": latest.TakenAt, "$lte": cutoff},
+				})
+				if err != nil || count == 0 {
+					return err
+				}
+
+				snapshot, err := balanceAsOf(ctx, client, owner.field, o.ID, cutoff)
+				if err == ErrBalanceBeforeCreation {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if err := insertBalanceSnapshot(ctx, client, *snapshot); err != nil {
+					return err
+				}
+				stored = true
+				return nil
+			})
+			if err != nil {
+				return taken, err
+			}
+			if stored {
+				taken++
+			}
+		}
+	}
+	return taken, nil
+}
+
+// RunBalanceSnapshotter periodically snapshots balances until the process exits. Meant to be started as a goroutine.
+func RunBalanceSnapshotter(client *mongo.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		taken, err := TakeBalanceSnapshots(client)
+		if err != nil {
+			log.Printf("Balance snapshotter failed: %v", err)
+			continue
+		}
+		if taken > 0 {
+			log.Printf("Balance snapshotter stored %d snapshots", taken)
+		}
+	}
+}
+
+// CustomerBalanceAsOf adds up the point-in-time balances of every current wallet of a customer.
+// Wallets created after asOf count as zero.
+func CustomerBalanceAsOf(client *mongo.Client, customerID string, asOf time.Time) (models.Money, error) {
+	total := models.NewMoney(0, models.DefaultCurrency)
+	cursor, err := client.Database("walletManager").Collection("virtual_wallets").Find(
+		context.Background(),
+		bson.M{"customer_id": customerID},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return total, err
+	}
+	var wallets []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(context.Background(), &wallets); err != nil {
+		return total, err
+	}
+
+	for _, wallet := range wallets {
+		snapshot, err := WalletBalanceAsOf(client, wallet.ID, asOf)
+		if err == ErrBalanceBeforeCreation {
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+		if total, err = total.Add(snapshot.Balance); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"testing"
+	"time"
+)
+
+func TestTransactionEffect(t *testing.T) {
+	amount := models.MustParseMoney("10.00")
+	tests := []struct {
+		transactionType models.TransactionType
+		originalType    models.TransactionType
+		balance         int64
+		hold            int64
+	}{
+		{transactionType: models.Deposit, balance: 1000, hold: 0},
+		{transactionType: models.Debit, balance: -1000, hold: 0},
+		{transactionType: models.Hold, balance: -1000, hold: 1000},
+		{transactionType: models.Release, balance: 1000, hold: -1000},
+		{transactionType: models.Capture, balance: 0, hold: -1000},
+		{transactionType: models.Reversal, originalType: models.Credit, balance: -1000, hold: 0},
+		{transactionType: models.Refund, originalType: models.Withdraw, balance: 1000, hold: 0},
+		{transactionType: models.HoldAdjustmentCredit, balance: 0, hold: 1000},
+	}
+	for _, test := range tests {
+		balance, hold := transactionEffect(models.Transaction{Type: test.transactionType, Amount: amount}, test.originalType)
+		if balance != models.NewMoney(test.balance, models.DefaultCurrency) || hold != models.NewMoney(test.hold, models.DefaultCurrency) {
+			t.Errorf("%s of a %q: effect = %v, %v; want %d and %d units", test.transactionType, test.originalType, balance, hold, test.balance, test.hold)
+		}
+	}
+}
+
+func TestWalletBalanceAsOf(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	beforeCreation := time.Now().Add(-time.Second)
+	walletID := createWallet(t, client, accountID, models.CashWallet, "100.00")
+
+	time.Sleep(10 * time.Millisecond)
+	afterOpening := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if err := CreateVirtualWalletTransaction(client, walletID, "", models.Deposit, models.MustParseMoney("50.00")); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateVirtualWalletTransaction(client, walletID, "", models.Withdraw, models.MustParseMoney("30.00")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		asOf time.Time
+		want string
+	}{
+		{name: "opening balance", asOf: afterOpening, want: "100.00"},
+		{name: "after the ledger entries", asOf: now, want: "120.00"},
+	}
+	for _, test := range tests {
+		snapshot, err := WalletBalanceAsOf(client, walletID, test.asOf)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if snapshot.Balance != models.MustParseMoney(test.want) || !snapshot.HoldBalance.IsZero() {
+			t.Errorf("%s: balance = %v, hold = %v; want %s and no hold", test.name, snapshot.Balance, snapshot.HoldBalance, test.want)
+		}
+	}
+	if _, err := WalletBalanceAsOf(client, walletID, beforeCreation); err != ErrBalanceBeforeCreation {
+		t.Errorf("balance before creation returned %v, want ErrBalanceBeforeCreation", err)
+	}
+
+	total, err := CustomerBalanceAsOf(client, accountID.Hex(), afterOpening)
+	if err != nil || total != models.MustParseMoney("100.00") {
+		t.Errorf("customer balance = %v, %v; want 100.00", total, err)
+	}
+}
+
+func TestAccountBalanceAsOfWithHolds(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "100.00")
+	time.Sleep(10 * time.Millisecond)
+	funded := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if _, err := CreateHold(client, accountID, models.MustParseMoney("40.00"), "order-1", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := AccountBalanceAsOf(client, accountID, funded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Balance != models.MustParseMoney("100.00") || !snapshot.HoldBalance.IsZero() {
+		t.Errorf("balance before the hold = %v, %v; want 100.00 and no hold", snapshot.Balance, snapshot.HoldBalance)
+	}
+	snapshot, err = AccountBalanceAsOf(client, accountID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Balance != models.MustParseMoney("60.00") || snapshot.HoldBalance != models.MustParseMoney("40.00") {
+		t.Errorf("balance after the hold = %v, %v; want 60.00 and 40.00 held", snapshot.Balance, snapshot.HoldBalance)
+	}
+
+	// Entries younger than snapshotSettleDelay are not snapshotted yet
+	taken, err := TakeBalanceSnapshots(client)
+	if err != nil || taken != 0 {
+		t.Errorf("TakeBalanceSnapshots = %d, %v; want 0 snapshots", taken, err)
+	}
+}
@@ -0,0 +1,501 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mfus_WalletTransactionManager/models"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// Largest file accepted by CreateBatch
+	MaxBatchRows = 100000
+	// Largest all-or-nothing batch, which is applied in a single MongoDB transaction
+	MaxAtomicBatchRows = 1000
+	// Rows are stored in chunks of this size while the file is read
+	batchInsertChunk = 500
+)
+
+var (
+	ErrBatchNotFound      = errors.New("Batch not found")
+	ErrBatchEmpty         = errors.New("Batch file contains no rows")
+	ErrBatchTooLarge      = errors.New("Batch file contains too many rows")
+	ErrInvalidBatchFormat = errors.New("Invalid batch format. Must be 'csv' or 'ndjson'")
+	ErrInvalidBatchMode   = errors.New("Invalid batch mode. Must be 'per_row' or 'all_or_nothing'")
+)
+
+func batchesCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("batches")
+}
+
+func batchRowsCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("batch_rows")
+}
+
+// EnsureBatchIndexes creates the indexes used to walk a batch's rows in file order
+func EnsureBatchIndexes(client *mongo.Client) error {
+	_, err := batchRowsCollection(client).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "batch_id", Value: 1}, {Key: "status", Value: 1}, {Key: "line", Value: 1}}},
+	})
+	return err
+}
+
+// CreateBatch reads a CSV or NDJSON file of wallet_id, type, amount and optional reference columns, validates
+// every row and stores the batch. Rows failing validation are rejected immediately; in all-or-nothing mode one
+// rejected row fails the whole batch. Valid rows are applied later by ProcessBatch.
+func CreateBatch(client *mongo.Client, format string, mode models.BatchMode, file io.Reader) (*models.Batch, error) {
+	if format != "csv" && format != "ndjson" {
+		return nil, ErrInvalidBatchFormat
+	}
+	if mode != models.BatchPerRow && mode != models.BatchAllOrNothing {
+		return nil, ErrInvalidBatchMode
+	}
+	maxRows := int64(MaxBatchRows)
+	if mode == models.BatchAllOrNothing {
+		maxRows = MaxAtomicBatchRows
+	}
+
+	batch := &models.Batch{
+		ID:        primitive.NewObjectID(),
+		Mode:      mode,
+		Format:    format,
+		Status:    models.BatchProcessing,
+		CreatedAt: time.Now(),
+	}
+
+	// Validate and store the rows in chunks so the file is never held in memory
+	wallets := map[string]error{}
+	var chunk []interface{}
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		_, err := batchRowsCollection(client).InsertMany(context.Background(), chunk)
+		chunk = chunk[:0]
+		return err
+	}
+	err := readBatchFile(format, file, func(row models.BatchRow) error {
+		batch.TotalRows++
+		if batch.TotalRows > maxRows {
+			return ErrBatchTooLarge
+		}
+		row.ID = primitive.NewObjectID()
+		row.BatchID = batch.ID
+		row.Status = models.BatchRowPending
+		if row.Error == "" {
+			row.Error = validateBatchRow(client, row, wallets)
+		}
+		if row.Error != "" {
+			row.Status = models.BatchRowRejected
+			batch.Rejected++
+			batch.Processed++
+		}
+		chunk = append(chunk, row)
+		if len(chunk) >= batchInsertChunk {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err == nil && batch.TotalRows == 0 {
+		err = ErrBatchEmpty
+	}
+	if err != nil {
+		// Remove the rows stored so far; the batch itself was never created
+		batchRowsCollection(client).DeleteMany(context.Background(), bson.M{"batch_id": batch.ID})
+		return nil, err
+	}
+
+	if mode == models.BatchAllOrNothing && batch.Rejected > 0 {
+		now := time.Now()
+		batch.Status = models.BatchFailed
+		batch.Error = fmt.Sprintf("%d rows failed validation, no rows were applied", batch.Rejected)
+		batch.Processed = batch.TotalRows
+		batch.CompletedAt = &now
+		_, err := batchRowsCollection(client).UpdateMany(context.Background(),
+			bson.M{"batch_id": batch.ID, "status": models.BatchRowPending},
+			bson.M{"$set": bson.M{"status": models.BatchRowSkipped}},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if mode == models.BatchPerRow && batch.Processed == batch.TotalRows {
+		now := time.Now()
+		batch.Status = models.BatchCompleted
+		batch.CompletedAt = &now
+	}
+
+	if _, err := batchesCollection(client).InsertOne(context.Background(), batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// Helper function to read the rows of a batch file. Rows that cannot be parsed are passed on with an error
+// so they show up in the error report; only a file that cannot be read at all fails the upload.
+func readBatchFile(format string, file io.Reader, fn func(row models.BatchRow) error) error {
+	if format == "csv" {
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		first := true
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			// An optional header row names the columns
+			if first && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "wallet_id") {
+				first = false
+				continue
+			}
+			first = false
+
+			line, _ := reader.FieldPos(0)
+			row := models.BatchRow{Line: int64(line)}
+			if len(record) < 3 || len(record) > 4 {
+				row.Error = "Expected wallet_id, type, amount and an optional reference"
+			}
+			fields := append(record, "", "", "", "")
+			row.WalletID = strings.TrimSpace(fields[0])
+			row.Type = strings.TrimSpace(fields[1])
+			row.Amount = strings.TrimSpace(fields[2])
+			row.Reference = strings.TrimSpace(fields[3])
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var line int64
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record struct {
+			WalletID  string          `json:"wallet_id"`
+			Type      string          `json:"type"`
+			Amount    json.RawMessage `json:"amount"`
+			Reference string          `json:"reference"`
+		}
+		row := models.BatchRow{Line: line}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			row.Error = "Invalid JSON"
+		} else {
+			row.WalletID = record.WalletID
+			row.Type = record.Type
+			row.Reference = record.Reference
+			// Amounts may be JSON strings or numbers
+			var amount string
+			if json.Unmarshal(record.Amount, &amount) != nil {
+				amount = string(record.Amount)
+			}
+			row.Amount = amount
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Helper function to parse the raw values of a batch row
+func parseBatchRow(row models.BatchRow) (primitive.ObjectID, models.TransactionType, models.Money, error) {
+	walletID, err := primitive.ObjectIDFromHex(row.WalletID)
+	if err != nil {
+		return walletID, "", models.Money{}, errors.New("Invalid virtual wallet ID")
+	}
+	transactionType := models.TransactionType(row.Type)
+	if transactionType != models.Credit && transactionType != models.Debit {
+		return walletID, "", models.Money{}, errors.New("Invalid transaction type. Must be 'debit' or 'credit'")
+	}
+	amount, err := models.ParseMoney(row.Amount, "")
+	if err != nil || !amount.IsPositive() {
+		return walletID, "", models.Money{}, errors.New("Transaction amount must be positive")
+	}
+	return walletID, transactionType, amount, nil
+}
+
+// Helper function returning why a row is invalid, or "" when it can be applied.
+// Wallet lookups are cached since batches usually repeat wallets.
+func validateBatchRow(client *mongo.Client, row models.BatchRow, wallets map[string]error) string {
+	walletID, _, _, err := parseBatchRow(row)
+	if err != nil {
+		return err.Error()
+	}
+	walletErr, ok := wallets[row.WalletID]
+	if !ok {
+		_, walletErr = FindVirtualWallet(client, walletID, "_id")
+		wallets[row.WalletID] = walletErr
+	}
+	if walletErr == mongo.ErrNoDocuments {
+		return "Virtual wallet not found"
+	}
+	if walletErr != nil {
+		return "Failed to retrieve virtual wallet"
+	}
+	return ""
+}
+
+// FindBatch returns a batch with its progress counters
+func FindBatch(client *mongo.Client, batchID primitive.ObjectID) (*models.Batch, error) {
+	var batch models.Batch
+	err := batchesCollection(client).FindOne(context.Background(), bson.M{"_id": batchID}).Decode(&batch)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrBatchNotFound
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// WriteBatchErrorReport passes every rejected row of a batch to fn in file order
+func WriteBatchErrorReport(client *mongo.Client, batchID primitive.ObjectID, fn func(row models.BatchRow) error) error {
+	if _, err := FindBatch(client, batchID); err != nil {
+		return err
+	}
+	cursor, err := batchRowsCollection(client).Find(context.Background(),
+		bson.M{"batch_id": batchID, "status": models.BatchRowRejected},
+		options.Find().SetSort(bson.D{{Key: "line", Value: 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+	for cursor.Next(context.Background()) {
+		var row models.BatchRow
+		if err := cursor.Decode(&row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// ProcessBatch applies the pending rows of a batch. Each row's balance change and its result are written in
+// one MongoDB transaction, so an interrupted batch can be resumed without applying a row twice.
+// Meant to be started as a goroutine.
+func ProcessBatch(client *mongo.Client, batchID primitive.ObjectID) {
+	batch, err := FindBatch(client, batchID)
+	if err != nil {
+		log.Printf("Batch %s could not be loaded: %v", batchID.Hex(), err)
+		return
+	}
+	if batch.Status != models.BatchProcessing {
+		return
+	}
+
+	if batch.Mode == models.BatchAllOrNothing {
+		err = processAtomicBatch(client, batch)
+	} else {
+		err = processPerRowBatch(client, batch)
+	}
+	if err != nil {
+		log.Printf("Batch %s stopped: %v", batchID.Hex(), err)
+	}
+}
+
+// Helper function to apply the pending rows of a per-row batch one at a time
+func processPerRowBatch(client *mongo.Client, batch *models.Batch) error {
+	cursor, err := batchRowsCollection(client).Find(context.Background(),
+		bson.M{"batch_id": batch.ID, "status": models.BatchRowPending},
+		options.Find().SetSort(bson.D{{Key: "line", Value: 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var row models.BatchRow
+		if err := cursor.Decode(&row); err != nil {
+			return err
+		}
+
+		posting, rowErr := prepareBatchRow(client, row)
+		if rowErr == nil {
+			rowErr = runInTransaction(client, func(ctx mongo.SessionContext) error {
+				if err := applyBatchPosting(ctx, posting); err != nil {
+					return err
+				}
+				return finishBatchRow(ctx, client, row, models.BatchRowApplied, posting.transaction.ID, "")
+			})
+		}
+		if rowErr != nil {
+			err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+				return finishBatchRow(ctx, client, row, models.BatchRowRejected, primitive.NilObjectID, rowErr.Error())
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	return markBatch(context.Background(), client, batch.ID, models.BatchCompleted, "")
+}
+
+// Helper function to apply every row of an all-or-nothing batch in a single MongoDB transaction.
+// The first failing row is rejected and every other row is skipped.
+func processAtomicBatch(client *mongo.Client, batch *models.Batch) error {
+	cursor, err := batchRowsCollection(client).Find(context.Background(),
+		bson.M{"batch_id": batch.ID, "status": models.BatchRowPending},
+		options.Find().SetSort(bson.D{{Key: "line", Value: 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	var rows []models.BatchRow
+	if err := cursor.All(context.Background(), &rows); err != nil {
+		return err
+	}
+
+	failed := -1
+	var failure error
+	postings := make([]*walletPosting, len(rows))
+	for i, row := range rows {
+		postings[i], failure = prepareBatchRow(client, row)
+		if failure != nil {
+			failed = i
+			break
+		}
+	}
+
+	if failed < 0 {
+		failure = runInTransaction(client, func(ctx mongo.SessionContext) error {
+			failed = -1
+			for i, posting := range postings {
+				if err := applyBatchPosting(ctx, posting); err != nil {
+					failed = i
+					return err
+				}
+				if err := finishBatchRow(ctx, client, rows[i], models.BatchRowApplied, posting.transaction.ID, ""); err != nil {
+					return err
+				}
+			}
+			return markBatch(ctx, client, batch.ID, models.BatchCompleted, "")
+		})
+		if failure == nil {
+			return nil
+		}
+		if failed < 0 {
+			// The batch could not be written for a reason unrelated to its rows
+			return failure
+		}
+	}
+
+	return runInTransaction(client, func(ctx mongo.SessionContext) error {
+		err := finishBatchRow(ctx, client, rows[failed], models.BatchRowRejected, primitive.NilObjectID, failure.Error())
+		if err != nil {
+			return err
+		}
+		_, err = batchRowsCollection(client).UpdateMany(ctx,
+			bson.M{"batch_id": batch.ID, "status": models.BatchRowPending},
+			bson.M{"$set": bson.M{"status": models.BatchRowSkipped}},
+		)
+		if err != nil {
+			return err
+		}
+		_, err = batchesCollection(client).UpdateOne(ctx, bson.M{"_id": batch.ID}, bson.M{"$set": bson.M{"processed": batch.TotalRows}})
+		if err != nil {
+			return err
+		}
+		return markBatch(ctx, client, batch.ID, models.BatchFailed, fmt.Sprintf("Line %d was rejected: %s. No rows were applied", rows[failed].Line, failure.Error()))
+	})
+}
+
+// Helper function to validate a row against the current wallet state. Limits are checked when the row is applied.
+func prepareBatchRow(client *mongo.Client, row models.BatchRow) (*walletPosting, error) {
+	walletID, transactionType, amount, err := parseBatchRow(row)
+	if err != nil {
+		return nil, err
+	}
+	return prepareWalletPosting(client, walletID, "", transactionType, amount, row.Reference)
+}
+
+// Helper function to apply a prepared row. The funds guard is evaluated inside the MongoDB transaction, so when
+// it fails the wallet cannot cover the row, counting the rows applied before it in an all-or-nothing batch.
+func applyBatchPosting(ctx mongo.SessionContext, posting *walletPosting) error {
+	err := posting.apply(ctx)
+	if err == ErrConcurrentUpdate {
+		return ErrInsufficientFunds
+	}
+	return err
+}
+
+// Helper function to record the result of a row and count it on its batch
+func finishBatchRow(ctx mongo.SessionContext, client *mongo.Client, row models.BatchRow, status models.BatchRowStatus, transactionID primitive.ObjectID, rowError string) error {
+	set := bson.M{"status": status}
+	if !transactionID.IsZero() {
+		set["transaction_id"] = transactionID
+	}
+	if rowError != "" {
+		set["error"] = rowError
+	}
+	result, err := batchRowsCollection(client).UpdateOne(ctx, bson.M{"_id": row.ID, "status": models.BatchRowPending}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	counter := "succeeded"
+	if status == models.BatchRowRejected {
+		counter = "rejected"
+	}
+	_, err = batchesCollection(client).UpdateOne(ctx, bson.M{"_id": row.BatchID}, bson.M{"$inc": bson.M{"processed": 1, counter: 1}})
+	return err
+}
+
+// Helper function to set the final status of a batch
+func markBatch(ctx context.Context, client *mongo.Client, batchID primitive.ObjectID, status models.BatchStatus, batchError string) error {
+	set := bson.M{"status": status, "completed_at": time.Now()}
+	if batchError != "" {
+		set["error"] = batchError
+	}
+	_, err := batchesCollection(client).UpdateOne(ctx, bson.M{"_id": batchID}, bson.M{"$set": set})
+	return err
+}
+
+// ResumeBatches restarts processing of every batch that was still running when the server stopped
+func ResumeBatches(client *mongo.Client) error {
+	cursor, err := batchesCollection(client).Find(context.Background(), bson.M{"status": models.BatchProcessing}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var batches []models.Batch
+	if err := cursor.All(context.Background(), &batches); err != nil {
+		return err
+	}
+	for _, batch := range batches {
+		go ProcessBatch(client, batch.ID)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"fmt"
+	"mfus_WalletTransactionManager/models"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestReadBatchFile(t *testing.T) {
+	tests := []struct {
+		format string
+		file   string
+		want   []models.BatchRow
+	}{
+		{
+			format: "csv",
+			file:   "wallet_id,type,amount,reference\nw1, credit, 10.00, payroll\nw2,debit\n",
+			want: []models.BatchRow{
+				{Line: 2, WalletID: "w1", Type: "credit", Amount: "10.00", Reference: "payroll"},
+				{Line: 3, WalletID: "w2", Type: "debit", Error: "Expected wallet_id, type, amount and an optional reference"},
+			},
+		},
+		{
+			format: "ndjson",
+			file:   "{\"wallet_id\":\"w1\",\"type\":\"credit\",\"amount\":\"10.00\"}\n\n{\"wallet_id\":\"w2\",\"type\":\"debit\",\"amount\":2.5}\nnot json\n",
+			want: []models.BatchRow{
+				{Line: 1, WalletID: "w1", Type: "credit", Amount: "10.00"},
+				{Line: 3, WalletID: "w2", Type: "debit", Amount: "2.5"},
+				{Line: 4, Error: "Invalid JSON"},
+			},
+		},
+	}
+	for _, test := range tests {
+		var rows []models.BatchRow
+		err := readBatchFile(test.format, strings.NewReader(test.file), func(row models.BatchRow) error {
+			rows = append(rows, row)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", test.format, err)
+		}
+		if fmt.Sprint(rows) != fmt.Sprint(test.want) {
+			t.Errorf("%s: rows = %+v, want %+v", test.format, rows, test.want)
+		}
+	}
+}
+
+// Helper function to import and process an all-or-nothing batch of debits against one wallet
+func runAtomicDebitBatch(t *testing.T, client *mongo.Client, walletID primitive.ObjectID, amounts ...string) (*models.Batch, []models.BatchRow) {
+	t.Helper()
+	var file strings.Builder
+	for _, amount := range amounts {
+		fmt.Fprintf(&file, "%s,debit,%s\n", walletID.Hex(), amount)
+	}
+	batch, err := CreateBatch(client, "csv", models.BatchAllOrNothing, strings.NewReader(file.String()))
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	ProcessBatch(client, batch.ID)
+
+	batch, err = FindBatch(client, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rejected []models.BatchRow
+	err = WriteBatchErrorReport(client, batch.ID, func(row models.BatchRow) error {
+		rejected = append(rejected, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batch, rejected
+}
+
+func TestAtomicBatchCountsEarlierRowsTowardsLimits(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	walletID := createWallet(t, client, accountID, models.CashWallet, "1000.00")
+	if err := SetLimitOverrides(client, accountID, models.TransactionLimits{DailyWithdrawal: limitAmount("100.00")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each row fits the daily limit on its own, the third one does not fit after the first two
+	batch, rejected := runAtomicDebitBatch(t, client, walletID, "40.00", "40.00", "40.00")
+	if batch.Status != models.BatchFailed || batch.Succeeded != 0 {
+		t.Errorf("batch = %+v, want a failed batch without applied rows", batch)
+	}
+	if len(rejected) != 1 || rejected[0].Line != 3 || !strings.Contains(rejected[0].Error, "limit") {
+		t.Errorf("rejected rows = %+v, want line 3 rejected by the daily limit", rejected)
+	}
+	virtualWallet, err := FindVirtualWallet(client, walletID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if virtualWallet.Balance != models.MustParseMoney("1000.00") {
+		t.Errorf("balance = %v, want 1000.00", virtualWallet.Balance)
+	}
+}
+
+func TestAtomicBatchReportsInsufficientFunds(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	walletID := createWallet(t, client, accountID, models.CashWallet, "50.00")
+
+	// Both rows pass validation against the current balance, but not together
+	batch, rejected := runAtomicDebitBatch(t, client, walletID, "30.00", "30.00")
+	if batch.Status != models.BatchFailed || batch.Error != "Line 2 was rejected: Insufficient funds. No rows were applied" {
+		t.Errorf("batch status = %s, error = %q", batch.Status, batch.Error)
+	}
+	if len(rejected) != 1 || rejected[0].Line != 2 || rejected[0].Error != ErrInsufficientFunds.Error() {
+		t.Errorf("rejected rows = %+v, want line 2 rejected for insufficient funds", rejected)
+	}
+}
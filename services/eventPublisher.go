@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"mfus_WalletTransactionManager/models"
+	"os"
+	"sync"
+)
+
+// EventPublisher hands outbox events to a broker or sink. Publish must only return nil once the event is
+// safely handed over. The outbox relay calls Publish from a single goroutine, in order per key, and retries
+// an event until it succeeds, so publishers can see the same event more than once.
+type EventPublisher interface {
+	Publish(ctx context.Context, event models.OutboxEvent) error
+	Close() error
+}
+
+// MemoryEventPublisher keeps published events in memory. Useful for tests and single-process setups
+// where only webhooks consume events.
+type MemoryEventPublisher struct {
+	mu     sync.Mutex
+	events []models.OutboxEvent
+}
+
+func NewMemoryEventPublisher() *MemoryEventPublisher {
+	return &MemoryEventPublisher{}
+}
+
+func (p *MemoryEventPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events returns a copy of the events published so far
+func (p *MemoryEventPublisher) Events() []models.OutboxEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]models.OutboxEvent(nil), p.events...)
+}
+
+func (p *MemoryEventPublisher) Close() error {
+	return nil
+}
+
+// FileEventPublisher appends events to a file as newline-delimited JSON and syncs after every event
+type FileEventPublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileEventPublisher(path string) (*FileEventPublisher, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileEventPublisher{file: file}, nil
+}
+
+func (p *FileEventPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return p.file.Sync()
+}
+
+func (p *FileEventPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.file.Close()
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"mfus_WalletTransactionManager/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrHouseWalletNotConfigured = errors.New("House revenue wallet is not configured")
+	ErrInvalidFeeSchedule       = errors.New("Invalid fee schedule")
+	ErrOpenEndedTierRequired    = errors.New("A tiered fee schedule needs exactly one tier without up_to")
+)
+
+// Wallet that receives every fee charged. Set at startup with SetHouseRevenueWallet.
+var houseRevenueWalletID primitive.ObjectID
+
+// SetHouseRevenueWallet configures the wallet that fee income is credited to
+func SetHouseRevenueWallet(virtualWalletID primitive.ObjectID) {
+	houseRevenueWalletID = virtualWalletID
+}
+
+func feeSchedulesCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("fee_schedules")
+}
+
+// CreateFeeSchedule validates and stores a fee schedule. A tiered schedule must have one open-ended top tier
+// so that every amount falls in a tier, and every amount of the schedule must be in its currency.
+func CreateFeeSchedule(client *mongo.Client, schedule models.FeeSchedule) (*models.FeeSchedule, error) {
+	switch schedule.Method {
+	case models.FlatFee, models.PercentageFee:
+	case models.TieredFee:
+		if len(schedule.Tiers) == 0 {
+			return nil, ErrInvalidFeeSchedule
+		}
+		openEnded := 0
+		for _, tier := range schedule.Tiers {
+			if tier.UpTo == nil {
+				openEnded++
+			}
+		}
+		if openEnded != 1 {
+			return nil, ErrOpenEndedTierRequired
+		}
+	default:
+		return nil, ErrInvalidFeeSchedule
+	}
+	if !chargesFee(schedule.TransactionType) || schedule.BasisPoints < 0 {
+		return nil, ErrInvalidFeeSchedule
+	}
+
+	// Unset amounts take over the currency of the schedule
+	if schedule.Currency == "" {
+		schedule.Currency = models.DefaultCurrency
+	}
+	schedule.Tiers = append([]models.FeeTier(nil), schedule.Tiers...)
+	amounts := []*models.Money{&schedule.Flat, schedule.Min, schedule.Max}
+	for i := range schedule.Tiers {
+		amounts = append(amounts, &schedule.Tiers[i].Flat, schedule.Tiers[i].UpTo)
+	}
+	for _, amount := range amounts {
+		if amount == nil {
+			continue
+		}
+		if amount.IsZero() {
+			*amount = models.NewMoney(0, schedule.Currency)
+		} else if amount.CurrencyCode() != schedule.Currency {
+			return nil, models.ErrCurrencyMismatch
+		}
+	}
+	if schedule.Min != nil && schedule.Max != nil && schedule.Min.Units > schedule.Max.Units {
+		return nil, ErrInvalidFeeSchedule
+	}
+
+	schedule.ID = primitive.NewObjectID()
+	schedule.CreatedAt = time.Now()
+	_, err := feeSchedulesCollection(client).InsertOne(context.Background(), schedule)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListFeeSchedules returns every stored fee schedule
+func ListFeeSchedules(client *mongo.Client) ([]models.FeeSchedule, error) {
+	cursor, err := feeSchedulesCollection(client).Find(context.Background(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	schedules := []models.FeeSchedule{}
+	err = cursor.All(context.Background(), &schedules)
+	return schedules, err
+}
+
+// Helper function to find the most specific active schedule for a transaction in the given currency.
+// A schedule for the exact AccountType outranks one for the exact WalletType, which outranks a generic one.
+func findFeeSchedule(ctx context.Context, client *mongo.Client, transactionType models.TransactionType, accountType models.AccountType, walletType models.WalletType, currency string) (*models.FeeSchedule, error) {
+	currencies := bson.A{currency}
+	if currency == models.DefaultCurrency {
+		// Schedules stored before schedules had a currency are in INR
+		currencies = append(currencies, nil)
+	}
+	cursor, err := feeSchedulesCollection(client).Find(ctx, bson.M{
+		"transaction_type": transactionType,
+		"active":           true,
+		"currency":         bson.M{"$in": currencies},
+		"account_type":     bson.M{"$in": bson.A{accountType, "", nil}},
+		"wallet_type":      bson.M{"$in": bson.A{walletType, "", nil}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var schedules []models.FeeSchedule
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, nil
+	}
+
+	specificity := func(schedule models.FeeSchedule) int {
+		score := 0
+		if schedule.AccountType != "" {
+			score += 2
+		}
+		if schedule.WalletType != "" {
+			score++
+		}
+		return score
+	}
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return specificity(schedules[i]) > specificity(schedules[j])
+	})
+	return &schedules[0], nil
+}
+
+// ComputeFee applies a schedule to an amount, including its minimum and maximum caps.
+// The amount must be in the currency of the schedule.
+func ComputeFee(schedule models.FeeSchedule, amount models.Money) (models.Money, error) {
+	currency := schedule.Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	if amount.CurrencyCode() != currency {
+		return models.Money{}, models.ErrCurrencyMismatch
+	}
+	fee := models.NewMoney(0, currency)
+	var err error
+
+	switch schedule.Method {
+	case models.FlatFee:
+		fee = schedule.Flat
+	case models.PercentageFee:
+		fee = basisPointsOf(amount, schedule.BasisPoints)
+	case models.TieredFee:
+		if len(schedule.Tiers) == 0 {
+			return models.Money{}, ErrInvalidFeeSchedule
+		}
+		tiers := append([]models.FeeTier(nil), schedule.Tiers...)
+		sort.SliceStable(tiers, func(i, j int) bool {
+			if tiers[i].UpTo == nil {
+				return false
+			}
+			return tiers[j].UpTo == nil || tiers[i].UpTo.Units < tiers[j].UpTo.Units
+		})
+		// Amounts above every bound of a schedule without an open-ended tier fall in the top tier
+		tier := tiers[len(tiers)-1]
+		for _, t := range tiers {
+			if t.UpTo == nil {
+				tier = t
+				break
+			}
+			cmp, err := amount.Cmp(*t.UpTo)
+			if err != nil {
+				return models.Money{}, err
+			}
+			if cmp <= 0 {
+				tier = t
+				break
+			}
+		}
+		fee, err = basisPointsOf(amount, tier.BasisPoints).Add(tier.Flat)
+		if err != nil {
+			return models.Money{}, err
+		}
+	default:
+		return models.Money{}, ErrInvalidFeeSchedule
+	}
+
+	if schedule.Min != nil {
+		cmp, err := fee.Cmp(*schedule.Min)
+		if err != nil {
+			return models.Money{}, err
+		}
+		if cmp < 0 {
+			fee = *schedule.Min
+		}
+	}
+	if schedule.Max != nil {
+		cmp, err := fee.Cmp(*schedule.Max)
+		if err != nil {
+			return models.Money{}, err
+		}
+		if cmp > 0 {
+			fee = *schedule.Max
+		}
+	}
+	return fee, nil
+}
+
+// Helper function to compute a basis point share of an amount, rounding half up to the nearest minor unit
+func basisPointsOf(amount models.Money, basisPoints int64) models.Money {
+	product := new(big.Int).Mul(big.NewInt(amount.Units), big.NewInt(basisPoints))
+	product.Add(product, big.NewInt(5000))
+	product.Quo(product, big.NewInt(10000))
+	return models.NewMoney(product.Int64(), amount.CurrencyCode())
+}
+
+// Helper function to quote the fee of a transaction for an account type and wallet type
+func quoteFee(ctx context.Context, client *mongo.Client, transactionType models.TransactionType, accountType models.AccountType, walletType models.WalletType, amount models.Money) (models.FeeQuote, error) {
+	quote := models.FeeQuote{Amount: amount, Fee: models.NewMoney(0, amount.CurrencyCode()), Total: amount}
+	if !chargesFee(transactionType) {
+		return quote, nil
+	}
+	schedule, err := findFeeSchedule(ctx, client, transactionType, accountType, walletType, amount.CurrencyCode())
+	if err != nil || schedule == nil {
+		return quote, err
+	}
+
+	quote.Fee, err = ComputeFee(*schedule, amount)
+	if err != nil {
+		return quote, err
+	}
+	quote.Total, err = amount.Add(quote.Fee)
+	if err != nil {
+		return quote, err
+	}
+	quote.ScheduleID = &schedule.ID
+	return quote, nil
+}
+
+// QuoteWalletFee previews the fee of a transaction on a virtual wallet
+func QuoteWalletFee(client *mongo.Client, virtualWalletID primitive.ObjectID, transactionType models.TransactionType, amount models.Money) (models.FeeQuote, error) {
+	virtualWallet, err := FindVirtualWallet(client, virtualWalletID, "")
+	if err != nil {
+		return models.FeeQuote{}, err
+	}
+	if virtualWallet.ID == houseRevenueWalletID {
+		return models.FeeQuote{Amount: amount, Fee: models.NewMoney(0, amount.CurrencyCode()), Total: amount}, nil
+	}
+	accountType, err := walletAccountType(client, virtualWallet)
+	if err != nil {
+		return models.FeeQuote{}, err
+	}
+	return quoteFee(context.Background(), client, transactionType, accountType, virtualWallet.Policy.WalletType, amount)
+}
+
+// QuoteAccountFee previews the fee of a transaction posted directly to an account
+func QuoteAccountFee(client *mongo.Client, accountID primitive.ObjectID, transactionType models.TransactionType, amount models.Money) (models.FeeQuote, error) {
+	account, err := FindAccount(client, accountID)
+	if err != nil {
+		return models.FeeQuote{}, err
+	}
+	return quoteFee(context.Background(), client, transactionType, account.Type, "", amount)
+}
+
+// Helper function reporting whether a transaction type is subject to fee schedules.
+// Holds, releases and transfer legs are never charged.
+func chargesFee(transactionType models.TransactionType) bool {
+	switch transactionType {
+	case models.Deposit, models.Credit, models.Withdraw, models.Debit:
+		return true
+	}
+	return false
+}
+
+// Helper function returning the fee charged for a transaction on a virtual wallet.
+// The house revenue wallet itself is never charged.
+func walletTransactionFee(client *mongo.Client, virtualWallet *models.VirtualWallet, transactionType models.TransactionType, amount models.Money) (models.Money, error) {
+	if virtualWallet.ID == houseRevenueWalletID {
+		return models.NewMoney(0, amount.CurrencyCode()), nil
+	}
+	accountType, err := walletAccountType(client, virtualWallet)
+	if err != nil {
+		return models.Money{}, err
+	}
+	quote, err := quoteFee(context.Background(), client, transactionType, accountType, virtualWallet.Policy.WalletType, amount)
+	return quote.Fee, err
+}
+
+// Helper function returning the AccountType of the account owning a wallet, or "" if it has none
+func walletAccountType(client *mongo.Client, virtualWallet *models.VirtualWallet) (models.AccountType, error) {
+	accountID, err := primitive.ObjectIDFromHex(virtualWallet.CustomerID)
+	if err != nil {
+		// Legacy wallets may not reference an account
+		return "", nil
+	}
+	account, err := FindAccount(client, accountID)
+	if err == ErrAccountNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return account.Type, nil
+}
+
+// Helper function returning the fee ledger entry for a transaction. The caller debits it from the payer.
+func feeTransaction(parent models.Transaction, fee models.Money) models.Transaction {
+	return models.Transaction{
+		ID:        primitive.NewObjectID(),
+		WalletID:  parent.WalletID,
+		AccountID: parent.AccountID,
+		Type:      models.Fee,
+		Amount:    fee,
+		Reference: parent.Reference,
+		LinkedID:  parent.ID,
+		CreatedAt: parent.CreatedAt,
+	}
+}
+
+// Helper function to book a charged fee: writes the fee entry of the payer and credits the
+// house revenue wallet. Must run inside the MongoDB transaction that debited the payer.
+func bookFee(ctx mongo.SessionContext, client *mongo.Client, fee models.Transaction) error {
+	if houseRevenueWalletID.IsZero() {
+		return ErrHouseWalletNotConfigured
+	}
+	if err := insertTransaction(ctx, client, fee); err != nil {
+		return err
+	}
+
+	houseWallets := client.Database("walletManager").Collection("virtual_wallets")
+	result, err := houseWallets.UpdateOne(ctx,
+		bson.M{"_id": houseRevenueWalletID, "balance.currency": fee.Amount.CurrencyCode()},
+		bson.M{
+			"$inc": bson.M{"balance.units": fee.Amount.Units},
+			"$set": bson.M{"date_modified": fee.CreatedAt},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		count, err := houseWallets.CountDocuments(ctx, bson.M{"_id": houseRevenueWalletID})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			// Fees are collected in one currency only
+			return models.ErrCurrencyMismatch
+		}
+		return ErrHouseWalletNotConfigured
+	}
+	return insertTransaction(ctx, client, models.Transaction{
+		ID:        primitive.NewObjectID(),
+		WalletID:  houseRevenueWalletID,
+		Type:      models.FeeIncome,
+		Amount:    fee.Amount,
+		Reference: fee.Reference,
+		LinkedID:  fee.ID,
+		CreatedAt: fee.CreatedAt,
+	})
+}
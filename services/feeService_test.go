@@ -0,0 +1,165 @@
+package services
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestComputeFee(t *testing.T) {
+	tiered := models.FeeSchedule{
+		Method: models.TieredFee,
+		Tiers: []models.FeeTier{
+			{UpTo: limitAmount("1000.00"), Flat: models.MustParseMoney("5.00"), BasisPoints: 0},
+			{UpTo: nil, Flat: models.MustParseMoney("0"), BasisPoints: 50},
+			{UpTo: limitAmount("100.00"), Flat: models.MustParseMoney("1.00"), BasisPoints: 0},
+		},
+	}
+	// A stored schedule without an open-ended tier charges amounts above it by its top tier
+	capped := models.FeeSchedule{
+		Method: models.TieredFee,
+		Tiers: []models.FeeTier{
+			{UpTo: limitAmount("100.00"), Flat: models.MustParseMoney("1.00")},
+			{UpTo: limitAmount("1000.00"), Flat: models.MustParseMoney("5.00")},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		schedule models.FeeSchedule
+		amount   string
+		want     string
+	}{
+		{name: "flat", schedule: models.FeeSchedule{Method: models.FlatFee, Flat: models.MustParseMoney("2.50")}, amount: "10.00", want: "2.50"},
+		{name: "percentage rounds half up", schedule: models.FeeSchedule{Method: models.PercentageFee, BasisPoints: 150}, amount: "10.10", want: "0.15"},
+		{name: "minimum", schedule: models.FeeSchedule{Method: models.PercentageFee, BasisPoints: 100, Min: limitAmount("1.00")}, amount: "10.00", want: "1.00"},
+		{name: "maximum", schedule: models.FeeSchedule{Method: models.PercentageFee, BasisPoints: 100, Max: limitAmount("5.00")}, amount: "10000.00", want: "5.00"},
+		{name: "lowest tier", schedule: tiered, amount: "100.00", want: "1.00"},
+		{name: "middle tier", schedule: tiered, amount: "100.01", want: "5.00"},
+		{name: "open-ended tier", schedule: tiered, amount: "2000.00", want: "10.00"},
+		{name: "above every tier", schedule: capped, amount: "5000.00", want: "5.00"},
+	}
+	for _, test := range tests {
+		fee, err := ComputeFee(test.schedule, models.MustParseMoney(test.amount))
+		if err != nil || fee != models.MustParseMoney(test.want) {
+			t.Errorf("%s: fee = %v, %v; want %s", test.name, fee, err, test.want)
+		}
+	}
+
+	usd := models.FeeSchedule{Method: models.FlatFee, Currency: "USD", Flat: models.NewMoney(100, "USD")}
+	if _, err := ComputeFee(usd, models.MustParseMoney("10.00")); err != models.ErrCurrencyMismatch {
+		t.Errorf("USD schedule on an INR amount returned %v, want ErrCurrencyMismatch", err)
+	}
+	if fee, err := ComputeFee(usd, models.NewMoney(1000, "USD")); err != nil || fee != models.NewMoney(100, "USD") {
+		t.Errorf("USD schedule on a USD amount = %v, %v; want 1.00 USD", fee, err)
+	}
+}
+
+func TestCreateFeeScheduleValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule models.FeeSchedule
+		want     error
+	}{
+		{
+			name:     "no tiers",
+			schedule: models.FeeSchedule{TransactionType: models.Debit, Method: models.TieredFee},
+			want:     ErrInvalidFeeSchedule,
+		},
+		{
+			name: "no open-ended tier",
+			schedule: models.FeeSchedule{TransactionType: models.Debit, Method: models.TieredFee, Tiers: []models.FeeTier{
+				{UpTo: limitAmount("100.00"), Flat: models.MustParseMoney("1.00")},
+			}},
+			want: ErrOpenEndedTierRequired,
+		},
+		{
+			name: "two open-ended tiers",
+			schedule: models.FeeSchedule{TransactionType: models.Debit, Method: models.TieredFee, Tiers: []models.FeeTier{
+				{Flat: models.MustParseMoney("1.00")},
+				{Flat: models.MustParseMoney("2.00")},
+			}},
+			want: ErrOpenEndedTierRequired,
+		},
+		{
+			name:     "flat fee in another currency",
+			schedule: models.FeeSchedule{TransactionType: models.Debit, Method: models.FlatFee, Currency: "USD", Flat: models.MustParseMoney("1.00")},
+			want:     models.ErrCurrencyMismatch,
+		},
+		{
+			name: "tier bound in another currency",
+			schedule: models.FeeSchedule{TransactionType: models.Debit, Method: models.TieredFee, Currency: "USD", Tiers: []models.FeeTier{
+				{UpTo: limitAmount("100.00")},
+				{BasisPoints: 10},
+			}},
+			want: models.ErrCurrencyMismatch,
+		},
+		{
+			name:     "minimum above maximum",
+			schedule: models.FeeSchedule{TransactionType: models.Debit, Method: models.PercentageFee, Min: limitAmount("5.00"), Max: limitAmount("1.00")},
+			want:     ErrInvalidFeeSchedule,
+		},
+		{
+			name:     "holds are not charged",
+			schedule: models.FeeSchedule{TransactionType: models.Hold, Method: models.FlatFee},
+			want:     ErrInvalidFeeSchedule,
+		},
+	}
+	for _, test := range tests {
+		// Invalid schedules are rejected before anything is stored
+		if _, err := CreateFeeSchedule(nil, test.schedule); err != test.want {
+			t.Errorf("%s: error = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestWalletFeesMatchCurrency(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	houseWalletID := createWallet(t, client, accountID, models.CashWallet, "0.00")
+	SetHouseRevenueWallet(houseWalletID)
+	defer SetHouseRevenueWallet(primitive.NilObjectID)
+
+	_, err := CreateFeeSchedule(client, models.FeeSchedule{
+		Name:            "Debit fee",
+		TransactionType: models.Debit,
+		Method:          models.FlatFee,
+		Flat:            models.MustParseMoney("1.00"),
+		Active:          true,
+	})
+	if err != nil {
+		t.Fatalf("CreateFeeSchedule: %v", err)
+	}
+	inrWalletID := createWallet(t, client, accountID, models.CashWallet, "100.00")
+	// Limits are in a single currency, so the USD wallet belongs to an account with USD limits
+	usdAccountID := createFundedAccount(t, client, "0.00")
+	usdLimit := models.NewMoney(1000000, "USD")
+	if err := SetLimitOverrides(client, usdAccountID, models.TransactionLimits{MaxPerTransaction: &usdLimit, DailyWithdrawal: &usdLimit, MonthlyWithdrawal: &usdLimit}); err != nil {
+		t.Fatal(err)
+	}
+	usdWalletID := createWallet(t, client, usdAccountID, models.CashWallet, "100.00 USD")
+
+	if err := CreateVirtualWalletTransaction(client, inrWalletID, "", models.Debit, models.MustParseMoney("10.00")); err != nil {
+		t.Fatalf("INR debit: %v", err)
+	}
+	if err := CreateVirtualWalletTransaction(client, usdWalletID, "", models.Debit, models.NewMoney(1000, "USD")); err != nil {
+		t.Fatalf("USD debit: %v", err)
+	}
+
+	// Only the INR debit matches the INR schedule
+	expected := map[primitive.ObjectID]models.Money{
+		inrWalletID:   models.MustParseMoney("89.00"),
+		usdWalletID:   models.NewMoney(9000, "USD"),
+		houseWalletID: models.MustParseMoney("1.00"),
+	}
+	for walletID, want := range expected {
+		virtualWallet, err := FindVirtualWallet(client, walletID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if virtualWallet.Balance != want {
+			t.Errorf("wallet %s balance = %v, want %v", walletID.Hex(), virtualWallet.Balance, want)
+		}
+	}
+}
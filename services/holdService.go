@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mfus_WalletTransactionManager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Lifetime of a hold created without an explicit expiry
+const DefaultHoldLifetime = 7 * 24 * time.Hour
+
+var (
+	ErrAccountNotFound    = errors.New("Account not found")
+	ErrHoldNotFound       = errors.New("Hold not found")
+	ErrHoldNotActive      = errors.New("Hold is no longer active")
+	ErrHoldExpiryInPast   = errors.New("Hold expiry must be in the future")
+	ErrCaptureExceedsHold = errors.New("Capture amount exceeds the held amount")
+)
+
+func holdsCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("holds")
+}
+
+// EnsureHoldIndexes creates the indexes used to list an account's holds and to find stale holds
+func EnsureHoldIndexes(client *mongo.Client) error {
+	_, err := holdsCollection(client).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "account_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}}},
+	})
+	return err
+}
+
+// CreateHold reserves funds on an account by moving them from Balance to HoldBalance
+// and records the reservation as an active hold with its own ID.
+func CreateHold(client *mongo.Client, accountID primitive.ObjectID, amount models.Money, reference string, expiresAt time.Time) (*models.AuthorizationHold, error) {
+	if !amount.IsPositive() {
+		return nil, errors.New("Hold amount must be positive")
+	}
+	now := time.Now()
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(DefaultHoldLifetime)
+	}
+	if !expiresAt.After(now) {
+		return nil, ErrHoldExpiryInPast
+	}
+
+	hold := &models.AuthorizationHold{
+		ID:             primitive.NewObjectID(),
+		AccountID:      accountID,
+		Amount:         amount,
+		CapturedAmount: models.NewMoney(0, amount.CurrencyCode()),
+		Reference:      reference,
+		Status:         models.HoldActive,
+		ExpiresAt:      expiresAt,
+		CreatedAt:      now,
+		DateModified:   now,
+	}
+	entry := models.Transaction{
+		ID:        primitive.NewObjectID(),
+		AccountID: accountID,
+		Type:      models.Hold,
+		Amount:    amount,
+		Reference: reference,
+		HoldID:    hold.ID,
+		CreatedAt: now,
+	}
+
+	err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+		if err := applyTransactionLimits(ctx, client, entry); err != nil {
+			return err
+		}
+		accounts := client.Database("walletManager").Collection("accounts")
+		result, err := accounts.UpdateOne(ctx,
+			bson.M{"_id": accountID, "balance.currency": amount.CurrencyCode(), "balance.units": bson.M{"$gte": amount.Units}},
+			bson.M{
+				"$inc": bson.M{"balance.units": -amount.Units, "hold_balance.units": amount.Units},
+				"$set": bson.M{"date_modified": now},
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			var account models.Account
+			err := accounts.FindOne(ctx, bson.M{"_id": accountID}).Decode(&account)
+			if err == mongo.ErrNoDocuments {
+				return ErrAccountNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if account.Balance.CurrencyCode() != amount.CurrencyCode() {
+				return models.ErrCurrencyMismatch
+			}
+			return ErrInsufficientFunds
+		}
+
+		if _, err := holdsCollection(client).InsertOne(ctx, hold); err != nil {
+			return err
+		}
+		return insertTransaction(ctx, client, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// FindHold returns a hold by ID
+func FindHold(client *mongo.Client, holdID primitive.ObjectID) (*models.AuthorizationHold, error) {
+	return findHold(context.Background(), client, holdID)
+}
+
+func findHold(ctx context.Context, client *mongo.Client, holdID primitive.ObjectID) (*models.AuthorizationHold, error) {
+	var hold models.AuthorizationHold
+	err := holdsCollection(client).FindOne(ctx, bson.M{"_id": holdID}).Decode(&hold)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrHoldNotFound
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// CaptureHold settles an active hold. A nil amount captures the full hold; a smaller amount is a
+// partial capture and the remainder is released back into the account balance.
+func CaptureHold(client *mongo.Client, holdID primitive.ObjectID, amount *models.Money) (*models.AuthorizationHold, error) {
+	var captured *models.AuthorizationHold
+	err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+		hold, err := findHold(ctx, client, holdID)
+		if err != nil {
+			return err
+		}
+		if hold.Status != models.HoldActive || !hold.ExpiresAt.After(time.Now()) {
+			return ErrHoldNotActive
+		}
+
+		captureAmount := hold.Amount
+		if amount != nil {
+			captureAmount = *amount
+		}
+		if !captureAmount.IsPositive() {
+			return errors.New("Capture amount must be positive")
+		}
+		if cmp, err := captureAmount.Cmp(hold.Amount); err != nil {
+			return err
+		} else if cmp > 0 {
+			return ErrCaptureExceedsHold
+		}
+		remainder, err := hold.Amount.Sub(captureAmount)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		hold.Status = models.HoldCaptured
+		hold.CapturedAmount = captureAmount
+		hold.DateModified = now
+		if err := settleHold(ctx, client, hold, remainder); err != nil {
+			return err
+		}
+
+		err = insertTransaction(ctx, client, models.Transaction{
+			ID:        primitive.NewObjectID(),
+			AccountID: hold.AccountID,
+			Type:      models.Capture,
+			Amount:    captureAmount,
+			Reference: hold.Reference,
+			HoldID:    hold.ID,
+			CreatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		captured = hold
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return captured, nil
+}
+
+// VoidHold cancels an active hold and releases the full amount back into the account balance
+func VoidHold(client *mongo.Client, holdID primitive.ObjectID) (*models.AuthorizationHold, error) {
+	var voided *models.AuthorizationHold
+	err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+		hold, err := findHold(ctx, client, holdID)
+		if err != nil {
+			return err
+		}
+		if hold.Status != models.HoldActive {
+			return ErrHoldNotActive
+		}
+
+		hold.Status = models.HoldVoided
+		hold.DateModified = time.Now()
+		if err := settleHold(ctx, client, hold, hold.Amount); err != nil {
+			return err
+		}
+		voided = hold
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return voided, nil
+}
+
+// Helper function to close an active hold with its new status. The full held amount leaves HoldBalance
+// and the given release amount returns to Balance, recorded as a release entry in the ledger.
+func settleHold(ctx mongo.SessionContext, client *mongo.Client, hold *models.AuthorizationHold, release models.Money) error {
+	// Only an active hold may change status, so concurrent capture, void and expiry cannot both succeed
+	result, err := holdsCollection(client).UpdateOne(ctx,
+		bson.M{"_id": hold.ID, "status": models.HoldActive},
+		bson.M{"$set": bson.M{
+			"status":          hold.Status,
+			"captured_amount": hold.CapturedAmount,
+			"date_modified":   hold.DateModified,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrHoldNotActive
+	}
+
+	result, err = client.Database("walletManager").Collection("accounts").UpdateOne(ctx,
+		bson.M{"_id": hold.AccountID, "hold_balance.units": bson.M{"$gte": hold.Amount.Units}},
+		bson.M{
+			"$inc": bson.M{"balance.units": release.Units, "hold_balance.units": -hold.Amount.Units},
+			"$set": bson.M{"date_modified": hold.DateModified},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	if !release.IsPositive() {
+		return nil
+	}
+	return insertTransaction(ctx, client, models.Transaction{
+		ID:        primitive.NewObjectID(),
+		AccountID: hold.AccountID,
+		Type:      models.Release,
+		Amount:    release,
+		Reference: hold.Reference,
+		HoldID:    hold.ID,
+		CreatedAt: hold.DateModified,
+	})
+}
+
+// ExpireStaleHolds releases every active hold whose expiry has passed and returns how many were expired.
+// Holds that fail to expire are logged and skipped; the returned error reports how many failed.
+func ExpireStaleHolds(client *mongo.Client) (int, error) {
+	cursor, err := holdsCollection(client).Find(
+		context.Background(),
+		bson.M{"status": models.HoldActive, "expires_at": bson.M{"$lte": time.Now()}},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	var stale []models.AuthorizationHold
+	err = cursor.All(context.Background(), &stale)
+	if err != nil {
+		return 0, err
+	}
+
+	expired, failed := 0, 0
+	for _, s := range stale {
+		err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+			hold, err := findHold(ctx, client, s.ID)
+			if err != nil {
+				return err
+			}
+			hold.Status = models.HoldExpired
+			hold.DateModified = time.Now()
+			return settleHold(ctx, client, hold, hold.Amount)
+		})
+		if err == ErrHoldNotActive {
+			// Captured or voided after it was listed
+			continue
+		}
+		if err != nil {
+			// One broken hold must not stall the rest of the sweep
+			log.Printf("Hold %s could not be expired: %v", s.ID.Hex(), err)
+			failed++
+			continue
+		}
+		expired++
+	}
+
+	if failed > 0 {
+		return expired, fmt.Errorf("%d of %d stale holds could not be expired", failed, len(stale))
+	}
+	return expired, nil
+}
+
+// RunHoldExpirer periodically expires stale holds until the process exits. Meant to be started as a goroutine.
+func RunHoldExpirer(client *mongo.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		expired, err := ExpireStaleHolds(client)
+		if err != nil {
+			log.Printf("Hold expirer failed: %v", err)
+		}
+		if expired > 0 {
+			log.Printf("Hold expirer released %d expired holds", expired)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"mfus_WalletTransactionManager/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Helper function to create an account funded with the given balance
+func createFundedAccount(t *testing.T, client *mongo.Client, balance string) primitive.ObjectID {
+	t.Helper()
+	now := time.Now()
+	accountID, err := CreateAccount(client, models.Account{
+		Email:        "holds@example.com",
+		Type:         models.Retail,
+		Balance:      models.NewMoney(0, models.DefaultCurrency),
+		HoldBalance:  models.NewMoney(0, models.DefaultCurrency),
+		CreatedAt:    now,
+		DateModified: now,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	if _, err := CreateAccountTransaction(client, accountID, models.Credit, models.MustParseMoney(balance)); err != nil {
+		t.Fatalf("Failed to fund account: %v", err)
+	}
+	return accountID
+}
+
+// Helper function to check an account's available and held balances
+func assertAccountBalances(t *testing.T, client *mongo.Client, accountID primitive.ObjectID, balance, held string) {
+	t.Helper()
+	account, err := FindAccount(client, accountID)
+	if err != nil {
+		t.Fatalf("Failed to load account: %v", err)
+	}
+	if account.Balance != models.MustParseMoney(balance) || account.HoldBalance != models.MustParseMoney(held) {
+		t.Errorf("balance = %v, held = %v; want %s and %s", account.Balance, account.HoldBalance, balance, held)
+	}
+}
+
+func TestHoldCaptureAndVoid(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "100.00")
+
+	if _, err := CreateHold(client, accountID, models.MustParseMoney("150.00"), "too-much", time.Time{}); err != ErrInsufficientFunds {
+		t.Errorf("oversized hold returned %v, want ErrInsufficientFunds", err)
+	}
+	if _, err := CreateHold(client, accountID, models.MustParseMoney("1.00"), "past", time.Now().Add(-time.Minute)); err != ErrHoldExpiryInPast {
+		t.Errorf("hold expiring in the past returned %v, want ErrHoldExpiryInPast", err)
+	}
+
+	captured, err := CreateHold(client, accountID, models.MustParseMoney("40.00"), "order-1", time.Time{})
+	if err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+	voided, err := CreateHold(client, accountID, models.MustParseMoney("25.00"), "order-2", time.Time{})
+	if err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+	assertAccountBalances(t, client, accountID, "35.00", "65.00")
+
+	tooMuch := models.MustParseMoney("50.00")
+	if _, err := CaptureHold(client, captured.ID, &tooMuch); err != ErrCaptureExceedsHold {
+		t.Errorf("capture above the hold returned %v, want ErrCaptureExceedsHold", err)
+	}
+	partial := models.MustParseMoney("30.00")
+	if _, err := CaptureHold(client, captured.ID, &partial); err != nil {
+		t.Fatalf("CaptureHold: %v", err)
+	}
+	if _, err := VoidHold(client, voided.ID); err != nil {
+		t.Fatalf("VoidHold: %v", err)
+	}
+	// The uncaptured 10.00 and the voided 25.00 are back in the balance
+	assertAccountBalances(t, client, accountID, "70.00", "0.00")
+
+	if _, err := VoidHold(client, captured.ID); err != ErrHoldNotActive {
+		t.Errorf("voiding a captured hold returned %v, want ErrHoldNotActive", err)
+	}
+	if _, err := CaptureHold(client, primitive.NewObjectID(), nil); err != ErrHoldNotFound {
+		t.Errorf("capturing an unknown hold returned %v, want ErrHoldNotFound", err)
+	}
+}
+
+func TestExpireStaleHolds(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "100.00")
+
+	stale, err := CreateHold(client, accountID, models.MustParseMoney("30.00"), "stale", time.Time{})
+	if err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+	if _, err := CreateHold(client, accountID, models.MustParseMoney("20.00"), "fresh", time.Time{}); err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+	_, err = holdsCollection(client).UpdateOne(context.Background(),
+		bson.M{"_id": stale.ID}, bson.M{"$set": bson.M{"expires_at": time.Now().Add(-time.Minute)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := ExpireStaleHolds(client)
+	if err != nil || expired != 1 {
+		t.Fatalf("ExpireStaleHolds = %d, %v; want 1, nil", expired, err)
+	}
+	hold, err := FindHold(client, stale.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Status != models.HoldExpired {
+		t.Errorf("status = %s, want %s", hold.Status, models.HoldExpired)
+	}
+	assertAccountBalances(t, client, accountID, "80.00", "20.00")
+
+	if _, err := CaptureHold(client, stale.ID, nil); err != ErrHoldNotActive {
+		t.Errorf("capturing an expired hold returned %v, want ErrHoldNotActive", err)
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mfus_WalletTransactionManager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How long a stored Idempotency-Key is honoured before MongoDB's TTL monitor removes it
+const IdempotencyKeyTTL = 24 * time.Hour
+
+var ErrIdempotencyKeyInUse = errors.New("Idempotency key already used")
+
+func idempotencyCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("idempotency_keys")
+}
+
+// EnsureIdempotencyIndexes creates the TTL index that expires stored idempotency keys
+func EnsureIdempotencyIndexes(client *mongo.Client) error {
+	_, err := idempotencyCollection(client).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.M{"created_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(int32(IdempotencyKeyTTL.Seconds())),
+	})
+	return err
+}
+
+// Helper function to build the stored ID of a key within its caller's scope
+func idempotencyRecordID(scope string, key string) string {
+	return scope + ":" + key
+}
+
+// ReserveIdempotencyKey claims a caller's key for a new request. If the caller already used the key,
+// the existing record is returned together with ErrIdempotencyKeyInUse.
+func ReserveIdempotencyKey(client *mongo.Client, scope string, key string, requestHash string) (*models.IdempotencyRecord, error) {
+	record := models.IdempotencyRecord{
+		ID:          idempotencyRecordID(scope, key),
+		Scope:       scope,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   time.Now(),
+	}
+	_, err := idempotencyCollection(client).InsertOne(context.Background(), record)
+	if err == nil {
+		return &record, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	var existing models.IdempotencyRecord
+	err = idempotencyCollection(client).FindOne(context.Background(), bson.M{"_id": record.ID}).Decode(&existing)
+	if err != nil {
+		return nil, err
+	}
+	return &existing, ErrIdempotencyKeyInUse
+}
+
+// CompleteIdempotencyKey stores the response of the original request against its key
+func CompleteIdempotencyKey(client *mongo.Client, scope string, key string, statusCode int, contentType string, body []byte) error {
+	_, err := idempotencyCollection(client).UpdateOne(
+		context.Background(),
+		bson.M{"_id": idempotencyRecordID(scope, key)},
+		bson.M{"$set": bson.M{
+			"completed":     true,
+			"status_code":   statusCode,
+			"content_type":  contentType,
+			"response_body": body,
+		}},
+	)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a key whose request failed, so the client can retry it
+func ReleaseIdempotencyKey(client *mongo.Client, scope string, key string) error {
+	_, err := idempotencyCollection(client).DeleteOne(context.Background(), bson.M{"_id": idempotencyRecordID(scope, key)})
+	return err
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+)
+
+// A reserved key is answered with its record until completed, and then with the stored response
+func TestIdempotencyKeyReserveAndComplete(t *testing.T) {
+	client := testMongoClient(t)
+
+	record, err := ReserveIdempotencyKey(client, "account:a", "key-1", "hash-1")
+	if err != nil {
+		t.Fatalf("first reservation: %v", err)
+	}
+	if record.Completed {
+		t.Error("a new reservation is already completed")
+	}
+
+	record, err = ReserveIdempotencyKey(client, "account:a", "key-1", "hash-1")
+	if err != ErrIdempotencyKeyInUse {
+		t.Fatalf("second reservation returned %v, want %v", err, ErrIdempotencyKeyInUse)
+	}
+	if record.Completed || record.RequestHash != "hash-1" {
+		t.Errorf("in-flight record = %+v, want the uncompleted reservation of hash-1", record)
+	}
+
+	if err := CompleteIdempotencyKey(client, "account:a", "key-1", 201, "application/json", []byte(`{"ok":true}`)); err != nil {
+		t.Fatal(err)
+	}
+	record, err = ReserveIdempotencyKey(client, "account:a", "key-1", "hash-2")
+	if err != ErrIdempotencyKeyInUse {
+		t.Fatalf("reservation after completion returned %v, want %v", err, ErrIdempotencyKeyInUse)
+	}
+	if !record.Completed || record.StatusCode != 201 || record.ContentType != "application/json" || !bytes.Equal(record.ResponseBody, []byte(`{"ok":true}`)) {
+		t.Errorf("completed record = %+v, want the stored 201 response", record)
+	}
+	if record.RequestHash != "hash-1" {
+		t.Errorf("completed record hash = %s, want the original request's", record.RequestHash)
+	}
+}
+
+// A released key can be reserved again, as after a request that failed with a server error
+func TestIdempotencyKeyRelease(t *testing.T) {
+	client := testMongoClient(t)
+
+	if _, err := ReserveIdempotencyKey(client, "account:a", "key-1", "hash-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReleaseIdempotencyKey(client, "account:a", "key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveIdempotencyKey(client, "account:a", "key-1", "hash-1"); err != nil {
+		t.Errorf("reservation after release: %v", err)
+	}
+}
+
+// The same key sent by different callers names different requests
+func TestIdempotencyKeysAreScopedToCaller(t *testing.T) {
+	client := testMongoClient(t)
+
+	if _, err := ReserveIdempotencyKey(client, "account:a", "key-1", "hash-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompleteIdempotencyKey(client, "account:a", "key-1", 201, "application/json", []byte(`{"secret":true}`)); err != nil {
+		t.Fatal(err)
+	}
+	for _, scope := range []string{"account:b", "admin"} {
+		record, err := ReserveIdempotencyKey(client, scope, "key-1", "hash-1")
+		if err != nil {
+			t.Fatalf("reservation by %s: %v", scope, err)
+		}
+		if record.Completed {
+			t.Errorf("reservation by %s sees another caller's response", scope)
+		}
+	}
+
+	// Releasing one caller's key leaves the others in place
+	if err := ReleaseIdempotencyKey(client, "account:b", "key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReserveIdempotencyKey(client, "account:a", "key-1", "hash-1"); err != ErrIdempotencyKeyInUse {
+		t.Errorf("account:a reservation after another caller's release returned %v, want %v", err, ErrIdempotencyKeyInUse)
+	}
+}
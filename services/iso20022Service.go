@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mfus_WalletTransactionManager/models"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrNoBalanceEffect = errors.New("Transaction does not change the balance")
+
+// Issuer of the proprietary bank transaction codes, which are the ledger TransactionTypes
+const camtCodeIssuer = "walletManager"
+
+// Helper function to format ISODateTime values
+func camtDateTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Helper function to split a signed amount into an unsigned camt amount and its CRDT/DBIT indicator
+func camtAmount(m models.Money) (models.CamtAmount, string) {
+	indicator := "CRDT"
+	if m.IsNegative() {
+		indicator = "DBIT"
+		m = m.Neg()
+	}
+	return models.CamtAmount{Currency: m.CurrencyCode(), Value: m.Decimal()}, indicator
+}
+
+// Helper function to truncate free text to the length allowed by a MaxNText type
+func camtText(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
+
+// Helper function to identify the wallet or account. The wallet's customer is given as the owner.
+func camtAccount(ownerID primitive.ObjectID, customerID string, currency string) models.CamtAccount {
+	account := models.CamtAccount{
+		ID:       models.CamtOtherID{ID: ownerID.Hex()},
+		Currency: currency,
+	}
+	if customerID != "" {
+		account.Owner = &models.CamtOwner{ID: models.CamtOtherID{ID: camtText(customerID, 35)}}
+	}
+	return account
+}
+
+// Helper function to map a ledger entry to a booked camt entry. change is the signed effect on Balance.
+func camtEntry(transactionID primitive.ObjectID, transactionType models.TransactionType, reference string, date time.Time, change models.Money) models.CamtEntry {
+	amount, indicator := camtAmount(change)
+	entry := models.CamtEntry{
+		Reference:   transactionID.Hex(),
+		Amount:      amount,
+		CreditDebit: indicator,
+		Reversal:    transactionType == models.Reversal,
+		Status:      "BOOK",
+		BookingDate: camtDateTime(date),
+		ValueDate:   camtDateTime(date),
+		ServicerRef: transactionID.Hex(),
+		BankTxCode:  models.CamtProprietary{Code: string(transactionType), Issuer: camtCodeIssuer},
+	}
+	if reference != "" {
+		entry.Details = &models.CamtTxDetails{Unstructured: camtText(reference, 140)}
+	}
+	return entry
+}
+
+// Camt053Builder is a StatementSink that collects a statement into a camt.053 document.
+// The balances and totals precede the entries in the message, so the whole statement is held until End.
+// Entries that only move money between Balance and HoldBalance are left out, as the statement reports Balance.
+type Camt053Builder struct {
+	header    models.StatementHeader
+	statement models.CamtStatement
+	credits   models.Money
+	debits    models.Money
+	nCredits  int
+	nDebits   int
+	document  *models.Camt053Document
+}
+
+func (b *Camt053Builder) Begin(header models.StatementHeader) error {
+	b.header = header
+	currency := header.OpeningBalance.CurrencyCode()
+	ownerID := header.AccountID
+	if !header.WalletID.IsZero() {
+		ownerID = header.WalletID
+	}
+	now := camtDateTime(time.Now())
+	b.statement = models.CamtStatement{
+		ID:        ownerID.Hex() + "-" + header.To.UTC().Format("20060102"),
+		CreatedAt: now,
+		FromTo:    &models.CamtFromTo{From: camtDateTime(header.From), To: camtDateTime(header.To)},
+		Account:   camtAccount(ownerID, header.CustomerID, currency),
+	}
+	b.credits = models.NewMoney(0, currency)
+	b.debits = models.NewMoney(0, currency)
+	return nil
+}
+
+func (b *Camt053Builder) Line(line models.StatementLine) error {
+	if line.Amount.IsZero() {
+		return nil
+	}
+	var err error
+	if line.Amount.IsNegative() {
+		b.nDebits++
+		b.debits, err = b.debits.Add(line.Amount.Neg())
+	} else {
+		b.nCredits++
+		b.credits, err = b.credits.Add(line.Amount)
+	}
+	if err != nil {
+		return err
+	}
+	b.statement.Entries = append(b.statement.Entries, camtEntry(line.TransactionID, line.Type, line.Reference, line.Date, line.Amount))
+	return nil
+}
+
+func (b *Camt053Builder) End(summary models.StatementSummary) error {
+	for _, balance := range []struct {
+		code   string
+		amount models.Money
+		date   time.Time
+	}{
+		{code: "OPBD", amount: b.header.OpeningBalance, date: b.header.From},
+		{code: "CLBD", amount: summary.ClosingBalance, date: b.header.To},
+	} {
+		amount, indicator := camtAmount(balance.amount)
+		b.statement.Balances = append(b.statement.Balances, models.CamtBalance{
+			Code:        balance.code,
+			Amount:      amount,
+			CreditDebit: indicator,
+			DateTime:    camtDateTime(balance.date),
+		})
+	}
+
+	if count := b.nCredits + b.nDebits; count > 0 {
+		sum, err := b.credits.Add(b.debits)
+		if err != nil {
+			return err
+		}
+		net, err := b.credits.Sub(b.debits)
+		if err != nil {
+			return err
+		}
+		netAmount, indicator := camtAmount(net)
+		b.statement.Summary = &models.CamtTxsSummary{
+			Total: models.CamtTotal{
+				Count:       strconv.Itoa(count),
+				Sum:         sum.Decimal(),
+				NetAmount:   netAmount.Value,
+				CreditDebit: indicator,
+			},
+			Credits: models.CamtNumberSum{Count: strconv.Itoa(b.nCredits), Sum: b.credits.Decimal()},
+			Debits:  models.CamtNumberSum{Count: strconv.Itoa(b.nDebits), Sum: b.debits.Decimal()},
+		}
+	}
+
+	b.document = &models.Camt053Document{
+		Namespace: models.Camt053Namespace,
+		Statement: models.CamtBankToCustomer{
+			GroupHeader: models.CamtGroupHeader{MessageID: primitive.NewObjectID().Hex(), CreatedAt: b.statement.CreatedAt},
+			Statements:  []models.CamtStatement{b.statement},
+		},
+	}
+	return nil
+}
+
+// Document returns the finished camt.053 message, or nil before End
+func (b *Camt053Builder) Document() *models.Camt053Document {
+	return b.document
+}
+
+// TransactionNotification builds the camt.054 debit/credit notification of one posting
+func TransactionNotification(client *mongo.Client, transactionID primitive.ObjectID) (*models.Camt054Document, error) {
+	transaction, err := FindTransaction(client, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reversals and refunds take their direction from the entry they compensate
+	var originalType models.TransactionType
+	if !transaction.LinkedID.IsZero() {
+		var original models.Transaction
+		err := transactionsCollection(client).FindOne(context.Background(), bson.M{"_id": transaction.LinkedID}).Decode(&original)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+		originalType = original.Type
+	}
+	change, _ := transactionEffect(*transaction, originalType)
+	if change.IsZero() {
+		return nil, ErrNoBalanceEffect
+	}
+
+	var customerID string
+	if !transaction.WalletID.IsZero() {
+		var wallet models.VirtualWallet
+		err := client.Database("walletManager").Collection("virtual_wallets").FindOne(context.Background(), bson.M{"_id": transaction.WalletID}).Decode(&wallet)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+		customerID = wallet.CustomerID
+	}
+	return camt054Document(*transaction, change, customerID), nil
+}
+
+// Helper function to build the camt.054 notification of a posting whose signed effect on Balance is change
+func camt054Document(transaction models.Transaction, change models.Money, customerID string) *models.Camt054Document {
+	ownerID := transaction.AccountID
+	if !transaction.WalletID.IsZero() {
+		ownerID = transaction.WalletID
+	}
+	now := camtDateTime(time.Now())
+	return &models.Camt054Document{
+		Namespace: models.Camt054Namespace,
+		Notification: models.CamtDebitCreditNotice{
+			GroupHeader: models.CamtGroupHeader{MessageID: primitive.NewObjectID().Hex(), CreatedAt: now},
+			Notifications: []models.CamtStatement{{
+				ID:        transaction.ID.Hex(),
+				CreatedAt: now,
+				Account:   camtAccount(ownerID, customerID, change.CurrencyCode()),
+				Entries:   []models.CamtEntry{camtEntry(transaction.ID, transaction.Type, transaction.Reference, transaction.CreatedAt, change)},
+			}},
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"encoding/xml"
+	"mfus_WalletTransactionManager/models"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Helper function to validate a document against one of the ISO 20022 schemas in testdata with xmllint
+func validateCamt(t *testing.T, document interface{}, schema string) {
+	t.Helper()
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint is not installed")
+	}
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to encode document: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "document.xml")
+	if err := os.WriteFile(path, append([]byte(xml.Header), data...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command(xmllint, "--noout", "--schema", filepath.Join("testdata", schema), path).CombinedOutput()
+	if err != nil {
+		t.Errorf("document does not match %s: %v\n%s\n%s", schema, err, output, data)
+	}
+}
+
+func TestCamt053MatchesSchema(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	builder := &Camt053Builder{}
+	err := builder.Begin(models.StatementHeader{
+		WalletID:       primitive.NewObjectID(),
+		CustomerID:     primitive.NewObjectID().Hex(),
+		From:           from,
+		To:             from.AddDate(0, 1, 0),
+		OpeningBalance: models.MustParseMoney("100.00"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []models.StatementLine{
+		{TransactionID: primitive.NewObjectID(), Date: from.Add(time.Hour), Type: models.Deposit, Reference: "salary", Amount: models.MustParseMoney("50.00"), Balance: models.MustParseMoney("150.00")},
+		{TransactionID: primitive.NewObjectID(), Date: from.Add(2 * time.Hour), Type: models.Debit, Amount: models.NewMoney(-2000, models.DefaultCurrency), Balance: models.MustParseMoney("130.00")},
+		// Moves money into HoldBalance only and is left out
+		{TransactionID: primitive.NewObjectID(), Date: from.Add(3 * time.Hour), Type: models.Capture, Amount: models.NewMoney(0, models.DefaultCurrency), Balance: models.MustParseMoney("130.00")},
+	}
+	for _, line := range lines {
+		if err := builder.Line(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := builder.End(models.StatementSummary{ClosingBalance: models.MustParseMoney("130.00")}); err != nil {
+		t.Fatal(err)
+	}
+
+	statement := builder.Document().Statement.Statements[0]
+	if len(statement.Entries) != 2 || statement.Summary == nil || statement.Summary.Total.Count != "2" {
+		t.Errorf("statement has %d entries and summary %+v, want 2 entries", len(statement.Entries), statement.Summary)
+	}
+	validateCamt(t, builder.Document(), "camt.053.001.08.xsd")
+}
+
+func TestCamt054MatchesSchema(t *testing.T) {
+	transaction := models.Transaction{
+		ID:        primitive.NewObjectID(),
+		WalletID:  primitive.NewObjectID(),
+		Type:      models.Withdraw,
+		Amount:    models.MustParseMoney("12.34"),
+		Reference: "ATM <main street> & co",
+		CreatedAt: time.Now(),
+	}
+	change, _ := transactionEffect(transaction, "")
+	validateCamt(t, camt054Document(transaction, change, primitive.NewObjectID().Hex()), "camt.054.001.08.xsd")
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"mfus_WalletTransactionManager/models"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LimitExceededError is returned when a transaction would break one of the account's limits
+type LimitExceededError struct {
+	Violation models.LimitViolation
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("Transaction exceeds the %s limit", e.Violation.Limit)
+}
+
+// Transaction types started by a customer. Only these are checked against limits and counted; releases,
+// captures, fees and compensating entries follow from an earlier transaction or are posted by the system.
+var customerInitiatedTypes = []models.TransactionType{models.Deposit, models.Withdraw, models.Credit, models.Debit, models.Hold, models.TransferOut}
+
+// Transaction types that count towards the daily and monthly withdrawal limits
+var withdrawalTypes = []models.TransactionType{models.Withdraw, models.Debit, models.Hold, models.TransferOut}
+
+func limitUsageCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("limit_usage")
+}
+
+func limitAmount(s string) *models.Money {
+	m := models.MustParseMoney(s)
+	return &m
+}
+
+func limitCount(n int64) *int64 {
+	return &n
+}
+
+// Built-in limits per AccountType, used when no profile is stored in the limit_profiles collection
+var defaultLimits = map[models.AccountType]models.TransactionLimits{
+	models.Retail: {
+		MaxPerTransaction: limitAmount("50000"),
+		DailyWithdrawal:   limitAmount("100000"),
+		MonthlyWithdrawal: limitAmount("1000000"),
+		MaxCountPerHour:   limitCount(20),
+	},
+	models.ChannelPartner: {
+		MaxPerTransaction: limitAmount("500000"),
+		DailyWithdrawal:   limitAmount("2000000"),
+		MonthlyWithdrawal: limitAmount("20000000"),
+		MaxCountPerHour:   limitCount(100),
+	},
+	models.Corporate: {
+		MaxPerTransaction: limitAmount("1000000"),
+		DailyWithdrawal:   limitAmount("5000000"),
+		MonthlyWithdrawal: limitAmount("50000000"),
+		MaxCountPerHour:   limitCount(200),
+	},
+	models.Traders: {
+		MaxPerTransaction: limitAmount("2000000"),
+		DailyWithdrawal:   limitAmount("10000000"),
+		MonthlyWithdrawal: limitAmount("100000000"),
+		MaxCountPerHour:   limitCount(500),
+	},
+	models.PrimeCorporate: {
+		MaxPerTransaction: limitAmount("10000000"),
+		DailyWithdrawal:   limitAmount("50000000"),
+		MonthlyWithdrawal: limitAmount("500000000"),
+		MaxCountPerHour:   limitCount(1000),
+	},
+}
+
+// EffectiveLimits returns the limits of an account: the stored profile of its AccountType
+// (or the built-in default) with the account's own overrides applied on top.
+func EffectiveLimits(client *mongo.Client, account *models.Account) (models.TransactionLimits, error) {
+	return effectiveLimits(context.Background(), client, account)
+}
+
+func effectiveLimits(ctx context.Context, client *mongo.Client, account *models.Account) (models.TransactionLimits, error) {
+	accountType := account.Type
+	if _, ok := defaultLimits[accountType]; !ok {
+		accountType = models.Retail
+	}
+
+	limits := defaultLimits[accountType]
+	var profile models.LimitProfile
+	err := client.Database("walletManager").Collection("limit_profiles").FindOne(ctx, bson.M{"_id": accountType}).Decode(&profile)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return limits, err
+	}
+	if err == nil {
+		limits = profile.Limits
+	}
+
+	if overrides := account.LimitOverrides; overrides != nil {
+		if overrides.MaxPerTransaction != nil {
+			limits.MaxPerTransaction = overrides.MaxPerTransaction
+		}
+		if overrides.DailyWithdrawal != nil {
+			limits.DailyWithdrawal = overrides.DailyWithdrawal
+		}
+		if overrides.MonthlyWithdrawal != nil {
+			limits.MonthlyWithdrawal = overrides.MonthlyWithdrawal
+		}
+		if overrides.MaxCountPerHour != nil {
+			limits.MaxCountPerHour = overrides.MaxCountPerHour
+		}
+	}
+
+	return limits, nil
+}
+
+// SetLimitOverrides stores per-account limit overrides; nil fields fall back to the AccountType limits
+func SetLimitOverrides(client *mongo.Client, accountID primitive.ObjectID, overrides models.TransactionLimits) error {
+	result, err := client.Database("walletManager").Collection("accounts").UpdateOne(
+		context.Background(),
+		bson.M{"_id": accountID},
+		bson.M{"$set": bson.M{"limit_overrides": overrides, "date_modified": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+// Helper function to check a customer-initiated ledger entry against the limits of the account that owns it
+// and add it to the account's usage. It must run in the MongoDB transaction that writes the entry: concurrent
+// entries of one account all write its usage document, so all but one are retried and see the updated usage.
+func applyTransactionLimits(ctx mongo.SessionContext, client *mongo.Client, transaction models.Transaction) error {
+	if !containsTransactionType(customerInitiatedTypes, transaction.Type) {
+		return nil
+	}
+
+	accountID := transaction.AccountID
+	if accountID.IsZero() {
+		var virtualWallet models.VirtualWallet
+		err := client.Database("walletManager").Collection("virtual_wallets").FindOne(ctx,
+			bson.M{"_id": transaction.WalletID},
+			options.FindOne().SetProjection(bson.M{"customer_id": 1}),
+		).Decode(&virtualWallet)
+		if err != nil {
+			return err
+		}
+		accountID, err = primitive.ObjectIDFromHex(virtualWallet.CustomerID)
+		if err != nil {
+			// Legacy wallets without an owning account have no limits to apply
+			return nil
+		}
+	}
+
+	var account models.Account
+	err := client.Database("walletManager").Collection("accounts").FindOne(ctx, bson.M{"_id": accountID}).Decode(&account)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrAccountNotFound
+		}
+		return err
+	}
+	limits, err := effectiveLimits(ctx, client, &account)
+	if err != nil {
+		return err
+	}
+
+	usage := models.LimitUsage{AccountID: accountID}
+	err = limitUsageCollection(client).FindOne(ctx, bson.M{"_id": accountID}).Decode(&usage)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	usage, err = checkTransactionLimits(limits, usage, time.Now(), transaction.Type, transaction.Amount)
+	if err != nil {
+		return err
+	}
+	_, err = limitUsageCollection(client).ReplaceOne(ctx, bson.M{"_id": accountID}, usage, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Helper function to check one transaction against the per-transaction maximum, the hourly transaction count
+// and the daily and monthly withdrawal totals, and return the usage including the transaction.
+// Usage is counted in fixed UTC hours, days and months. Amounts in another currency than a money limit that
+// applies to them cannot be compared with it, so they are refused as if the limit had no headroom in their currency.
+func checkTransactionLimits(limits models.TransactionLimits, usage models.LimitUsage, now time.Time, transactionType models.TransactionType, amount models.Money) (models.LimitUsage, error) {
+	now = now.UTC()
+	hourStart := now.Truncate(time.Hour)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	if limits.MaxPerTransaction != nil {
+		if limits.MaxPerTransaction.CurrencyCode() != amount.CurrencyCode() || amount.Units > limits.MaxPerTransaction.Units {
+			return usage, amountViolation("max_per_transaction", *limits.MaxPerTransaction, models.NewMoney(0, amount.CurrencyCode()), amount)
+		}
+	}
+
+	if !usage.HourStart.Equal(hourStart) {
+		usage.HourStart = hourStart
+		usage.HourCount = 0
+	}
+	if limits.MaxCountPerHour != nil && usage.HourCount+1 > *limits.MaxCountPerHour {
+		return usage, &LimitExceededError{Violation: models.LimitViolation{
+			Limit:     "max_count_per_hour",
+			Maximum:   strconv.FormatInt(*limits.MaxCountPerHour, 10),
+			Used:      strconv.FormatInt(usage.HourCount, 10),
+			Requested: "1",
+			Headroom:  strconv.FormatInt(max64(*limits.MaxCountPerHour-usage.HourCount, 0), 10),
+		}}
+	}
+	usage.HourCount++
+
+	windows := []struct {
+		name  string
+		limit *models.Money
+		start time.Time
+		since *time.Time
+		used  *models.Money
+	}{
+		{name: "daily_withdrawal", limit: limits.DailyWithdrawal, start: dayStart, since: &usage.DayStart, used: &usage.DayWithdrawn},
+		{name: "monthly_withdrawal", limit: limits.MonthlyWithdrawal, start: monthStart, since: &usage.MonthStart, used: &usage.MonthWithdrawn},
+	}
+	for _, window := range windows {
+		if window.limit == nil {
+			continue
+		}
+		currency := window.limit.CurrencyCode()
+		if !window.since.Equal(window.start) || window.used.CurrencyCode() != currency {
+			// A new window, or a limit that changed currency, starts from zero
+			*window.since = window.start
+			*window.used = models.NewMoney(0, currency)
+		}
+		if !containsTransactionType(withdrawalTypes, transactionType) {
+			continue
+		}
+		if amount.CurrencyCode() != currency {
+			return usage, amountViolation(window.name, *window.limit, models.NewMoney(0, amount.CurrencyCode()), amount)
+		}
+		total, err := window.used.Add(amount)
+		if err != nil {
+			return usage, err
+		}
+		if total.Units > window.limit.Units {
+			return usage, amountViolation(window.name, *window.limit, *window.used, amount)
+		}
+		*window.used = total
+	}
+
+	return usage, nil
+}
+
+func containsTransactionType(types []models.TransactionType, transactionType models.TransactionType) bool {
+	for _, t := range types {
+		if t == transactionType {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to build a LimitExceededError for a money limit. A limit in another currency than
+// the requested amount leaves no headroom.
+func amountViolation(name string, maximum, used, requested models.Money) error {
+	headroom, err := maximum.Sub(used)
+	if err != nil || headroom.IsNegative() {
+		headroom = models.NewMoney(0, requested.CurrencyCode())
+	}
+	return &LimitExceededError{Violation: models.LimitViolation{
+		Limit:     name,
+		Maximum:   maximum.String(),
+		Used:      used.String(),
+		Requested: requested.String(),
+		Headroom:  headroom.String(),
+	}}
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckTransactionLimits(t *testing.T) {
+	limits := models.TransactionLimits{
+		MaxPerTransaction: limitAmount("100.00"),
+		DailyWithdrawal:   limitAmount("150.00"),
+		MonthlyWithdrawal: limitAmount("1000.00"),
+		MaxCountPerHour:   limitCount(3),
+	}
+	now := time.Date(2024, 5, 31, 23, 30, 0, 0, time.UTC)
+	usage := models.LimitUsage{}
+
+	usage, err := checkTransactionLimits(limits, usage, now, models.Debit, models.MustParseMoney("100.00"))
+	if err != nil {
+		t.Fatalf("first debit: %v", err)
+	}
+	// Deposits are counted per hour but not towards the withdrawal totals
+	usage, err = checkTransactionLimits(limits, usage, now, models.Deposit, models.MustParseMoney("100.00"))
+	if err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	if usage.HourCount != 2 || usage.DayWithdrawn != models.MustParseMoney("100.00") {
+		t.Fatalf("usage = %+v, want 2 transactions and 100.00 withdrawn", usage)
+	}
+
+	_, err = checkTransactionLimits(limits, usage, now, models.Debit, models.MustParseMoney("100.01"))
+	if limitErr, ok := err.(*LimitExceededError); !ok || limitErr.Violation.Limit != "max_per_transaction" {
+		t.Errorf("oversized debit returned %v, want the max_per_transaction limit", err)
+	}
+	_, err = checkTransactionLimits(limits, usage, now, models.TransferOut, models.MustParseMoney("60.00"))
+	if limitErr, ok := err.(*LimitExceededError); !ok || limitErr.Violation.Limit != "daily_withdrawal" || limitErr.Violation.Headroom != "50.00 INR" {
+		t.Errorf("transfer over the daily total returned %v, want the daily_withdrawal limit with 50.00 INR headroom", err)
+	}
+
+	usage, err = checkTransactionLimits(limits, usage, now, models.Hold, models.MustParseMoney("50.00"))
+	if err != nil {
+		t.Fatalf("hold within the daily total: %v", err)
+	}
+	_, err = checkTransactionLimits(limits, usage, now, models.Credit, models.MustParseMoney("1.00"))
+	if limitErr, ok := err.(*LimitExceededError); !ok || limitErr.Violation.Limit != "max_count_per_hour" {
+		t.Errorf("fourth transaction in an hour returned %v, want the max_count_per_hour limit", err)
+	}
+
+	// The hour, the day and the month start again at midnight UTC
+	usage, err = checkTransactionLimits(limits, usage, now.Add(time.Hour), models.Debit, models.MustParseMoney("100.00"))
+	if err != nil {
+		t.Fatalf("debit on the next day: %v", err)
+	}
+	if usage.HourCount != 1 || usage.DayWithdrawn != models.MustParseMoney("100.00") || usage.MonthWithdrawn != models.MustParseMoney("100.00") {
+		t.Errorf("usage after midnight = %+v, want only the new debit", usage)
+	}
+}
+
+func TestCheckTransactionLimitsCurrency(t *testing.T) {
+	// An INR limit cannot be compared with a USD amount, so the amount is refused
+	tests := []struct {
+		name   string
+		limits models.TransactionLimits
+		limit  string
+	}{
+		{name: "per transaction", limits: models.TransactionLimits{MaxPerTransaction: limitAmount("100.00")}, limit: "max_per_transaction"},
+		{name: "daily", limits: models.TransactionLimits{DailyWithdrawal: limitAmount("100.00")}, limit: "daily_withdrawal"},
+		{name: "monthly", limits: models.TransactionLimits{MonthlyWithdrawal: limitAmount("100.00")}, limit: "monthly_withdrawal"},
+	}
+	for _, test := range tests {
+		_, err := checkTransactionLimits(test.limits, models.LimitUsage{}, time.Now(), models.Debit, models.NewMoney(500, "USD"))
+		limitErr, ok := err.(*LimitExceededError)
+		if !ok || limitErr.Violation.Limit != test.limit || limitErr.Violation.Headroom != "0.00 USD" {
+			t.Errorf("%s: USD debit against an INR limit returned %v, want a %s violation without headroom", test.name, err, test.limit)
+		}
+	}
+
+	// Withdrawal limits do not apply to credits, whatever their currency
+	if _, err := checkTransactionLimits(tests[1].limits, models.LimitUsage{}, time.Now(), models.Credit, models.NewMoney(500, "USD")); err != nil {
+		t.Errorf("USD credit against an INR withdrawal limit: %v", err)
+	}
+
+	// Limits in the amount's currency apply as usual
+	usdLimits := models.TransactionLimits{DailyWithdrawal: &models.Money{Units: 10000, Currency: "USD"}}
+	usage, err := checkTransactionLimits(usdLimits, models.LimitUsage{}, time.Now(), models.Debit, models.NewMoney(500, "USD"))
+	if err != nil {
+		t.Fatalf("USD debit against a USD limit: %v", err)
+	}
+	if usage.DayWithdrawn != models.NewMoney(500, "USD") {
+		t.Errorf("daily usage = %v, want 5.00 USD", usage.DayWithdrawn)
+	}
+}
+
+func TestForeignCurrencyDebitRefusedByLimits(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	walletID, err := CreateVirtualWallet(client, models.VirtualWallet{
+		CustomerID:   accountID.Hex(),
+		WalletType:   models.CashWallet,
+		Balance:      models.NewMoney(100000, "USD"),
+		DateCreated:  time.Now(),
+		DateModified: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("CreateVirtualWallet: %v", err)
+	}
+
+	// The account only has the default INR limits
+	err = CreateVirtualWalletTransaction(client, walletID, "", models.Debit, models.NewMoney(1000, "USD"))
+	if _, ok := err.(*LimitExceededError); !ok {
+		t.Errorf("USD debit on an INR-limited account returned %v, want a LimitExceededError", err)
+	}
+
+	// USD limits let it through
+	usdLimit := models.NewMoney(1000000, "USD")
+	if err := SetLimitOverrides(client, accountID, models.TransactionLimits{MaxPerTransaction: &usdLimit, DailyWithdrawal: &usdLimit, MonthlyWithdrawal: &usdLimit}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateVirtualWalletTransaction(client, walletID, "", models.Debit, models.NewMoney(1000, "USD")); err != nil {
+		t.Errorf("USD debit on a USD-limited account: %v", err)
+	}
+	virtualWallet, err := FindVirtualWallet(client, walletID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if virtualWallet.Balance != models.NewMoney(99000, "USD") {
+		t.Errorf("balance = %v, want 990.00 USD after the one allowed debit", virtualWallet.Balance)
+	}
+}
+
+func TestParallelDebitsRespectDailyLimit(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	walletID := createWallet(t, client, accountID, models.CashWallet, "1000.00")
+	if err := SetLimitOverrides(client, accountID, models.TransactionLimits{DailyWithdrawal: limitAmount("100.00")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The daily limit covers exactly three debits of 30.00
+	const requests = 10
+	const expectedDebits = 3
+	start := make(chan struct{})
+	errs := make(chan error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs <- CreateVirtualWalletTransaction(client, walletID, "", models.Debit, models.MustParseMoney("30.00"))
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	succeeded, rejected := 0, 0
+	for err := range errs {
+		switch err.(type) {
+		case nil:
+			succeeded++
+		case *LimitExceededError:
+			rejected++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != expectedDebits || rejected != requests-expectedDebits {
+		t.Errorf("%d debits succeeded and %d were rejected, want %d and %d", succeeded, rejected, expectedDebits, requests-expectedDebits)
+	}
+	virtualWallet, err := FindVirtualWallet(client, walletID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if virtualWallet.Balance != models.MustParseMoney("910.00") {
+		t.Errorf("balance = %v, want 910.00", virtualWallet.Balance)
+	}
+}
+
+func TestTransfersCountTowardsLimits(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	sourceID := createWallet(t, client, accountID, models.CashWallet, "500.00")
+	destinationID := createWallet(t, client, accountID, models.CashWallet, "0.00")
+	if err := SetLimitOverrides(client, accountID, models.TransactionLimits{DailyWithdrawal: limitAmount("100.00")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Transfer(client, sourceID, destinationID, models.MustParseMoney("80.00"), "rent"); err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	_, err := Transfer(client, sourceID, destinationID, models.MustParseMoney("30.00"), "rent")
+	if limitErr, ok := err.(*LimitExceededError); !ok || limitErr.Violation.Limit != "daily_withdrawal" {
+		t.Errorf("transfer over the daily total returned %v, want the daily_withdrawal limit", err)
+	}
+	// System transfers are not started by the customer
+	if _, err := SystemTransfer(client, sourceID, destinationID, models.MustParseMoney("30.00"), "settlement"); err != nil {
+		t.Errorf("SystemTransfer: %v", err)
+	}
+}
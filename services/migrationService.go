@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"mfus_WalletTransactionManager/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Helper function to build an aggregation expression that converts a legacy float64 amount into int64 minor units
+func minorUnitsExpression(field string) bson.M {
+	scale := math.Pow10(models.CurrencyExponent(models.DefaultCurrency))
+	return bson.M{"$cond": bson.A{
+		bson.M{"$in": bson.A{bson.M{"$type": field}, bson.A{"double", "decimal"}}},
+		bson.M{"$toLong": bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{field, scale}}, 0}}},
+		bson.M{"$toLong": field},
+	}}
+}
+
+// Helper function to build an aggregation expression that turns a legacy numeric amount into a
+// {units, currency} document. Legacy amounts carry no currency and are always DefaultCurrency.
+// Missing fields and amounts that are already documents are left as they are.
+func moneyDocumentExpression(field string) bson.M {
+	return bson.M{"$cond": bson.A{
+		bson.M{"$isNumber": field},
+		bson.M{"units": minorUnitsExpression(field), "currency": models.DefaultCurrency},
+		field,
+	}}
+}
+
+// Money fields of each collection, and arrays of embedded documents with their own money fields
+var moneyFields = []struct {
+	collection string
+	fields     []string
+	arrays     map[string][]string
+}{
+	{collection: "accounts", fields: []string{"balance", "hold_balance", "limit_overrides.max_per_transaction", "limit_overrides.daily_withdrawal", "limit_overrides.monthly_withdrawal"}, arrays: map[string][]string{"transactions": {"amount"}}},
+	{collection: "virtual_wallets", fields: []string{"balance", "hold_balance", "credit_limit"}, arrays: map[string][]string{"transactions": {"amount"}}},
+	{collection: "transactions", fields: []string{"amount", "refunded_amount"}},
+	{collection: "holds", fields: []string{"amount", "captured_amount"}},
+	{collection: "balance_snapshots", fields: []string{"balance", "hold_balance"}},
+	{collection: "fee_schedules", fields: []string{"flat", "min", "max"}, arrays: map[string][]string{"tiers": {"up_to", "flat"}}},
+	{collection: "limit_profiles", fields: []string{"limits.max_per_transaction", "limits.daily_withdrawal", "limits.monthly_withdrawal"}},
+}
+
+// MigrateMoneyFields rewrites legacy numeric amounts (float64 decimal amounts and int64 minor units)
+// into {units, currency} documents in every collection that stores Money.
+// Documents that were already migrated are left untouched, so the migration can be re-run safely.
+func MigrateMoneyFields(client *mongo.Client) (int64, error) {
+	var modified int64
+	for _, target := range moneyFields {
+		conditions := bson.A{}
+		set := bson.M{}
+		for _, field := range target.fields {
+			conditions = append(conditions, bson.M{field: bson.M{"$type": "number"}})
+			set[field] = moneyDocumentExpression("$" + field)
+		}
+		for array, fields := range target.arrays {
+			converted := bson.M{}
+			for _, field := range fields {
+				conditions = append(conditions, bson.M{array + "." + field: bson.M{"$type": "number"}})
+				converted[field] = moneyDocumentExpression("$$item." + field)
+			}
+			set[array] = bson.M{"$cond": bson.A{
+				bson.M{"$isArray": "$" + array},
+				bson.M{"$map": bson.M{
+					"input": "$" + array,
+					"as":    "item",
+					"in":    bson.M{"$mergeObjects": bson.A{"$$item", converted}},
+				}},
+				"$" + array,
+			}}
+		}
+
+		result, err := client.Database("walletManager").Collection(target.collection).UpdateMany(
+			context.Background(),
+			bson.M{"$or": conditions},
+			bson.A{bson.M{"$set": set}},
+		)
+		if err != nil {
+			return modified, err
+		}
+		modified += result.ModifiedCount
+	}
+
+	return modified, nil
+}
+
+// MigrateEmbeddedTransactions unwinds the transactions arrays embedded in virtual_wallets and accounts
+// into the transactions ledger collection and removes the arrays afterwards. Entries are upserted by ID,
+// so an interrupted run can simply be started again.
+func MigrateEmbeddedTransactions(client *mongo.Client) (int64, error) {
+	owners := []struct {
+		collection string
+		ownerField string
+	}{
+		{collection: "virtual_wallets", ownerField: "wallet_id"},
+		{collection: "accounts", ownerField: "account_id"},
+	}
+
+	var moved int64
+	for _, owner := range owners {
+		collection := client.Database("walletManager").Collection(owner.collection)
+		cursor, err := collection.Find(
+			context.Background(),
+			bson.M{"transactions": bson.M{"$exists": true}},
+			options.Find().SetProjection(bson.M{"transactions": 1}),
+		)
+		if err != nil {
+			return moved, err
+		}
+
+		for cursor.Next(context.Background()) {
+			var document struct {
+				ID           primitive.ObjectID `bson:"_id"`
+				Transactions []bson.M           `bson:"transactions"`
+			}
+			if err := cursor.Decode(&document); err != nil {
+				cursor.Close(context.Background())
+				return moved, err
+			}
+
+			for index, transaction := range document.Transactions {
+				id, ok := transaction["_id"].(primitive.ObjectID)
+				if !ok || id.IsZero() {
+					// Legacy entries were written without an ID
+					id = legacyTransactionID(document.ID, index, transaction)
+				}
+				transaction["_id"] = id
+				transaction[owner.ownerField] = document.ID
+
+				_, err := transactionsCollection(client).ReplaceOne(
+					context.Background(),
+					bson.M{"_id": id},
+					transaction,
+					options.Replace().SetUpsert(true),
+				)
+				if err != nil {
+					cursor.Close(context.Background())
+					return moved, err
+				}
+				moved++
+			}
+
+			_, err := collection.UpdateOne(context.Background(), bson.M{"_id": document.ID}, bson.M{"$unset": bson.M{"transactions": ""}})
+			if err != nil {
+				cursor.Close(context.Background())
+				return moved, err
+			}
+		}
+		err = cursor.Err()
+		cursor.Close(context.Background())
+		if err != nil {
+			return moved, err
+		}
+	}
+
+	return moved, nil
+}
+
+// Helper function to build a deterministic ObjectID for a legacy embedded transaction that has none.
+// The ID combines the entry timestamp with a hash of its owner and array position, so re-running the
+// migration upserts the same ledger entry instead of duplicating it.
+func legacyTransactionID(ownerID primitive.ObjectID, index int, transaction bson.M) primitive.ObjectID {
+	var id primitive.ObjectID
+	if createdAt, ok := transaction["created_at"].(primitive.DateTime); ok {
+		binary.BigEndian.PutUint32(id[0:4], uint32(createdAt.Time().Unix()))
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", ownerID.Hex(), index)))
+	copy(id[4:], sum[:8])
+	return id
+}
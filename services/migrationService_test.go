@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"mfus_WalletTransactionManager/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLegacyTransactionIDIsDeterministic(t *testing.T) {
+	ownerID := primitive.NewObjectID()
+	createdAt := primitive.NewDateTimeFromTime(time.Date(2023, 4, 1, 10, 0, 0, 0, time.UTC))
+	entry := bson.M{"type": "credit", "created_at": createdAt}
+
+	first := legacyTransactionID(ownerID, 3, entry)
+	if second := legacyTransactionID(ownerID, 3, entry); first != second {
+		t.Errorf("IDs differ between runs: %s and %s", first.Hex(), second.Hex())
+	}
+	if other := legacyTransactionID(ownerID, 4, entry); other == first {
+		t.Error("Entries at different positions share an ID")
+	}
+	if !first.Timestamp().Equal(createdAt.Time()) {
+		t.Errorf("ID timestamp is %v, want %v", first.Timestamp(), createdAt.Time())
+	}
+}
+
+func TestMigrateEmbeddedTransactions(t *testing.T) {
+	client := testMongoClient(t)
+	ctx := context.Background()
+	wallets := client.Database("walletManager").Collection("virtual_wallets")
+
+	walletID := primitive.NewObjectID()
+	existingID := primitive.NewObjectID()
+	createdAt := primitive.NewDateTimeFromTime(time.Now().Add(-time.Hour))
+	_, err := wallets.InsertOne(ctx, bson.M{
+		"_id":          walletID,
+		"customer_id":  primitive.NewObjectID().Hex(),
+		"balance":      12.5,
+		"hold_balance": 0.0,
+		"transactions": bson.A{
+			bson.M{"_id": existingID, "type": "credit", "amount": 20.0, "created_at": createdAt},
+			bson.M{"type": "debit", "amount": 7.5, "created_at": createdAt},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateMoneyFields(client); err != nil {
+		t.Fatalf("Money migration failed: %v", err)
+	}
+	for run := 0; run < 2; run++ {
+		if _, err := MigrateEmbeddedTransactions(client); err != nil {
+			t.Fatalf("Run %d failed: %v", run, err)
+		}
+	}
+
+	ledger, err := ListWalletTransactions(client, walletID, models.TransactionQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.Transactions) != 2 {
+		t.Fatalf("Ledger holds %d entries, want 2", len(ledger.Transactions))
+	}
+	amounts := map[models.TransactionType]models.Money{}
+	for _, transaction := range ledger.Transactions {
+		amounts[transaction.Type] = transaction.Amount
+	}
+	if amounts[models.Credit] != models.MustParseMoney("20.00") || amounts[models.Debit] != models.MustParseMoney("7.50") {
+		t.Errorf("Migrated amounts are %v", amounts)
+	}
+	if count, _ := transactionsCollection(client).CountDocuments(ctx, bson.M{"_id": existingID}); count != 1 {
+		t.Error("Entry with an existing ID was not kept under that ID")
+	}
+
+	virtualWallet, err := FindVirtualWallet(client, walletID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if virtualWallet.Balance != models.MustParseMoney("12.50") {
+		t.Errorf("Balance is %v, want 12.50 INR", virtualWallet.Balance)
+	}
+	if count, _ := wallets.CountDocuments(ctx, bson.M{"transactions": bson.M{"$exists": true}}); count != 0 {
+		t.Error("Embedded transactions array was not removed")
+	}
+}
+
+func TestMigrateMoneyFieldsIsIdempotent(t *testing.T) {
+	client := testMongoClient(t)
+	ctx := context.Background()
+	accountID := primitive.NewObjectID()
+	_, err := client.Database("walletManager").Collection("accounts").InsertOne(ctx, bson.M{
+		"_id":          accountID,
+		"balance":      int64(1050),
+		"hold_balance": 2.25,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 0; run < 2; run++ {
+		if _, err := MigrateMoneyFields(client); err != nil {
+			t.Fatalf("Run %d failed: %v", run, err)
+		}
+	}
+
+	var stored bson.Raw
+	if err := client.Database("walletManager").Collection("accounts").FindOne(ctx, bson.M{"_id": accountID}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if units := stored.Lookup("balance", "units").AsInt64(); units != 1050 {
+		t.Errorf("balance.units = %d, want 1050", units)
+	}
+	if units := stored.Lookup("hold_balance", "units").AsInt64(); units != 225 {
+		t.Errorf("hold_balance.units = %d, want 225", units)
+	}
+	if currency := stored.Lookup("balance", "currency").StringValue(); currency != models.DefaultCurrency {
+		t.Errorf("balance.currency = %q, want %q", currency, models.DefaultCurrency)
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Helper function to connect to the MongoDB named by MONGODB_TEST_URI, skipping the test when it is not set.
+// The server must be a disposable replica set: the walletManager database is dropped before and after each test.
+func testMongoClient(t *testing.T) *mongo.Client {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	if err := client.Database("walletManager").Drop(context.Background()); err != nil {
+		t.Fatalf("Failed to drop the test database: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Database("walletManager").Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return client
+}
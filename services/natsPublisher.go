@@ -0,0 +1,319 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mfus_WalletTransactionManager/models"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Used when the context passed to Publish has no deadline
+const natsTimeout = 5 * time.Second
+
+// NATSEventPublisher publishes events to a NATS server on "<subject prefix>.<event type>". It speaks the NATS
+// client protocol directly. Every publish waits for the server to confirm it: with JetStream the stream's
+// acknowledgement, otherwise a PING round trip. The Nats-Msg-Id header carries the event ID so JetStream can
+// drop events the relay sends twice. A broken connection is redialled on the next publish.
+type NATSEventPublisher struct {
+	url           string
+	subjectPrefix string
+	jetStream     bool
+
+	mu      sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+	headers bool
+	inbox   string
+	replies int64
+}
+
+// natsServerInfo is the part of the server's INFO message the publisher uses
+type natsServerInfo struct {
+	Headers     bool `json:"headers"`
+	TLSRequired bool `json:"tls_required"`
+}
+
+func NewNATSEventPublisher(serverURL string, subjectPrefix string, jetStream bool) *NATSEventPublisher {
+	return &NATSEventPublisher{url: serverURL, subjectPrefix: subjectPrefix, jetStream: jetStream}
+}
+
+func (p *NATSEventPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(natsTimeout)
+	}
+	if p.conn == nil {
+		if err := p.connect(deadline); err != nil {
+			return err
+		}
+	}
+	if err := p.publish(deadline, event); err != nil {
+		p.closeConn()
+		return err
+	}
+	return nil
+}
+
+func (p *NATSEventPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeConn()
+	return nil
+}
+
+func (p *NATSEventPublisher) closeConn() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+		p.reader = nil
+	}
+}
+
+// Helper function to dial the server and complete the CONNECT handshake
+func (p *NATSEventPublisher) connect(deadline time.Time) error {
+	server, err := url.Parse(p.url)
+	if err != nil {
+		return err
+	}
+	host := server.Host
+	if server.Port() == "" {
+		host = net.JoinHostPort(server.Hostname(), "4222")
+	}
+	conn, err := net.DialTimeout("tcp", host, time.Until(deadline))
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(deadline)
+	p.conn = conn
+	p.reader = bufio.NewReader(conn)
+
+	// The server starts with INFO before any TLS upgrade
+	line, err := p.readLine()
+	if err != nil {
+		p.closeConn()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		p.closeConn()
+		return fmt.Errorf("NATS: unexpected greeting %q", line)
+	}
+	var info natsServerInfo
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		p.closeConn()
+		return err
+	}
+	if info.TLSRequired || server.Scheme == "tls" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: server.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			p.closeConn()
+			return err
+		}
+		p.conn = tlsConn
+		p.reader = bufio.NewReader(tlsConn)
+	}
+	p.headers = info.Headers
+
+	options := map[string]interface{}{
+		"verbose":       false,
+		"pedantic":      false,
+		"lang":          "go",
+		"version":       "walletManager",
+		"name":          "walletManager-outbox",
+		"protocol":      1,
+		"headers":       info.Headers,
+		"no_responders": info.Headers,
+	}
+	if server.User != nil {
+		if password, ok := server.User.Password(); ok {
+			options["user"] = server.User.Username()
+			options["pass"] = password
+		} else {
+			options["auth_token"] = server.User.Username()
+		}
+	}
+	connectOptions, err := json.Marshal(options)
+	if err != nil {
+		p.closeConn()
+		return err
+	}
+	if _, err := fmt.Fprintf(p.conn, "CONNECT %s\r\nPING\r\n", connectOptions); err != nil {
+		p.closeConn()
+		return err
+	}
+	if err := p.waitForPong(); err != nil {
+		p.closeConn()
+		return err
+	}
+
+	// JetStream acknowledgements arrive on a private inbox
+	if p.jetStream {
+		token := make([]byte, 12)
+		if _, err := rand.Read(token); err != nil {
+			p.closeConn()
+			return err
+		}
+		p.inbox = "_INBOX." + hex.EncodeToString(token)
+		if _, err := fmt.Fprintf(p.conn, "SUB %s.* 1\r\n", p.inbox); err != nil {
+			p.closeConn()
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to send one event and wait for the server to confirm it
+func (p *NATSEventPublisher) publish(deadline time.Time, event models.OutboxEvent) error {
+	p.conn.SetDeadline(deadline)
+	subject := p.subjectPrefix + "." + string(event.Type)
+	reply := ""
+	if p.jetStream {
+		p.replies++
+		reply = p.inbox + "." + strconv.FormatInt(p.replies, 10)
+	}
+
+	var message bytes.Buffer
+	if p.headers {
+		header := "NATS/1.0\r\n" +
+			"Nats-Msg-Id: " + event.ID.Hex() + "\r\n" +
+			"Wallet-Event-Key: " + event.Key + "\r\n" +
+			"Wallet-Event-Sequence: " + strconv.FormatInt(event.Sequence, 10) + "\r\n\r\n"
+		fmt.Fprintf(&message, "HPUB %s %s%d %d\r\n%s", subject, replyArgument(reply), len(header), len(header)+len(event.Payload), header)
+	} else {
+		fmt.Fprintf(&message, "PUB %s %s%d\r\n", subject, replyArgument(reply), len(event.Payload))
+	}
+	message.Write(event.Payload)
+	message.WriteString("\r\n")
+	if !p.jetStream {
+		message.WriteString("PING\r\n")
+	}
+	if _, err := p.conn.Write(message.Bytes()); err != nil {
+		return err
+	}
+
+	if !p.jetStream {
+		return p.waitForPong()
+	}
+	return p.waitForAck(reply)
+}
+
+func replyArgument(reply string) string {
+	if reply == "" {
+		return ""
+	}
+	return reply + " "
+}
+
+func (p *NATSEventPublisher) readLine() (string, error) {
+	line, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Helper function to read server messages until the PONG of our PING
+func (p *NATSEventPublisher) waitForPong() error {
+	for {
+		op, _, _, err := p.readMessage()
+		if err != nil {
+			return err
+		}
+		if op == "PONG" {
+			return nil
+		}
+	}
+}
+
+// Helper function to read server messages until the JetStream acknowledgement on reply
+func (p *NATSEventPublisher) waitForAck(reply string) error {
+	for {
+		op, subject, message, err := p.readMessage()
+		if err != nil {
+			return err
+		}
+		if (op != "MSG" && op != "HMSG") || subject != reply {
+			continue
+		}
+		if op == "HMSG" {
+			// Status-only replies, e.g. 503 when no stream listens on the subject
+			headerEnd := bytes.Index(message, []byte("\r\n\r\n"))
+			if headerEnd < 0 {
+				return errors.New("NATS: malformed acknowledgement headers")
+			}
+			statusLine := message[:bytes.IndexByte(message, '\r')]
+			if status := strings.TrimSpace(strings.TrimPrefix(string(statusLine), "NATS/1.0")); status != "" {
+				return fmt.Errorf("NATS: publish rejected with status %s", status)
+			}
+			message = message[headerEnd+4:]
+		}
+		var ack struct {
+			Stream string `json:"stream"`
+			Error  *struct {
+				Code        int    `json:"code"`
+				Description string `json:"description"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(message, &ack); err != nil {
+			return err
+		}
+		if ack.Error != nil {
+			return fmt.Errorf("NATS: JetStream error %d: %s", ack.Error.Code, ack.Error.Description)
+		}
+		if ack.Stream == "" {
+			return errors.New("NATS: invalid JetStream acknowledgement")
+		}
+		return nil
+	}
+}
+
+// Helper function to read one protocol message. Server PINGs are answered; -ERR is returned as an error.
+// For MSG and HMSG the subject and the full body (headers included) are returned.
+func (p *NATSEventPublisher) readMessage() (string, string, []byte, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return "", "", nil, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", "", nil, nil
+	}
+	op := strings.ToUpper(fields[0])
+	switch op {
+	case "PING":
+		_, err := io.WriteString(p.conn, "PONG\r\n")
+		return op, "", nil, err
+	case "-ERR":
+		return op, "", nil, fmt.Errorf("NATS: %s", strings.TrimSpace(strings.TrimPrefix(line, fields[0])))
+	case "MSG", "HMSG":
+		// MSG <subject> <sid> [reply] <size>; HMSG <subject> <sid> [reply] <header size> <total size>
+		if len(fields) < 4 {
+			return "", "", nil, fmt.Errorf("NATS: malformed %s", op)
+		}
+		size, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			return "", "", nil, err
+		}
+		body := make([]byte, size+2)
+		if _, err := io.ReadFull(p.reader, body); err != nil {
+			return "", "", nil, err
+		}
+		return op, fields[1], body[:size], nil
+	}
+	return op, "", nil, nil
+}
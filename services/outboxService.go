@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"mfus_WalletTransactionManager/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// Failed publish attempts after which an event becomes a dead letter
+	MaxOutboxAttempts = 20
+	// Number of outbox events read per relay round
+	outboxBatchSize = 500
+	// How long one relay owns the outbox; it is renewed every round
+	outboxLease = 30 * time.Second
+	// Published events are kept this long for troubleshooting
+	outboxRetention = 7 * 24 * time.Hour
+	maxOutboxError  = 500
+)
+
+// Wakes the relay when a MongoDB transaction commits so new events do not wait for the next poll
+var outboxWakeup = make(chan struct{}, 1)
+
+func outboxCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("outbox")
+}
+
+// The per-key sequence counters. sequence is the last sequence handed out and published the last one published.
+func outboxSequencesCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("outbox_sequences")
+}
+
+func outboxLeasesCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("outbox_leases")
+}
+
+// EnsureOutboxIndexes creates the outbox indexes and the sequence collection, which cannot be created
+// implicitly inside a MongoDB transaction on older servers
+func EnsureOutboxIndexes(client *mongo.Client) error {
+	err := client.Database("walletManager").CreateCollection(context.Background(), "outbox_sequences")
+	if commandErr, ok := err.(mongo.CommandError); err != nil && !(ok && commandErr.Code == 48) {
+		// 48 is NamespaceExists
+		return err
+	}
+	_, err = outboxCollection(client).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "published_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "key", Value: 1}, {Key: "sequence", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{
+			Keys:    bson.D{{Key: "published_at", Value: 1}},
+			Options: options.Index().SetName("published_at_ttl").SetExpireAfterSeconds(int32(outboxRetention.Seconds())),
+		},
+	})
+	return err
+}
+
+// Helper function to write an event to the outbox. Must run inside the MongoDB transaction making the change,
+// so the event exists exactly when the change was committed. The per-key counter is updated in the same
+// transaction, so concurrent changes to one wallet or account conflict and commit with consecutive sequences.
+func recordEvent(ctx context.Context, client *mongo.Client, eventType models.EventType, key primitive.ObjectID, customerID string, data interface{}) error {
+	var counter struct {
+		Sequence int64 `bson:"sequence"`
+	}
+	err := outboxSequencesCollection(client).FindOneAndUpdate(ctx,
+		bson.M{"_id": key.Hex()},
+		bson.M{"$inc": bson.M{"sequence": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return err
+	}
+
+	event := models.Event{
+		ID:         primitive.NewObjectID(),
+		Type:       eventType,
+		CustomerID: customerID,
+		Key:        key.Hex(),
+		Sequence:   counter.Sequence,
+		CreatedAt:  time.Now(),
+		Data:       data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = outboxCollection(client).InsertOne(ctx, models.OutboxEvent{
+		ID:         event.ID,
+		Key:        event.Key,
+		Sequence:   event.Sequence,
+		Type:       eventType,
+		CustomerID: customerID,
+		Payload:    payload,
+		CreatedAt:  event.CreatedAt,
+		Status:     models.OutboxPending,
+	})
+	return err
+}
+
+// Helper function to record the event of a ledger entry. The customer of a wallet entry is looked up
+// in the same transaction; the customer of an account entry is the account itself.
+func recordTransactionEvent(ctx context.Context, client *mongo.Client, transaction models.Transaction) error {
+	key := transaction.AccountID
+	customerID := transaction.AccountID.Hex()
+	if !transaction.WalletID.IsZero() {
+		key = transaction.WalletID
+		var virtualWallet models.VirtualWallet
+		err := client.Database("walletManager").Collection("virtual_wallets").FindOne(ctx,
+			bson.M{"_id": transaction.WalletID},
+			options.FindOne().SetProjection(bson.M{"customer_id": 1}),
+		).Decode(&virtualWallet)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		customerID = virtualWallet.CustomerID
+	}
+	return recordEvent(ctx, client, transactionEventType(transaction.Type), key, customerID, transaction)
+}
+
+// Helper function to pick the event of a wallet or account ledger entry
+func transactionEventType(transactionType models.TransactionType) models.EventType {
+	switch transactionType {
+	case models.Hold:
+		return models.EventHoldCreated
+	case models.Release:
+		return models.EventHoldReleased
+	case models.Capture:
+		return models.EventHoldCaptured
+	}
+	return models.EventTransactionCreated
+}
+
+func wakeOutboxRelay() {
+	select {
+	case outboxWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// RunOutboxRelay publishes outbox events until the process exits, polling every interval and whenever a
+// transaction commits. Each event is queued for the matching webhooks and handed to the publisher; it is
+// retried until both succeed, so delivery is at-least-once. After MaxOutboxAttempts failures the event is
+// dead-lettered with an alert in the log. Events of one wallet or account are published strictly in sequence
+// order, skipping only dead letters, and a lease makes sure only one process relays at a time.
+// Meant to be started as a goroutine.
+func RunOutboxRelay(client *mongo.Client, publisher EventPublisher, interval time.Duration) {
+	owner := primitive.NewObjectID().Hex()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		acquired, err := acquireOutboxLease(client, owner)
+		if err != nil {
+			log.Printf("Outbox relay failed to acquire lease: %v", err)
+		}
+		for acquired {
+			more, err := relayOutbox(client, publisher)
+			if err != nil {
+				log.Printf("Outbox relay failed: %v", err)
+				break
+			}
+			if !more {
+				break
+			}
+			if acquired, err = acquireOutboxLease(client, owner); err != nil {
+				log.Printf("Outbox relay failed to renew lease: %v", err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-outboxWakeup:
+		}
+	}
+}
+
+// Helper function to take or renew the relay lease. Returns false while another process holds it.
+func acquireOutboxLease(client *mongo.Client, owner string) (bool, error) {
+	now := time.Now()
+	_, err := outboxLeasesCollection(client).UpdateOne(context.Background(),
+		bson.M{"_id": "relay", "$or": bson.A{
+			bson.M{"owner": owner},
+			bson.M{"expires_at": bson.M{"$lt": now}},
+		}},
+		bson.M{"$set": bson.M{"owner": owner, "expires_at": now.Add(outboxLease)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Helper function to publish one batch of unpublished events. Returns true when more may be waiting.
+func relayOutbox(client *mongo.Client, publisher EventPublisher) (bool, error) {
+	cursor, err := outboxCollection(client).Find(context.Background(),
+		bson.M{"published_at": nil, "status": bson.M{"$ne": models.OutboxDead}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(outboxBatchSize),
+	)
+	if err != nil {
+		return false, err
+	}
+	var events []models.OutboxEvent
+	if err := cursor.All(context.Background(), &events); err != nil {
+		return false, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Key != events[j].Key {
+			return events[i].Key < events[j].Key
+		}
+		return events[i].Sequence < events[j].Sequence
+	})
+
+	// The next sequence to publish per key. A key stops for this round at a failure or a gap, so no event
+	// overtakes an earlier one of the same wallet or account.
+	next := map[string]int64{}
+	blocked := map[string]bool{}
+	published := 0
+	for _, event := range events {
+		if blocked[event.Key] {
+			continue
+		}
+		expected, ok := next[event.Key]
+		if !ok {
+			var counter struct {
+				Published int64 `bson:"published"`
+			}
+			err := outboxSequencesCollection(client).FindOne(context.Background(), bson.M{"_id": event.Key}).Decode(&counter)
+			if err != nil && err != mongo.ErrNoDocuments {
+				return false, err
+			}
+			expected = counter.Published + 1
+		}
+		switch {
+		case event.Sequence < expected:
+			// Published before the relay stopped, but not marked
+			if err := markOutboxEventPublished(client, event); err != nil {
+				return false, err
+			}
+			continue
+		case event.Sequence > expected:
+			// An earlier event is not in this batch yet
+			blocked[event.Key] = true
+			continue
+		}
+
+		if err := publishOutboxEvent(client, publisher, event); err != nil {
+			message := err.Error()
+			if len(message) > maxOutboxError {
+				message = message[:maxOutboxError]
+			}
+			if event.Attempts+1 >= MaxOutboxAttempts {
+				if err := deadLetterOutboxEvent(client, event, message); err != nil {
+					return false, err
+				}
+				next[event.Key] = event.Sequence + 1
+				continue
+			}
+			blocked[event.Key] = true
+			_, err = outboxCollection(client).UpdateOne(context.Background(),
+				bson.M{"_id": event.ID},
+				bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"last_error": message}},
+			)
+			if err != nil {
+				return false, err
+			}
+			continue
+		}
+		if err := markOutboxEventPublished(client, event); err != nil {
+			return false, err
+		}
+		next[event.Key] = event.Sequence + 1
+		published++
+	}
+	return published > 0 && len(events) == outboxBatchSize, nil
+}
+
+// Helper function to queue the webhook deliveries of an event and hand it to the publisher
+func publishOutboxEvent(client *mongo.Client, publisher EventPublisher, event models.OutboxEvent) error {
+	if err := queueWebhookDeliveries(client, event); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), outboxLease/3)
+	defer cancel()
+	return publisher.Publish(ctx, event)
+}
+
+func markOutboxEventPublished(client *mongo.Client, event models.OutboxEvent) error {
+	now := time.Now()
+	_, err := outboxCollection(client).UpdateOne(context.Background(),
+		bson.M{"_id": event.ID},
+		bson.M{"$set": bson.M{"published_at": now, "status": models.OutboxPublished}, "$unset": bson.M{"last_error": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	return advanceOutboxSequence(client, event)
+}
+
+// Helper function to give up on an event after its last attempt. The key's published counter moves past it,
+// so the events after it are not held back forever; operators find dead letters by their status.
+func deadLetterOutboxEvent(client *mongo.Client, event models.OutboxEvent, message string) error {
+	_, err := outboxCollection(client).UpdateOne(context.Background(),
+		bson.M{"_id": event.ID},
+		bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"status": models.OutboxDead, "last_error": message}},
+	)
+	if err != nil {
+		return err
+	}
+	log.Printf("ALERT: outbox event %s (%s, key %s, sequence %d) was dead-lettered after %d attempts: %s",
+		event.ID.Hex(), event.Type, event.Key, event.Sequence, event.Attempts+1, message)
+	return advanceOutboxSequence(client, event)
+}
+
+// Helper function to record that every event of a key up to and including this one has been handled
+func advanceOutboxSequence(client *mongo.Client, event models.OutboxEvent) error {
+	_, err := outboxSequencesCollection(client).UpdateOne(context.Background(),
+		bson.M{"_id": event.Key, "published": bson.M{"$not": bson.M{"$gte": event.Sequence}}},
+		bson.M{"$set": bson.M{"published": event.Sequence}},
+	)
+	return err
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mfus_WalletTransactionManager/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// scriptedPublisher is a MemoryEventPublisher that can fail chosen events. With lostAck the event reaches
+// the broker but the acknowledgement does not reach the relay.
+type scriptedPublisher struct {
+	*MemoryEventPublisher
+	lostAck func(event models.OutboxEvent) bool
+	reject  func(event models.OutboxEvent) bool
+}
+
+func (p *scriptedPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	if p.reject != nil && p.reject(event) {
+		return errors.New("broker unavailable")
+	}
+	if err := p.MemoryEventPublisher.Publish(ctx, event); err != nil {
+		return err
+	}
+	if p.lostAck != nil && p.lostAck(event) {
+		return errors.New("acknowledgement lost")
+	}
+	return nil
+}
+
+// Helper function to load every outbox event
+func outboxEvents(t *testing.T, client *mongo.Client) []models.OutboxEvent {
+	t.Helper()
+	cursor, err := outboxCollection(client).Find(context.Background(), bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []models.OutboxEvent
+	if err := cursor.All(context.Background(), &events); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// Helper function returning the sequences published for one key, in publish order
+func publishedSequences(events []models.OutboxEvent, key string) []int64 {
+	var sequences []int64
+	for _, event := range events {
+		if event.Key == key {
+			sequences = append(sequences, event.Sequence)
+		}
+	}
+	return sequences
+}
+
+// Helper function to create a wallet with a few ledger entries, giving it events with sequences 1 to 4
+func createWalletWithEvents(t *testing.T, client *mongo.Client) string {
+	t.Helper()
+	accountID := createFundedAccount(t, client, "0.00")
+	walletID := createWallet(t, client, accountID, models.CashWallet, "0.00")
+	for i := 0; i < 3; i++ {
+		if err := CreateVirtualWalletTransaction(client, walletID, "", models.Deposit, models.MustParseMoney("1.00")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return walletID.Hex()
+}
+
+func TestOutboxRelayRestartKeepsOrderAndDeliversAtLeastOnce(t *testing.T) {
+	client := testMongoClient(t)
+	if err := EnsureOutboxIndexes(client); err != nil {
+		t.Fatal(err)
+	}
+	walletKey := createWalletWithEvents(t, client)
+
+	// The first relay stops after the broker took event 3 without acknowledging it
+	first := &scriptedPublisher{
+		MemoryEventPublisher: NewMemoryEventPublisher(),
+		lostAck: func(event models.OutboxEvent) bool {
+			return event.Key == walletKey && event.Sequence == 3
+		},
+	}
+	if _, err := relayOutbox(client, first); err != nil {
+		t.Fatalf("first relay: %v", err)
+	}
+	if got := publishedSequences(first.Events(), walletKey); len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Fatalf("first relay published %v for the wallet, want [1 2 3]", got)
+	}
+
+	// A new relay, as after a restart, sends event 3 again and then event 4
+	second := NewMemoryEventPublisher()
+	for {
+		more, err := relayOutbox(client, second)
+		if err != nil {
+			t.Fatalf("second relay: %v", err)
+		}
+		if !more {
+			break
+		}
+	}
+	if got := publishedSequences(second.Events(), walletKey); len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Errorf("second relay published %v for the wallet, want [3 4]", got)
+	}
+
+	// Every event reached the broker at least once and is marked published
+	seen := map[string]bool{}
+	for _, event := range append(first.Events(), second.Events()...) {
+		seen[event.ID.Hex()] = true
+	}
+	for _, event := range outboxEvents(t, client) {
+		if !seen[event.ID.Hex()] {
+			t.Errorf("event %s (%s %d) was never published", event.ID.Hex(), event.Key, event.Sequence)
+		}
+		if event.Status != models.OutboxPublished || event.PublishedAt == nil {
+			t.Errorf("event %s status = %q, want published", event.ID.Hex(), event.Status)
+		}
+	}
+}
+
+func TestOutboxRelayDeadLettersAfterMaxAttempts(t *testing.T) {
+	client := testMongoClient(t)
+	if err := EnsureOutboxIndexes(client); err != nil {
+		t.Fatal(err)
+	}
+	walletKey := createWalletWithEvents(t, client)
+
+	publisher := &scriptedPublisher{
+		MemoryEventPublisher: NewMemoryEventPublisher(),
+		reject: func(event models.OutboxEvent) bool {
+			return event.Key == walletKey && event.Sequence == 2
+		},
+	}
+	for i := 0; i < MaxOutboxAttempts-1; i++ {
+		if _, err := relayOutbox(client, publisher); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Events after the failing one wait for it
+	if got := publishedSequences(publisher.Events(), walletKey); len(got) != 1 {
+		t.Fatalf("published %v for the wallet before the last attempt, want [1]", got)
+	}
+
+	if _, err := relayOutbox(client, publisher); err != nil {
+		t.Fatal(err)
+	}
+	if got := publishedSequences(publisher.Events(), walletKey); len(got) != 3 || got[1] != 3 || got[2] != 4 {
+		t.Errorf("published %v for the wallet, want [1 3 4]", got)
+	}
+	for _, event := range outboxEvents(t, client) {
+		if event.Key != walletKey || event.Sequence != 2 {
+			continue
+		}
+		if event.Status != models.OutboxDead || event.Attempts != MaxOutboxAttempts || event.LastError != "broker unavailable" {
+			t.Errorf("failing event = status %q, %d attempts, error %q; want a dead letter after %d attempts", event.Status, event.Attempts, event.LastError, MaxOutboxAttempts)
+		}
+	}
+
+	// Dead letters are not retried
+	if _, err := relayOutbox(client, publisher); err != nil {
+		t.Fatal(err)
+	}
+	if got := publishedSequences(publisher.Events(), walletKey); len(got) != 3 {
+		t.Errorf("published %v for the wallet after another round, want [1 3 4]", got)
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"mfus_WalletTransactionManager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidReasonCode = errors.New("Invalid reason code")
+
+func reconciliationReportsCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("reconciliation_reports")
+}
+
+// Reconcile recomputes the Balance and HoldBalance of every wallet and account from its opening balance snapshot
+// and every ledger entry after it, and stores the mismatches as a report. Later snapshots are not trusted, since
+// a balance overwrite snapshots whatever it stored. Owners without a snapshot are replayed from zero. With correct set, adjustment entries carrying the reason code are posted so the
+// history matches the stored balances again; the stored balances themselves are never changed, so reason
+// codes of entries that move the balance are refused.
+func Reconcile(client *mongo.Client, correct bool, reason models.ReasonCode) (*models.ReconciliationReport, error) {
+	if correct && (!reason.IsValid() || reason.MovesBalance()) {
+		return nil, ErrInvalidReasonCode
+	}
+
+	report := &models.ReconciliationReport{
+		ID:         primitive.NewObjectID(),
+		StartedAt:  time.Now(),
+		Corrected:  correct,
+		Mismatches: []models.BalanceMismatch{},
+	}
+	if correct {
+		report.ReasonCode = reason
+	}
+
+	for _, owner := range []struct {
+		field      string
+		collection string
+	}{
+		{field: "wallet_id", collection: "virtual_wallets"},
+		{field: "account_id", collection: "accounts"},
+	} {
+		cursor, err := client.Database("walletManager").Collection(owner.collection).Find(
+			context.Background(),
+			bson.M{},
+			options.Find().SetProjection(bson.M{"_id": 1}),
+		)
+		if err != nil {
+			return nil, err
+		}
+		var owners []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.All(context.Background(), &owners); err != nil {
+			return nil, err
+		}
+
+		for _, o := range owners {
+			var mismatch *models.BalanceMismatch
+			err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+				var err error
+				mismatch, err = reconcileOwner(ctx, client, owner.collection, owner.field, o.ID, correct, reason)
+				return err
+			})
+			if err == mongo.ErrNoDocuments {
+				// Deleted while the job was running
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			report.Checked++
+			if mismatch != nil {
+				report.Mismatches = append(report.Mismatches, *mismatch)
+			}
+		}
+	}
+
+	report.CompletedAt = time.Now()
+	if _, err := reconciliationReportsCollection(client).InsertOne(context.Background(), report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Helper function to compare one wallet or account with its history. Returns nil when they agree.
+func reconcileOwner(ctx mongo.SessionContext, client *mongo.Client, collection string, ownerField string, ownerID primitive.ObjectID, correct bool, reason models.ReasonCode) (*models.BalanceMismatch, error) {
+	var stored struct {
+		Balance     models.Money `bson:"balance"`
+		HoldBalance models.Money `bson:"hold_balance"`
+	}
+	err := client.Database("walletManager").Collection(collection).FindOne(ctx, bson.M{"_id": ownerID}).Decode(&stored)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start from the opening balance, which is the earliest snapshot, or from zero before the first ledger entry
+	var base models.BalanceSnapshot
+	err = balanceSnapshotsCollection(client).FindOne(ctx,
+		bson.M{ownerField: ownerID},
+		options.FindOne().SetSort(bson.D{{Key: "taken_at", Value: 1}}),
+	).Decode(&base)
+	if err == mongo.ErrNoDocuments {
+		base.Balance = models.NewMoney(0, stored.Balance.CurrencyCode())
+		base.HoldBalance = models.NewMoney(0, stored.Balance.CurrencyCode())
+	} else if err != nil {
+		return nil, err
+	}
+	balance, hold, err := ledgerEffect(ctx, client, ownerField, ownerID, bson.M{"$gt": base.TakenAt}, true, stored.Balance.CurrencyCode())
+	if err != nil {
+		return nil, err
+	}
+	expectedBalance, err := base.Balance.Add(balance)
+	if err != nil {
+		return nil, err
+	}
+	expectedHold, err := base.HoldBalance.Add(hold)
+	if err != nil {
+		return nil, err
+	}
+
+	balanceDrift, err := stored.Balance.Sub(expectedBalance)
+	if err != nil {
+		return nil, err
+	}
+	holdDrift, err := stored.HoldBalance.Sub(expectedHold)
+	if err != nil {
+		return nil, err
+	}
+	if balanceDrift.IsZero() && holdDrift.IsZero() {
+		return nil, nil
+	}
+
+	mismatch := &models.BalanceMismatch{
+		StoredBalance:       stored.Balance,
+		ExpectedBalance:     expectedBalance,
+		BalanceDrift:        balanceDrift,
+		StoredHoldBalance:   stored.HoldBalance,
+		ExpectedHoldBalance: expectedHold,
+		HoldBalanceDrift:    holdDrift,
+	}
+	if ownerField == "wallet_id" {
+		mismatch.WalletID = ownerID
+	} else {
+		mismatch.AccountID = ownerID
+	}
+	if !correct {
+		return mismatch, nil
+	}
+
+	now := time.Now()
+	adjustments := []struct {
+		drift         models.Money
+		credit, debit models.TransactionType
+	}{
+		{drift: balanceDrift, credit: models.AdjustmentCredit, debit: models.AdjustmentDebit},
+		{drift: holdDrift, credit: models.HoldAdjustmentCredit, debit: models.HoldAdjustmentDebit},
+	}
+	for _, adjustment := range adjustments {
+		if adjustment.drift.IsZero() {
+			continue
+		}
+		entry := models.Transaction{
+			ID:         primitive.NewObjectID(),
+			Type:       adjustment.credit,
+			Amount:     adjustment.drift,
+			ReasonCode: reason,
+			CreatedAt:  now,
+		}
+		if adjustment.drift.IsNegative() {
+			entry.Type = adjustment.debit
+			entry.Amount = adjustment.drift.Neg()
+		}
+		if ownerField == "wallet_id" {
+			entry.WalletID = ownerID
+		} else {
+			entry.AccountID = ownerID
+		}
+		if err := insertTransaction(ctx, client, entry); err != nil {
+			return nil, err
+		}
+		mismatch.AdjustmentIDs = append(mismatch.AdjustmentIDs, entry.ID)
+	}
+	return mismatch, nil
+}
+
+// ListReconciliationReports returns the most recent reconciliation reports, newest first
+func ListReconciliationReports(client *mongo.Client, limit int64) ([]models.ReconciliationReport, error) {
+	cursor, err := reconciliationReportsCollection(client).Find(
+		context.Background(),
+		bson.M{},
+		options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	reports := []models.ReconciliationReport{}
+	err = cursor.All(context.Background(), &reports)
+	return reports, err
+}
+
+// RunReconciler periodically reconciles balances in report-only mode until the process exits.
+// Meant to be started as a goroutine.
+func RunReconciler(client *mongo.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		report, err := Reconcile(client, false, "")
+		if err != nil {
+			log.Printf("Reconciliation failed: %v", err)
+			continue
+		}
+		if len(report.Mismatches) > 0 {
+			log.Printf("Reconciliation found %d of %d balances drifting from the ledger", len(report.Mismatches), report.Checked)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"mfus_WalletTransactionManager/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestReconcileReportsBalanceOverwrites(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	walletID := createWallet(t, client, accountID, models.CashWallet, "100.00")
+	if err := CreateVirtualWalletTransaction(client, walletID, "", models.Deposit, models.MustParseMoney("20.00")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Reconcile(client, false, "")
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(report.Mismatches) != 0 {
+		t.Fatalf("mismatches before the overwrite = %+v, want none", report.Mismatches)
+	}
+
+	// A write outside the service layer, which the ledger still only explains as 120.00. Like the balance
+	// overwrites of older releases it snapshots what it stored.
+	overwrittenAt := time.Now()
+	_, err = client.Database("walletManager").Collection("virtual_wallets").UpdateOne(context.Background(),
+		bson.M{"_id": walletID},
+		bson.M{"$set": bson.M{"balance": models.MustParseMoney("150.00")}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = insertBalanceSnapshot(context.Background(), client, models.BalanceSnapshot{
+		WalletID:    walletID,
+		Balance:     models.MustParseMoney("150.00"),
+		HoldBalance: models.MustParseMoney("0"),
+		TakenAt:     overwrittenAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reconcile(client, true, "not-a-reason"); err != ErrInvalidReasonCode {
+		t.Errorf("correction with an unknown reason returned %v, want ErrInvalidReasonCode", err)
+	}
+	// Manual balance edit adjustments move the balance, so they cannot explain drift
+	if _, err := Reconcile(client, true, models.ReasonManualBalanceEdit); err != ErrInvalidReasonCode {
+		t.Errorf("correction as a manual edit returned %v, want ErrInvalidReasonCode", err)
+	}
+	report, err = Reconcile(client, true, models.ReasonReconciliationDrift)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(report.Mismatches) != 1 {
+		t.Fatalf("mismatches after the overwrite = %+v, want one", report.Mismatches)
+	}
+	mismatch := report.Mismatches[0]
+	if mismatch.WalletID != walletID || mismatch.ExpectedBalance != models.MustParseMoney("120.00") || mismatch.BalanceDrift != models.MustParseMoney("30.00") {
+		t.Errorf("mismatch = %+v, want 30.00 drift on wallet %s", mismatch, walletID.Hex())
+	}
+	if len(mismatch.AdjustmentIDs) != 1 {
+		t.Errorf("adjustments = %v, want one", mismatch.AdjustmentIDs)
+	}
+
+	// The adjustment explains the drift
+	report, err = Reconcile(client, false, "")
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(report.Mismatches) != 0 {
+		t.Errorf("mismatches after the correction = %+v, want none", report.Mismatches)
+	}
+	// The snapshot already holds the overwritten balance, so the adjustment is not added on top of it
+	balance, err := WalletBalanceAsOf(client, walletID, time.Now())
+	if err != nil {
+		t.Fatalf("WalletBalanceAsOf: %v", err)
+	}
+	if balance.Balance != models.MustParseMoney("150.00") {
+		t.Errorf("balance after the correction = %v, want 150.00", balance.Balance)
+	}
+	reports, err := ListReconciliationReports(client, 10)
+	if err != nil || len(reports) != 3 {
+		t.Errorf("ListReconciliationReports = %d reports, %v; want 3", len(reports), err)
+	}
+}
+
+func TestSetVirtualWalletBalancePostsAdjustment(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	walletID := createWallet(t, client, accountID, models.CashWallet, "100.00")
+	if err := CreateVirtualWalletTransaction(client, walletID, "", models.Deposit, models.MustParseMoney("20.00")); err != nil {
+		t.Fatal(err)
+	}
+	beforeEdit := time.Now()
+
+	if err := SetVirtualWalletBalance(client, walletID, models.MustParseMoney("150.00")); err != nil {
+		t.Fatalf("SetVirtualWalletBalance: %v", err)
+	}
+	if err := SetVirtualWalletBalance(client, walletID, models.MustParseMoney("150.00")); err != nil {
+		t.Fatalf("SetVirtualWalletBalance to the same balance: %v", err)
+	}
+	if err := SetVirtualWalletBalance(client, walletID, models.NewMoney(15000, "USD")); err != models.ErrCurrencyMismatch {
+		t.Errorf("SetVirtualWalletBalance in another currency returned %v, want ErrCurrencyMismatch", err)
+	}
+
+	var adjustments []models.Transaction
+	cursor, err := transactionsCollection(client).Find(context.Background(), bson.M{"wallet_id": walletID, "reason_code": models.ReasonManualBalanceEdit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cursor.All(context.Background(), &adjustments); err != nil {
+		t.Fatal(err)
+	}
+	if len(adjustments) != 1 || adjustments[0].Type != models.AdjustmentCredit || adjustments[0].Amount != models.MustParseMoney("30.00") {
+		t.Fatalf("manual edit entries = %+v, want one 30.00 adjustment credit", adjustments)
+	}
+
+	// The ledger explains the new balance
+	report, err := Reconcile(client, false, "")
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(report.Mismatches) != 0 {
+		t.Errorf("mismatches after the edit = %+v, want none", report.Mismatches)
+	}
+
+	// The edit moved the balance at the time it was made
+	for asOf, want := range map[time.Time]string{beforeEdit: "120.00", time.Now(): "150.00"} {
+		balance, err := WalletBalanceAsOf(client, walletID, asOf)
+		if err != nil {
+			t.Fatalf("WalletBalanceAsOf: %v", err)
+		}
+		if balance.Balance != models.MustParseMoney(want) {
+			t.Errorf("balance as of %s = %s, want %s", asOf.Format(time.RFC3339Nano), balance.Balance, want)
+		}
+	}
+}
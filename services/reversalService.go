@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"mfus_WalletTransactionManager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrTransactionNotFound      = errors.New("Transaction not found")
+	ErrTransactionNotReversible = errors.New("Only deposit, withdraw, credit and debit transactions can be reversed or refunded")
+	ErrAlreadyReversed          = errors.New("Transaction has already been reversed or fully refunded")
+	ErrReversalAfterRefund      = errors.New("Transaction has been partially refunded and can no longer be reversed")
+	ErrRefundExceedsOriginal    = errors.New("Refund amount exceeds the remaining refundable amount")
+	ErrRefundNotRecipient       = errors.New("Only the account that received the funds can refund this transaction")
+)
+
+// ReverseTransaction undoes a deposit, withdrawal, credit or debit in full. A reversal entry linked to the
+// original is posted, the balance change is undone and the original is marked as reversed.
+// Fees charged on the original are not returned.
+func ReverseTransaction(client *mongo.Client, transactionID primitive.ObjectID, reference string) (*models.Transaction, error) {
+	return compensateTransaction(client, transactionID, models.Reversal, nil, reference)
+}
+
+// RefundTransaction returns part or all of a transaction. Refunds may be repeated until
+// their total reaches the original amount. Refunds of deposits and credits are checked like debits.
+func RefundTransaction(client *mongo.Client, transactionID primitive.ObjectID, amount models.Money, reference string) (*models.Transaction, error) {
+	if !amount.IsPositive() {
+		return nil, errors.New("Refund amount must be positive")
+	}
+	return compensateTransaction(client, transactionID, models.Refund, &amount, reference)
+}
+
+// VerifyRefundRecipient checks that an account received the funds of a transaction and so may refund them.
+// Only deposits and credits have a recipient in the ledger: the account they were posted to, or the owner
+// of their wallet. Money that left through a withdrawal or debit went to someone outside the ledger.
+func VerifyRefundRecipient(client *mongo.Client, transactionID primitive.ObjectID, accountID string) error {
+	original, err := FindTransaction(client, transactionID)
+	if err != nil {
+		return err
+	}
+	if original.Type != models.Deposit && original.Type != models.Credit {
+		return ErrRefundNotRecipient
+	}
+	owner, err := TransactionOwner(client, original)
+	if err != nil {
+		return err
+	}
+	if owner != accountID {
+		return ErrRefundNotRecipient
+	}
+	return nil
+}
+
+// Helper function to post a reversal or refund against a ledger entry. The original is re-read and updated
+// inside the MongoDB transaction, so concurrent reversals of the same entry cannot both succeed.
+func compensateTransaction(client *mongo.Client, transactionID primitive.ObjectID, compensationType models.TransactionType, amount *models.Money, reference string) (*models.Transaction, error) {
+	var compensation *models.Transaction
+	err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+		var original models.Transaction
+		err := transactionsCollection(client).FindOne(ctx, bson.M{"_id": transactionID}).Decode(&original)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return ErrTransactionNotFound
+			}
+			return err
+		}
+
+		var inflow bool
+		switch original.Type {
+		case models.Deposit, models.Credit:
+			inflow = true
+		case models.Withdraw, models.Debit:
+			inflow = false
+		default:
+			return ErrTransactionNotReversible
+		}
+		if original.Status == models.TransactionReversed || original.Status == models.TransactionRefunded {
+			return ErrAlreadyReversed
+		}
+
+		if original.RefundedAmount.IsZero() {
+			// Never refunded, so the field was omitted
+			original.RefundedAmount = models.NewMoney(0, original.Amount.CurrencyCode())
+		}
+		remaining, err := original.Amount.Sub(original.RefundedAmount)
+		if err != nil {
+			return err
+		}
+		compensationAmount := remaining
+		if compensationType == models.Reversal {
+			if original.RefundedAmount.IsPositive() {
+				return ErrReversalAfterRefund
+			}
+		} else if amount != nil {
+			if cmp, err := amount.Cmp(remaining); err != nil {
+				return err
+			} else if cmp > 0 {
+				return ErrRefundExceedsOriginal
+			}
+			compensationAmount = *amount
+		}
+		refunded, err := original.RefundedAmount.Add(compensationAmount)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		status := models.TransactionPartiallyRefunded
+		switch {
+		case compensationType == models.Reversal:
+			status = models.TransactionReversed
+		case refunded.Units >= original.Amount.Units:
+			status = models.TransactionRefunded
+		}
+
+		// Mark the original, guarded on the state that was just validated
+		result, err := transactionsCollection(client).UpdateOne(ctx,
+			bson.M{"_id": original.ID, "status": bson.M{"$nin": bson.A{models.TransactionReversed, models.TransactionRefunded}}},
+			bson.M{"$set": bson.M{"status": status, "refunded_amount": refunded}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrAlreadyReversed
+		}
+
+		// A refund pays the money back out like a debit, so it is held to the same wallet policy and limits
+		if compensationType == models.Refund && inflow {
+			if err := checkRefundAllowed(ctx, client, original, compensationAmount); err != nil {
+				return err
+			}
+		}
+
+		// Undo the balance change: money that came in goes back out and vice versa
+		delta := compensationAmount
+		if inflow {
+			delta = compensationAmount.Neg()
+		}
+		if err := applyCompensation(ctx, client, original, delta, now); err != nil {
+			return err
+		}
+
+		compensation = &models.Transaction{
+			ID:        primitive.NewObjectID(),
+			WalletID:  original.WalletID,
+			AccountID: original.AccountID,
+			Type:      compensationType,
+			Amount:    compensationAmount,
+			Reference: reference,
+			LinkedID:  original.ID,
+			CreatedAt: now,
+		}
+		return insertTransaction(ctx, client, *compensation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return compensation, nil
+}
+
+// Helper function to check a refund of a deposit or credit against the wallet policy and the account limits
+// as if it were a debit of the refunded amount, and count it towards the account's usage
+func checkRefundAllowed(ctx mongo.SessionContext, client *mongo.Client, original models.Transaction, amount models.Money) error {
+	if !original.WalletID.IsZero() {
+		var virtualWallet models.VirtualWallet
+		err := client.Database("walletManager").Collection("virtual_wallets").FindOne(ctx, bson.M{"_id": original.WalletID}).Decode(&virtualWallet)
+		if err != nil {
+			return err
+		}
+		if err := checkWalletPolicy(WalletPolicyFor(&virtualWallet), models.Debit); err != nil {
+			return err
+		}
+	}
+	return applyTransactionLimits(ctx, client, models.Transaction{
+		WalletID:  original.WalletID,
+		AccountID: original.AccountID,
+		Type:      models.Debit,
+		Amount:    amount,
+	})
+}
+
+// Helper function to apply a compensating balance change to the wallet or account owning a ledger entry.
+// Outgoing changes are guarded like withdrawals: they may not take the balance below what the owner may spend.
+func applyCompensation(ctx mongo.SessionContext, client *mongo.Client, original models.Transaction, delta models.Money, now time.Time) error {
+	collection := client.Database("walletManager").Collection("accounts")
+	filter := bson.M{"_id": original.AccountID}
+	if !original.WalletID.IsZero() {
+		collection = client.Database("walletManager").Collection("virtual_wallets")
+		filter = bson.M{"_id": original.WalletID}
+	}
+
+	if delta.IsNegative() {
+		required := delta.Neg()
+		if !original.WalletID.IsZero() {
+			var virtualWallet models.VirtualWallet
+			err := collection.FindOne(ctx, filter).Decode(&virtualWallet)
+			if err != nil {
+				return err
+			}
+			required, err = requiredBalance(WalletPolicyFor(&virtualWallet), delta.Neg())
+			if err != nil {
+				return err
+			}
+		}
+		filter["balance.units"] = bson.M{"$gte": required.Units}
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, bson.M{
+		"$inc": bson.M{"balance.units": delta.Units},
+		"$set": bson.M{"date_modified": now},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if delta.IsNegative() {
+			return ErrInsufficientFunds
+		}
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
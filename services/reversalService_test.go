@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"mfus_WalletTransactionManager/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReverseAndRefundTransaction(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "100.00")
+	debit, err := CreateAccountTransaction(client, accountID, models.Debit, models.MustParseMoney("40.00"))
+	if err != nil {
+		t.Fatalf("debit: %v", err)
+	}
+
+	if _, err := RefundTransaction(client, debit.ID, models.MustParseMoney("10.00"), "partial"); err != nil {
+		t.Fatalf("partial refund: %v", err)
+	}
+	assertAccountBalances(t, client, accountID, "70.00", "0.00")
+	if _, err := RefundTransaction(client, debit.ID, models.MustParseMoney("30.01"), ""); err != ErrRefundExceedsOriginal {
+		t.Errorf("refund above the remainder returned %v, want ErrRefundExceedsOriginal", err)
+	}
+	if _, err := ReverseTransaction(client, debit.ID, ""); err != ErrReversalAfterRefund {
+		t.Errorf("reversal after a refund returned %v, want ErrReversalAfterRefund", err)
+	}
+	if _, err := RefundTransaction(client, debit.ID, models.MustParseMoney("30.00"), "rest"); err != nil {
+		t.Fatalf("refund of the remainder: %v", err)
+	}
+	assertAccountBalances(t, client, accountID, "100.00", "0.00")
+
+	credit, err := CreateAccountTransaction(client, accountID, models.Credit, models.MustParseMoney("25.00"))
+	if err != nil {
+		t.Fatalf("credit: %v", err)
+	}
+	reversal, err := ReverseTransaction(client, credit.ID, "mistake")
+	if err != nil {
+		t.Fatalf("reversal: %v", err)
+	}
+	if reversal.Type != models.Reversal {
+		t.Errorf("reversal type = %s, want %s", reversal.Type, models.Reversal)
+	}
+	assertAccountBalances(t, client, accountID, "100.00", "0.00")
+	if _, err := ReverseTransaction(client, credit.ID, ""); err != ErrAlreadyReversed {
+		t.Errorf("second reversal returned %v, want ErrAlreadyReversed", err)
+	}
+	if _, err := ReverseTransaction(client, primitive.NewObjectID(), ""); err != ErrTransactionNotFound {
+		t.Errorf("reversal of an unknown transaction returned %v, want ErrTransactionNotFound", err)
+	}
+}
+
+func TestVerifyRefundRecipient(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "100.00")
+	otherID := createFundedAccount(t, client, "0.00")
+	credit, err := CreateAccountTransaction(client, accountID, models.Credit, models.MustParseMoney("10.00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	debit, err := CreateAccountTransaction(client, accountID, models.Debit, models.MustParseMoney("10.00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		transactionID primitive.ObjectID
+		accountID     primitive.ObjectID
+		want          error
+	}{
+		{name: "recipient", transactionID: credit.ID, accountID: accountID, want: nil},
+		{name: "another account", transactionID: credit.ID, accountID: otherID, want: ErrRefundNotRecipient},
+		{name: "money sent out", transactionID: debit.ID, accountID: accountID, want: ErrRefundNotRecipient},
+		{name: "unknown transaction", transactionID: primitive.NewObjectID(), accountID: accountID, want: ErrTransactionNotFound},
+	}
+	for _, test := range tests {
+		if err := VerifyRefundRecipient(client, test.transactionID, test.accountID.Hex()); err != test.want {
+			t.Errorf("%s: error = %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	// A wallet transaction belongs to the owner of the wallet
+	walletID := createWallet(t, client, accountID, models.CashWallet, "0.00")
+	if err := CreateVirtualWalletTransaction(client, walletID, "", models.Deposit, models.MustParseMoney("5.00")); err != nil {
+		t.Fatal(err)
+	}
+	page, err := ListWalletTransactions(client, walletID, models.TransactionQuery{})
+	if err != nil || len(page.Transactions) == 0 {
+		t.Fatalf("ListWalletTransactions: %v, %v", page, err)
+	}
+	if err := VerifyRefundRecipient(client, page.Transactions[0].ID, accountID.Hex()); err != nil {
+		t.Errorf("wallet owner: %v", err)
+	}
+	if err := VerifyRefundRecipient(client, page.Transactions[0].ID, otherID.Hex()); err != ErrRefundNotRecipient {
+		t.Errorf("another account on a wallet deposit returned %v, want ErrRefundNotRecipient", err)
+	}
+}
+
+// A refund pays money out, so it cannot cash out a wallet that forbids withdrawals
+func TestRefundFollowsWalletPolicy(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	rewardID := createWallet(t, client, accountID, models.RewardWallet, "0.00")
+	if err := CreateVirtualWalletTransaction(client, rewardID, "", models.Deposit, models.MustParseMoney("50.00")); err != nil {
+		t.Fatal(err)
+	}
+	var deposit models.Transaction
+	err := transactionsCollection(client).FindOne(context.Background(), bson.M{"wallet_id": rewardID, "type": models.Deposit}).Decode(&deposit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RefundTransaction(client, deposit.ID, models.MustParseMoney("50.00"), "cash out"); err != ErrCashWithdrawalNotAllowed {
+		t.Errorf("refund from a RewardWallet returned %v, want ErrCashWithdrawalNotAllowed", err)
+	}
+	virtualWallet, err := FindVirtualWallet(client, rewardID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if virtualWallet.Balance != models.MustParseMoney("50.00") {
+		t.Errorf("balance = %v, want the untouched 50.00", virtualWallet.Balance)
+	}
+	original, err := FindTransaction(client, deposit.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original.Status != "" || !original.RefundedAmount.IsZero() {
+		t.Errorf("deposit after the refused refund = %s, refunded %v; want it untouched", original.Status, original.RefundedAmount)
+	}
+}
+
+// Refunds count towards the withdrawal limits like debits
+func TestRefundFollowsWithdrawalLimits(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	if err := SetLimitOverrides(client, accountID, models.TransactionLimits{DailyWithdrawal: limitAmount("50.00")}); err != nil {
+		t.Fatal(err)
+	}
+	credit, err := CreateAccountTransaction(client, accountID, models.Credit, models.MustParseMoney("100.00"))
+	if err != nil {
+		t.Fatalf("credit: %v", err)
+	}
+
+	_, err = RefundTransaction(client, credit.ID, models.MustParseMoney("60.00"), "")
+	if limitErr, ok := err.(*LimitExceededError); !ok || limitErr.Violation.Limit != "daily_withdrawal" {
+		t.Errorf("refund above the daily limit returned %v, want a daily_withdrawal violation", err)
+	}
+	assertAccountBalances(t, client, accountID, "100.00", "0.00")
+
+	if _, err := RefundTransaction(client, credit.ID, models.MustParseMoney("40.00"), ""); err != nil {
+		t.Fatalf("refund within the daily limit: %v", err)
+	}
+	assertAccountBalances(t, client, accountID, "60.00", "0.00")
+	// The refund used 40.00 of the 50.00 daily limit
+	if _, err := CreateAccountTransaction(client, accountID, models.Debit, models.MustParseMoney("20.00")); err == nil {
+		t.Error("debit above the remaining daily limit was accepted")
+	}
+}
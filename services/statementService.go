@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"mfus_WalletTransactionManager/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StatementSink receives a statement as it is read from the ledger: the header, every line in time order
+// and finally the summary. Lines are handed over one at a time so long histories are never held in memory.
+type StatementSink interface {
+	Begin(header models.StatementHeader) error
+	Line(line models.StatementLine) error
+	End(summary models.StatementSummary) error
+}
+
+// WriteWalletStatement streams the statement of a virtual wallet for entries after from up to and including to
+func WriteWalletStatement(client *mongo.Client, virtualWalletID primitive.ObjectID, from, to time.Time, sink StatementSink) error {
+	return writeStatement(client, "wallet_id", virtualWalletID, from, to, sink)
+}
+
+// WriteAccountStatement streams the statement of an account for entries after from up to and including to
+func WriteAccountStatement(client *mongo.Client, accountID primitive.ObjectID, from, to time.Time, sink StatementSink) error {
+	return writeStatement(client, "account_id", accountID, from, to, sink)
+}
+
+// Helper function to build a statement. The opening balance is the point-in-time balance at from,
+// then the ledger is read with a cursor and the running balance is carried from line to line.
+func writeStatement(client *mongo.Client, ownerField string, ownerID primitive.ObjectID, from, to time.Time, sink StatementSink) error {
+	// A statement cannot start before the wallet or account existed
+	var owner struct {
+		DateCreated time.Time `bson:"date_created"`
+		CreatedAt   time.Time `bson:"created_at"`
+		CustomerID  string    `bson:"customer_id"`
+	}
+	collection := client.Database("walletManager").Collection("accounts")
+	if ownerField == "wallet_id" {
+		collection = client.Database("walletManager").Collection("virtual_wallets")
+	}
+	err := collection.FindOne(context.Background(), bson.M{"_id": ownerID}).Decode(&owner)
+	if err != nil {
+		return err
+	}
+	created := owner.CreatedAt
+	if ownerField == "wallet_id" {
+		created = owner.DateCreated
+	}
+	if from.Before(created) {
+		from = created
+	}
+	if to.After(time.Now()) {
+		to = time.Now()
+	}
+
+	opening, err := readBalanceAsOf(client, ownerField, ownerID, from)
+	if err != nil {
+		return err
+	}
+	header := models.StatementHeader{From: from, To: to, OpeningBalance: opening.Balance}
+	if ownerField == "wallet_id" {
+		header.WalletID = ownerID
+		header.CustomerID = owner.CustomerID
+	} else {
+		header.AccountID = ownerID
+	}
+	if err := sink.Begin(header); err != nil {
+		return err
+	}
+
+	cursor, err := transactionsCollection(client).Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{ownerField: ownerID, "created_at": bson.M{"$gt": from, "$lte": to}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "transactions",
+			"localField":   "linked_transaction_id",
+			"foreignField": "_id",
+			"as":           "original",
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	running := opening.Balance
+	totals := map[models.TransactionType]*models.StatementTotal{}
+	for cursor.Next(context.Background()) {
+		var entry struct {
+			models.Transaction `bson:",inline"`
+			Original           []struct {
+				Type models.TransactionType `bson:"type"`
+			} `bson:"original"`
+		}
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+		var originalType models.TransactionType
+		if len(entry.Original) > 0 {
+			originalType = entry.Original[0].Type
+		}
+
+		change, _ := transactionEffect(entry.Transaction, originalType)
+		if running, err = running.Add(change); err != nil {
+			return err
+		}
+		err := sink.Line(models.StatementLine{
+			TransactionID: entry.ID,
+			Date:          entry.CreatedAt,
+			Type:          entry.Type,
+			Reference:     entry.Reference,
+			Amount:        change,
+			Balance:       running,
+		})
+		if err != nil {
+			return err
+		}
+
+		total, ok := totals[entry.Type]
+		if !ok {
+			total = &models.StatementTotal{Type: entry.Type, Amount: models.NewMoney(0, entry.Amount.CurrencyCode())}
+			totals[entry.Type] = total
+		}
+		total.Count++
+		if total.Amount, err = total.Amount.Add(entry.Amount); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	summary := models.StatementSummary{ClosingBalance: running, Totals: []models.StatementTotal{}}
+	for _, total := range totals {
+		summary.Totals = append(summary.Totals, *total)
+	}
+	sort.Slice(summary.Totals, func(i, j int) bool {
+		return summary.Totals[i].Type < summary.Totals[j].Type
+	})
+	return sink.End(summary)
+}
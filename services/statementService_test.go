@@ -0,0 +1,78 @@
+package services
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"testing"
+	"time"
+)
+
+// recordingSink keeps every part of a statement for inspection
+type recordingSink struct {
+	header  models.StatementHeader
+	lines   []models.StatementLine
+	summary models.StatementSummary
+}
+
+func (s *recordingSink) Begin(header models.StatementHeader) error {
+	s.header = header
+	return nil
+}
+
+func (s *recordingSink) Line(line models.StatementLine) error {
+	s.lines = append(s.lines, line)
+	return nil
+}
+
+func (s *recordingSink) End(summary models.StatementSummary) error {
+	s.summary = summary
+	return nil
+}
+
+func TestWriteWalletStatement(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	walletID := createWallet(t, client, accountID, models.CashWallet, "100.00")
+	time.Sleep(10 * time.Millisecond)
+	from := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	for _, entry := range []struct {
+		transactionType models.TransactionType
+		amount          string
+	}{
+		{models.Deposit, "50.00"},
+		{models.Debit, "20.00"},
+		{models.Deposit, "5.00"},
+	} {
+		if err := CreateVirtualWalletTransaction(client, walletID, "", entry.transactionType, models.MustParseMoney(entry.amount)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sink := &recordingSink{}
+	if err := WriteWalletStatement(client, walletID, from, time.Now().Add(time.Hour), sink); err != nil {
+		t.Fatalf("WriteWalletStatement: %v", err)
+	}
+	if sink.header.OpeningBalance != models.MustParseMoney("100.00") || sink.header.CustomerID != accountID.Hex() {
+		t.Errorf("header = %+v, want an opening balance of 100.00 for customer %s", sink.header, accountID.Hex())
+	}
+	running := []string{"150.00", "130.00", "135.00"}
+	if len(sink.lines) != len(running) {
+		t.Fatalf("statement has %d lines, want %d", len(sink.lines), len(running))
+	}
+	for i, want := range running {
+		if sink.lines[i].Balance != models.MustParseMoney(want) {
+			t.Errorf("line %d running balance = %v, want %s", i, sink.lines[i].Balance, want)
+		}
+	}
+	if sink.lines[1].Amount != models.NewMoney(-2000, models.DefaultCurrency) {
+		t.Errorf("debit line amount = %v, want -20.00", sink.lines[1].Amount)
+	}
+	if sink.summary.ClosingBalance != models.MustParseMoney("135.00") {
+		t.Errorf("closing balance = %v, want 135.00", sink.summary.ClosingBalance)
+	}
+	totals := sink.summary.Totals
+	if len(totals) != 2 || totals[0].Type != models.Debit || totals[1].Type != models.Deposit ||
+		totals[1].Count != 2 || totals[1].Amount != models.MustParseMoney("55.00") {
+		t.Errorf("totals = %+v, want 1 debit and 2 deposits of 55.00", totals)
+	}
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"mfus_WalletTransactionManager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidLastEventID = errors.New("Invalid Last-Event-ID")
+	ErrStreamHistoryLost  = errors.New("Last-Event-ID is too old to resume from, reconnect without it")
+)
+
+// How long the stream waits for a change before sending a heartbeat
+const streamHeartbeat = 15 * time.Second
+
+// EventStreamSink receives a live event stream. Begin is called once the change stream is open, before
+// any event; Heartbeat is called whenever nothing happened for a while so idle connections stay open.
+type EventStreamSink interface {
+	Begin() error
+	Event(event models.StreamEvent) error
+	Heartbeat() error
+}
+
+// streamScope is the set of wallets and the account whose changes a stream carries. A customer stream
+// also picks up wallets the customer creates while it runs.
+type streamScope struct {
+	accountID  primitive.ObjectID
+	customerID string
+	walletIDs  []primitive.ObjectID
+}
+
+// changeEvent is the part of a MongoDB change event the streams use
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	Namespace     struct {
+		Collection string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument      bson.Raw `bson:"fullDocument"`
+	UpdateDescription struct {
+		UpdatedFields struct {
+			Balance          *models.Money `bson:"balance"`
+			HoldBalance      *models.Money `bson:"hold_balance"`
+			BalanceUnits     *int64        `bson:"balance.units"`
+			HoldBalanceUnits *int64        `bson:"hold_balance.units"`
+		} `bson:"updatedFields"`
+	} `bson:"updateDescription"`
+}
+
+// StreamWalletEvents streams the ledger entries and balance changes of a virtual wallet until ctx is done
+// or the wallet is deleted. Without lastEventID the stream starts with the current balance; with it the
+// stream resumes right after that event.
+func StreamWalletEvents(ctx context.Context, client *mongo.Client, virtualWalletID primitive.ObjectID, lastEventID string, sink EventStreamSink) error {
+	scope := &streamScope{walletIDs: []primitive.ObjectID{virtualWalletID}}
+	return streamEvents(ctx, client, scope, lastEventID, sink)
+}
+
+// StreamCustomerEvents streams the ledger entries and balance changes of a customer's account and all
+// of its virtual wallets, including wallets created while the stream is open
+func StreamCustomerEvents(ctx context.Context, client *mongo.Client, accountID primitive.ObjectID, lastEventID string, sink EventStreamSink) error {
+	scope := &streamScope{accountID: accountID, customerID: accountID.Hex(), walletIDs: []primitive.ObjectID{}}
+	cursor, err := client.Database("walletManager").Collection("virtual_wallets").Find(ctx,
+		bson.M{"customer_id": scope.customerID},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	var virtualWallets []models.VirtualWallet
+	if err := cursor.All(ctx, &virtualWallets); err != nil {
+		return err
+	}
+	for _, virtualWallet := range virtualWallets {
+		scope.walletIDs = append(scope.walletIDs, virtualWallet.ID)
+	}
+	return streamEvents(ctx, client, scope, lastEventID, sink)
+}
+
+// Helper function to run a change stream over the wallets and account of scope. The change stream is
+// opened before the initial balances are read, so a change made in between is sent rather than lost.
+// When the customer creates a wallet the change stream is reopened right after that event to include it.
+func streamEvents(ctx context.Context, client *mongo.Client, scope *streamScope, lastEventID string, sink EventStreamSink) error {
+	var resumeToken interface{}
+	if lastEventID != "" {
+		resumeToken = bson.M{"_data": lastEventID}
+	}
+	stream, err := watchScope(ctx, client, scope, resumeToken)
+	if err != nil {
+		return err
+	}
+	defer func() { stream.Close(context.Background()) }()
+
+	// The current balances are only sent to new clients; a resumed client already has them
+	var initial []models.BalanceSnapshot
+	if lastEventID == "" {
+		if initial, err = currentBalances(ctx, client, scope); err != nil {
+			return err
+		}
+	}
+	if err := sink.Begin(); err != nil {
+		return err
+	}
+	position := resumePosition(stream.ResumeToken())
+	for _, balance := range initial {
+		if err := sink.Event(models.StreamEvent{ID: position, Type: models.EventBalanceUpdated, Data: balance}); err != nil {
+			return err
+		}
+	}
+
+	for {
+		if !stream.TryNext(ctx) {
+			if err := stream.Err(); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			if err := sink.Heartbeat(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var change changeEvent
+		if err := stream.Decode(&change); err != nil {
+			return err
+		}
+		position = resumePosition(stream.ResumeToken())
+		event, err := streamEventFromChange(change)
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			event.ID = position
+			if err := sink.Event(*event); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case change.OperationType == "delete" && scope.accountID.IsZero():
+			// The wallet of a wallet stream is gone
+			return nil
+		case change.OperationType == "insert" && change.Namespace.Collection == "virtual_wallets":
+			scope.walletIDs = append(scope.walletIDs, change.DocumentKey.ID)
+			resumeToken := stream.ResumeToken()
+			stream.Close(context.Background())
+			reopened, err := watchScope(ctx, client, scope, resumeToken)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			stream = reopened
+		}
+	}
+}
+
+// Helper function to open the change stream of scope, after resumeToken when given
+func watchScope(ctx context.Context, client *mongo.Client, scope *streamScope, resumeToken interface{}) (*mongo.ChangeStream, error) {
+	// Updates that leave both balances alone, e.g. of limits, are not sent
+	balanceChanged := bson.A{
+		bson.M{"operationType": "replace"},
+		bson.M{"operationType": "update", "updateDescription.updatedFields.balance": bson.M{"$exists": true}},
+		bson.M{"operationType": "update", "updateDescription.updatedFields.hold_balance": bson.M{"$exists": true}},
+	}
+	walletChange := bson.M{
+		"ns.coll":         "virtual_wallets",
+		"documentKey._id": bson.M{"$in": scope.walletIDs},
+		"$or":             append(bson.A{bson.M{"operationType": "delete"}}, balanceChanged...),
+	}
+	ledgerEntry := bson.M{
+		"ns.coll":                "transactions",
+		"operationType":          "insert",
+		"fullDocument.wallet_id": bson.M{"$in": scope.walletIDs},
+	}
+	match := bson.A{walletChange, ledgerEntry}
+	if !scope.accountID.IsZero() {
+		match = append(match,
+			bson.M{"ns.coll": "virtual_wallets", "operationType": "insert", "fullDocument.customer_id": scope.customerID},
+			bson.M{"ns.coll": "accounts", "documentKey._id": scope.accountID, "$or": balanceChanged},
+			bson.M{"ns.coll": "transactions", "operationType": "insert", "fullDocument.account_id": scope.accountID},
+		)
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"$or": match}}}}
+
+	streamOptions := options.ChangeStream().SetFullDocument(options.UpdateLookup).SetMaxAwaitTime(streamHeartbeat)
+	if resumeToken != nil {
+		streamOptions.SetResumeAfter(resumeToken)
+	}
+	stream, err := client.Database("walletManager").Watch(ctx, pipeline, streamOptions)
+	if err != nil && resumeToken != nil {
+		if commandErr, ok := err.(mongo.CommandError); ok {
+			// 286 is ChangeStreamHistoryLost, 280 ChangeStreamFatalError
+			if commandErr.Code == 286 || commandErr.Code == 280 {
+				return nil, ErrStreamHistoryLost
+			}
+			return nil, ErrInvalidLastEventID
+		}
+	}
+	return stream, err
+}
+
+// Helper function to read the balances a new client starts from
+func currentBalances(ctx context.Context, client *mongo.Client, scope *streamScope) ([]models.BalanceSnapshot, error) {
+	var balances []models.BalanceSnapshot
+	if !scope.accountID.IsZero() {
+		var account models.Account
+		err := client.Database("walletManager").Collection("accounts").FindOne(ctx, bson.M{"_id": scope.accountID}).Decode(&account)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, accountBalance(account))
+	}
+	if len(scope.walletIDs) == 0 {
+		return balances, nil
+	}
+	cursor, err := client.Database("walletManager").Collection("virtual_wallets").Find(ctx,
+		bson.M{"_id": bson.M{"$in": scope.walletIDs}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var virtualWallets []models.VirtualWallet
+	if err := cursor.All(ctx, &virtualWallets); err != nil {
+		return nil, err
+	}
+	if scope.accountID.IsZero() && len(virtualWallets) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	for _, virtualWallet := range virtualWallets {
+		balances = append(balances, walletBalance(virtualWallet))
+	}
+	return balances, nil
+}
+
+// Helper function to turn a change event into the event sent to the client. Returns nil for changes
+// that carry nothing to send, e.g. an update of a document deleted before it could be looked up.
+func streamEventFromChange(change changeEvent) (*models.StreamEvent, error) {
+	switch change.Namespace.Collection {
+	case "transactions":
+		var transaction models.Transaction
+		if err := bson.Unmarshal(change.FullDocument, &transaction); err != nil {
+			return nil, err
+		}
+		return &models.StreamEvent{Type: transactionEventType(transaction.Type), Data: transaction}, nil
+
+	case "virtual_wallets":
+		if change.OperationType == "delete" {
+			return &models.StreamEvent{
+				Type: models.EventWalletDeleted,
+				Data: models.BalanceSnapshot{WalletID: change.DocumentKey.ID, TakenAt: time.Now()},
+			}, nil
+		}
+		if change.FullDocument == nil {
+			return nil, nil
+		}
+		var virtualWallet models.VirtualWallet
+		if err := bson.Unmarshal(change.FullDocument, &virtualWallet); err != nil {
+			return nil, err
+		}
+		balance := walletBalance(virtualWallet)
+		if change.OperationType == "insert" {
+			return &models.StreamEvent{Type: models.EventWalletCreated, Data: balance}, nil
+		}
+		applyUpdatedBalances(&balance, change)
+		return &models.StreamEvent{Type: models.EventBalanceUpdated, Data: balance}, nil
+
+	case "accounts":
+		if change.FullDocument == nil {
+			return nil, nil
+		}
+		var account models.Account
+		if err := bson.Unmarshal(change.FullDocument, &account); err != nil {
+			return nil, err
+		}
+		balance := accountBalance(account)
+		applyUpdatedBalances(&balance, change)
+		return &models.StreamEvent{Type: models.EventBalanceUpdated, Data: balance}, nil
+	}
+	return nil, nil
+}
+
+// Helper function to prefer the balances written by the update itself. The looked-up document is read
+// after the change and may already include later ones.
+// Postings $inc the units of a balance, so only the units show up as updated; the currency never changes.
+func applyUpdatedBalances(balance *models.BalanceSnapshot, change changeEvent) {
+	updated := change.UpdateDescription.UpdatedFields
+	if updated.Balance != nil {
+		balance.Balance = *updated.Balance
+	}
+	if updated.BalanceUnits != nil {
+		balance.Balance.Units = *updated.BalanceUnits
+	}
+	if updated.HoldBalance != nil {
+		balance.HoldBalance = *updated.HoldBalance
+	}
+	if updated.HoldBalanceUnits != nil {
+		balance.HoldBalance.Units = *updated.HoldBalanceUnits
+	}
+}
+
+func walletBalance(virtualWallet models.VirtualWallet) models.BalanceSnapshot {
+	return models.BalanceSnapshot{
+		WalletID:    virtualWallet.ID,
+		Balance:     virtualWallet.Balance,
+		HoldBalance: virtualWallet.HoldBalance,
+		TakenAt:     virtualWallet.DateModified,
+	}
+}
+
+func accountBalance(account models.Account) models.BalanceSnapshot {
+	return models.BalanceSnapshot{
+		AccountID:   account.ID,
+		Balance:     account.Balance,
+		HoldBalance: account.HoldBalance,
+		TakenAt:     account.DateModified,
+	}
+}
+
+// Helper function to turn a change stream resume token into an event ID
+func resumePosition(token bson.Raw) string {
+	if token == nil {
+		return ""
+	}
+	data, ok := token.Lookup("_data").StringValueOK()
+	if !ok {
+		return ""
+	}
+	return data
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"mfus_WalletTransactionManager/models"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Helper function to marshal a document into the full document of a change event
+func fullDocument(t *testing.T, document interface{}) bson.Raw {
+	t.Helper()
+	data, err := bson.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestStreamEventFromChange(t *testing.T) {
+	walletID := primitive.NewObjectID()
+	wallet := models.VirtualWallet{
+		ID:          walletID,
+		Balance:     models.MustParseMoney("75.00"),
+		HoldBalance: models.MustParseMoney("5.00"),
+	}
+
+	var change changeEvent
+	change.OperationType = "insert"
+	change.Namespace.Collection = "transactions"
+	change.FullDocument = fullDocument(t, models.Transaction{WalletID: walletID, Type: models.Hold, Amount: models.MustParseMoney("5.00")})
+	event, err := streamEventFromChange(change)
+	if err != nil || event == nil || event.Type != models.EventHoldCreated {
+		t.Fatalf("hold ledger entry = %+v, %v; want a %s event", event, err, models.EventHoldCreated)
+	}
+	if transaction, ok := event.Data.(models.Transaction); !ok || transaction.Amount != models.MustParseMoney("5.00") {
+		t.Errorf("hold ledger entry data = %+v, want the transaction", event.Data)
+	}
+
+	change = changeEvent{}
+	change.OperationType = "insert"
+	change.Namespace.Collection = "virtual_wallets"
+	change.FullDocument = fullDocument(t, wallet)
+	event, err = streamEventFromChange(change)
+	if err != nil || event == nil || event.Type != models.EventWalletCreated {
+		t.Fatalf("wallet insert = %+v, %v; want a %s event", event, err, models.EventWalletCreated)
+	}
+
+	// The units written by the update win over the looked-up document, which may include later changes
+	change.OperationType = "update"
+	change.UpdateDescription.UpdatedFields.BalanceUnits = new(int64)
+	*change.UpdateDescription.UpdatedFields.BalanceUnits = 9000
+	event, err = streamEventFromChange(change)
+	if err != nil || event == nil || event.Type != models.EventBalanceUpdated {
+		t.Fatalf("wallet update = %+v, %v; want a %s event", event, err, models.EventBalanceUpdated)
+	}
+	balance := event.Data.(models.BalanceSnapshot)
+	if balance.WalletID != walletID || balance.Balance != models.MustParseMoney("90.00") || balance.HoldBalance != models.MustParseMoney("5.00") {
+		t.Errorf("wallet update balance = %+v, want 90.00 held 5.00", balance)
+	}
+
+	change = changeEvent{}
+	change.OperationType = "delete"
+	change.Namespace.Collection = "virtual_wallets"
+	change.DocumentKey.ID = walletID
+	event, err = streamEventFromChange(change)
+	if err != nil || event == nil || event.Type != models.EventWalletDeleted || event.Data.(models.BalanceSnapshot).WalletID != walletID {
+		t.Errorf("wallet delete = %+v, %v; want a %s event for the wallet", event, err, models.EventWalletDeleted)
+	}
+
+	// An account updated and deleted before it could be looked up has nothing to send
+	change = changeEvent{}
+	change.OperationType = "update"
+	change.Namespace.Collection = "accounts"
+	if event, err := streamEventFromChange(change); err != nil || event != nil {
+		t.Errorf("account update without a document = %+v, %v; want nothing", event, err)
+	}
+}
+
+func TestResumePosition(t *testing.T) {
+	if position := resumePosition(nil); position != "" {
+		t.Errorf("position of no token = %q, want none", position)
+	}
+	if position := resumePosition(fullDocument(t, bson.M{"_data": "8263A1"})); position != "8263A1" {
+		t.Errorf("position = %q, want 8263A1", position)
+	}
+	if position := resumePosition(fullDocument(t, bson.M{"_data": int32(1)})); position != "" {
+		t.Errorf("position of a token without string data = %q, want none", position)
+	}
+}
+
+// channelSink passes the events of a stream to the test
+type channelSink struct {
+	begun  chan struct{}
+	events chan models.StreamEvent
+}
+
+func newChannelSink() *channelSink {
+	return &channelSink{begun: make(chan struct{}), events: make(chan models.StreamEvent, 16)}
+}
+
+func (s *channelSink) Begin() error {
+	close(s.begun)
+	return nil
+}
+
+func (s *channelSink) Event(event models.StreamEvent) error {
+	s.events <- event
+	return nil
+}
+
+func (s *channelSink) Heartbeat() error {
+	return nil
+}
+
+// Helper function to run a wallet stream in the background; change streams need a replica set
+func startWalletStream(t *testing.T, ctx context.Context, run func(sink EventStreamSink) error) (*channelSink, chan error) {
+	t.Helper()
+	sink := newChannelSink()
+	done := make(chan error, 1)
+	go func() { done <- run(sink) }()
+	select {
+	case <-sink.begun:
+	case err := <-done:
+		if err != nil && strings.Contains(err.Error(), "replica set") {
+			t.Skip("MongoDB does not support change streams without a replica set")
+		}
+		t.Fatalf("stream ended before it began: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("stream did not begin")
+	}
+	return sink, done
+}
+
+// Helper function to wait for the next event of a stream
+func nextStreamEvent(t *testing.T, sink *channelSink) models.StreamEvent {
+	t.Helper()
+	select {
+	case event := <-sink.events:
+		return event
+	case <-time.After(10 * time.Second):
+		t.Fatal("no event was streamed")
+	}
+	return models.StreamEvent{}
+}
+
+func TestStreamWalletEventsResume(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	walletID := createWallet(t, client, accountID, models.CashWallet, "10.00")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sink, done := startWalletStream(t, ctx, func(sink EventStreamSink) error {
+		return StreamWalletEvents(ctx, client, walletID, "", sink)
+	})
+
+	// A new client starts with the current balance
+	initial := nextStreamEvent(t, sink)
+	if initial.Type != models.EventBalanceUpdated || initial.Data.(models.BalanceSnapshot).Balance != models.MustParseMoney("10.00") {
+		t.Fatalf("first event = %+v, want the current balance of 10.00", initial)
+	}
+
+	if err := CreateVirtualWalletTransaction(client, walletID, "", models.Deposit, models.MustParseMoney("5.00")); err != nil {
+		t.Fatal(err)
+	}
+	var seen []models.StreamEvent
+	for len(seen) < 2 {
+		seen = append(seen, nextStreamEvent(t, sink))
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("stream ended with %v, want nil after the client left", err)
+	}
+
+	types := map[models.EventType]bool{}
+	for _, event := range seen {
+		types[event.Type] = true
+		if event.ID == "" {
+			t.Errorf("%s event has no ID to resume from", event.Type)
+		}
+	}
+	if !types[models.EventTransactionCreated] || !types[models.EventBalanceUpdated] {
+		t.Errorf("events after the deposit = %+v, want the ledger entry and the new balance", seen)
+	}
+
+	// A reconnecting client gets the events after its Last-Event-ID and not the current balance again
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	sink, done = startWalletStream(t, ctx, func(sink EventStreamSink) error {
+		return StreamWalletEvents(ctx, client, walletID, seen[0].ID, sink)
+	})
+	resumed := nextStreamEvent(t, sink)
+	if resumed.ID != seen[1].ID || resumed.Type != seen[1].Type {
+		t.Errorf("resumed with %s %s, want %s %s", resumed.Type, resumed.ID, seen[1].Type, seen[1].ID)
+	}
+	cancel()
+	<-done
+
+	if err := StreamWalletEvents(context.Background(), client, walletID, "not-a-token", newChannelSink()); err != ErrInvalidLastEventID {
+		t.Errorf("unknown Last-Event-ID returned %v, want ErrInvalidLastEventID", err)
+	}
+}
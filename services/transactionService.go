@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mfus_WalletTransactionManager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Helper function returning the ledger collection that holds every wallet and account transaction
+func transactionsCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("transactions")
+}
+
+// EnsureTransactionIndexes creates the indexes used to list a wallet's or account's history in time order
+func EnsureTransactionIndexes(client *mongo.Client) error {
+	_, err := transactionsCollection(client).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "wallet_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "account_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "transfer_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	return err
+}
+
+// runInTransaction executes fn inside a MongoDB multi-document transaction.
+// Transient transaction errors and unknown commit results are retried by the driver's WithTransaction helper.
+// Multi-document transactions require MongoDB to run as a replica set.
+func runInTransaction(client *mongo.Client, fn func(ctx mongo.SessionContext) error) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	txnOptions := options.Transaction().
+		SetReadConcern(readconcern.Snapshot()).
+		SetWriteConcern(writeconcern.New(writeconcern.WMajority()))
+
+	_, err = session.WithTransaction(context.Background(), func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	}, txnOptions)
+	if err == nil {
+		// The transaction may have written outbox events
+		wakeOutboxRelay()
+	}
+	return err
+}
+
+// Helper function to append a transaction to the ledger. Its event is written to the outbox alongside it.
+func insertTransaction(ctx context.Context, client *mongo.Client, transaction models.Transaction) error {
+	_, err := transactionsCollection(client).InsertOne(ctx, transaction)
+	if err != nil {
+		return err
+	}
+	return recordTransactionEvent(ctx, client, transaction)
+}
+
+// FindTransaction returns a ledger entry by ID
+func FindTransaction(client *mongo.Client, transactionID primitive.ObjectID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	err := transactionsCollection(client).FindOne(context.Background(), bson.M{"_id": transactionID}).Decode(&transaction)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// TransactionOwner returns the ID of the account a ledger entry belongs to: the account it was posted to,
+// or the account owning its wallet. It returns mongo.ErrNoDocuments if the wallet no longer exists.
+func TransactionOwner(client *mongo.Client, transaction *models.Transaction) (string, error) {
+	if transaction.WalletID.IsZero() {
+		return transaction.AccountID.Hex(), nil
+	}
+	virtualWallet, err := FindVirtualWallet(client, transaction.WalletID, "customer_id")
+	if err != nil {
+		return "", err
+	}
+	return virtualWallet.CustomerID, nil
+}
+
+const (
+	DefaultTransactionPageSize = 50
+	MaxTransactionPageSize     = 500
+)
+
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// transactionCursor is the position after the last entry of a page, encoded as opaque base64 JSON
+type transactionCursor struct {
+	CreatedAt time.Time          `json:"t"`
+	ID        primitive.ObjectID `json:"id"`
+}
+
+func encodeTransactionCursor(transaction models.Transaction) string {
+	data, _ := json.Marshal(transactionCursor{CreatedAt: transaction.CreatedAt, ID: transaction.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeTransactionCursor(cursor string) (*transactionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var position transactionCursor
+	if err := json.Unmarshal(data, &position); err != nil || position.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &position, nil
+}
+
+// Helper function to turn the optional filters of a query into a ledger filter for one owner
+func transactionQueryFilter(ownerField string, ownerID primitive.ObjectID, query models.TransactionQuery) (bson.M, error) {
+	filter := bson.M{ownerField: ownerID}
+	if len(query.Types) > 0 {
+		filter["type"] = bson.M{"$in": query.Types}
+	}
+
+	if query.MinAmount != nil && query.MaxAmount != nil && query.MinAmount.CurrencyCode() != query.MaxAmount.CurrencyCode() {
+		return nil, models.ErrCurrencyMismatch
+	}
+	amount := bson.M{}
+	if query.MinAmount != nil {
+		amount["$gte"] = query.MinAmount.Units
+		filter["amount.currency"] = query.MinAmount.CurrencyCode()
+	}
+	if query.MaxAmount != nil {
+		amount["$lte"] = query.MaxAmount.Units
+		filter["amount.currency"] = query.MaxAmount.CurrencyCode()
+	}
+	if len(amount) > 0 {
+		filter["amount.units"] = amount
+	}
+
+	createdAt := bson.M{}
+	if !query.StartDate.IsZero() {
+		createdAt["$gte"] = query.StartDate
+	}
+	if !query.EndDate.IsZero() {
+		createdAt["$lt"] = query.EndDate
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	// Keyset pagination: continue strictly after the (created_at, _id) position of the cursor
+	if query.Cursor != "" {
+		position, err := decodeTransactionCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		operator := "$gt"
+		if query.Descending {
+			operator = "$lt"
+		}
+		filter["$or"] = bson.A{
+			bson.M{"created_at": bson.M{operator: position.CreatedAt}},
+			bson.M{"created_at": position.CreatedAt, "_id": bson.M{operator: position.ID}},
+		}
+	}
+
+	return filter, nil
+}
+
+// Helper function to list one page of ledger entries for a wallet or an account
+func listTransactions(client *mongo.Client, ownerField string, ownerID primitive.ObjectID, query models.TransactionQuery) (*models.TransactionPage, error) {
+	filter, err := transactionQueryFilter(ownerField, ownerID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultTransactionPageSize
+	}
+	if limit > MaxTransactionPageSize {
+		limit = MaxTransactionPageSize
+	}
+	direction := 1
+	if query.Descending {
+		direction = -1
+	}
+
+	// Fetch one extra entry to find out whether another page follows
+	cursor, err := transactionsCollection(client).Find(
+		context.Background(),
+		filter,
+		options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: direction}, {Key: "_id", Value: direction}}).
+			SetLimit(limit+1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	page := &models.TransactionPage{Transactions: []models.Transaction{}}
+	err = cursor.All(context.Background(), &page.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(page.Transactions)) > limit {
+		page.Transactions = page.Transactions[:limit]
+		page.NextCursor = encodeTransactionCursor(page.Transactions[limit-1])
+	}
+
+	return page, nil
+}
+
+// ListWalletTransactions returns one filtered, sorted page of a virtual wallet's history
+func ListWalletTransactions(client *mongo.Client, virtualWalletID primitive.ObjectID, query models.TransactionQuery) (*models.TransactionPage, error) {
+	return listTransactions(client, "wallet_id", virtualWalletID, query)
+}
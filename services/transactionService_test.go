@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"mfus_WalletTransactionManager/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTransactionCursorRoundTrip(t *testing.T) {
+	transaction := models.Transaction{ID: primitive.NewObjectID(), CreatedAt: time.Now().UTC().Truncate(time.Millisecond)}
+	position, err := decodeTransactionCursor(encodeTransactionCursor(transaction))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position.ID != transaction.ID || !position.CreatedAt.Equal(transaction.CreatedAt) {
+		t.Errorf("Decoded %+v, want %s at %v", position, transaction.ID.Hex(), transaction.CreatedAt)
+	}
+
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		if _, err := decodeTransactionCursor(cursor); err != ErrInvalidCursor {
+			t.Errorf("decodeTransactionCursor(%q) returned %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestTransactionQueryFilter(t *testing.T) {
+	walletID := primitive.NewObjectID()
+	minAmount := models.MustParseMoney("10.00")
+	maxAmount := models.MustParseMoney("20.00")
+	filter, err := transactionQueryFilter("wallet_id", walletID, models.TransactionQuery{
+		Types:     []models.TransactionType{models.Debit},
+		MinAmount: &minAmount,
+		MaxAmount: &maxAmount,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter["wallet_id"] != walletID {
+		t.Errorf("Filter is not scoped to the wallet: %v", filter)
+	}
+	units, ok := filter["amount.units"].(bson.M)
+	if !ok || units["$gte"] != int64(1000) || units["$lte"] != int64(2000) {
+		t.Errorf("amount.units filter is %v", filter["amount.units"])
+	}
+	if filter["amount.currency"] != models.DefaultCurrency {
+		t.Errorf("amount.currency filter is %v", filter["amount.currency"])
+	}
+
+	usd := models.NewMoney(100, "USD")
+	_, err = transactionQueryFilter("wallet_id", walletID, models.TransactionQuery{MinAmount: &minAmount, MaxAmount: &usd})
+	if err != models.ErrCurrencyMismatch {
+		t.Errorf("Mixed currency range returned %v, want ErrCurrencyMismatch", err)
+	}
+
+	_, err = transactionQueryFilter("wallet_id", walletID, models.TransactionQuery{Cursor: "garbage"})
+	if err != ErrInvalidCursor {
+		t.Errorf("Invalid cursor returned %v, want ErrInvalidCursor", err)
+	}
+
+	cursor := encodeTransactionCursor(models.Transaction{ID: primitive.NewObjectID(), CreatedAt: time.Now()})
+	for descending, operator := range map[bool]string{false: "$gt", true: "$lt"} {
+		filter, err := transactionQueryFilter("wallet_id", walletID, models.TransactionQuery{Cursor: cursor, Descending: descending})
+		if err != nil {
+			t.Fatal(err)
+		}
+		after := filter["$or"].(bson.A)[0].(bson.M)["created_at"].(bson.M)
+		if _, ok := after[operator]; !ok {
+			t.Errorf("Descending=%v pages with %v, want %s", descending, after, operator)
+		}
+	}
+}
+
+func TestListTransactionsPages(t *testing.T) {
+	client := testMongoClient(t)
+	walletID := primitive.NewObjectID()
+	start := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	for i := 0; i < 5; i++ {
+		transactionType := models.Credit
+		if i%2 == 1 {
+			transactionType = models.Debit
+		}
+		_, err := transactionsCollection(client).InsertOne(context.Background(), models.Transaction{
+			ID:        primitive.NewObjectID(),
+			WalletID:  walletID,
+			Type:      transactionType,
+			Amount:    models.NewMoney(int64(i+1)*100, models.DefaultCurrency),
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, descending := range []bool{false, true} {
+		var seen []models.Money
+		query := models.TransactionQuery{Limit: 2, Descending: descending}
+		for pages := 0; ; pages++ {
+			if pages > 3 {
+				t.Fatal("Paging did not stop")
+			}
+			page, err := ListWalletTransactions(client, walletID, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, transaction := range page.Transactions {
+				seen = append(seen, transaction.Amount)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		if len(seen) != 5 {
+			t.Fatalf("Descending=%v returned %d entries, want 5", descending, len(seen))
+		}
+		for i := 1; i < len(seen); i++ {
+			cmp, _ := seen[i-1].Cmp(seen[i])
+			if (cmp > 0) != descending {
+				t.Errorf("Descending=%v returned %v out of order", descending, seen)
+				break
+			}
+		}
+	}
+
+	minAmount := models.MustParseMoney("2.00")
+	page, err := ListWalletTransactions(client, walletID, models.TransactionQuery{
+		Types:     []models.TransactionType{models.Debit},
+		MinAmount: &minAmount,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Transactions) != 2 {
+		t.Errorf("Filter returned %d debits of at least 2.00, want 2", len(page.Transactions))
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"mfus_WalletTransactionManager/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrSameWallet                = errors.New("Source and destination wallets must be different")
+	ErrSourceWalletNotFound      = errors.New("Source wallet not found")
+	ErrDestinationWalletNotFound = errors.New("Destination wallet not found")
+)
+
+// Transfer moves funds between two virtual wallets inside a single MongoDB transaction.
+// The debit and the credit are written as a linked pair of transactions sharing one transfer ID,
+// so either both wallets change or neither does.
+func Transfer(client *mongo.Client, sourceWalletID, destinationWalletID primitive.ObjectID, amount models.Money, reference string) (*models.Transfer, error) {
+	return transfer(client, sourceWalletID, destinationWalletID, amount, reference, false)
+}
+
+// SystemTransfer is a Transfer initiated by the platform itself, e.g. settlement through a TransitWallet
+func SystemTransfer(client *mongo.Client, sourceWalletID, destinationWalletID primitive.ObjectID, amount models.Money, reference string) (*models.Transfer, error) {
+	return transfer(client, sourceWalletID, destinationWalletID, amount, reference, true)
+}
+
+func transfer(client *mongo.Client, sourceWalletID, destinationWalletID primitive.ObjectID, amount models.Money, reference string, system bool) (*models.Transfer, error) {
+	if !amount.IsPositive() {
+		return nil, errors.New("Transfer amount must be positive")
+	}
+	if sourceWalletID == destinationWalletID {
+		return nil, ErrSameWallet
+	}
+
+	transfer := &models.Transfer{
+		ID:                  primitive.NewObjectID(),
+		SourceWalletID:      sourceWalletID,
+		DestinationWalletID: destinationWalletID,
+		Amount:              amount,
+		Reference:           reference,
+		DebitTransactionID:  primitive.NewObjectID(),
+		CreditTransactionID: primitive.NewObjectID(),
+	}
+
+	err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+		return applyTransfer(ctx, client, transfer, system)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// Helper function to write both legs of a transfer using the session context of the surrounding transaction
+func applyTransfer(ctx mongo.SessionContext, client *mongo.Client, transfer *models.Transfer, system bool) error {
+	collection := client.Database("walletManager").Collection("virtual_wallets")
+	now := time.Now()
+
+	// Load both wallets to apply their wallet type policies
+	var source, destination models.VirtualWallet
+	if err := collection.FindOne(ctx, bson.M{"_id": transfer.SourceWalletID}).Decode(&source); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrSourceWalletNotFound
+		}
+		return err
+	}
+	if err := collection.FindOne(ctx, bson.M{"_id": transfer.DestinationWalletID}).Decode(&destination); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrDestinationWalletNotFound
+		}
+		return err
+	}
+	// Transfers are never converted, so both wallets must hold the currency of the amount
+	if source.Balance.CurrencyCode() != transfer.Amount.CurrencyCode() || destination.Balance.CurrencyCode() != transfer.Amount.CurrencyCode() {
+		return models.ErrCurrencyMismatch
+	}
+	sourcePolicy := WalletPolicyFor(&source)
+	if !system && (sourcePolicy.SystemTransfersOnly || WalletPolicyFor(&destination).SystemTransfersOnly) {
+		return ErrSystemTransfersOnly
+	}
+	required, err := requiredBalance(sourcePolicy, transfer.Amount)
+	if err != nil {
+		return err
+	}
+
+	debit := models.Transaction{
+		ID:         transfer.DebitTransactionID,
+		WalletID:   transfer.SourceWalletID,
+		Type:       models.TransferOut,
+		Amount:     transfer.Amount,
+		Reference:  transfer.Reference,
+		TransferID: transfer.ID,
+		CreatedAt:  now,
+	}
+	credit := models.Transaction{
+		ID:         transfer.CreditTransactionID,
+		WalletID:   transfer.DestinationWalletID,
+		Type:       models.TransferIn,
+		Amount:     transfer.Amount,
+		Reference:  transfer.Reference,
+		TransferID: transfer.ID,
+		CreatedAt:  now,
+	}
+
+	// Transfers started by a customer count towards the limits of the source wallet's account
+	if !system {
+		if err := applyTransactionLimits(ctx, client, debit); err != nil {
+			return err
+		}
+	}
+
+	// Debit the source wallet only if it still holds enough funds
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": transfer.SourceWalletID, "balance.units": bson.M{"$gte": required.Units}},
+		bson.M{
+			"$inc": bson.M{"balance.units": -transfer.Amount.Units},
+			"$set": bson.M{"date_modified": now},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInsufficientFunds
+	}
+	if err := insertTransaction(ctx, client, debit); err != nil {
+		return err
+	}
+
+	// Credit the destination wallet
+	result, err = collection.UpdateOne(ctx,
+		bson.M{"_id": transfer.DestinationWalletID},
+		bson.M{
+			"$inc": bson.M{"balance.units": transfer.Amount.Units},
+			"$set": bson.M{"date_modified": now},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrDestinationWalletNotFound
+	}
+
+	return insertTransaction(ctx, client, credit)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"mfus_WalletTransactionManager/models"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Helper function to compare the balances of wallets with the expected amounts
+func assertWalletBalances(t *testing.T, client *mongo.Client, expected map[primitive.ObjectID]string) {
+	t.Helper()
+	for walletID, want := range expected {
+		virtualWallet, err := FindVirtualWallet(client, walletID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if virtualWallet.Balance != models.MustParseMoney(want) {
+			t.Errorf("wallet %s balance = %v, want %s", walletID.Hex(), virtualWallet.Balance, want)
+		}
+	}
+}
+
+// Helper function to count the ledger entries of one type
+func countEntries(t *testing.T, client *mongo.Client, transactionType models.TransactionType) int64 {
+	t.Helper()
+	count, err := transactionsCollection(client).CountDocuments(context.Background(), bson.M{"type": transactionType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// Concurrent transfers from one wallet move exactly what it holds, and every debit leg has its credit leg
+func TestParallelTransfersAreAtomic(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	sourceID := createWallet(t, client, accountID, models.CashWallet, "100.00")
+	destinationID := createWallet(t, client, accountID, models.CashWallet, "0.00")
+
+	// The source covers exactly four transfers of 25.00
+	const requests = 10
+	const expectedTransfers = 4
+	start := make(chan struct{})
+	errs := make(chan error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := Transfer(client, sourceID, destinationID, models.MustParseMoney("25.00"), "split")
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			succeeded++
+		case ErrInsufficientFunds:
+		default:
+			t.Errorf("Transfer: %v", err)
+		}
+	}
+	if succeeded != expectedTransfers {
+		t.Errorf("%d transfers succeeded, want %d", succeeded, expectedTransfers)
+	}
+	assertWalletBalances(t, client, map[primitive.ObjectID]string{sourceID: "0.00", destinationID: "100.00"})
+	if out, in := countEntries(t, client, models.TransferOut), countEntries(t, client, models.TransferIn); out != expectedTransfers || in != expectedTransfers {
+		t.Errorf("ledger holds %d transfer_out and %d transfer_in entries, want %d of each", out, in, expectedTransfers)
+	}
+}
+
+// When the credit leg fails after the debit leg was written, neither leg is kept
+func TestTransferRollsBackFailedCreditLeg(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	sourceID := createWallet(t, client, accountID, models.CashWallet, "0.00")
+	destinationID := createWallet(t, client, accountID, models.CashWallet, "0.00")
+	if err := CreateVirtualWalletTransaction(client, sourceID, "", models.Deposit, models.MustParseMoney("100.00")); err != nil {
+		t.Fatal(err)
+	}
+	var deposit models.Transaction
+	if err := transactionsCollection(client).FindOne(context.Background(), bson.M{"wallet_id": sourceID}).Decode(&deposit); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reusing the deposit's ID makes the credit leg's ledger insert fail after both balances were updated
+	transfer := &models.Transfer{
+		ID:                  primitive.NewObjectID(),
+		SourceWalletID:      sourceID,
+		DestinationWalletID: destinationID,
+		Amount:              models.MustParseMoney("40.00"),
+		DebitTransactionID:  primitive.NewObjectID(),
+		CreditTransactionID: deposit.ID,
+	}
+	err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+		return applyTransfer(ctx, client, transfer, false)
+	})
+	if !mongo.IsDuplicateKeyError(err) {
+		t.Fatalf("transfer with a failing credit leg returned %v, want a duplicate key error", err)
+	}
+
+	assertWalletBalances(t, client, map[primitive.ObjectID]string{sourceID: "100.00", destinationID: "0.00"})
+	count, err := transactionsCollection(client).CountDocuments(context.Background(), bson.M{"transfer_id": transfer.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("ledger holds %d entries of the failed transfer, want none", count)
+	}
+	// Neither is the debit counted towards the account limits
+	var usage models.LimitUsage
+	err = limitUsageCollection(client).FindOne(context.Background(), bson.M{"_id": accountID}).Decode(&usage)
+	if err != nil && err != mongo.ErrNoDocuments {
+		t.Fatal(err)
+	}
+	if err == nil && !usage.DayWithdrawn.IsZero() {
+		t.Errorf("daily usage = %v, want nothing withdrawn", usage.DayWithdrawn)
+	}
+}
+
+// A transient transaction error retries the whole transfer, which is applied once
+func TestTransferRetriesTransientErrors(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	sourceID := createWallet(t, client, accountID, models.CashWallet, "100.00")
+	destinationID := createWallet(t, client, accountID, models.CashWallet, "0.00")
+	transfer := &models.Transfer{
+		ID:                  primitive.NewObjectID(),
+		SourceWalletID:      sourceID,
+		DestinationWalletID: destinationID,
+		Amount:              models.MustParseMoney("40.00"),
+		DebitTransactionID:  primitive.NewObjectID(),
+		CreditTransactionID: primitive.NewObjectID(),
+	}
+
+	attempts := 0
+	err := runInTransaction(client, func(ctx mongo.SessionContext) error {
+		attempts++
+		if err := applyTransfer(ctx, client, transfer, false); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			// Like a write conflict reported by the server after both legs were written
+			return mongo.CommandError{Code: 112, Name: "WriteConflict", Labels: []string{"TransientTransactionError"}}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transfer after a transient error: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("transfer ran %d times, want 2", attempts)
+	}
+
+	assertWalletBalances(t, client, map[primitive.ObjectID]string{sourceID: "60.00", destinationID: "40.00"})
+	count, err := transactionsCollection(client).CountDocuments(context.Background(), bson.M{"transfer_id": transfer.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("ledger holds %d entries of the transfer, want its two legs", count)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"mfus_WalletTransactionManager/models"
+)
+
+var (
+	ErrCashWithdrawalNotAllowed = errors.New("This wallet type cannot be withdrawn to cash")
+	ErrHoldsNotAllowed          = errors.New("This wallet type does not support holds")
+	ErrSystemTransfersOnly      = errors.New("This wallet type only accepts system transfers")
+	ErrCreditLimitNotAllowed    = errors.New("Only a CreditWallet can have a credit limit")
+	ErrInvalidWalletType        = errors.New("Invalid wallet type")
+)
+
+// Credit limit of a CreditWallet created without one. Set at startup with SetDefaultCreditLimit.
+var defaultCreditLimit models.Money
+
+// SetDefaultCreditLimit configures the credit limit new CreditWallets in the limit's currency are given
+func SetDefaultCreditLimit(limit models.Money) {
+	defaultCreditLimit = limit
+}
+
+// WalletPolicyFor returns the behaviour of a wallet based on its type.
+// Wallets created before wallet types were enforced have no type and behave like a CashWallet.
+func WalletPolicyFor(virtualWallet *models.VirtualWallet) models.WalletPolicy {
+	walletType := virtualWallet.WalletType
+	if walletType == "" {
+		walletType = models.CashWallet
+	}
+	policy := models.WalletPolicy{
+		WalletType:          walletType,
+		CreditLimit:         models.NewMoney(0, virtualWallet.Balance.CurrencyCode()),
+		AllowCashWithdrawal: true,
+		AllowHolds:          true,
+	}
+
+	switch walletType {
+	case models.CreditWallet:
+		// May go negative down to the credit limit
+		if !virtualWallet.CreditLimit.IsZero() {
+			policy.CreditLimit = virtualWallet.CreditLimit
+		}
+	case models.RewardWallet:
+		// Rewards can be spent through transfers but never paid out as cash
+		policy.AllowCashWithdrawal = false
+	case models.TransitWallet:
+		// Settlement wallet moved only by the system
+		policy.AllowCashWithdrawal = false
+		policy.AllowHolds = false
+		policy.SystemTransfersOnly = true
+	}
+
+	return policy
+}
+
+// Helper function to check a direct wallet transaction against the wallet policy
+func checkWalletPolicy(policy models.WalletPolicy, transactionType models.TransactionType) error {
+	if policy.SystemTransfersOnly {
+		return ErrSystemTransfersOnly
+	}
+	switch transactionType {
+	case models.Withdraw, models.Debit:
+		if !policy.AllowCashWithdrawal {
+			return ErrCashWithdrawalNotAllowed
+		}
+	case models.Hold, models.Release:
+		if !policy.AllowHolds {
+			return ErrHoldsNotAllowed
+		}
+	}
+	return nil
+}
+
+// Helper function returning the balance a wallet must hold before amount can be taken from it.
+// For a CreditWallet this is reduced by the credit limit, allowing the balance to go negative.
+func requiredBalance(policy models.WalletPolicy, amount models.Money) (models.Money, error) {
+	return amount.Sub(policy.CreditLimit)
+}
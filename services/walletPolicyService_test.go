@@ -0,0 +1,109 @@
+package services
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCheckWalletPolicy(t *testing.T) {
+	tests := []struct {
+		walletType      models.WalletType
+		transactionType models.TransactionType
+		want            error
+	}{
+		{walletType: "", transactionType: models.Withdraw, want: nil},
+		{walletType: models.CashWallet, transactionType: models.Hold, want: nil},
+		{walletType: models.CreditWallet, transactionType: models.Debit, want: nil},
+		{walletType: models.RewardWallet, transactionType: models.Credit, want: nil},
+		{walletType: models.RewardWallet, transactionType: models.Withdraw, want: ErrCashWithdrawalNotAllowed},
+		{walletType: models.TradeWallet, transactionType: models.Debit, want: nil},
+		{walletType: models.TransitWallet, transactionType: models.Deposit, want: ErrSystemTransfersOnly},
+	}
+	for _, test := range tests {
+		policy := WalletPolicyFor(&models.VirtualWallet{WalletType: test.walletType, Balance: models.MustParseMoney("0")})
+		if err := checkWalletPolicy(policy, test.transactionType); err != test.want {
+			t.Errorf("%q %s: error = %v, want %v", test.walletType, test.transactionType, err, test.want)
+		}
+	}
+}
+
+func TestRequiredBalance(t *testing.T) {
+	credit := WalletPolicyFor(&models.VirtualWallet{
+		WalletType:  models.CreditWallet,
+		Balance:     models.MustParseMoney("0"),
+		CreditLimit: models.MustParseMoney("50.00"),
+	})
+	required, err := requiredBalance(credit, models.MustParseMoney("80.00"))
+	if err != nil || required != models.MustParseMoney("30.00") {
+		t.Errorf("CreditWallet requires %v, %v; want 30.00", required, err)
+	}
+
+	// Only a CreditWallet takes its credit limit into account
+	cash := WalletPolicyFor(&models.VirtualWallet{
+		WalletType:  models.CashWallet,
+		Balance:     models.MustParseMoney("0"),
+		CreditLimit: models.MustParseMoney("50.00"),
+	})
+	required, err = requiredBalance(cash, models.MustParseMoney("80.00"))
+	if err != nil || required != models.MustParseMoney("80.00") {
+		t.Errorf("CashWallet requires %v, %v; want 80.00", required, err)
+	}
+}
+
+func TestCreditWalletStopsAtCreditLimit(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	now := time.Now()
+	walletID, err := CreateVirtualWallet(client, models.VirtualWallet{
+		CustomerID:   accountID.Hex(),
+		WalletType:   models.CreditWallet,
+		Balance:      models.MustParseMoney("10.00"),
+		CreditLimit:  models.MustParseMoney("50.00"),
+		DateCreated:  now,
+		DateModified: now,
+	})
+	if err != nil {
+		t.Fatalf("CreateVirtualWallet: %v", err)
+	}
+
+	if err := CreateVirtualWalletTransaction(client, walletID, "", models.Debit, models.MustParseMoney("60.00")); err != nil {
+		t.Fatalf("debit within the credit limit: %v", err)
+	}
+	if err := CreateVirtualWalletTransaction(client, walletID, "", models.Debit, models.MustParseMoney("0.01")); err != ErrInsufficientFunds {
+		t.Errorf("debit beyond the credit limit returned %v, want ErrInsufficientFunds", err)
+	}
+	virtualWallet, err := FindVirtualWallet(client, walletID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if virtualWallet.Balance != models.MustParseMoney("-50.00") {
+		t.Errorf("balance = %v, want -50.00", virtualWallet.Balance)
+	}
+
+	rewardID := createWallet(t, client, accountID, models.RewardWallet, "10.00")
+	if err := CreateVirtualWalletTransaction(client, rewardID, "", models.Withdraw, models.MustParseMoney("1.00")); err != ErrCashWithdrawalNotAllowed {
+		t.Errorf("RewardWallet withdrawal returned %v, want ErrCashWithdrawalNotAllowed", err)
+	}
+}
+
+func TestCreditWalletDefaultCreditLimit(t *testing.T) {
+	client := testMongoClient(t)
+	SetDefaultCreditLimit(models.MustParseMoney("25.00"))
+	defer SetDefaultCreditLimit(models.Money{})
+	accountID := createFundedAccount(t, client, "0.00")
+
+	creditID := createWallet(t, client, accountID, models.CreditWallet, "0.00")
+	cashID := createWallet(t, client, accountID, models.CashWallet, "0.00")
+	for walletID, want := range map[primitive.ObjectID]string{creditID: "25.00", cashID: "0"} {
+		virtualWallet, err := FindVirtualWallet(client, walletID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if virtualWallet.CreditLimit != models.MustParseMoney(want) {
+			t.Errorf("%s credit limit = %v, want %s", virtualWallet.WalletType, virtualWallet.CreditLimit, want)
+		}
+	}
+}
@@ -12,9 +12,23 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Helper function for finding a virtual wallet document by ID and optionally retrieving its transactions
+var (
+	ErrInsufficientFunds = errors.New("Insufficient funds")
+	ErrConcurrentUpdate  = errors.New("Wallet was modified by a concurrent request, please retry")
+)
+
+// Helper function returning the error of a failed funds check. A currency mismatch is reported as is.
+func insufficientFunds(err error) error {
+	if err != nil {
+		return err
+	}
+	return ErrInsufficientFunds
+}
+
+// Helper function for finding a virtual wallet document by ID and optionally projecting a single field.
+// Transaction history lives in the transactions collection, see ListWalletTransactions.
 func FindVirtualWallet(client *mongo.Client, virtualWalletID primitive.ObjectID, fields string) (*models.VirtualWallet, error) {
-	// Define projection to include transaction field if specified
+	// Define projection to include the requested field if specified
 	projection := bson.M{}
 	if fields != "" {
 		projection[fields] = 1
@@ -29,90 +43,350 @@ func FindVirtualWallet(client *mongo.Client, virtualWalletID primitive.ObjectID,
 	if err != nil {
 		return nil, err
 	}
+	virtualWallet.Policy = WalletPolicyFor(&virtualWallet)
 
 	return &virtualWallet, nil
 }
 
-// Helper function to create a new virtual wallet transaction and update virtual wallet balance
-func CreateVirtualWalletTransaction(client *mongo.Client, virtualWalletID primitive.ObjectID, customerID string, transactionType models.TransactionType, amount float64) error {
-	// Find virtual wallet document in database
-	virtualWallet, err := FindVirtualWallet(client, virtualWalletID, customerID)
+// Helper function to create a new virtual wallet transaction and update virtual wallet balance.
+// The balance change is a conditional update whose filter re-checks the funds being taken, so concurrent
+// requests can never overdraw the wallet. It runs in the same MongoDB transaction as the ledger insert.
+func CreateVirtualWalletTransaction(client *mongo.Client, virtualWalletID primitive.ObjectID, customerID string, transactionType models.TransactionType, amount models.Money) error {
+	posting, err := prepareWalletPosting(client, virtualWalletID, customerID, transactionType, amount, "")
 	if err != nil {
 		return err
 	}
+	return runInTransaction(client, posting.apply)
+}
 
-	/* 	switch transactionType {
-	   	case "debit":
-	   		transactionType = "Deposit"
-	   	case "credit":
-	   		transactionType = "withdraw"
-	   	default:
-	   		errors.New("Invalid transaction type") */
+// walletPosting is a validated wallet transaction ready to be written
+type walletPosting struct {
+	client      *mongo.Client
+	customerID  string
+	transaction models.Transaction
+	guard       bson.M
+	update      bson.M
+	fee         models.Money
+}
 
-	// Create new transaction document
+// Helper function to validate a wallet transaction against the wallet's current state and build its update.
+// The funds check is repeated by the guarded update when the posting is applied.
+func prepareWalletPosting(client *mongo.Client, virtualWalletID primitive.ObjectID, customerID string, transactionType models.TransactionType, amount models.Money, reference string) (*walletPosting, error) {
+	if !amount.IsPositive() {
+		return nil, errors.New("Transaction amount must be positive")
+	}
+
+	// Find virtual wallet document in database
+	virtualWallet, err := FindVirtualWallet(client, virtualWalletID, "")
+	if err != nil {
+		return nil, err
+	}
+	if customerID != "" && virtualWallet.CustomerID != customerID {
+		return nil, mongo.ErrNoDocuments
+	}
+	// Postings are never converted, so the amount must be in the wallet's currency
+	if amount.CurrencyCode() != virtualWallet.Balance.CurrencyCode() {
+		return nil, models.ErrCurrencyMismatch
+	}
+
+	// Enforce the behaviour of the wallet type
+	policy := WalletPolicyFor(virtualWallet)
+	if err := checkWalletPolicy(policy, transactionType); err != nil {
+		return nil, err
+	}
+	required, err := requiredBalance(policy, amount)
+	if err != nil {
+		return nil, err
+	}
 
+	// Look up the fee of the matching fee schedule, if any
+	fee, err := walletTransactionFee(client, virtualWallet, transactionType, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create new transaction document
 	newTransaction := models.Transaction{
+		ID:        primitive.NewObjectID(),
+		WalletID:  virtualWalletID,
 		Type:      transactionType,
 		Amount:    amount,
+		Reference: reference,
 		CreatedAt: time.Now(),
 	}
 
 	// Build update query
 	update := bson.M{
-		"$push": bson.M{"transactions": newTransaction},
+		"$set": bson.M{"date_modified": newTransaction.CreatedAt},
 	}
+	guard := bson.M{}
 
 	// Update virtual wallet document based on transaction type
 	switch transactionType {
-	case "deposit":
-		update["$inc"] = bson.M{"balance": amount}
+	case models.Deposit, models.Credit:
+		net, err := amount.Sub(fee)
+		if err != nil {
+			return nil, err
+		}
+		if net.IsNegative() {
+			// A fee larger than the deposit is taken from the existing balance
+			required, err = requiredBalance(policy, net.Neg())
+			if err != nil {
+				return nil, err
+			}
+			if cmp, err := virtualWallet.Balance.Cmp(required); err != nil || cmp < 0 {
+				return nil, insufficientFunds(err)
+			}
+			guard["balance.units"] = bson.M{"$gte": required.Units}
+		}
+
+		update["$inc"] = bson.M{"balance.units": net.Units}
 
-	case "withdraw":
-		if virtualWallet.Balance < amount {
-			return errors.New("Insufficient funds")
+	case models.Withdraw, models.Debit:
+		total, err := amount.Add(fee)
+		if err != nil {
+			return nil, err
+		}
+		required, err = requiredBalance(policy, total)
+		if err != nil {
+			return nil, err
+		}
+		if cmp, err := virtualWallet.Balance.Cmp(required); err != nil || cmp < 0 {
+			return nil, insufficientFunds(err)
 		}
 
-		update["$inc"] = bson.M{"balance": -amount}
+		update["$inc"] = bson.M{"balance.units": -total.Units}
+		guard["balance.units"] = bson.M{"$gte": required.Units}
 
-	case "hold":
-		if virtualWallet.Balance < amount {
-			return errors.New("Insufficient funds")
+	case models.Hold:
+		if cmp, err := virtualWallet.Balance.Cmp(required); err != nil || cmp < 0 {
+			return nil, insufficientFunds(err)
 		}
 
-		update["$inc"] = bson.M{"balance": -amount, "hold_balance": amount}
+		update["$inc"] = bson.M{"balance.units": -amount.Units, "hold_balance.units": amount.Units}
+		guard["balance.units"] = bson.M{"$gte": required.Units}
 
-	case "release":
-		if virtualWallet.HoldBalance < amount {
-			return errors.New("Invalid amount to release")
+	case models.Release:
+		if cmp, err := virtualWallet.HoldBalance.Cmp(amount); err != nil || cmp < 0 {
+			return nil, errors.New("Invalid amount to release")
 		}
 
-		update["$inc"] = bson.M{"balance": amount, "hold_balance": -amount}
+		update["$inc"] = bson.M{"balance.units": amount.Units, "hold_balance.units": -amount.Units}
+		guard["hold_balance.units"] = bson.M{"$gte": amount.Units}
 
 	default:
-		return errors.New("Invalid transaction type")
+		return nil, errors.New("Invalid transaction type")
 	}
 
-	// Update virtual wallet document in database
-	err = UpdateVirtualWallet(client, virtualWalletID, customerID, update)
+	return &walletPosting{
+		client:      client,
+		customerID:  customerID,
+		transaction: newTransaction,
+		guard:       guard,
+		update:      update,
+		fee:         fee,
+	}, nil
+}
+
+// Helper function to check the account limits, update the wallet balance, append the ledger entry and book
+// the fee. Must run inside a MongoDB transaction so the writes are atomic.
+func (p *walletPosting) apply(ctx mongo.SessionContext) error {
+	if err := applyTransactionLimits(ctx, p.client, p.transaction); err != nil {
+		return err
+	}
+	err := UpdateVirtualWallet(ctx, p.client, p.transaction.WalletID, p.customerID, p.guard, p.update)
 	if err != nil {
 		return err
 	}
-
-	return nil
+	if err := insertTransaction(ctx, p.client, p.transaction); err != nil {
+		return err
+	}
+	if !p.fee.IsPositive() {
+		return nil
+	}
+	return bookFee(ctx, p.client, feeTransaction(p.transaction, p.fee))
 }
 
-// Helper function to update virtual wallet document in database by ID and customer ID
-func UpdateVirtualWallet(client *mongo.Client, virtualWalletID primitive.ObjectID, customerID string, update bson.M) error {
+// Helper function to update virtual wallet document in database by ID and customer ID.
+// Extra guard conditions are added to the filter; if the document no longer satisfies them
+// ErrConcurrentUpdate is returned and nothing is written.
+func UpdateVirtualWallet(ctx context.Context, client *mongo.Client, virtualWalletID primitive.ObjectID, customerID string, guard bson.M, update bson.M) error {
 	// Build filter query
 	filter := bson.M{"_id": virtualWalletID}
 	if customerID != "" {
 		filter["customer_id"] = customerID
 	}
+	for field, condition := range guard {
+		filter[field] = condition
+	}
 	// Update virtual wallet document in database
-	_, err := client.Database("walletManager").Collection("virtual_wallets").UpdateOne(context.Background(), filter, update)
+	result, err := client.Database("walletManager").Collection("virtual_wallets").UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		if len(guard) == 0 {
+			return mongo.ErrNoDocuments
+		}
+		return ErrConcurrentUpdate
+	}
+
+	return nil
+}
+
+// SetVirtualWalletBalance sets the balance of a wallet on behalf of an admin. The difference to the current
+// balance is posted as an adjustment entry with the manual balance edit reason code, so the ledger keeps explaining
+// the stored balance and reconciliation reports no drift.
+func SetVirtualWalletBalance(client *mongo.Client, virtualWalletID primitive.ObjectID, balance models.Money) error {
+	return runInTransaction(client, func(ctx mongo.SessionContext) error {
+		var virtualWallet models.VirtualWallet
+		err := client.Database("walletManager").Collection("virtual_wallets").FindOne(ctx, bson.M{"_id": virtualWalletID}).Decode(&virtualWallet)
+		if err != nil {
+			return err
+		}
+		// The currency of a wallet never changes
+		if virtualWallet.Balance.CurrencyCode() != balance.CurrencyCode() {
+			return models.ErrCurrencyMismatch
+		}
+		difference, err := balance.Sub(virtualWallet.Balance)
+		if err != nil {
+			return err
+		}
+		if difference.IsZero() {
+			return nil
+		}
+
+		adjustment := models.Transaction{
+			ID:         primitive.NewObjectID(),
+			WalletID:   virtualWalletID,
+			Type:       models.AdjustmentCredit,
+			Amount:     difference,
+			ReasonCode: models.ReasonManualBalanceEdit,
+			CreatedAt:  time.Now(),
+		}
+		if difference.IsNegative() {
+			adjustment.Type = models.AdjustmentDebit
+			adjustment.Amount = difference.Neg()
+		}
+		// Only change the balance it was computed from, so concurrent transactions are not overwritten
+		err = UpdateVirtualWallet(ctx, client, virtualWalletID, "",
+			bson.M{"balance.units": virtualWallet.Balance.Units},
+			bson.M{
+				"$inc": bson.M{"balance.units": difference.Units},
+				"$set": bson.M{"date_modified": adjustment.CreatedAt},
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if err := insertTransaction(ctx, client, adjustment); err != nil {
+			return err
+		}
+		virtualWallet.Balance = balance
+		virtualWallet.DateModified = adjustment.CreatedAt
+		return recordEvent(ctx, client, models.EventBalanceSet, virtualWalletID, virtualWallet.CustomerID, virtualWallet)
+	})
+}
+
+var ErrWalletNotOwned = errors.New("Virtual wallet does not belong to this account")
+
+// CreateVirtualWallet inserts a wallet for an existing account. The customer ID of the wallet is the
+// owning account's ID, and the wallet ID is added to Account.VirtualWallets in the same MongoDB transaction.
+func CreateVirtualWallet(client *mongo.Client, virtualWallet models.VirtualWallet) (primitive.ObjectID, error) {
+	accountID, err := primitive.ObjectIDFromHex(virtualWallet.CustomerID)
+	if err != nil {
+		return primitive.NilObjectID, ErrAccountNotFound
+	}
+	if virtualWallet.ID.IsZero() {
+		virtualWallet.ID = primitive.NewObjectID()
+	}
+	// The currency of the opening balance is the currency of the wallet
+	currency := virtualWallet.Balance.CurrencyCode()
+	if !virtualWallet.CreditLimit.IsZero() && virtualWallet.CreditLimit.CurrencyCode() != currency {
+		return primitive.NilObjectID, models.ErrCurrencyMismatch
+	}
+	// Credit wallets get the configured credit limit unless an operator chose one
+	if virtualWallet.WalletType == models.CreditWallet && virtualWallet.CreditLimit.IsZero() && defaultCreditLimit.CurrencyCode() == currency {
+		virtualWallet.CreditLimit = defaultCreditLimit
+	}
+	virtualWallet.Balance = models.NewMoney(virtualWallet.Balance.Units, currency)
+	virtualWallet.HoldBalance = models.NewMoney(0, currency)
+	virtualWallet.CreditLimit = models.NewMoney(virtualWallet.CreditLimit.Units, currency)
+
+	err = runInTransaction(client, func(ctx mongo.SessionContext) error {
+		result, err := client.Database("walletManager").Collection("accounts").UpdateOne(ctx,
+			bson.M{"_id": accountID},
+			bson.M{
+				"$addToSet": bson.M{"virtual_wallets": virtualWallet.ID.Hex()},
+				"$set":      bson.M{"date_modified": time.Now()},
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrAccountNotFound
+		}
+		_, err = client.Database("walletManager").Collection("virtual_wallets").InsertOne(ctx, virtualWallet)
+		if err != nil {
+			return err
+		}
+		// The opening balance is not in the ledger, so record it for point-in-time queries
+		err = insertBalanceSnapshot(ctx, client, models.BalanceSnapshot{
+			WalletID:    virtualWallet.ID,
+			Balance:     virtualWallet.Balance,
+			HoldBalance: virtualWallet.HoldBalance,
+			TakenAt:     virtualWallet.DateCreated,
+		})
+		if err != nil {
+			return err
+		}
+		return recordEvent(ctx, client, models.EventWalletCreated, virtualWallet.ID, virtualWallet.CustomerID, virtualWallet)
+	})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return virtualWallet.ID, nil
+}
 
+// DeleteVirtualWallet removes a wallet and unlinks it from its owning account in one MongoDB transaction
+func DeleteVirtualWallet(client *mongo.Client, virtualWalletID primitive.ObjectID) error {
+	return runInTransaction(client, func(ctx mongo.SessionContext) error {
+		var virtualWallet models.VirtualWallet
+		err := client.Database("walletManager").Collection("virtual_wallets").FindOneAndDelete(ctx, bson.M{"_id": virtualWalletID}).Decode(&virtualWallet)
+		if err != nil {
+			return err
+		}
+		err = recordEvent(ctx, client, models.EventWalletDeleted, virtualWalletID, virtualWallet.CustomerID, virtualWallet)
+		if err != nil {
+			return err
+		}
+		accountID, err := primitive.ObjectIDFromHex(virtualWallet.CustomerID)
+		if err != nil {
+			// Wallets created before accounts were linked may not reference an account
+			return nil
+		}
+		_, err = client.Database("walletManager").Collection("accounts").UpdateOne(ctx,
+			bson.M{"_id": accountID},
+			bson.M{
+				"$pull": bson.M{"virtual_wallets": virtualWalletID.Hex()},
+				"$set":  bson.M{"date_modified": time.Now()},
+			},
+		)
+		return err
+	})
+}
+
+// VerifyWalletOwner checks that a virtual wallet belongs to the given account.
+// It returns mongo.ErrNoDocuments if the wallet does not exist and ErrWalletNotOwned if it belongs to someone else.
+func VerifyWalletOwner(client *mongo.Client, virtualWalletID primitive.ObjectID, accountID string) error {
+	virtualWallet, err := FindVirtualWallet(client, virtualWalletID, "customer_id")
+	if err != nil {
+		return err
+	}
+	if virtualWallet.CustomerID != accountID {
+		return ErrWalletNotOwned
+	}
 	return nil
 }
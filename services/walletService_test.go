@@ -0,0 +1,72 @@
+package services
+
+import (
+	"mfus_WalletTransactionManager/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Helper function to create a wallet of the given type for an account
+func createWallet(t *testing.T, client *mongo.Client, accountID primitive.ObjectID, walletType models.WalletType, balance string) primitive.ObjectID {
+	t.Helper()
+	now := time.Now()
+	walletID, err := CreateVirtualWallet(client, models.VirtualWallet{
+		CustomerID:   accountID.Hex(),
+		WalletType:   walletType,
+		Balance:      models.MustParseMoney(balance),
+		DateCreated:  now,
+		DateModified: now,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	return walletID
+}
+
+func TestCreateVirtualWalletRequiresAccount(t *testing.T) {
+	client := testMongoClient(t)
+	for _, customerID := range []string{"not-an-id", primitive.NewObjectID().Hex()} {
+		_, err := CreateVirtualWallet(client, models.VirtualWallet{CustomerID: customerID, Balance: models.MustParseMoney("1.00")})
+		if err != ErrAccountNotFound {
+			t.Errorf("customer %q: error = %v, want ErrAccountNotFound", customerID, err)
+		}
+	}
+}
+
+func TestVirtualWalletAccountLink(t *testing.T) {
+	client := testMongoClient(t)
+	accountID := createFundedAccount(t, client, "0.00")
+	walletID := createWallet(t, client, accountID, models.CashWallet, "10.00")
+
+	account, err := FindAccount(client, accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(account.VirtualWallets) != 1 || account.VirtualWallets[0] != walletID.Hex() {
+		t.Errorf("account wallets = %v, want [%s]", account.VirtualWallets, walletID.Hex())
+	}
+
+	if err := VerifyWalletOwner(client, walletID, accountID.Hex()); err != nil {
+		t.Errorf("owner check failed: %v", err)
+	}
+	if err := VerifyWalletOwner(client, walletID, primitive.NewObjectID().Hex()); err != ErrWalletNotOwned {
+		t.Errorf("check by another account returned %v, want ErrWalletNotOwned", err)
+	}
+	if err := VerifyWalletOwner(client, primitive.NewObjectID(), accountID.Hex()); err != mongo.ErrNoDocuments {
+		t.Errorf("check of an unknown wallet returned %v, want mongo.ErrNoDocuments", err)
+	}
+
+	if err := DeleteVirtualWallet(client, walletID); err != nil {
+		t.Fatalf("DeleteVirtualWallet: %v", err)
+	}
+	account, err = FindAccount(client, accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(account.VirtualWallets) != 0 {
+		t.Errorf("account wallets after delete = %v, want none", account.VirtualWallets)
+	}
+}
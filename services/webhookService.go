@@ -0,0 +1,495 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mfus_WalletTransactionManager/models"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidWebhookURL          = errors.New("Webhook URL must be an absolute http or https URL")
+	ErrWebhookHostNotAllowed      = errors.New("Webhook URL must resolve to public IP addresses only")
+	ErrInvalidEventType           = errors.New("Invalid event type")
+	ErrWebhookNotFound            = errors.New("Webhook subscription not found")
+	ErrWebhookDeliveryNotFound    = errors.New("Webhook delivery not found")
+	ErrWebhookDeliveryNotFinished = errors.New("Webhook delivery is still pending")
+)
+
+// Headers sent with every webhook delivery. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret, prefixed with "sha256=".
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+const (
+	// Failed deliveries are retried after 30s, 1m, 2m, ... and become dead letters after the last attempt
+	MaxWebhookAttempts = 8
+	webhookRetryBase   = 30 * time.Second
+	webhookRetryMax    = 6 * time.Hour
+	// How long a dispatcher owns a delivery it is posting before others may pick it up again
+	webhookLease    = time.Minute
+	webhookTimeout  = 10 * time.Second
+	webhookWorkers  = 4
+	maxWebhookError = 500
+)
+
+// Deliveries only connect to public addresses. The address is checked again when the connection is made,
+// so a host that resolved to a public address at subscribe time cannot be pointed at internal services later.
+// Proxies from the environment are not used, since the check would then only see the proxy's address.
+var webhookHTTPClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return ErrWebhookHostNotAllowed
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+	},
+}
+
+// Special-purpose address ranges that must never receive webhooks: this host, private and carrier-grade NAT
+// networks, documentation and benchmarking ranges, multicast, and translation prefixes such as NAT64, 6to4
+// and Teredo, which embed an IPv4 address that could itself be internal.
+var webhookDeniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/96"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("fec0::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// Helper function to check whether an address may receive webhooks. IPv4-mapped IPv6 addresses are
+// checked as the IPv4 address they carry.
+func isPublicIP(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range webhookDeniedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Helper function to resolve the host of a webhook URL and check that every address it resolves to is public
+func checkWebhookHost(ctx context.Context, host string) error {
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addresses) == 0 {
+		return ErrWebhookHostNotAllowed
+	}
+	for _, address := range addresses {
+		if !isPublicIP(address.IP) {
+			return ErrWebhookHostNotAllowed
+		}
+	}
+	return nil
+}
+
+// Wakes the dispatcher when new deliveries are queued so they do not wait for the next poll
+var webhookWakeup = make(chan struct{}, 1)
+
+func webhookSubscriptionsCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("webhook_subscriptions")
+}
+
+func webhookDeliveriesCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("walletManager").Collection("webhook_deliveries")
+}
+
+// EnsureWebhookIndexes creates the indexes used to match subscriptions and to find due deliveries
+func EnsureWebhookIndexes(client *mongo.Client) error {
+	_, err := webhookSubscriptionsCollection(client).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "customer_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = webhookDeliveriesCollection(client).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "subscription_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "event_id", Value: 1}, {Key: "subscription_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// CreateWebhookSubscription stores a subscription for the customer's events. The returned subscription includes the secret.
+func CreateWebhookSubscription(client *mongo.Client, customerID string, request models.CreateWebhookRequest) (*models.WebhookSubscription, error) {
+	target, err := url.Parse(request.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+	if err := checkWebhookHost(context.Background(), target.Hostname()); err != nil {
+		return nil, err
+	}
+	for _, eventType := range request.EventTypes {
+		if !eventType.IsValid() {
+			return nil, ErrInvalidEventType
+		}
+	}
+
+	subscription := &models.WebhookSubscription{
+		ID:         primitive.NewObjectID(),
+		CustomerID: customerID,
+		URL:        request.URL,
+		Secret:     request.Secret,
+		EventTypes: request.EventTypes,
+		CreatedAt:  time.Now(),
+	}
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = []models.EventType{}
+	}
+	if subscription.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		subscription.Secret = "whsec_" + hex.EncodeToString(secret)
+	}
+
+	if _, err := webhookSubscriptionsCollection(client).InsertOne(context.Background(), subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// ListWebhookSubscriptions returns the customer's subscriptions without their secrets
+func ListWebhookSubscriptions(client *mongo.Client, customerID string) ([]models.WebhookSubscription, error) {
+	cursor, err := webhookSubscriptionsCollection(client).Find(
+		context.Background(),
+		bson.M{"customer_id": customerID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetProjection(bson.M{"secret": 0}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions := []models.WebhookSubscription{}
+	err = cursor.All(context.Background(), &subscriptions)
+	return subscriptions, err
+}
+
+// DeleteWebhookSubscription removes a subscription. Its pending deliveries become dead letters when they are next attempted.
+func DeleteWebhookSubscription(client *mongo.Client, customerID string, subscriptionID primitive.ObjectID) error {
+	result, err := webhookSubscriptionsCollection(client).DeleteOne(context.Background(), bson.M{"_id": subscriptionID, "customer_id": customerID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the delivery log of one subscription, newest first, optionally filtered by status
+func ListWebhookDeliveries(client *mongo.Client, customerID string, subscriptionID primitive.ObjectID, status models.WebhookDeliveryStatus, limit int64) ([]models.WebhookDelivery, error) {
+	count, err := webhookSubscriptionsCollection(client).CountDocuments(context.Background(), bson.M{"_id": subscriptionID, "customer_id": customerID})
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrWebhookNotFound
+	}
+	filter := bson.M{"subscription_id": subscriptionID}
+	if status != "" {
+		filter["status"] = status
+	}
+	return findWebhookDeliveries(client, filter, limit)
+}
+
+// ListDeadWebhookDeliveries returns the customer's deliveries that ran out of retries, newest first
+func ListDeadWebhookDeliveries(client *mongo.Client, customerID string, limit int64) ([]models.WebhookDelivery, error) {
+	return findWebhookDeliveries(client, bson.M{"customer_id": customerID, "status": models.WebhookDead}, limit)
+}
+
+func findWebhookDeliveries(client *mongo.Client, filter bson.M, limit int64) ([]models.WebhookDelivery, error) {
+	cursor, err := webhookDeliveriesCollection(client).Find(
+		context.Background(),
+		filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []models.WebhookDelivery{}
+	err = cursor.All(context.Background(), &deliveries)
+	return deliveries, err
+}
+
+// RedeliverWebhook queues a finished delivery to be sent again with a fresh set of retries.
+// The original attempts stay in its log.
+func RedeliverWebhook(client *mongo.Client, customerID string, deliveryID primitive.ObjectID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := webhookDeliveriesCollection(client).FindOneAndUpdate(
+		context.Background(),
+		bson.M{"_id": deliveryID, "customer_id": customerID, "status": bson.M{"$ne": models.WebhookPending}},
+		bson.M{"$set": bson.M{
+			"status":          models.WebhookPending,
+			"retries":         0,
+			"next_attempt_at": time.Now(),
+			"date_modified":   time.Now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&delivery)
+	if err == mongo.ErrNoDocuments {
+		count, err := webhookDeliveriesCollection(client).CountDocuments(context.Background(), bson.M{"_id": deliveryID, "customer_id": customerID})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, ErrWebhookDeliveryNotFinished
+		}
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	wakeWebhookDispatcher()
+	return &delivery, nil
+}
+
+// Helper function to queue an outbox event for every subscription of its customer that wants it.
+// Called by the outbox relay, which may hand over the same event again; the unique index on event and
+// subscription keeps a retried event from being delivered twice.
+func queueWebhookDeliveries(client *mongo.Client, event models.OutboxEvent) error {
+	if event.CustomerID == "" {
+		return nil
+	}
+	cursor, err := webhookSubscriptionsCollection(client).Find(context.Background(), bson.M{
+		"customer_id": event.CustomerID,
+		"$or": bson.A{
+			bson.M{"event_types": event.Type},
+			bson.M{"event_types": bson.M{"$size": 0}},
+		},
+	}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var subscriptions []models.WebhookSubscription
+	if err := cursor.All(context.Background(), &subscriptions); err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	deliveries := make([]interface{}, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:             primitive.NewObjectID(),
+			SubscriptionID: subscription.ID,
+			CustomerID:     event.CustomerID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        event.Payload,
+			Status:         models.WebhookPending,
+			NextAttemptAt:  now,
+			Attempts:       []models.WebhookAttempt{},
+			CreatedAt:      now,
+			DateModified:   now,
+		})
+	}
+	_, err = webhookDeliveriesCollection(client).InsertMany(context.Background(), deliveries, options.InsertMany().SetOrdered(false))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	wakeWebhookDispatcher()
+	return nil
+}
+
+func wakeWebhookDispatcher() {
+	select {
+	case webhookWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// RunWebhookDispatcher posts due webhook deliveries until the process exits, polling every interval
+// and whenever new deliveries are queued. Meant to be started as a goroutine.
+func RunWebhookDispatcher(client *mongo.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		done := make(chan struct{})
+		for i := 0; i < webhookWorkers; i++ {
+			go func() {
+				defer func() { done <- struct{}{} }()
+				for {
+					delivered, err := dispatchNextWebhook(client)
+					if err != nil {
+						log.Printf("Webhook dispatcher failed: %v", err)
+						return
+					}
+					if !delivered {
+						return
+					}
+				}
+			}()
+		}
+		for i := 0; i < webhookWorkers; i++ {
+			<-done
+		}
+
+		select {
+		case <-ticker.C:
+		case <-webhookWakeup:
+		}
+	}
+}
+
+// Helper function to claim one due delivery and post it. Returns false when nothing is due.
+func dispatchNextWebhook(client *mongo.Client) (bool, error) {
+	now := time.Now()
+	var delivery models.WebhookDelivery
+	err := webhookDeliveriesCollection(client).FindOneAndUpdate(
+		context.Background(),
+		bson.M{"status": models.WebhookPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(webhookLease)}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).SetReturnDocument(options.After),
+	).Decode(&delivery)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	attempt := models.WebhookAttempt{At: now}
+	var subscription models.WebhookSubscription
+	err = webhookSubscriptionsCollection(client).FindOne(context.Background(), bson.M{"_id": delivery.SubscriptionID}).Decode(&subscription)
+	switch err {
+	case nil:
+		attempt.StatusCode, err = postWebhook(subscription, delivery)
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+	case mongo.ErrNoDocuments:
+		attempt.Error = ErrWebhookNotFound.Error()
+	default:
+		return false, err
+	}
+	attempt.DurationMs = time.Since(now).Milliseconds()
+	if len(attempt.Error) > maxWebhookError {
+		attempt.Error = attempt.Error[:maxWebhookError]
+	}
+
+	set := bson.M{"date_modified": time.Now()}
+	switch {
+	case attempt.Error == "":
+		set["status"] = models.WebhookDelivered
+	case subscription.ID.IsZero() || delivery.Retries+1 >= MaxWebhookAttempts:
+		set["status"] = models.WebhookDead
+		set["retries"] = delivery.Retries + 1
+	default:
+		set["retries"] = delivery.Retries + 1
+		set["next_attempt_at"] = time.Now().Add(webhookBackoff(delivery.Retries + 1))
+	}
+	_, err = webhookDeliveriesCollection(client).UpdateOne(context.Background(),
+		bson.M{"_id": delivery.ID},
+		bson.M{"$set": set, "$push": bson.M{"attempts": attempt}},
+	)
+	return true, err
+}
+
+// Helper function to compute the wait before the next attempt after the given number of failures
+func webhookBackoff(failures int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < failures && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMax {
+		delay = webhookRetryMax
+	}
+	return delay
+}
+
+// SignWebhook returns the signature header value for a payload sent at the given Unix timestamp
+func SignWebhook(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Helper function to POST a delivery. Any 2xx response counts as delivered.
+func postWebhook(subscription models.WebhookSubscription, delivery models.WebhookDelivery) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "walletManager-webhooks")
+	request.Header.Set(WebhookEventHeader, string(delivery.EventType))
+	request.Header.Set(WebhookDeliveryHeader, delivery.ID.Hex())
+	request.Header.Set(WebhookTimestampHeader, timestamp)
+	request.Header.Set(WebhookSignatureHeader, SignWebhook(subscription.Secret, timestamp, delivery.Payload))
+
+	response, err := webhookHTTPClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("Endpoint responded with %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
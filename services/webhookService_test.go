@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"io/ioutil"
+	"mfus_WalletTransactionManager/models"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.216.34", want: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{ip: "127.0.0.1", want: false},
+		{ip: "10.1.2.3", want: false},
+		{ip: "172.16.0.1", want: false},
+		{ip: "192.168.1.1", want: false},
+		{ip: "169.254.169.254", want: false},
+		{ip: "0.0.0.0", want: false},
+		{ip: "::1", want: false},
+		{ip: "fe80::1", want: false},
+		{ip: "fd00::1", want: false},
+		{ip: "::ffff:127.0.0.1", want: false},
+		{ip: "::ffff:93.184.216.34", want: true},
+		{ip: "100.64.0.1", want: false},
+		{ip: "100.127.255.254", want: false},
+		{ip: "0.1.2.3", want: false},
+		{ip: "192.0.0.170", want: false},
+		{ip: "198.18.0.1", want: false},
+		{ip: "203.0.113.7", want: false},
+		{ip: "224.0.0.1", want: false},
+		{ip: "255.255.255.255", want: false},
+		{ip: "::", want: false},
+		{ip: "::127.0.0.1", want: false},
+		{ip: "64:ff9b::7f00:1", want: false},
+		{ip: "64:ff9b::a00:5", want: false},
+		{ip: "2002:7f00:1::1", want: false},
+		{ip: "2001:0:4136:e378:8000:63bf:80ff:fffe", want: false},
+		{ip: "2001:db8::1", want: false},
+		{ip: "ff02::1", want: false},
+	}
+	for _, test := range tests {
+		if got := isPublicIP(net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", test.ip, got, test.want)
+		}
+	}
+}
+
+func TestCreateWebhookSubscriptionRejectsInternalHosts(t *testing.T) {
+	for _, url := range []string{
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"https://10.0.0.5/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]:9000/hook",
+	} {
+		// Rejected before anything is stored
+		_, err := CreateWebhookSubscription(nil, primitive.NewObjectID().Hex(), models.CreateWebhookRequest{URL: url})
+		if err != ErrWebhookHostNotAllowed {
+			t.Errorf("%s: error = %v, want ErrWebhookHostNotAllowed", url, err)
+		}
+	}
+}
+
+func TestWebhookDialRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	defer server.Close()
+
+	// A host that resolves to a loopback address after subscribing is refused when connecting
+	subscription := models.WebhookSubscription{URL: server.URL, Secret: "whsec_test"}
+	_, err := postWebhook(subscription, models.WebhookDelivery{ID: primitive.NewObjectID(), Payload: []byte(`{}`)})
+	if !errors.Is(err, ErrWebhookHostNotAllowed) {
+		t.Errorf("delivery to %s returned %v, want ErrWebhookHostNotAllowed", server.URL, err)
+	}
+	if called {
+		t.Error("the loopback endpoint was called")
+	}
+}
+
+func TestPostWebhookSignsPayload(t *testing.T) {
+	payload := []byte(`{"type":"transaction.posted"}`)
+	status := http.StatusOK
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	// The test server listens on loopback, which the delivery client refuses
+	defer func(original *http.Client) { webhookHTTPClient = original }(webhookHTTPClient)
+	webhookHTTPClient = server.Client()
+
+	subscription := models.WebhookSubscription{URL: server.URL, Secret: "whsec_test"}
+	delivery := models.WebhookDelivery{ID: primitive.NewObjectID(), EventType: models.EventType("transaction.posted"), Payload: payload}
+	if _, err := postWebhook(subscription, delivery); err != nil {
+		t.Fatalf("postWebhook: %v", err)
+	}
+	timestamp := received.Header.Get(WebhookTimestampHeader)
+	if received.Header.Get(WebhookSignatureHeader) != SignWebhook("whsec_test", timestamp, body) || string(body) != string(payload) {
+		t.Errorf("signature %q does not match the body %s", received.Header.Get(WebhookSignatureHeader), body)
+	}
+	if received.Header.Get(WebhookDeliveryHeader) != delivery.ID.Hex() {
+		t.Errorf("delivery header = %q, want %s", received.Header.Get(WebhookDeliveryHeader), delivery.ID.Hex())
+	}
+
+	status = http.StatusInternalServerError
+	if code, err := postWebhook(subscription, delivery); err == nil || code != http.StatusInternalServerError {
+		t.Errorf("postWebhook to a failing endpoint = %d, %v; want 500 and an error", code, err)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: 30 * time.Second},
+		{failures: 2, want: time.Minute},
+		{failures: 4, want: 4 * time.Minute},
+		{failures: 20, want: webhookRetryMax},
+	}
+	for _, test := range tests {
+		if got := webhookBackoff(test.failures); got != test.want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", test.failures, got, test.want)
+		}
+	}
+}
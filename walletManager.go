@@ -4,13 +4,20 @@ import (
 	"context"
 	"log"
 	"mfus_WalletTransactionManager/handlers"
+	"mfus_WalletTransactionManager/models"
+	"mfus_WalletTransactionManager/services"
+	"net"
 	"net/http"
 	"os"
+	"time"
 
 	handle "github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // Main function to start HTTP server
@@ -30,41 +37,225 @@ func main() {
 	defer logfile.Close()
 
 	defer client.Disconnect(context.Background())
+
+	// Make sure stored idempotency keys expire
+	if err := services.EnsureIdempotencyIndexes(client); err != nil {
+		log.Fatalf("Failed to create idempotency key indexes: %v", err)
+	}
+
+	// Index the transactions ledger by wallet/account and time
+	if err := services.EnsureTransactionIndexes(client); err != nil {
+		log.Fatalf("Failed to create transaction indexes: %v", err)
+	}
+	if err := services.EnsureHoldIndexes(client); err != nil {
+		log.Fatalf("Failed to create hold indexes: %v", err)
+	}
+	if err := services.EnsureBalanceSnapshotIndexes(client); err != nil {
+		log.Fatalf("Failed to create balance snapshot indexes: %v", err)
+	}
+	if err := services.EnsureBatchIndexes(client); err != nil {
+		log.Fatalf("Failed to create batch indexes: %v", err)
+	}
+	if err := services.EnsureWebhookIndexes(client); err != nil {
+		log.Fatalf("Failed to create webhook indexes: %v", err)
+	}
+	if err := services.EnsureOutboxIndexes(client); err != nil {
+		log.Fatalf("Failed to create outbox indexes: %v", err)
+	}
+
+	// Fees are credited to the house revenue wallet
+	if houseWallet := os.Getenv("HOUSE_REVENUE_WALLET_ID"); houseWallet != "" {
+		houseWalletID, err := primitive.ObjectIDFromHex(houseWallet)
+		if err != nil {
+			log.Fatalf("Invalid HOUSE_REVENUE_WALLET_ID: %v", err)
+		}
+		services.SetHouseRevenueWallet(houseWalletID)
+	}
+
+	// Credit wallets get this credit limit unless an operator sets one, e.g. "500.00" or "500.00 USD"
+	if creditLimit := os.Getenv("CREDIT_WALLET_DEFAULT_LIMIT"); creditLimit != "" {
+		limit, err := models.ParseMoney(creditLimit, "")
+		if err != nil {
+			log.Fatalf("Invalid CREDIT_WALLET_DEFAULT_LIMIT: %v", err)
+		}
+		services.SetDefaultCreditLimit(limit)
+	}
+
+	// Admin endpoints require the operator token; without one they refuse every request
+	handlers.SetAdminToken(os.Getenv("ADMIN_TOKEN"))
+
+	// Release expired authorization holds in the background
+	go services.RunHoldExpirer(client, time.Minute)
+
+	// Snapshot balances daily so point-in-time queries replay little history
+	go services.RunBalanceSnapshotter(client, 24*time.Hour)
+
+	// Report balances that drift from the ledger
+	go services.RunReconciler(client, 24*time.Hour)
+
+	// Publish outbox events to the configured publisher and the webhook subscriptions
+	var publisher services.EventPublisher
+	switch os.Getenv("EVENT_PUBLISHER") {
+	case "", "memory":
+		publisher = services.NewMemoryEventPublisher()
+	case "file":
+		path := os.Getenv("EVENT_LOG_FILE")
+		if path == "" {
+			path = "events.ndjson"
+		}
+		publisher, err = services.NewFileEventPublisher(path)
+		if err != nil {
+			log.Fatalf("Failed to open event log: %v", err)
+		}
+	case "nats":
+		natsURL := os.Getenv("NATS_URL")
+		if natsURL == "" {
+			natsURL = "nats://localhost:4222"
+		}
+		subjectPrefix := os.Getenv("NATS_SUBJECT_PREFIX")
+		if subjectPrefix == "" {
+			subjectPrefix = "walletManager.events"
+		}
+		publisher = services.NewNATSEventPublisher(natsURL, subjectPrefix, os.Getenv("NATS_JETSTREAM") == "true")
+	default:
+		log.Fatalf("Invalid EVENT_PUBLISHER. Must be 'memory', 'file' or 'nats'")
+	}
+	defer publisher.Close()
+	go services.RunOutboxRelay(client, publisher, time.Second)
+
+	// Send queued webhook deliveries and retry failed ones
+	go services.RunWebhookDispatcher(client, 10*time.Second)
+
+	// Finish batch imports interrupted by a restart
+	if err := services.ResumeBatches(client); err != nil {
+		log.Printf("Failed to resume batches: %v", err)
+	}
+
+	// Load the OpenAPI spec used for documentation and request validation
+	spec, err := handlers.LoadOpenAPISpec()
+	if err != nil {
+		log.Fatalf("Failed to load OpenAPI spec: %v", err)
+	}
+
 	// Set up router and routes
-	r := mux.NewRouter()
+	r := newRouter(client)
+
+	// Every API route must be described by the spec
+	if err := spec.CheckRoutes(r); err != nil {
+		log.Fatal(err)
+	}
+
+	// Set up API documentation endpoints
+	r.HandleFunc("/openapi.json", handlers.GetOpenAPISpecHandler(spec))
+	r.Handle("/docs", http.RedirectHandler("/docs/", http.StatusMovedPermanently))
+	r.PathPrefix("/docs/").Handler(handlers.SwaggerUIHandler("/docs/"))
+
+	// Wrap the router with logging and validation middleware
+	loggedRouter := handle.LoggingHandler(log.Writer(), handlers.ValidationMiddleware(spec, r))
+
+	// Start the gRPC server alongside the REST API
+	grpcAddress := os.Getenv("GRPC_ADDR")
+	if grpcAddress == "" {
+		grpcAddress = ":9090"
+	}
+	grpcListener, err := net.Listen("tcp", grpcAddress)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC: %v", err)
+	}
+	// gRPC callers must present the service token; without one every call is refused
+	handlers.SetGRPCServiceToken(os.Getenv("GRPC_SERVICE_TOKEN"))
+
+	// Serve gRPC over TLS when a certificate is configured, so the service token is not sent in the clear
+	var grpcOptions []grpc.ServerOption
+	certFile, keyFile := os.Getenv("GRPC_TLS_CERT_FILE"), os.Getenv("GRPC_TLS_KEY_FILE")
+	if certFile != "" || keyFile != "" {
+		tlsCredentials, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load gRPC TLS certificate: %v", err)
+		}
+		grpcOptions = append(grpcOptions, grpc.Creds(tlsCredentials))
+	} else {
+		log.Printf("GRPC_TLS_CERT_FILE is not set, serving gRPC without TLS")
+	}
+	grpcServer := handlers.NewGRPCServer(client, grpcOptions...)
+	go func() {
+		log.Fatal(grpcServer.Serve(grpcListener))
+	}()
 
-	// Create a new validator instance
-	//validate := validator.New()
+	// Start server
+	log.Fatal(http.ListenAndServe(":8080", loggedRouter))
+}
+
+// Helper function to register every API route on a new router
+func newRouter(client *mongo.Client) *mux.Router {
+	r := mux.NewRouter()
 
 	// Set up account endpoints
 	r.HandleFunc("/accounts", handlers.CreateAccountHandler(client)).Methods("POST")
-	r.HandleFunc("/accounts/{id}", handlers.GetAccountHandler(client)).Methods("GET")
+	r.Handle("/accounts/{id}", handlers.AccountOwnershipMiddleware(handlers.GetAccountHandler(client))).Methods("GET")
+	r.Handle("/accounts/{id}/wallets", handlers.AccountOwnershipMiddleware(handlers.GetAccountWalletsHandler(client))).Methods("GET")
+	r.Handle("/accounts/{id}/balance", handlers.AccountOwnershipMiddleware(handlers.GetAccountBalanceHandler(client))).Methods("GET")
+	r.Handle("/accounts/{id}/statement", handlers.AccountOwnershipMiddleware(handlers.GetAccountStatementHandler(client))).Methods("GET")
+	r.Handle("/accounts/{id}/limits", handlers.AccountOwnershipMiddleware(handlers.GetAccountLimitsHandler(client))).Methods("GET")
+	r.Handle("/accounts/{id}/limits", handlers.AdminMiddleware(handlers.UpdateAccountLimitsHandler(client))).Methods("PUT")
 
 	// Set up transaction on Account endpoints
-	r.HandleFunc("/accounts/{id}/transactions", handlers.CreateTransactionHandler(client)).Methods("POST")
-	r.HandleFunc("/accounts/{id}/hold", handlers.HoldBalanceHandler(client)).Methods("POST")
-	r.HandleFunc("/accounts/{id}/release", handlers.ReleaseHoldBalanceHandler(client)).Methods("POST")
+	r.Handle("/accounts/{id}/transactions", handlers.AccountOwnershipMiddleware(handlers.IdempotencyMiddleware(client, handlers.CreateAccountTransactionHandler(client)))).Methods("POST")
+	r.Handle("/accounts/{id}/transactions", handlers.AccountOwnershipMiddleware(handlers.GetAccountTransactionsHandler(client))).Methods("GET")
+	r.Handle("/accounts/{id}/hold", handlers.AccountOwnershipMiddleware(handlers.IdempotencyMiddleware(client, handlers.HoldBalanceHandler(client)))).Methods("POST")
+	r.Handle("/accounts/{id}/release", handlers.AccountOwnershipMiddleware(handlers.IdempotencyMiddleware(client, handlers.ReleaseAccountHoldHandler(client)))).Methods("POST")
+
+	// Set up authorization hold endpoints
+	r.Handle("/holds/{id}", handlers.HoldOwnershipMiddleware(client, handlers.GetHoldHandler(client))).Methods("GET")
+	r.Handle("/holds/{id}/capture", handlers.HoldOwnershipMiddleware(client, handlers.IdempotencyMiddleware(client, handlers.CaptureHoldHandler(client)))).Methods("POST")
+	r.Handle("/holds/{id}/void", handlers.HoldOwnershipMiddleware(client, handlers.IdempotencyMiddleware(client, handlers.VoidHoldHandler(client)))).Methods("POST")
 
-	// Set up Wallet endpoints
-	r.HandleFunc("/virtual_wallets", handlers.CreateVirtualWalletHandler(client)).Methods("POST")
-	r.HandleFunc("/virtual_wallets/{id}", handlers.GetVirtualWalletHandler(client)).Methods("GET")
-	r.HandleFunc("/virtual_wallets/{id}", handlers.UpdateVirtualWalletHandler(client)).Methods("PUT")
-	r.HandleFunc("/virtual_wallets/{id}", handlers.DeleteVirtualWalletHandler(client)).Methods("DELETE")
+	// Set up Wallet endpoints. Every wallet-scoped route checks that the caller's account owns the wallet.
+	r.Handle("/virtual_wallets", handlers.CallerMiddleware(handlers.CreateVirtualWalletHandler(client))).Methods("POST")
+	r.Handle("/virtual_wallets/{id}", handlers.WalletOwnershipMiddleware(client, handlers.GetVirtualWalletHandler(client))).Methods("GET")
+	r.Handle("/virtual_wallets/{id}", handlers.AdminMiddleware(handlers.UpdateVirtualWalletHandler(client))).Methods("PUT")
+	r.Handle("/virtual_wallets/{id}", handlers.WalletOwnershipMiddleware(client, handlers.DeleteVirtualWalletHandler(client))).Methods("DELETE")
+	r.Handle("/virtual_wallets/{id}/balance", handlers.WalletOwnershipMiddleware(client, handlers.GetVirtualWalletBalanceHandler(client))).Methods("GET")
+	r.Handle("/virtual_wallets/{id}/statement", handlers.WalletOwnershipMiddleware(client, handlers.GetVirtualWalletStatementHandler(client))).Methods("GET")
+	r.Handle("/virtual_wallets/{id}/events", handlers.WalletOwnershipMiddleware(client, handlers.GetVirtualWalletEventsHandler(client))).Methods("GET")
 
 	// Set up transaction on Wallet endpoints
-	r.HandleFunc("/virtual_wallets/{id}/transactions", handlers.CreateTransactionHandler(client)).Methods("POST")
-	r.HandleFunc("/virtual_wallets/{id}/transactions", handlers.GetVirtualWalletTransactionsHandler(client)).Methods("GET")
+	r.Handle("/virtual_wallets/{id}/transactions", handlers.WalletOwnershipMiddleware(client, handlers.IdempotencyMiddleware(client, handlers.CreateTransactionHandler(client)))).Methods("POST")
+	r.Handle("/virtual_wallets/{id}/transactions", handlers.WalletOwnershipMiddleware(client, handlers.GetVirtualWalletTransactionsHandler(client))).Methods("GET")
+	r.Handle("/virtual_wallets/{id}/release", handlers.WalletOwnershipMiddleware(client, handlers.IdempotencyMiddleware(client, handlers.ReleaseHoldBalanceHandler(client)))).Methods("POST")
 
-	// Customer total balance endpoints
-	r.HandleFunc("/customers/{id}/total_balance", handlers.GetCustomerTotalBalanceHandler(client)).Methods("GET")
+	// Set up wallet-to-wallet transfer endpoints
+	r.Handle("/transfers", handlers.IdempotencyMiddleware(client, handlers.TransferHandler(client))).Methods("POST")
 
-	// Wrap the router with logging and validation middleware
-	loggedRouter := handle.LoggingHandler(log.Writer(), r)
+	// Set up bulk import endpoints
+	r.Handle("/batches", handlers.AdminMiddleware(handlers.MaxBodySizeMiddleware(handlers.MaxBatchUploadSize, handlers.IdempotencyMiddleware(client, handlers.CreateBatchHandler(client))))).Methods("POST")
+	r.Handle("/batches/{id}", handlers.AdminMiddleware(handlers.GetBatchHandler(client))).Methods("GET")
+	r.Handle("/batches/{id}/errors", handlers.AdminMiddleware(handlers.GetBatchErrorsHandler(client))).Methods("GET")
 
-	//	validatedRouter := handlers.ValidationMiddleware(validate, loggedRouter)
+	// Set up reversal and refund endpoints
+	r.Handle("/transactions/{id}/reverse", handlers.AdminMiddleware(handlers.IdempotencyMiddleware(client, handlers.ReverseTransactionHandler(client)))).Methods("POST")
+	r.Handle("/transactions/{id}/refund", handlers.IdempotencyMiddleware(client, handlers.RefundTransactionHandler(client))).Methods("POST")
+	r.Handle("/transactions/{id}/camt054", handlers.TransactionOwnershipMiddleware(client, handlers.GetTransactionNotificationHandler(client))).Methods("GET")
 
-	//	handlers.ValidateRequest(handle.ValidateRequest{Validate: validate})(loggedRouter))
+	// Set up fee endpoints
+	r.HandleFunc("/fees/quote", handlers.FeeQuoteHandler(client)).Methods("GET")
+	r.Handle("/fees/schedules", handlers.AdminMiddleware(handlers.CreateFeeScheduleHandler(client))).Methods("POST")
+	r.HandleFunc("/fees/schedules", handlers.GetFeeSchedulesHandler(client)).Methods("GET")
 
-	// Start server
-	log.Fatal(http.ListenAndServe(":8080", loggedRouter))
+	// Set up admin endpoints
+	r.Handle("/admin/reconciliation", handlers.AdminMiddleware(handlers.GetReconciliationReportsHandler(client))).Methods("GET")
+	r.Handle("/admin/reconciliation", handlers.AdminMiddleware(handlers.IdempotencyMiddleware(client, handlers.RunReconciliationHandler(client)))).Methods("POST")
+
+	// Customer total balance endpoints
+	r.Handle("/customers/{id}/total_balance", handlers.AccountOwnershipMiddleware(handlers.GetCustomerTotalBalanceHandler(client))).Methods("GET")
+	r.Handle("/customers/{id}/events", handlers.AccountOwnershipMiddleware(handlers.GetCustomerEventsHandler(client))).Methods("GET")
+
+	// Set up webhook endpoints; the customer ID is the ID of the owning account
+	r.Handle("/customers/{id}/webhooks", handlers.AccountOwnershipMiddleware(handlers.CreateWebhookHandler(client))).Methods("POST")
+	r.Handle("/customers/{id}/webhooks", handlers.AccountOwnershipMiddleware(handlers.GetWebhooksHandler(client))).Methods("GET")
+	r.Handle("/customers/{id}/webhooks/dead_letters", handlers.AccountOwnershipMiddleware(handlers.GetDeadWebhookDeliveriesHandler(client))).Methods("GET")
+	r.Handle("/customers/{id}/webhooks/deliveries/{delivery_id}/redeliver", handlers.AccountOwnershipMiddleware(handlers.RedeliverWebhookHandler(client))).Methods("POST")
+	r.Handle("/customers/{id}/webhooks/{webhook_id}", handlers.AccountOwnershipMiddleware(handlers.DeleteWebhookHandler(client))).Methods("DELETE")
+	r.Handle("/customers/{id}/webhooks/{webhook_id}/deliveries", handlers.AccountOwnershipMiddleware(handlers.GetWebhookDeliveriesHandler(client))).Methods("GET")
+	return r
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"mfus_WalletTransactionManager/handlers"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRoutesAreDocumented(t *testing.T) {
+	spec, err := handlers.LoadOpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.CheckRoutes(newRouter(nil)); err != nil {
+		t.Error(err)
+	}
+}
+
+// Account routes answer 401 without X-Account-ID and 403 for another account, before touching the database
+func TestAccountRoutesRequireOwner(t *testing.T) {
+	router := newRouter(nil)
+	accountID := primitive.NewObjectID().Hex()
+	routes := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: "POST", path: "/accounts/" + accountID + "/transactions", body: `{"type":"debit","amount":"10.00"}`},
+		{method: "GET", path: "/accounts/" + accountID + "/transactions"},
+		{method: "GET", path: "/accounts/" + accountID},
+		{method: "GET", path: "/accounts/" + accountID + "/balance"},
+		{method: "GET", path: "/accounts/" + accountID + "/limits"},
+		{method: "GET", path: "/customers/" + accountID + "/total_balance"},
+		{method: "POST", path: "/virtual_wallets", body: `{"customer_id":"` + accountID + `"}`},
+	}
+	for _, route := range routes {
+		for caller, want := range map[string]int{"": http.StatusUnauthorized, primitive.NewObjectID().Hex(): http.StatusForbidden} {
+			request := httptest.NewRequest(route.method, route.path, strings.NewReader(route.body))
+			if caller != "" {
+				request.Header.Set(handlers.AccountIDHeader, caller)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != want {
+				t.Errorf("%s %s by %q: status = %d, want %d", route.method, route.path, caller, recorder.Code, want)
+			}
+		}
+	}
+}
+
+// Setting a wallet balance is reserved to admins, even for the wallet's owner
+func TestWalletBalanceEditRequiresAdmin(t *testing.T) {
+	handlers.SetAdminToken("s3cret")
+	defer handlers.SetAdminToken("")
+	router := newRouter(nil)
+	path := "/virtual_wallets/" + primitive.NewObjectID().Hex()
+	for token, want := range map[string]int{"": http.StatusUnauthorized, "guess": http.StatusForbidden} {
+		request := httptest.NewRequest("PUT", path, strings.NewReader(`{"customer_id":"owner","balance":"1000.00"}`))
+		request.Header.Set(handlers.AccountIDHeader, primitive.NewObjectID().Hex())
+		if token != "" {
+			request.Header.Set(handlers.AdminTokenHeader, token)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != want {
+			t.Errorf("PUT %s with token %q: status = %d, want %d", path, token, recorder.Code, want)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+// Package walletpb holds the protobuf messages and the gRPC service of the wallet manager.
+// The Go files are generated from walletManager.proto with protoc-gen-go v1.34.2 and
+// protoc-gen-go-grpc v1.3.0; regenerate them with go generate after editing the proto file.
+package walletpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative walletManager.proto
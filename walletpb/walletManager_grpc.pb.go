@@ -0,0 +1,879 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: walletManager.proto
+
+package walletpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	WalletManager_CreateAccount_FullMethodName            = "/walletmanager.v1.WalletManager/CreateAccount"
+	WalletManager_GetAccount_FullMethodName               = "/walletmanager.v1.WalletManager/GetAccount"
+	WalletManager_ListAccountWallets_FullMethodName       = "/walletmanager.v1.WalletManager/ListAccountWallets"
+	WalletManager_GetAccountBalance_FullMethodName        = "/walletmanager.v1.WalletManager/GetAccountBalance"
+	WalletManager_CreateAccountTransaction_FullMethodName = "/walletmanager.v1.WalletManager/CreateAccountTransaction"
+	WalletManager_ListAccountTransactions_FullMethodName  = "/walletmanager.v1.WalletManager/ListAccountTransactions"
+	WalletManager_CreateWallet_FullMethodName             = "/walletmanager.v1.WalletManager/CreateWallet"
+	WalletManager_GetWallet_FullMethodName                = "/walletmanager.v1.WalletManager/GetWallet"
+	WalletManager_SetWalletBalance_FullMethodName         = "/walletmanager.v1.WalletManager/SetWalletBalance"
+	WalletManager_DeleteWallet_FullMethodName             = "/walletmanager.v1.WalletManager/DeleteWallet"
+	WalletManager_GetWalletBalance_FullMethodName         = "/walletmanager.v1.WalletManager/GetWalletBalance"
+	WalletManager_CreateWalletTransaction_FullMethodName  = "/walletmanager.v1.WalletManager/CreateWalletTransaction"
+	WalletManager_ReleaseWalletHold_FullMethodName        = "/walletmanager.v1.WalletManager/ReleaseWalletHold"
+	WalletManager_ListWalletTransactions_FullMethodName   = "/walletmanager.v1.WalletManager/ListWalletTransactions"
+	WalletManager_CreateHold_FullMethodName               = "/walletmanager.v1.WalletManager/CreateHold"
+	WalletManager_GetHold_FullMethodName                  = "/walletmanager.v1.WalletManager/GetHold"
+	WalletManager_CaptureHold_FullMethodName              = "/walletmanager.v1.WalletManager/CaptureHold"
+	WalletManager_VoidHold_FullMethodName                 = "/walletmanager.v1.WalletManager/VoidHold"
+	WalletManager_ReleaseAccountHold_FullMethodName       = "/walletmanager.v1.WalletManager/ReleaseAccountHold"
+	WalletManager_GetCustomerBalance_FullMethodName       = "/walletmanager.v1.WalletManager/GetCustomerBalance"
+)
+
+// WalletManagerClient is the client API for WalletManager service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WalletManagerClient interface {
+	// Accounts
+	CreateAccount(ctx context.Context, in *CreateAccountRequest, opts ...grpc.CallOption) (*Account, error)
+	GetAccount(ctx context.Context, in *GetAccountRequest, opts ...grpc.CallOption) (*Account, error)
+	ListAccountWallets(ctx context.Context, in *ListAccountWalletsRequest, opts ...grpc.CallOption) (*ListAccountWalletsResponse, error)
+	GetAccountBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error)
+	CreateAccountTransaction(ctx context.Context, in *CreateTransactionRequest, opts ...grpc.CallOption) (*Transaction, error)
+	// Streams the account's ledger entries matching the request, one page after another
+	ListAccountTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (WalletManager_ListAccountTransactionsClient, error)
+	// Virtual wallets
+	CreateWallet(ctx context.Context, in *CreateWalletRequest, opts ...grpc.CallOption) (*Wallet, error)
+	GetWallet(ctx context.Context, in *GetWalletRequest, opts ...grpc.CallOption) (*Wallet, error)
+	SetWalletBalance(ctx context.Context, in *SetWalletBalanceRequest, opts ...grpc.CallOption) (*Wallet, error)
+	DeleteWallet(ctx context.Context, in *DeleteWalletRequest, opts ...grpc.CallOption) (*DeleteWalletResponse, error)
+	GetWalletBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error)
+	CreateWalletTransaction(ctx context.Context, in *CreateTransactionRequest, opts ...grpc.CallOption) (*Wallet, error)
+	ReleaseWalletHold(ctx context.Context, in *ReleaseWalletHoldRequest, opts ...grpc.CallOption) (*Wallet, error)
+	// Streams the wallet's ledger entries matching the request, one page after another
+	ListWalletTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (WalletManager_ListWalletTransactionsClient, error)
+	// Authorization holds on account funds
+	CreateHold(ctx context.Context, in *CreateHoldRequest, opts ...grpc.CallOption) (*Hold, error)
+	GetHold(ctx context.Context, in *GetHoldRequest, opts ...grpc.CallOption) (*Hold, error)
+	CaptureHold(ctx context.Context, in *CaptureHoldRequest, opts ...grpc.CallOption) (*Hold, error)
+	VoidHold(ctx context.Context, in *VoidHoldRequest, opts ...grpc.CallOption) (*Hold, error)
+	ReleaseAccountHold(ctx context.Context, in *ReleaseAccountHoldRequest, opts ...grpc.CallOption) (*Hold, error)
+	// Customer balances
+	GetCustomerBalance(ctx context.Context, in *GetCustomerBalanceRequest, opts ...grpc.CallOption) (*CustomerBalance, error)
+}
+
+type walletManagerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWalletManagerClient(cc grpc.ClientConnInterface) WalletManagerClient {
+	return &walletManagerClient{cc}
+}
+
+func (c *walletManagerClient) CreateAccount(ctx context.Context, in *CreateAccountRequest, opts ...grpc.CallOption) (*Account, error) {
+	out := new(Account)
+	err := c.cc.Invoke(ctx, WalletManager_CreateAccount_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) GetAccount(ctx context.Context, in *GetAccountRequest, opts ...grpc.CallOption) (*Account, error) {
+	out := new(Account)
+	err := c.cc.Invoke(ctx, WalletManager_GetAccount_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) ListAccountWallets(ctx context.Context, in *ListAccountWalletsRequest, opts ...grpc.CallOption) (*ListAccountWalletsResponse, error) {
+	out := new(ListAccountWalletsResponse)
+	err := c.cc.Invoke(ctx, WalletManager_ListAccountWallets_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) GetAccountBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error) {
+	out := new(Balance)
+	err := c.cc.Invoke(ctx, WalletManager_GetAccountBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) CreateAccountTransaction(ctx context.Context, in *CreateTransactionRequest, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, WalletManager_CreateAccountTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) ListAccountTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (WalletManager_ListAccountTransactionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WalletManager_ServiceDesc.Streams[0], WalletManager_ListAccountTransactions_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &walletManagerListAccountTransactionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WalletManager_ListAccountTransactionsClient interface {
+	Recv() (*Transaction, error)
+	grpc.ClientStream
+}
+
+type walletManagerListAccountTransactionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *walletManagerListAccountTransactionsClient) Recv() (*Transaction, error) {
+	m := new(Transaction)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *walletManagerClient) CreateWallet(ctx context.Context, in *CreateWalletRequest, opts ...grpc.CallOption) (*Wallet, error) {
+	out := new(Wallet)
+	err := c.cc.Invoke(ctx, WalletManager_CreateWallet_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) GetWallet(ctx context.Context, in *GetWalletRequest, opts ...grpc.CallOption) (*Wallet, error) {
+	out := new(Wallet)
+	err := c.cc.Invoke(ctx, WalletManager_GetWallet_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) SetWalletBalance(ctx context.Context, in *SetWalletBalanceRequest, opts ...grpc.CallOption) (*Wallet, error) {
+	out := new(Wallet)
+	err := c.cc.Invoke(ctx, WalletManager_SetWalletBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) DeleteWallet(ctx context.Context, in *DeleteWalletRequest, opts ...grpc.CallOption) (*DeleteWalletResponse, error) {
+	out := new(DeleteWalletResponse)
+	err := c.cc.Invoke(ctx, WalletManager_DeleteWallet_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) GetWalletBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error) {
+	out := new(Balance)
+	err := c.cc.Invoke(ctx, WalletManager_GetWalletBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) CreateWalletTransaction(ctx context.Context, in *CreateTransactionRequest, opts ...grpc.CallOption) (*Wallet, error) {
+	out := new(Wallet)
+	err := c.cc.Invoke(ctx, WalletManager_CreateWalletTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) ReleaseWalletHold(ctx context.Context, in *ReleaseWalletHoldRequest, opts ...grpc.CallOption) (*Wallet, error) {
+	out := new(Wallet)
+	err := c.cc.Invoke(ctx, WalletManager_ReleaseWalletHold_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) ListWalletTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (WalletManager_ListWalletTransactionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WalletManager_ServiceDesc.Streams[1], WalletManager_ListWalletTransactions_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &walletManagerListWalletTransactionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WalletManager_ListWalletTransactionsClient interface {
+	Recv() (*Transaction, error)
+	grpc.ClientStream
+}
+
+type walletManagerListWalletTransactionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *walletManagerListWalletTransactionsClient) Recv() (*Transaction, error) {
+	m := new(Transaction)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *walletManagerClient) CreateHold(ctx context.Context, in *CreateHoldRequest, opts ...grpc.CallOption) (*Hold, error) {
+	out := new(Hold)
+	err := c.cc.Invoke(ctx, WalletManager_CreateHold_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) GetHold(ctx context.Context, in *GetHoldRequest, opts ...grpc.CallOption) (*Hold, error) {
+	out := new(Hold)
+	err := c.cc.Invoke(ctx, WalletManager_GetHold_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) CaptureHold(ctx context.Context, in *CaptureHoldRequest, opts ...grpc.CallOption) (*Hold, error) {
+	out := new(Hold)
+	err := c.cc.Invoke(ctx, WalletManager_CaptureHold_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) VoidHold(ctx context.Context, in *VoidHoldRequest, opts ...grpc.CallOption) (*Hold, error) {
+	out := new(Hold)
+	err := c.cc.Invoke(ctx, WalletManager_VoidHold_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) ReleaseAccountHold(ctx context.Context, in *ReleaseAccountHoldRequest, opts ...grpc.CallOption) (*Hold, error) {
+	out := new(Hold)
+	err := c.cc.Invoke(ctx, WalletManager_ReleaseAccountHold_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletManagerClient) GetCustomerBalance(ctx context.Context, in *GetCustomerBalanceRequest, opts ...grpc.CallOption) (*CustomerBalance, error) {
+	out := new(CustomerBalance)
+	err := c.cc.Invoke(ctx, WalletManager_GetCustomerBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WalletManagerServer is the server API for WalletManager service.
+// All implementations must embed UnimplementedWalletManagerServer
+// for forward compatibility
+type WalletManagerServer interface {
+	// Accounts
+	CreateAccount(context.Context, *CreateAccountRequest) (*Account, error)
+	GetAccount(context.Context, *GetAccountRequest) (*Account, error)
+	ListAccountWallets(context.Context, *ListAccountWalletsRequest) (*ListAccountWalletsResponse, error)
+	GetAccountBalance(context.Context, *GetBalanceRequest) (*Balance, error)
+	CreateAccountTransaction(context.Context, *CreateTransactionRequest) (*Transaction, error)
+	// Streams the account's ledger entries matching the request, one page after another
+	ListAccountTransactions(*ListTransactionsRequest, WalletManager_ListAccountTransactionsServer) error
+	// Virtual wallets
+	CreateWallet(context.Context, *CreateWalletRequest) (*Wallet, error)
+	GetWallet(context.Context, *GetWalletRequest) (*Wallet, error)
+	SetWalletBalance(context.Context, *SetWalletBalanceRequest) (*Wallet, error)
+	DeleteWallet(context.Context, *DeleteWalletRequest) (*DeleteWalletResponse, error)
+	GetWalletBalance(context.Context, *GetBalanceRequest) (*Balance, error)
+	CreateWalletTransaction(context.Context, *CreateTransactionRequest) (*Wallet, error)
+	ReleaseWalletHold(context.Context, *ReleaseWalletHoldRequest) (*Wallet, error)
+	// Streams the wallet's ledger entries matching the request, one page after another
+	ListWalletTransactions(*ListTransactionsRequest, WalletManager_ListWalletTransactionsServer) error
+	// Authorization holds on account funds
+	CreateHold(context.Context, *CreateHoldRequest) (*Hold, error)
+	GetHold(context.Context, *GetHoldRequest) (*Hold, error)
+	CaptureHold(context.Context, *CaptureHoldRequest) (*Hold, error)
+	VoidHold(context.Context, *VoidHoldRequest) (*Hold, error)
+	ReleaseAccountHold(context.Context, *ReleaseAccountHoldRequest) (*Hold, error)
+	// Customer balances
+	GetCustomerBalance(context.Context, *GetCustomerBalanceRequest) (*CustomerBalance, error)
+	mustEmbedUnimplementedWalletManagerServer()
+}
+
+// UnimplementedWalletManagerServer must be embedded to have forward compatible implementations.
+type UnimplementedWalletManagerServer struct {
+}
+
+func (UnimplementedWalletManagerServer) CreateAccount(context.Context, *CreateAccountRequest) (*Account, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAccount not implemented")
+}
+func (UnimplementedWalletManagerServer) GetAccount(context.Context, *GetAccountRequest) (*Account, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAccount not implemented")
+}
+func (UnimplementedWalletManagerServer) ListAccountWallets(context.Context, *ListAccountWalletsRequest) (*ListAccountWalletsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAccountWallets not implemented")
+}
+func (UnimplementedWalletManagerServer) GetAccountBalance(context.Context, *GetBalanceRequest) (*Balance, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAccountBalance not implemented")
+}
+func (UnimplementedWalletManagerServer) CreateAccountTransaction(context.Context, *CreateTransactionRequest) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAccountTransaction not implemented")
+}
+func (UnimplementedWalletManagerServer) ListAccountTransactions(*ListTransactionsRequest, WalletManager_ListAccountTransactionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListAccountTransactions not implemented")
+}
+func (UnimplementedWalletManagerServer) CreateWallet(context.Context, *CreateWalletRequest) (*Wallet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWallet not implemented")
+}
+func (UnimplementedWalletManagerServer) GetWallet(context.Context, *GetWalletRequest) (*Wallet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWallet not implemented")
+}
+func (UnimplementedWalletManagerServer) SetWalletBalance(context.Context, *SetWalletBalanceRequest) (*Wallet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetWalletBalance not implemented")
+}
+func (UnimplementedWalletManagerServer) DeleteWallet(context.Context, *DeleteWalletRequest) (*DeleteWalletResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWallet not implemented")
+}
+func (UnimplementedWalletManagerServer) GetWalletBalance(context.Context, *GetBalanceRequest) (*Balance, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWalletBalance not implemented")
+}
+func (UnimplementedWalletManagerServer) CreateWalletTransaction(context.Context, *CreateTransactionRequest) (*Wallet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWalletTransaction not implemented")
+}
+func (UnimplementedWalletManagerServer) ReleaseWalletHold(context.Context, *ReleaseWalletHoldRequest) (*Wallet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseWalletHold not implemented")
+}
+func (UnimplementedWalletManagerServer) ListWalletTransactions(*ListTransactionsRequest, WalletManager_ListWalletTransactionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListWalletTransactions not implemented")
+}
+func (UnimplementedWalletManagerServer) CreateHold(context.Context, *CreateHoldRequest) (*Hold, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateHold not implemented")
+}
+func (UnimplementedWalletManagerServer) GetHold(context.Context, *GetHoldRequest) (*Hold, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHold not implemented")
+}
+func (UnimplementedWalletManagerServer) CaptureHold(context.Context, *CaptureHoldRequest) (*Hold, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CaptureHold not implemented")
+}
+func (UnimplementedWalletManagerServer) VoidHold(context.Context, *VoidHoldRequest) (*Hold, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VoidHold not implemented")
+}
+func (UnimplementedWalletManagerServer) ReleaseAccountHold(context.Context, *ReleaseAccountHoldRequest) (*Hold, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseAccountHold not implemented")
+}
+func (UnimplementedWalletManagerServer) GetCustomerBalance(context.Context, *GetCustomerBalanceRequest) (*CustomerBalance, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCustomerBalance not implemented")
+}
+func (UnimplementedWalletManagerServer) mustEmbedUnimplementedWalletManagerServer() {}
+
+// UnsafeWalletManagerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WalletManagerServer will
+// result in compilation errors.
+type UnsafeWalletManagerServer interface {
+	mustEmbedUnimplementedWalletManagerServer()
+}
+
+func RegisterWalletManagerServer(s grpc.ServiceRegistrar, srv WalletManagerServer) {
+	s.RegisterService(&WalletManager_ServiceDesc, srv)
+}
+
+func _WalletManager_CreateAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).CreateAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_CreateAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).CreateAccount(ctx, req.(*CreateAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_GetAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).GetAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_GetAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).GetAccount(ctx, req.(*GetAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_ListAccountWallets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAccountWalletsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).ListAccountWallets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_ListAccountWallets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).ListAccountWallets(ctx, req.(*ListAccountWalletsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_GetAccountBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).GetAccountBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_GetAccountBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).GetAccountBalance(ctx, req.(*GetBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_CreateAccountTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).CreateAccountTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_CreateAccountTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).CreateAccountTransaction(ctx, req.(*CreateTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_ListAccountTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListTransactionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WalletManagerServer).ListAccountTransactions(m, &walletManagerListAccountTransactionsServer{stream})
+}
+
+type WalletManager_ListAccountTransactionsServer interface {
+	Send(*Transaction) error
+	grpc.ServerStream
+}
+
+type walletManagerListAccountTransactionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *walletManagerListAccountTransactionsServer) Send(m *Transaction) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _WalletManager_CreateWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).CreateWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_CreateWallet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).CreateWallet(ctx, req.(*CreateWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_GetWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).GetWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_GetWallet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).GetWallet(ctx, req.(*GetWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_SetWalletBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetWalletBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).SetWalletBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_SetWalletBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).SetWalletBalance(ctx, req.(*SetWalletBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_DeleteWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).DeleteWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_DeleteWallet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).DeleteWallet(ctx, req.(*DeleteWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_GetWalletBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).GetWalletBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_GetWalletBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).GetWalletBalance(ctx, req.(*GetBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_CreateWalletTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).CreateWalletTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_CreateWalletTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).CreateWalletTransaction(ctx, req.(*CreateTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_ReleaseWalletHold_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseWalletHoldRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).ReleaseWalletHold(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_ReleaseWalletHold_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).ReleaseWalletHold(ctx, req.(*ReleaseWalletHoldRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_ListWalletTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListTransactionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WalletManagerServer).ListWalletTransactions(m, &walletManagerListWalletTransactionsServer{stream})
+}
+
+type WalletManager_ListWalletTransactionsServer interface {
+	Send(*Transaction) error
+	grpc.ServerStream
+}
+
+type walletManagerListWalletTransactionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *walletManagerListWalletTransactionsServer) Send(m *Transaction) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _WalletManager_CreateHold_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateHoldRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).CreateHold(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_CreateHold_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).CreateHold(ctx, req.(*CreateHoldRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_GetHold_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHoldRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).GetHold(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_GetHold_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).GetHold(ctx, req.(*GetHoldRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_CaptureHold_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CaptureHoldRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).CaptureHold(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_CaptureHold_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).CaptureHold(ctx, req.(*CaptureHoldRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_VoidHold_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VoidHoldRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).VoidHold(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_VoidHold_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).VoidHold(ctx, req.(*VoidHoldRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_ReleaseAccountHold_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseAccountHoldRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).ReleaseAccountHold(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_ReleaseAccountHold_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).ReleaseAccountHold(ctx, req.(*ReleaseAccountHoldRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletManager_GetCustomerBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCustomerBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletManagerServer).GetCustomerBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WalletManager_GetCustomerBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletManagerServer).GetCustomerBalance(ctx, req.(*GetCustomerBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// WalletManager_ServiceDesc is the grpc.ServiceDesc for WalletManager service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WalletManager_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "walletmanager.v1.WalletManager",
+	HandlerType: (*WalletManagerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateAccount",
+			Handler:    _WalletManager_CreateAccount_Handler,
+		},
+		{
+			MethodName: "GetAccount",
+			Handler:    _WalletManager_GetAccount_Handler,
+		},
+		{
+			MethodName: "ListAccountWallets",
+			Handler:    _WalletManager_ListAccountWallets_Handler,
+		},
+		{
+			MethodName: "GetAccountBalance",
+			Handler:    _WalletManager_GetAccountBalance_Handler,
+		},
+		{
+			MethodName: "CreateAccountTransaction",
+			Handler:    _WalletManager_CreateAccountTransaction_Handler,
+		},
+		{
+			MethodName: "CreateWallet",
+			Handler:    _WalletManager_CreateWallet_Handler,
+		},
+		{
+			MethodName: "GetWallet",
+			Handler:    _WalletManager_GetWallet_Handler,
+		},
+		{
+			MethodName: "SetWalletBalance",
+			Handler:    _WalletManager_SetWalletBalance_Handler,
+		},
+		{
+			MethodName: "DeleteWallet",
+			Handler:    _WalletManager_DeleteWallet_Handler,
+		},
+		{
+			MethodName: "GetWalletBalance",
+			Handler:    _WalletManager_GetWalletBalance_Handler,
+		},
+		{
+			MethodName: "CreateWalletTransaction",
+			Handler:    _WalletManager_CreateWalletTransaction_Handler,
+		},
+		{
+			MethodName: "ReleaseWalletHold",
+			Handler:    _WalletManager_ReleaseWalletHold_Handler,
+		},
+		{
+			MethodName: "CreateHold",
+			Handler:    _WalletManager_CreateHold_Handler,
+		},
+		{
+			MethodName: "GetHold",
+			Handler:    _WalletManager_GetHold_Handler,
+		},
+		{
+			MethodName: "CaptureHold",
+			Handler:    _WalletManager_CaptureHold_Handler,
+		},
+		{
+			MethodName: "VoidHold",
+			Handler:    _WalletManager_VoidHold_Handler,
+		},
+		{
+			MethodName: "ReleaseAccountHold",
+			Handler:    _WalletManager_ReleaseAccountHold_Handler,
+		},
+		{
+			MethodName: "GetCustomerBalance",
+			Handler:    _WalletManager_GetCustomerBalance_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListAccountTransactions",
+			Handler:       _WalletManager_ListAccountTransactions_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListWalletTransactions",
+			Handler:       _WalletManager_ListWalletTransactions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "walletManager.proto",
+}